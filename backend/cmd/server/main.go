@@ -2,16 +2,30 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"time"
 
 	"spv-backend/config"
 	"spv-backend/internal/api"
+	"spv-backend/internal/auth"
+	"spv-backend/internal/cache"
 	"spv-backend/internal/contract"
+	"spv-backend/internal/events"
+	"spv-backend/internal/fee"
 	"spv-backend/internal/filter"
+	"spv-backend/internal/ot"
 	"spv-backend/internal/rpc"
+	"spv-backend/internal/txstatus"
+	"spv-backend/internal/watch"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
@@ -27,22 +41,30 @@ func main() {
 	log.Printf("Server: %s:%s", cfg.ServerHost, cfg.ServerPort)
 
 	// Get chain parameters based on network
-	var chainParams *chaincfg.Params
-	switch cfg.Network {
-	case "mainnet":
-		chainParams = &chaincfg.MainNetParams
-	case "testnet", "testnet3":
-		chainParams = &chaincfg.TestNet3Params
-	case "regtest":
-		chainParams = &chaincfg.RegressionNetParams
-	case "signet":
-		chainParams = &chaincfg.SigNetParams
-	default:
-		log.Fatalf("Unknown network: %s", cfg.Network)
+	chainParams, err := chainParamsForNetwork(cfg.Network)
+	if err != nil {
+		log.Fatalf("Invalid NETWORK: %v", err)
 	}
 
 	// Initialize RPC client
-	rpcClient := rpc.NewClient(cfg.RPCHost, cfg.RPCPort, cfg.RPCUser, cfg.RPCPassword)
+	rpcClient := rpc.NewClient(cfg.RPCHost, cfg.RPCPort, cfg.RPCUser, cfg.RPCPassword,
+		rpc.WithMethodAllowlist(cfg.RPCMethodAllowlist),
+		rpc.WithMethodDenylist(cfg.RPCMethodDenylist),
+		rpc.WithIdleConnections(cfg.RPCWarmConnections),
+		rpc.WithCallCoalescing(time.Duration(cfg.RPCCoalesceWindowMs)*time.Millisecond),
+		rpc.WithMaxConcurrent(cfg.RPCMaxConcurrent, time.Duration(cfg.RPCQueueTimeoutMs)*time.Millisecond))
+	if len(cfg.RPCMethodAllowlist) > 0 {
+		log.Printf("RPC method allowlist: %v", cfg.RPCMethodAllowlist)
+	}
+	if len(cfg.RPCMethodDenylist) > 0 {
+		log.Printf("RPC method denylist: %v", cfg.RPCMethodDenylist)
+	}
+	if cfg.RPCCoalesceWindowMs > 0 {
+		log.Printf("RPC call coalescing: %dms window", cfg.RPCCoalesceWindowMs)
+	}
+	if cfg.RPCMaxConcurrent > 0 {
+		log.Printf("RPC concurrency limit: %d requests", cfg.RPCMaxConcurrent)
+	}
 
 	// Test RPC connection
 	blockCount, err := rpcClient.GetBlockCount()
@@ -51,9 +73,45 @@ func main() {
 	}
 	log.Printf("Connected to Bitcoin Core - Block height: %d", blockCount)
 
+	// Open the connection pool before the first requests arrive
+	if cfg.RPCWarmConnections > 0 {
+		opened, err := rpcClient.Warm(cfg.RPCWarmConnections)
+		if err != nil {
+			log.Printf("Warning: RPC connection warm-up failed: %v", err)
+		}
+		log.Printf("Pre-warmed %d of %d RPC connections", opened, cfg.RPCWarmConnections)
+	}
+
+	// Make sure the node serves the configured network
+	chainParams, err = checkNodeNetwork(rpcClient, chainParams, cfg.NetworkMismatch)
+	if err != nil {
+		log.Fatalf("Network check failed: %v", err)
+	}
+	cfg.Network = chainParams.Name
+
 	// Initialize services
 	filterService := filter.NewService(rpcClient, chainParams)
+	filterService.SetWorkers(cfg.FilterWorkers, cfg.BlockWorkers)
+	filterService.SetVerificationMode(cfg.VerificationMode)
+	filterService.SetBlockFetch(cfg.BlockFetch)
+	filterService.SetMaxDerivedAddresses(cfg.MaxDerivedAddresses)
+	filterService.SetSupplyCheck(cfg.SupplySanityCheck)
+	filterService.SetTxOutSetThreshold(cfg.ScanTxOutSetThreshold)
+	if cfg.SkipUTXOVerification {
+		log.Printf("WARNING: SKIP_UTXO_VERIFICATION is set, scans do not check UTXOs with gettxout.")
+		log.Printf("WARNING: Outputs spent in the mempool or after a scan's end height are reported as unspent; only use this for deeply confirmed historical ranges.")
+		filterService.SetSkipVerification(true)
+	}
+	filterService.SetRetry(cfg.RPCRetries, time.Duration(cfg.RPCRetryBackoffMs)*time.Millisecond)
 	contractService := contract.NewService(rpcClient, cfg.ContractAddress)
+	contractService.SetNamedContracts(cfg.NamedContracts)
+	feeService := fee.NewService(rpcClient, cfg.FallbackFeeRate)
+	otService := ot.NewService(rpcClient)
+
+	// Probe the node so amounts are parsed according to its reporting format
+	if _, err := filterService.DetectAmountFormat(); err != nil {
+		log.Printf("Warning: failed to detect amount format, using decimal BTC parsing: %v", err)
+	}
 
 	// Log SPV mode configuration
 	spvModeStr := "disabled (direct scan)"
@@ -61,17 +119,179 @@ func main() {
 		spvModeStr = "enabled (BIP158 filters)"
 	}
 	log.Printf("SPV Mode: %s", spvModeStr)
+	log.Printf("Scan workers: filter=%d, block=%d", cfg.FilterWorkers, cfg.BlockWorkers)
+
+	// Persist filters across restarts if a cache directory is configured
+	if cfg.CacheDir != "" {
+		store, err := cache.NewStore(cfg.CacheDir, cfg.CacheCompression)
+		if err != nil {
+			log.Fatalf("Failed to open cache: %v", err)
+		}
+		filterService.SetCache(store)
+		log.Printf("Filter cache: %s (compression: %s)", cfg.CacheDir, cfg.CacheCompression)
+	}
+
+	// Keep recent activity for the event feed
+	var eventLog *events.Log
+	if cfg.EventLogSize > 0 {
+		eventLog = events.NewLog(cfg.EventLogSize)
+	}
+
+	// Follow the tip for watched addresses
+	var watchManager *watch.Manager
+	if cfg.WatchPollInterval > 0 {
+		watchManager = watch.NewManager(rpcClient, filterService, cfg.SPVMode, cfg.MaxWatches)
+		watchManager.SetEventLog(eventLog)
+		if cfg.WebhookWorkers > 0 {
+			notifier := watch.NewNotifier(cfg.WebhookWorkers, cfg.WebhookQueueSize, cfg.WebhookMaxRetries,
+				time.Duration(cfg.WebhookRetryBackoffMs)*time.Millisecond, cfg.MaxWebhookDeadLetters)
+			notifier.SetAllowPrivate(cfg.WebhookAllowPrivate)
+			notifier.Start(context.Background())
+			watchManager.SetNotifier(notifier)
+			log.Printf("Webhooks: %d workers, %d retries", cfg.WebhookWorkers, cfg.WebhookMaxRetries)
+		}
+		if err := watchManager.Start(context.Background(), time.Duration(cfg.WatchPollInterval)*time.Second); err != nil {
+			log.Fatalf("Failed to start address watcher: %v", err)
+		}
+		log.Printf("Address watching: every %ds, max %d watches", cfg.WatchPollInterval, cfg.MaxWatches)
+	}
+
+	// Follow broadcast transactions until they confirm or are dropped
+	var txTracker *txstatus.Tracker
+	if cfg.TxStatusPollInterval > 0 {
+		txTracker = txstatus.NewTracker(rpcClient, cfg.MaxTrackedTxs)
+		if err := txTracker.Start(context.Background(), time.Duration(cfg.TxStatusPollInterval)*time.Second); err != nil {
+			log.Fatalf("Failed to start transaction tracker: %v", err)
+		}
+		log.Printf("Transaction tracking: every %ds, max %d transactions", cfg.TxStatusPollInterval, cfg.MaxTrackedTxs)
+	}
 
 	// Initialize API handler with configuration (without merkle service)
-	handler := api.NewHandler(rpcClient, filterService, contractService, cfg)
+	handler := api.NewHandler(rpcClient, filterService, contractService, feeService, otService, watchManager, txTracker, eventLog, cfg)
 
 	// Setup router
-	router := api.SetupRouter(handler)
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	log.Printf("Authentication: %s", cfg.AuthMode)
+
+	router := api.SetupRouter(handler, authenticator)
+	if len(cfg.TrustedProxies) > 0 {
+		log.Printf("Trusted proxies: %v", cfg.TrustedProxies)
+	}
 
 	// Start server
 	addr := fmt.Sprintf("%s:%s", cfg.ServerHost, cfg.ServerPort)
 	log.Printf("Server listening on %s", addr)
-	if err := router.Run(addr); err != nil {
+	server := newServer(addr, router, cfg.EnableHTTP2)
+	if cfg.EnableHTTP2 {
+		log.Printf("HTTP/2 (h2c): enabled")
+	}
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// newServer returns the HTTP server for handler. The server does not
+// terminate TLS, so with enableHTTP2 HTTP/2 is offered as h2c; HTTP/1.1
+// requests are still served as before.
+func newServer(addr string, handler http.Handler, enableHTTP2 bool) *http.Server {
+	if enableHTTP2 {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+	return &http.Server{Addr: addr, Handler: handler}
+}
+
+// chainParamsForNetwork returns the chain parameters of a NETWORK name
+func chainParamsForNetwork(network string) (*chaincfg.Params, error) {
+	switch network {
+	case "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet", "testnet3":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	case "signet":
+		return &chaincfg.SigNetParams, nil
+	default:
+		return nil, fmt.Errorf("unknown network: %s", network)
+	}
+}
+
+// nodeChainNetworks maps the chain reported by getblockchaininfo to a NETWORK name
+var nodeChainNetworks = map[string]string{
+	"main":    "mainnet",
+	"test":    "testnet3",
+	"regtest": "regtest",
+	"signet":  "signet",
+}
+
+// checkNodeNetwork compares the node's chain with the configured chain
+// parameters and applies NETWORK_MISMATCH when they differ: "fail" refuses to
+// start, "warn" logs and keeps the configured network, and "trust_node"
+// switches to the node's network. It returns the chain parameters to use.
+func checkNodeNetwork(rpcClient *rpc.Client, configured *chaincfg.Params, mode string) (*chaincfg.Params, error) {
+	infoData, err := rpcClient.GetBlockchainInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockchain info: %w", err)
+	}
+
+	var info struct {
+		Chain string `json:"chain"`
+	}
+	if err := json.Unmarshal(infoData, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse blockchain info: %w", err)
+	}
+
+	nodeNetwork, known := nodeChainNetworks[info.Chain]
+	var nodeParams *chaincfg.Params
+	if known {
+		nodeParams, _ = chainParamsForNetwork(nodeNetwork)
+	}
+	if nodeParams != nil && nodeParams.Net == configured.Net {
+		return configured, nil
+	}
+
+	message := fmt.Sprintf("configured network %s, node reports %s", configured.Name, info.Chain)
+	switch mode {
+	case "warn":
+		log.Printf("Warning: network mismatch: %s", message)
+		return configured, nil
+	case "trust_node":
+		if nodeParams == nil {
+			return nil, fmt.Errorf("%s, which is not supported", message)
+		}
+		log.Printf("Network mismatch: %s, using the node's network", message)
+		return nodeParams, nil
+	default:
+		return nil, fmt.Errorf("%s (set NETWORK_MISMATCH=warn or trust_node to start anyway)", message)
+	}
+}
+
+// newAuthenticator builds the authenticator selected by AUTH_MODE
+// Returns nil when authentication is disabled
+func newAuthenticator(cfg *config.Config) (auth.Authenticator, error) {
+	switch cfg.AuthMode {
+	case "apikey":
+		return auth.NewAPIKeyAuthenticator(cfg.APIKeys), nil
+	case "jwt":
+		jwtConfig := auth.JWTConfig{
+			HS256Secret: cfg.JWTSecret,
+			JWKSURL:     cfg.JWTJWKSURL,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			Leeway:      30 * time.Second,
+		}
+		if cfg.JWTPublicKeyFile != "" {
+			pemData, err := os.ReadFile(cfg.JWTPublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+			}
+			jwtConfig.RS256PublicKey = string(pemData)
+		}
+		return auth.NewJWTAuthenticator(jwtConfig)
+	default:
+		return nil, nil
+	}
+}
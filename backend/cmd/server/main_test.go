@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// chainNode returns a node whose getblockchaininfo reports chain, as
+// Bitcoin Core names it
+func chainNode(t *testing.T, chain string) *rpctest.Node {
+	t.Helper()
+	node := rpctest.NewNode(t, rpctest.NewChain(&chaincfg.RegressionNetParams))
+	node.Handle("getblockchaininfo", func([]json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"chain": chain}, nil
+	})
+	return node
+}
+
+func TestCheckNodeNetworkMismatch(t *testing.T) {
+	// Configured for regtest, the node is on mainnet
+	for _, tc := range []struct {
+		mode string
+		want *chaincfg.Params
+	}{
+		{"fail", nil},
+		{"warn", &chaincfg.RegressionNetParams},
+		{"trust_node", &chaincfg.MainNetParams},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			node := chainNode(t, "main")
+			params, err := checkNodeNetwork(node.Client(), &chaincfg.RegressionNetParams, tc.mode)
+			if tc.want == nil {
+				if err == nil || !strings.Contains(err.Error(), "NETWORK_MISMATCH") {
+					t.Fatalf("got %v, %v; want startup refused", params, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if params != tc.want {
+				t.Errorf("using %s, want %s", params.Name, tc.want.Name)
+			}
+		})
+	}
+}
+
+func TestCheckNodeNetworkMatch(t *testing.T) {
+	// Core's chain names differ from NETWORK's
+	for chain, params := range map[string]*chaincfg.Params{
+		"main":    &chaincfg.MainNetParams,
+		"test":    &chaincfg.TestNet3Params,
+		"regtest": &chaincfg.RegressionNetParams,
+		"signet":  &chaincfg.SigNetParams,
+	} {
+		got, err := checkNodeNetwork(chainNode(t, chain).Client(), params, "fail")
+		if err != nil || got != params {
+			t.Errorf("node on %s configured as %s: got %v, %v", chain, params.Name, got, err)
+		}
+	}
+}
+
+func TestCheckNodeNetworkUnknownChain(t *testing.T) {
+	// A chain the server has no parameters for can be warned about, not adopted
+	node := chainNode(t, "testnet4")
+	if params, err := checkNodeNetwork(node.Client(), &chaincfg.RegressionNetParams, "warn"); err != nil || params != &chaincfg.RegressionNetParams {
+		t.Errorf("warn: got %v, %v", params, err)
+	}
+	for _, mode := range []string{"fail", "trust_node"} {
+		if _, err := checkNodeNetwork(node.Client(), &chaincfg.RegressionNetParams, mode); err == nil {
+			t.Errorf("%s: unknown node chain accepted", mode)
+		}
+	}
+}
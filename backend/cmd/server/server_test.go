@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/api"
+	"spv-backend/internal/contract"
+	"spv-backend/internal/fee"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/ot"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/net/http2"
+)
+
+// h2cClient speaks cleartext HTTP/2 with prior knowledge
+var h2cClient = &http.Client{Transport: &http2.Transport{
+	AllowHTTP: true,
+	DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	},
+}}
+
+// startServer serves the API over a regtest chain paying address in two
+// blocks, with HTTP/2 enabled or not
+func startServer(t *testing.T, enableHTTP2 bool) (*httptest.Server, string) {
+	t.Helper()
+	params := &chaincfg.RegressionNetParams
+	chain := rpctest.NewChain(params)
+	address := rpctest.Address(params, "p2wpkh", 1)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 2000)))
+
+	client := rpctest.NewNode(t, chain).Client()
+	handler := api.NewHandler(client, filter.NewService(client, params), contract.NewService(client, ""),
+		fee.NewService(client, 1), ot.NewService(client), nil, nil, nil, &config.Config{})
+	server := httptest.NewUnstartedServer(newServer("", api.SetupRouter(handler, nil), enableHTTP2).Handler)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, address.EncodeAddress()
+}
+
+func TestHTTP2ClientWhenEnabled(t *testing.T) {
+	server, address := startServer(t, true)
+
+	resp, err := h2cClient.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("h2c request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Errorf("got %s %d, want HTTP/2 200", resp.Proto, resp.StatusCode)
+	}
+
+	// Streamed scans still arrive line by line
+	body := `{"addresses":["` + address + `"],"start_height":0,"end_height":2}`
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/utxos/scan", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err = h2cClient.Do(req)
+	if err != nil {
+		t.Fatalf("h2c stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("stream served over %s", resp.Proto)
+	}
+	var lines []map[string]json.RawMessage
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("stream line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	// Two UTXOs, then the summary
+	if len(lines) != 3 || lines[2]["summary"] == nil {
+		t.Errorf("stream had %d lines, want 2 UTXOs and a summary: %v", len(lines), lines)
+	}
+
+	// HTTP/1.1 clients are served as before
+	resp, err = http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("HTTP/1.1 request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 || resp.StatusCode != http.StatusOK {
+		t.Errorf("got %s %d, want HTTP/1.1 200", resp.Proto, resp.StatusCode)
+	}
+}
+
+func TestHTTP2Disabled(t *testing.T) {
+	server, _ := startServer(t, false)
+
+	if resp, err := h2cClient.Get(server.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Errorf("h2c request served as %s", resp.Proto)
+	}
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("HTTP/1.1 request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HTTP/1.1 request got %d", resp.StatusCode)
+	}
+}
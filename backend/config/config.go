@@ -2,8 +2,16 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -11,23 +19,167 @@ import (
 // Config holds the application configuration
 type Config struct {
 	// Server configuration
-	ServerHost string
-	ServerPort string
+	ServerHost     string
+	ServerPort     string
+	TrustedProxies []string // Proxies whose X-Forwarded-For is honored, none by default
+	EnableHTTP2    bool     // Also serve cleartext HTTP/2 (h2c), e.g. behind a TLS-terminating proxy
 
 	// Bitcoin RPC configuration
 	RPCHost     string
 	RPCPort     string
 	RPCUser     string
-	RPCPassword string
+	RPCPassword string `secret:"true"`
+
+	// RPC methods the client may call, empty means unrestricted
+	RPCMethodAllowlist []string
+	// RPC methods the client may never call, even if allowlisted
+	RPCMethodDenylist []string
 
 	// Network (mainnet, testnet, regtest)
 	Network string
 
 	// Contract configuration
 	ContractAddress string
+	ContractsFile   string            // JSON file mapping contract names to addresses
+	NamedContracts  map[string]string // Loaded from ContractsFile
 
 	// UTXO scan configuration
-	SPVMode bool // true = use BIP158 filters, false = direct scan
+	SPVMode       bool // true = use BIP158 filters, false = direct scan
+	FilterWorkers int  // Concurrent filter fetch/match workers
+	BlockWorkers  int  // Concurrent full block fetch workers
+
+	// Fee estimation configuration
+	FallbackFeeRate float64 // sat/vB used when no estimate is available
+
+	// CORS configuration
+	CORSAllowedOrigins []string // "*" allows any origin
+	CORSMaxAge         int      // Preflight cache duration in seconds
+
+	// Authentication configuration
+	AuthMode         string   // "none", "apikey" or "jwt"
+	APIKeys          []string `secret:"true"` // Accepted keys when AuthMode is "apikey"
+	JWTSecret        string   `secret:"true"` // HS256 signing secret
+	JWTPublicKeyFile string   // PEM file with the RS256 public key
+	JWTJWKSURL       string   // JWKS endpoint for RS256 keys
+	JWTIssuer        string   // Required "iss" claim, if set
+	JWTAudience      string   // Required "aud" claim, if set
+
+	// Probe the contract and OT RPCs in /health/detailed
+	HealthDeepChecks bool
+
+	// Debug endpoints configuration
+	DebugEndpoints bool   // Enables /debug/* routes
+	DebugAPIKey    string `secret:"true"` // Optional key required in X-Debug-Key for /debug/* routes
+
+	// Pagination configuration
+	CursorSecret string `secret:"true"` // HMAC key for scan cursors, random per process if unset
+
+	// Hex ed25519 seed signing scan snapshots ("signed": true), unset disables them
+	SnapshotSigningKey string `secret:"true"`
+
+	// OT request amount bounds, in satoshis
+	OTMinAmount int64
+	OTMaxAmount int64
+
+	// Request timeouts per endpoint category, in seconds (0 disables)
+	TimeoutFast      int // Reads such as /health, /block and /fees
+	TimeoutScan      int // UTXO scans and address usage checks
+	TimeoutBroadcast int // Transaction and contract broadcasts
+
+	// Most RPC calls a single request may make (0 disables)
+	MaxRPCCallsPerRequest int
+
+	// Retries of transient block and filter fetch failures during scans
+	RPCRetries        int
+	RPCRetryBackoffMs int // Wait before the first retry, doubled after each
+
+	// Derive JSON-RPC ids from the request's X-Request-ID correlation ID
+	RPCRequestIDs bool
+
+	// Record per-method metrics for the RPC proxy routes (GET /metrics/proxy)
+	ProxyMetrics bool
+
+	// How scans check UTXOs for spends: "live" (each gettxout sees the
+	// state at its own call) or "snapshot" (one consistent point in time)
+	VerificationMode string
+
+	// Skip the gettxout pass for all scans, trusting the block walk's spend
+	// detection. Only correct for ranges whose outputs cannot be spent later.
+	SkipUTXOVerification bool
+
+	// How scans fetch blocks: "auto" (serialized blocks decoded locally,
+	// falling back to verbose JSON) or "verbose" (always verbosity 2)
+	BlockFetch string
+
+	// Error response format: "json" ({"error": ...}, or problem+json when
+	// the client accepts it) or "problem" (always application/problem+json)
+	ErrorFormat string
+
+	// Response envelope of the /ot/* RPC proxy: "rpc" ({"result", "error"}),
+	// "jsonrpc" (JSON-RPC 2.0 with the request's id) or "result" (the bare
+	// result on success). Requests can override it with ?envelope=.
+	ProxyEnvelope string
+
+	// Most addresses derived from the descriptors of one scan (0 disables)
+	MaxDerivedAddresses int
+
+	// Fail scans totalling more than the network can ever issue
+	SupplySanityCheck bool
+
+	// Scans listing several addresses that pay the same script: "alias"
+	// reports UTXOs under the first with the others in alias_addresses,
+	// "reject" answers 400
+	DuplicateAddressScripts string
+
+	// Addresses above which scans from genesis to the tip read the node's
+	// UTXO set with scantxoutset instead of matching blocks (0 disables)
+	ScanTxOutSetThreshold int
+	// Let GET /utxos read the UTXO set for ranges ending at the tip
+	UTXOsAutoTxOutSet bool
+
+	// Keep-alive connections to open to the node at startup (0 disables)
+	RPCWarmConnections int
+
+	// Window over which block hash, filter and header calls from concurrent
+	// requests are gathered into one batch (0 disables)
+	RPCCoalesceWindowMs int
+
+	// Most HTTP requests in flight to the node across the whole server (0
+	// disables the limit), and how long a call queues for a free slot
+	RPCMaxConcurrent  int
+	RPCQueueTimeoutMs int
+
+	// Safe mode rejects scans likely to overload the node
+	SafeMode                bool
+	SafeModeMaxDirectBlocks int // Largest block range of a direct-mode scan
+	SafeModeMaxAddresses    int // Most addresses in one scan
+
+	// Address watching (GET /watch/:id/utxos)
+	WatchPollInterval int // Seconds between tip checks, 0 disables watching
+	MaxWatches        int
+
+	// Webhook delivery of watch matches (0 workers disables webhooks)
+	WebhookWorkers        int
+	WebhookQueueSize      int
+	WebhookMaxRetries     int
+	WebhookRetryBackoffMs int // Before the first retry, doubled for each one after
+	MaxWebhookDeadLetters int
+	WebhookAllowPrivate   bool // Allow loopback, link-local and private destinations
+
+	// Recent events kept for GET /events (0 disables the feed)
+	EventLogSize int
+
+	// Broadcast transaction tracking (GET /tx/:txid/status)
+	TxStatusPollInterval int // Seconds between checks, 0 disables tracking
+	MaxTrackedTxs        int
+
+	// Startup behavior when the node's network differs from Network:
+	// "fail", "warn" or "trust_node"
+	NetworkMismatch string
+
+	// Persistent cache configuration
+	CacheDir         string // Directory for cached filters, caching is disabled if empty
+	CacheCompression string // "none" or "gzip"
 }
 
 // Load loads configuration from environment variables
@@ -44,7 +196,188 @@ func Load() (*Config, error) {
 		RPCPassword:     getEnv("RPC_PASSWORD", "test"),
 		Network:         getEnv("NETWORK", "regtest"),
 		ContractAddress: getEnv("CONTRACT_ADDRESS", "5c26651e9c97db61d8b5ca31f34d4ebae8498b12c3213797036657b176fe2583"),
+		ContractsFile:   getEnv("CONTRACTS_FILE", ""),
 		SPVMode:         getBoolEnv("SPV_MODE", false),
+
+		RPCMethodAllowlist: getListEnv("RPC_METHOD_ALLOWLIST", nil),
+		RPCMethodDenylist:  getListEnv("RPC_METHOD_DENYLIST", nil),
+		FilterWorkers:      getIntEnv("FILTER_WORKERS", 8),
+		BlockWorkers:       getIntEnv("BLOCK_WORKERS", 2),
+		TrustedProxies:     getListEnv("TRUSTED_PROXIES", nil),
+		EnableHTTP2:        getBoolEnv("ENABLE_HTTP2", false),
+
+		FallbackFeeRate: getFloatEnv("FALLBACK_FEE_RATE", 1.0),
+
+		CORSAllowedOrigins: getListEnv("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		CORSMaxAge:         getIntEnv("CORS_MAX_AGE", 600),
+
+		AuthMode:         getEnv("AUTH_MODE", "none"),
+		APIKeys:          getListEnv("API_KEYS", nil),
+		JWTSecret:        getEnv("JWT_SECRET", ""),
+		JWTPublicKeyFile: getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JWTJWKSURL:       getEnv("JWT_JWKS_URL", ""),
+		JWTIssuer:        getEnv("JWT_ISSUER", ""),
+		JWTAudience:      getEnv("JWT_AUDIENCE", ""),
+
+		HealthDeepChecks: getBoolEnv("HEALTH_DEEP_CHECKS", false),
+
+		DebugEndpoints: getBoolEnv("DEBUG_ENDPOINTS", false),
+		DebugAPIKey:    getEnv("DEBUG_API_KEY", ""),
+
+		CursorSecret: getEnv("CURSOR_SECRET", ""),
+
+		SnapshotSigningKey: getEnv("SNAPSHOT_SIGNING_KEY", ""),
+
+		OTMinAmount: getInt64Env("OT_MIN_AMOUNT", 0),
+		OTMaxAmount: getInt64Env("OT_MAX_AMOUNT", 21000000*100000000),
+
+		TimeoutFast:      getIntEnv("TIMEOUT_FAST", 15),
+		TimeoutScan:      getIntEnv("TIMEOUT_SCAN", 300),
+		TimeoutBroadcast: getIntEnv("TIMEOUT_BROADCAST", 60),
+
+		MaxRPCCallsPerRequest: getIntEnv("MAX_RPC_CALLS_PER_REQUEST", 10000),
+
+		RPCRetries:        getIntEnv("RPC_RETRIES", 3),
+		RPCRetryBackoffMs: getIntEnv("RPC_RETRY_BACKOFF_MS", 200),
+
+		RPCRequestIDs: getBoolEnv("RPC_REQUEST_IDS", true),
+
+		ProxyMetrics: getBoolEnv("PROXY_METRICS", true),
+
+		VerificationMode: getEnv("VERIFICATION_MODE", "live"),
+
+		SkipUTXOVerification: getBoolEnv("SKIP_UTXO_VERIFICATION", false),
+
+		BlockFetch: getEnv("BLOCK_FETCH", "auto"),
+
+		ErrorFormat: getEnv("ERROR_FORMAT", "json"),
+
+		ProxyEnvelope: getEnv("PROXY_ENVELOPE", "rpc"),
+
+		MaxDerivedAddresses: getIntEnv("MAX_DERIVED_ADDRESSES", 10000),
+
+		SupplySanityCheck: getBoolEnv("SUPPLY_SANITY_CHECK", true),
+
+		DuplicateAddressScripts: getEnv("DUPLICATE_ADDRESS_SCRIPTS", "alias"),
+
+		ScanTxOutSetThreshold: getIntEnv("SCANTXOUTSET_THRESHOLD", 0),
+		UTXOsAutoTxOutSet:     getBoolEnv("UTXOS_AUTO_TXOUTSET", true),
+
+		RPCWarmConnections: getIntEnv("RPC_WARM_CONNECTIONS", 0),
+
+		RPCCoalesceWindowMs: getIntEnv("RPC_COALESCE_WINDOW_MS", 0),
+
+		RPCMaxConcurrent:  getIntEnv("RPC_MAX_CONCURRENT", 0),
+		RPCQueueTimeoutMs: getIntEnv("RPC_QUEUE_TIMEOUT_MS", 10000),
+
+		SafeMode:                getBoolEnv("SAFE_MODE", true),
+		SafeModeMaxDirectBlocks: getIntEnv("SAFE_MODE_MAX_DIRECT_BLOCKS", 500),
+		SafeModeMaxAddresses:    getIntEnv("SAFE_MODE_MAX_ADDRESSES", 1000),
+
+		WatchPollInterval: getIntEnv("WATCH_POLL_INTERVAL", 10),
+		MaxWatches:        getIntEnv("MAX_WATCHES", 100),
+
+		WebhookWorkers:        getIntEnv("WEBHOOK_WORKERS", 0),
+		WebhookQueueSize:      getIntEnv("WEBHOOK_QUEUE_SIZE", 1000),
+		WebhookMaxRetries:     getIntEnv("WEBHOOK_MAX_RETRIES", 5),
+		WebhookRetryBackoffMs: getIntEnv("WEBHOOK_RETRY_BACKOFF_MS", 1000),
+		MaxWebhookDeadLetters: getIntEnv("MAX_WEBHOOK_DEAD_LETTERS", 1000),
+		WebhookAllowPrivate:   getBoolEnv("WEBHOOK_ALLOW_PRIVATE", false),
+
+		EventLogSize: getIntEnv("EVENT_LOG_SIZE", 10000),
+
+		TxStatusPollInterval: getIntEnv("TX_STATUS_POLL_INTERVAL", 30),
+		MaxTrackedTxs:        getIntEnv("MAX_TRACKED_TXS", 10000),
+
+		NetworkMismatch: getEnv("NETWORK_MISMATCH", "fail"),
+
+		CacheDir:         getEnv("CACHE_DIR", ""),
+		CacheCompression: getEnv("CACHE_COMPRESSION", "none"),
+	}
+
+	switch config.AuthMode {
+	case "none", "apikey", "jwt":
+	default:
+		return nil, fmt.Errorf("unknown AUTH_MODE: %s", config.AuthMode)
+	}
+	if config.AuthMode == "apikey" && len(config.APIKeys) == 0 {
+		return nil, fmt.Errorf("API_KEYS is required when AUTH_MODE=apikey")
+	}
+
+	switch config.NetworkMismatch {
+	case "fail", "warn", "trust_node":
+	default:
+		return nil, fmt.Errorf("unknown NETWORK_MISMATCH: %s", config.NetworkMismatch)
+	}
+
+	switch config.VerificationMode {
+	case "live", "snapshot":
+	default:
+		return nil, fmt.Errorf("unknown VERIFICATION_MODE: %s", config.VerificationMode)
+	}
+
+	switch config.BlockFetch {
+	case "auto", "verbose":
+	default:
+		return nil, fmt.Errorf("unknown BLOCK_FETCH: %s", config.BlockFetch)
+	}
+
+	switch config.ErrorFormat {
+	case "json", "problem":
+	default:
+		return nil, fmt.Errorf("unknown ERROR_FORMAT: %s", config.ErrorFormat)
+	}
+
+	switch config.ProxyEnvelope {
+	case "rpc", "jsonrpc", "result":
+	default:
+		return nil, fmt.Errorf("unknown PROXY_ENVELOPE: %s", config.ProxyEnvelope)
+	}
+
+	switch config.DuplicateAddressScripts {
+	case "alias", "reject":
+	default:
+		return nil, fmt.Errorf("unknown DUPLICATE_ADDRESS_SCRIPTS: %s", config.DuplicateAddressScripts)
+	}
+
+	switch config.CacheCompression {
+	case "none", "gzip":
+	default:
+		return nil, fmt.Errorf("unknown CACHE_COMPRESSION: %s", config.CacheCompression)
+	}
+
+	for _, proxy := range config.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry: %s", proxy)
+		}
+	}
+
+	if config.OTMinAmount < 0 || config.OTMaxAmount < config.OTMinAmount {
+		return nil, fmt.Errorf("OT_MIN_AMOUNT and OT_MAX_AMOUNT must satisfy 0 <= min <= max")
+	}
+
+	if config.ContractsFile != "" {
+		contracts, err := loadNamedContracts(config.ContractsFile)
+		if err != nil {
+			return nil, err
+		}
+		config.NamedContracts = contracts
+	}
+
+	// Without a configured secret, cursors are only valid for this process
+	if config.CursorSecret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate cursor secret: %w", err)
+		}
+		config.CursorSecret = hex.EncodeToString(secret)
+	}
+
+	if config.SnapshotSigningKey != "" {
+		seed, err := hex.DecodeString(config.SnapshotSigningKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("SNAPSHOT_SIGNING_KEY must be a %d byte hex ed25519 seed", ed25519.SeedSize)
+		}
 	}
 
 	// Validate required fields
@@ -55,6 +388,44 @@ func Load() (*Config, error) {
 	return config, nil
 }
 
+// Redacted returns the configuration as a map keyed by field name, with every
+// field tagged `secret:"true"` replaced by a placeholder when set
+func (c *Config) Redacted() map[string]interface{} {
+	redacted := make(map[string]interface{})
+	v := reflect.ValueOf(*c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+		if field.Tag.Get("secret") == "true" && !v.Field(i).IsZero() {
+			value = "[REDACTED]"
+		}
+		redacted[field.Name] = value
+	}
+	return redacted
+}
+
+// loadNamedContracts reads a JSON object mapping contract names to addresses
+func loadNamedContracts(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONTRACTS_FILE: %w", err)
+	}
+
+	var contracts map[string]string
+	if err := json.Unmarshal(data, &contracts); err != nil {
+		return nil, fmt.Errorf("failed to parse CONTRACTS_FILE: %w", err)
+	}
+
+	for name, address := range contracts {
+		if name == "" || address == "" {
+			return nil, fmt.Errorf("CONTRACTS_FILE: contract names and addresses must not be empty")
+		}
+	}
+
+	return contracts, nil
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -79,3 +450,60 @@ func getBoolEnv(key string, defaultValue bool) bool {
 		return defaultValue
 	}
 }
+
+// getIntEnv gets an integer environment variable with a default value
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// getInt64Env gets a 64-bit integer environment variable with a default value
+func getInt64Env(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// getFloatEnv gets a floating-point environment variable with a default value
+func getFloatEnv(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// getListEnv gets a comma-separated list environment variable with a default value
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return defaultValue
+	}
+	return items
+}
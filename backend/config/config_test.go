@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 172.16.0.0/12,::1")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if want := []string{"10.0.0.1", "172.16.0.0/12", "::1"}; !reflect.DeepEqual(cfg.TrustedProxies, want) {
+		t.Errorf("trusted proxies %v, want %v", cfg.TrustedProxies, want)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1,proxy.internal")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "proxy.internal") {
+		t.Errorf("got error %v for a host name, want the entry rejected", err)
+	}
+}
+
+func TestLoadNamedContracts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contracts.json")
+	if err := os.WriteFile(path, []byte(`{"token": "5c26651e", "escrow": "a1b2c3d4"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONTRACTS_FILE", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if want := map[string]string{"token": "5c26651e", "escrow": "a1b2c3d4"}; !reflect.DeepEqual(cfg.NamedContracts, want) {
+		t.Errorf("named contracts %v, want %v", cfg.NamedContracts, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"token": ""}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("loaded a contract without an address")
+	}
+}
+
+func TestLoadNetworkMismatch(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.NetworkMismatch != "fail" {
+		t.Errorf("network mismatch defaults to %q, want fail", cfg.NetworkMismatch)
+	}
+
+	t.Setenv("NETWORK_MISMATCH", "ignore")
+	if _, err := Load(); err == nil {
+		t.Error("unknown NETWORK_MISMATCH accepted")
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+func TestAddressUsedRange(t *testing.T) {
+	old := rpctest.Address(testParams, "p2wpkh", 1)
+	recent := rpctest.Address(testParams, "p2wpkh", 2)
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(old, 1000)))
+	for s.chain.Height() < filter.MaxScanRange+10 {
+		s.chain.AddBlock()
+	}
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(recent, 1000)))
+	tip := s.chain.Height()
+
+	tests := []struct {
+		address string
+		query   string
+		status  int
+		used    bool
+		first   int64
+	}{
+		// The default range is the last MaxScanRange+1 blocks
+		{recent.EncodeAddress(), "", http.StatusOK, true, tip},
+		{old.EncodeAddress(), "", http.StatusOK, false, 0},
+		{old.EncodeAddress(), "?start=0&end=2000", http.StatusOK, true, 1},
+		{old.EncodeAddress(), "?start=0", http.StatusBadRequest, false, 0},
+		{old.EncodeAddress(), "?start=5&end=4", http.StatusBadRequest, false, 0},
+		{"not-an-address", "", http.StatusBadRequest, false, 0},
+	}
+	for _, test := range tests {
+		w := s.do(http.MethodGet, "/address/"+test.address+"/used"+test.query, nil)
+		if w.Code != test.status {
+			t.Errorf("%s%s: got status %d, want %d: %s", test.address, test.query, w.Code, test.status, w.Body.String())
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var result filter.AddressUsageResult
+		decode(t, w, &result)
+		if result.EverMatched != test.used || (test.used && *result.FirstMatchedHeight != test.first) {
+			t.Errorf("%s%s: got %+v", test.address, test.query, result)
+		}
+		if test.query == "" && result.BlocksFiltered != filter.MaxScanRange+1 {
+			t.Errorf("default range filtered %d blocks", result.BlocksFiltered)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+func TestScanListsAddressesSharingAScript(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	upper := strings.ToUpper(address.EncodeAddress())
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{upper, address.EncodeAddress()}, 0, 1, nil))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if result.TotalUTXOs != 1 {
+		t.Fatalf("found %d UTXOs, want the output once", result.TotalUTXOs)
+	}
+	if utxo := result.UTXOs[0]; utxo.Address != upper || len(utxo.AliasAddresses) != 1 || utxo.AliasAddresses[0] != address.EncodeAddress() {
+		t.Errorf("UTXO under %s aliased by %v, want %s aliased by %s", utxo.Address, utxo.AliasAddresses, upper, address.EncodeAddress())
+	}
+}
+
+func TestScanRejectsAddressesSharingAScript(t *testing.T) {
+	s := newTestServer(t, &config.Config{DuplicateAddressScripts: "reject"}, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2wpkh", 2).EncodeAddress()
+	upper := strings.ToUpper(address.EncodeAddress())
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress(), other, upper}, 0, 1, nil))
+	expectStatus(t, w, http.StatusBadRequest)
+	var resp struct {
+		DuplicateScripts map[string][]string `json:"duplicate_scripts"`
+	}
+	decode(t, w, &resp)
+	if aliases := resp.DuplicateScripts[address.EncodeAddress()]; len(resp.DuplicateScripts) != 1 || len(aliases) != 1 || aliases[0] != upper {
+		t.Errorf("duplicate scripts %v, want %s aliased by %s", resp.DuplicateScripts, address.EncodeAddress(), upper)
+	}
+
+	// Distinct scripts are still scanned
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress(), other}, 0, 1, nil))
+	expectStatus(t, w, http.StatusOK)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"spv-backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key holding the authenticated principal
+const principalKey = "principal"
+
+// publicRoutes are reachable without authentication
+var publicRoutes = map[string]bool{
+	"/health": true,
+	"/routes": true,
+}
+
+// authMiddleware authenticates every non-public route with the configured
+// authenticator and stores the principal in the request context
+func authMiddleware(authenticator auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if publicRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			log.Printf("[Auth] Rejected %s %s from %s: %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanBalanceOnly(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 2000))
+	s.chain.AddBlock(fund)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(a, 4000)))
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 9), 1500)))
+	addresses := []string{a.EncodeAddress(), b.EncodeAddress()}
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), nil))
+	expectStatus(t, w, http.StatusOK)
+	var full filter.UTXOScanResult
+	decode(t, w, &full)
+	if full.TotalUTXOs != 2 || full.TotalSatoshis != 5000 {
+		t.Fatalf("full scan found %d UTXOs, %d sats", full.TotalUTXOs, full.TotalSatoshis)
+	}
+
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), map[string]interface{}{"balance_only": true}))
+	expectStatus(t, w, http.StatusOK)
+	var raw map[string]json.RawMessage
+	decode(t, w, &raw)
+	if utxos := string(raw["utxos"]); utxos != "" && utxos != "null" && utxos != "[]" {
+		t.Errorf("balance-only scan returned UTXOs %s", utxos)
+	}
+	var balance filter.UTXOScanResult
+	decode(t, w, &balance)
+	if balance.TotalUTXOs != full.TotalUTXOs || balance.TotalSatoshis != full.TotalSatoshis || balance.TotalAmount != full.TotalAmount {
+		t.Errorf("balance-only totals %d UTXOs, %d sats, %v BTC; full scan %d, %d, %v",
+			balance.TotalUTXOs, balance.TotalSatoshis, balance.TotalAmount, full.TotalUTXOs, full.TotalSatoshis, full.TotalAmount)
+	}
+	if balance.Balance == nil || balance.Balance.ConfirmedSatoshis != 5000 || balance.Balance.UnconfirmedSatoshis != 0 || balance.Balance.UTXOCount != 2 {
+		t.Errorf("balance %+v, want 5000 confirmed sats in 2 UTXOs", balance.Balance)
+	}
+}
+
+func TestBalanceAtHeight(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(a, 2000))
+	s.chain.AddBlock(fund)
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 9), 1900)))
+
+	// At height 1 the spend at height 2 has not happened
+	w := s.do(http.MethodGet, "/address/"+a.EncodeAddress()+"/balance-at/1", nil)
+	expectStatus(t, w, http.StatusOK)
+	var then filter.HistoricalBalance
+	decode(t, w, &then)
+	if then.BalanceSats != 3000 || then.UTXOCount != 2 || then.Height != 1 || then.Balance != 0.00003 {
+		t.Errorf("balance at 1: %+v, want 3000 sats in 2 UTXOs", then)
+	}
+
+	w = s.do(http.MethodGet, "/address/"+a.EncodeAddress()+"/balance-at/2", nil)
+	expectStatus(t, w, http.StatusOK)
+	var now filter.HistoricalBalance
+	decode(t, w, &now)
+	if now.BalanceSats != 1000 || now.UTXOCount != 1 {
+		t.Errorf("balance at 2: %+v, want 1000 sats in 1 UTXO", now)
+	}
+}
+
+func TestBalanceAtRejectsBadParams(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+
+	for path, status := range map[string]int{
+		"/address/notanaddress/balance-at/1":                  http.StatusBadRequest,
+		"/address/" + address + "/balance-at/-1":              http.StatusBadRequest,
+		"/address/" + address + "/balance-at/1?from_height=2": http.StatusBadRequest,
+		"/address/" + address + "/balance-at/5000":            http.StatusBadRequest,
+		"/address/" + address + "/balance-at/5":               http.StatusNotFound,
+	} {
+		w := s.do(http.MethodGet, path, nil)
+		if w.Code != status {
+			t.Errorf("%s: status %d, want %d", path, w.Code, status)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callBudgetMiddleware gives each request a budget of RPC calls, carried on
+// the request context that handlers bind their RPC clients to. Once it is
+// spent, further calls fail with rpc.ErrCallBudgetExceeded and the server
+// error the handler answers with becomes a 429.
+func callBudgetMiddleware(limit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		c.Request = c.Request.WithContext(rpc.WithCallBudget(c.Request.Context(), limit))
+		c.Writer = &callBudgetWriter{ResponseWriter: c.Writer, c: c}
+		c.Next()
+	}
+}
+
+// callBudgetWriter turns server error responses into 429 once the request's
+// RPC call budget has been exceeded
+type callBudgetWriter struct {
+	gin.ResponseWriter
+	c *gin.Context
+}
+
+func (w *callBudgetWriter) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError && rpc.CallBudgetExceeded(w.c.Request.Context()) {
+		code = http.StatusTooManyRequests
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+// budgetResponse is the body of a scan that ran out of RPC calls
+type budgetResponse struct {
+	Error         string                 `json:"error"`
+	PartialResult *filter.UTXOScanResult `json:"partial_result"`
+}
+
+// newBudgetServer returns a server allowing budget RPC calls per request,
+// over ten blocks each paying address once. Blocks are fetched one at a
+// time so the budget runs out at a known block.
+func newBudgetServer(t *testing.T, budget int) (*testServer, string) {
+	t.Helper()
+	s := newTestServer(t, &config.Config{MaxRPCCallsPerRequest: budget}, nil, func(s *testServer) {
+		s.handler.filterService.SetWorkers(1, 1)
+	})
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 10; i++ {
+		s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	}
+	return s, address.EncodeAddress()
+}
+
+func TestScanBudgetExceededDuringBlockFetch(t *testing.T) {
+	// Ten getblockhash calls and the tip, then four of the ten getblock calls
+	s, address := newBudgetServer(t, 15)
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 1, 10, nil))
+	expectStatus(t, w, http.StatusTooManyRequests)
+	var resp budgetResponse
+	decode(t, w, &resp)
+	if resp.Error == "" || resp.PartialResult == nil || resp.PartialResult.Partial == nil {
+		t.Fatalf("response %+v has no partial result", resp)
+	}
+	partial := resp.PartialResult.Partial
+	if partial.ScannedToHeight != 4 || resp.PartialResult.BlocksScanned != 4 {
+		t.Errorf("scanned %d blocks up to %d, want 4 up to 4", resp.PartialResult.BlocksScanned, partial.ScannedToHeight)
+	}
+	// Nothing was verified; the scanned blocks' outputs are reported as found
+	if len(resp.PartialResult.UTXOs) != 0 {
+		t.Errorf("%d UTXOs verified, want none", len(resp.PartialResult.UTXOs))
+	}
+	if len(partial.UnverifiedUTXOs) != 4 {
+		t.Errorf("%d unverified UTXOs, want one per scanned block", len(partial.UnverifiedUTXOs))
+	}
+	for _, utxo := range partial.UnverifiedUTXOs {
+		if utxo.Height > partial.ScannedToHeight {
+			t.Errorf("unverified UTXO at height %d is past the scanned blocks", utxo.Height)
+		}
+	}
+	if s.node.Requests() > 15 {
+		t.Errorf("node received %d calls, over the budget of 15", s.node.Requests())
+	}
+}
+
+func TestScanBudgetExceededDuringVerification(t *testing.T) {
+	// Every block is fetched, then the budget runs out part way through the
+	// gettxout checks
+	s, address := newBudgetServer(t, 25)
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 1, 10, nil))
+	expectStatus(t, w, http.StatusTooManyRequests)
+	var resp budgetResponse
+	decode(t, w, &resp)
+	if resp.PartialResult == nil || resp.PartialResult.Partial == nil {
+		t.Fatalf("response %+v has no partial result", resp)
+	}
+	partial := resp.PartialResult.Partial
+	if partial.ScannedToHeight != 10 {
+		t.Errorf("scanned to %d, want the whole range", partial.ScannedToHeight)
+	}
+	verified, unverified := resp.PartialResult.UTXOs, partial.UnverifiedUTXOs
+	if len(verified) == 0 || len(unverified) == 0 {
+		t.Fatalf("%d verified and %d unverified UTXOs, want the budget to run out between them", len(verified), len(unverified))
+	}
+
+	// Together they are every output, each reported once
+	seen := make(map[string]bool)
+	for _, utxo := range append(verified, unverified...) {
+		if seen[utxo.TxID] {
+			t.Errorf("UTXO %s reported twice", utxo.TxID)
+		}
+		seen[utxo.TxID] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("%d UTXOs reported, want all 10", len(seen))
+	}
+	if resp.PartialResult.TotalUTXOs != len(verified) {
+		t.Errorf("total %d counts more than the %d verified UTXOs", resp.PartialResult.TotalUTXOs, len(verified))
+	}
+	if s.node.Requests() > 25 {
+		t.Errorf("node received %d calls, over the budget of 25", s.node.Requests())
+	}
+}
+
+func TestScanBudgetIsPerRequest(t *testing.T) {
+	// Enough for one full scan: 10 block hashes, the tip, 10 blocks and 10
+	// gettxout checks
+	s, address := newBudgetServer(t, 31)
+
+	for i := 0; i < 2; i++ {
+		w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 1, 10, nil))
+		expectStatus(t, w, http.StatusOK)
+		var result filter.UTXOScanResult
+		decode(t, w, &result)
+		if result.TotalUTXOs != 10 || result.Partial != nil {
+			t.Errorf("scan %d found %d UTXOs, partial %+v", i+1, result.TotalUTXOs, result.Partial)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestChainParamsRegtest(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+
+	w := s.do(http.MethodGet, "/chainparams", nil)
+	expectStatus(t, w, http.StatusOK)
+	var params ChainParamsInfo
+	decode(t, w, &params)
+
+	// Bitcoin Core's regtest parameters (chainparams.cpp)
+	want := ChainParamsInfo{
+		Name:               "regtest",
+		Magic:              "fabfb5da",
+		GenesisHash:        "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		Bech32HRP:          "bcrt",
+		PubKeyHashAddrID:   0x6f,
+		ScriptHashAddrID:   0xc4,
+		PrivateKeyID:       0xef,
+		DefaultPort:        "18444",
+		CoinbaseMaturity:   100,
+		TargetBlockTimeSec: 600,
+	}
+	if params != want {
+		t.Errorf("got %+v, want %+v", params, want)
+	}
+	if s.node.Requests() != 0 {
+		t.Error("chain parameters were read from the node")
+	}
+}
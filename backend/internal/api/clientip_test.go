@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"spv-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIP serves a request from remoteAddr through the server's router and
+// returns the client IP its handlers see
+func clientIP(s *testServer, remoteAddr, forwardedFor string) string {
+	req := httptest.NewRequest(http.MethodGet, "/test/client-ip", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func newClientIPServer(t *testing.T, proxies []string) *testServer {
+	s := newTestServer(t, &config.Config{TrustedProxies: proxies}, nil, nil)
+	s.router.GET("/test/client-ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return s
+}
+
+func TestClientIPWithoutTrustedProxy(t *testing.T) {
+	s := newClientIPServer(t, nil)
+
+	// X-Forwarded-For is ignored from anyone
+	if got := clientIP(s, "10.0.0.1:4000", "203.0.113.7"); got != "10.0.0.1" {
+		t.Errorf("client IP %q, want the peer 10.0.0.1", got)
+	}
+	if got := clientIP(s, "198.51.100.2:4000", ""); got != "198.51.100.2" {
+		t.Errorf("client IP %q, want the peer 198.51.100.2", got)
+	}
+}
+
+func TestClientIPWithTrustedProxy(t *testing.T) {
+	s := newClientIPServer(t, []string{"10.0.0.0/8"})
+
+	// A trusted proxy forwards the client's address
+	if got := clientIP(s, "10.0.0.1:4000", "203.0.113.7"); got != "203.0.113.7" {
+		t.Errorf("client IP %q through a trusted proxy, want 203.0.113.7", got)
+	}
+	// The client's own X-Forwarded-For entries before the proxy are not trusted
+	if got := clientIP(s, "10.0.0.1:4000", "192.0.2.99, 203.0.113.7"); got != "203.0.113.7" {
+		t.Errorf("client IP %q with a spoofed entry, want 203.0.113.7", got)
+	}
+	// Other peers cannot claim an address
+	if got := clientIP(s, "198.51.100.2:4000", "203.0.113.7"); got != "198.51.100.2" {
+		t.Errorf("client IP %q from an untrusted peer, want 198.51.100.2", got)
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CoinbaseOutput is a coinbase output paying one of the requested addresses,
+// split into the parts taken from the block subsidy and from fees
+type CoinbaseOutput struct {
+	Address  string `json:"address"`
+	Vout     int    `json:"vout"`
+	Satoshis int64  `json:"satoshis"`
+	Subsidy  int64  `json:"subsidy"` // Satoshis
+	Fees     int64  `json:"fees"`    // Satoshis
+}
+
+// CoinbaseBlock is a block whose coinbase paid a requested address
+type CoinbaseBlock struct {
+	Height        int64            `json:"height"`
+	Hash          string           `json:"hash"`
+	CoinbaseTxID  string           `json:"coinbase_txid"`
+	BlockSubsidy  int64            `json:"block_subsidy"`  // Satoshis, from getblockstats
+	BlockFees     int64            `json:"block_fees"`     // Satoshis, from getblockstats
+	CoinbaseTotal int64            `json:"coinbase_total"` // Satoshis paid by the whole coinbase
+	Outputs       []CoinbaseOutput `json:"outputs"`
+}
+
+// CoinbaseTotals sums coinbase payments, overall or to one address
+type CoinbaseTotals struct {
+	Satoshis int64 `json:"satoshis"`
+	Subsidy  int64 `json:"subsidy"`
+	Fees     int64 `json:"fees"`
+	Blocks   int   `json:"blocks"` // Blocks whose coinbase paid it
+}
+
+// CoinbaseFeesResult is the answer of GET /coinbase/fees
+type CoinbaseFeesResult struct {
+	StartHeight int64                     `json:"start_height"`
+	EndHeight   int64                     `json:"end_height"`
+	Total       CoinbaseTotals            `json:"total"`
+	ByAddress   map[string]CoinbaseTotals `json:"by_address"`
+	Blocks      []CoinbaseBlock           `json:"blocks"` // In height order
+}
+
+// coinbaseBlockStats is the part of getblockstats the split needs
+type coinbaseBlockStats struct {
+	BlockHash string `json:"blockhash"`
+	Subsidy   int64  `json:"subsidy"`
+	TotalFee  int64  `json:"totalfee"`
+}
+
+// GetCoinbaseFees handles GET /coinbase/fees?addresses=&start=&end=
+// Sums what the coinbases of at most 1000 blocks paid the comma separated
+// addresses, e.g. a pool's payout addresses. Each output is split between
+// subsidy and fees in proportion to the block's subsidy and fee total from
+// getblockstats, so a coinbase that claims less than its reward gives up
+// both alike. Fee totals need the blocks' undo data, so a range reaching
+// into pruned blocks fails.
+func (h *Handler) GetCoinbaseFees(c *gin.Context) {
+	addresses := parseAddressList(c.Query("addresses"))
+	if len(addresses) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address is required"})
+		return
+	}
+	scripts := make(map[string]string, len(addresses)) // scriptPubKey hex -> first address paying it
+	for _, address := range addresses {
+		script, err := h.filterService.AddressToScriptPubKey(address)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid address %s: %s", address, err)})
+			return
+		}
+		if _, exists := scripts[hex.EncodeToString(script)]; !exists {
+			scripts[hex.EncodeToString(script)] = address
+		}
+	}
+
+	startHeight, err := strconv.ParseInt(c.Query("start"), 10, 64)
+	if err != nil || startHeight < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start parameter"})
+		return
+	}
+	endHeight, err := strconv.ParseInt(c.Query("end"), 10, 64)
+	if err != nil || endHeight < startHeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end parameter (start or above)"})
+		return
+	}
+	if endHeight-startHeight+1 > maxStatsRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range too large, max %d blocks", maxStatsRange)})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if endHeight > tip {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("end beyond the chain tip %d", tip)})
+		return
+	}
+
+	result := CoinbaseFeesResult{
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		ByAddress:   make(map[string]CoinbaseTotals),
+		Blocks:      []CoinbaseBlock{},
+	}
+	for batchStart := startHeight; batchStart <= endHeight; batchStart += statsBatchSize {
+		batchEnd := batchStart + statsBatchSize - 1
+		if batchEnd > endHeight {
+			batchEnd = endHeight
+		}
+		stats, err := h.coinbaseBlockStats(c, batchStart, batchEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i, blockStats := range stats {
+			block, err := h.coinbasePayments(c, batchStart+int64(i), blockStats, scripts)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if block != nil {
+				result.add(block)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// add counts a block's payments into the totals
+func (r *CoinbaseFeesResult) add(block *CoinbaseBlock) {
+	r.Blocks = append(r.Blocks, *block)
+	r.Total.Blocks++
+	paid := make(map[string]bool)
+	for _, output := range block.Outputs {
+		r.Total.Satoshis += output.Satoshis
+		r.Total.Subsidy += output.Subsidy
+		r.Total.Fees += output.Fees
+
+		totals := r.ByAddress[output.Address]
+		totals.Satoshis += output.Satoshis
+		totals.Subsidy += output.Subsidy
+		totals.Fees += output.Fees
+		if !paid[output.Address] {
+			paid[output.Address] = true
+			totals.Blocks++
+		}
+		r.ByAddress[output.Address] = totals
+	}
+}
+
+// coinbaseBlockStats reads the hash, subsidy and fee total of the blocks
+// from startHeight to endHeight with one batch of getblockstats calls
+func (h *Handler) coinbaseBlockStats(c *gin.Context, startHeight, endHeight int64) ([]coinbaseBlockStats, error) {
+	requests := make([]rpc.RPCRequest, 0, endHeight-startHeight+1)
+	for height := startHeight; height <= endHeight; height++ {
+		requests = append(requests, rpc.RPCRequest{
+			Jsonrpc: "1.0",
+			Method:  "getblockstats",
+			Params:  []interface{}{height, []string{"blockhash", "subsidy", "totalfee"}},
+			ID:      len(requests),
+		})
+	}
+
+	responses, err := h.rpcFor(c).BatchCall(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]coinbaseBlockStats, len(requests))
+	answered := make([]bool, len(requests))
+	for _, resp := range responses {
+		if resp.ID < 0 || resp.ID >= len(requests) || answered[resp.ID] {
+			continue
+		}
+		height := startHeight + int64(resp.ID)
+		if resp.Error != nil {
+			return nil, fmt.Errorf("getblockstats failed at height %d: %s", height, resp.Error.Message)
+		}
+		if err := json.Unmarshal(resp.Result, &stats[resp.ID]); err != nil {
+			return nil, fmt.Errorf("failed to parse block stats at height %d: %w", height, err)
+		}
+		answered[resp.ID] = true
+	}
+
+	for i, ok := range answered {
+		if !ok {
+			return nil, fmt.Errorf("no block stats for height %d", startHeight+int64(i))
+		}
+	}
+	return stats, nil
+}
+
+// coinbasePayments returns what a block's coinbase paid the scripts, nil if
+// it paid none of them
+func (h *Handler) coinbasePayments(c *gin.Context, height int64, stats coinbaseBlockStats, scripts map[string]string) (*CoinbaseBlock, error) {
+	blockData, err := h.rpcFor(c).GetBlock(stats.BlockHash, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", stats.BlockHash, err)
+	}
+	var block struct {
+		Tx []string `json:"tx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		return nil, fmt.Errorf("failed to parse block %s: %w", stats.BlockHash, err)
+	}
+	if len(block.Tx) == 0 {
+		return nil, fmt.Errorf("block %s has no transactions", stats.BlockHash)
+	}
+
+	// Passing the block hash finds the coinbase without -txindex
+	txData, err := h.rpcFor(c).GetRawTransactionInBlock(block.Tx[0], true, stats.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coinbase of block %s: %w", stats.BlockHash, err)
+	}
+	var coinbase struct {
+		Vout []struct {
+			Value        json.Number `json:"value"`
+			N            int         `json:"n"`
+			ScriptPubKey struct {
+				Hex string `json:"hex"`
+			} `json:"scriptPubKey"`
+		} `json:"vout"`
+	}
+	if err := json.Unmarshal(txData, &coinbase); err != nil {
+		return nil, fmt.Errorf("failed to parse coinbase of block %s: %w", stats.BlockHash, err)
+	}
+
+	result := &CoinbaseBlock{
+		Height:       height,
+		Hash:         stats.BlockHash,
+		CoinbaseTxID: block.Tx[0],
+		BlockSubsidy: stats.Subsidy,
+		BlockFees:    stats.TotalFee,
+	}
+	for _, vout := range coinbase.Vout {
+		satoshis, err := filter.ParseSatoshis(vout.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse coinbase output %d of block %s: %w", vout.N, stats.BlockHash, err)
+		}
+		result.CoinbaseTotal += satoshis
+
+		address, ok := scripts[vout.ScriptPubKey.Hex]
+		if !ok {
+			continue
+		}
+		subsidy := subsidyShare(satoshis, stats.Subsidy, stats.TotalFee)
+		result.Outputs = append(result.Outputs, CoinbaseOutput{
+			Address:  address,
+			Vout:     vout.N,
+			Satoshis: satoshis,
+			Subsidy:  subsidy,
+			Fees:     satoshis - subsidy,
+		})
+	}
+
+	if len(result.Outputs) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// subsidyShare returns the part of a coinbase output of satoshis taken from
+// the subsidy, in proportion to subsidy / (subsidy + fees), rounded down.
+// The product can overflow int64, so it is computed exactly.
+func subsidyShare(satoshis, subsidy, fees int64) int64 {
+	if subsidy+fees <= 0 {
+		return 0
+	}
+	share := new(big.Int).Mul(big.NewInt(satoshis), big.NewInt(subsidy))
+	share.Quo(share, big.NewInt(subsidy+fees))
+	return share.Int64()
+}
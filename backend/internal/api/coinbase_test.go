@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestCoinbaseFeesSplitsSubsidyAndFees(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	pool := rpctest.Address(testParams, "p2wpkh", 1)
+	partner := rpctest.Address(testParams, "p2tr", 2)
+	payee := rpctest.Address(testParams, "p2pkh", 3)
+	subsidy := s.chain.Subsidy(2)
+
+	fund := s.chain.NewTx(nil, rpctest.PayTo(payee, 100000), rpctest.PayTo(payee, 100000))
+	s.chain.AddBlock(fund)
+	// The pool takes the whole reward of a block paying 10000 sats in fees
+	solo := s.chain.AddBlockPaying([]*wire.TxOut{rpctest.PayTo(pool, subsidy+10000)},
+		s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(payee, 90000)))
+	// and three quarters of one paying 20000, sharing the rest
+	reward := subsidy + 20000
+	shared := s.chain.AddBlockPaying([]*wire.TxOut{rpctest.PayTo(pool, reward/4*3), rpctest.PayTo(partner, reward/4)},
+		s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(payee, 80000)))
+	// A block paying neither is left out
+	s.chain.AddBlock()
+
+	w := s.do(http.MethodGet, "/coinbase/fees?addresses="+pool.EncodeAddress()+","+partner.EncodeAddress()+"&start=0&end=4", nil)
+	expectStatus(t, w, http.StatusOK)
+	var result CoinbaseFeesResult
+	decode(t, w, &result)
+
+	if len(result.Blocks) != 2 || result.Blocks[0].Hash != solo.Hash || result.Blocks[1].Hash != shared.Hash {
+		t.Fatalf("blocks %+v, want the two paying blocks", result.Blocks)
+	}
+	first := result.Blocks[0]
+	if first.BlockSubsidy != subsidy || first.BlockFees != 10000 || first.CoinbaseTotal != subsidy+10000 || first.CoinbaseTxID != solo.TxIDs()[0] {
+		t.Errorf("first block %+v", first)
+	}
+	if len(first.Outputs) != 1 || first.Outputs[0] != (CoinbaseOutput{Address: pool.EncodeAddress(), Vout: 0, Satoshis: subsidy + 10000, Subsidy: subsidy, Fees: 10000}) {
+		t.Errorf("first block outputs %+v, want the whole subsidy and fees", first.Outputs)
+	}
+	wantShared := []CoinbaseOutput{
+		{Address: pool.EncodeAddress(), Vout: 0, Satoshis: reward / 4 * 3, Subsidy: subsidy / 4 * 3, Fees: 15000},
+		{Address: partner.EncodeAddress(), Vout: 1, Satoshis: reward / 4, Subsidy: subsidy / 4, Fees: 5000},
+	}
+	if outputs := result.Blocks[1].Outputs; len(outputs) != 2 || outputs[0] != wantShared[0] || outputs[1] != wantShared[1] {
+		t.Errorf("shared block outputs %+v, want %+v", outputs, wantShared)
+	}
+
+	wantTotal := CoinbaseTotals{Satoshis: 2*subsidy + 30000, Subsidy: 2 * subsidy, Fees: 30000, Blocks: 2}
+	if result.Total != wantTotal {
+		t.Errorf("total %+v, want %+v", result.Total, wantTotal)
+	}
+	if got := result.ByAddress[pool.EncodeAddress()]; got != (CoinbaseTotals{Satoshis: subsidy + 10000 + reward/4*3, Subsidy: subsidy + subsidy/4*3, Fees: 25000, Blocks: 2}) {
+		t.Errorf("pool totals %+v", got)
+	}
+	if got := result.ByAddress[partner.EncodeAddress()]; got != (CoinbaseTotals{Satoshis: reward / 4, Subsidy: subsidy / 4, Fees: 5000, Blocks: 1}) {
+		t.Errorf("partner totals %+v", got)
+	}
+}
+
+func TestSubsidyShareDoesNotOverflow(t *testing.T) {
+	// satoshis * subsidy is far beyond int64
+	if got := subsidyShare(2_000_000_000_000_000, 5_000_000_000, 5_000_000_000); got != 1_000_000_000_000_000 {
+		t.Errorf("got %d, want half", got)
+	}
+	if got := subsidyShare(1000, 0, 0); got != 0 {
+		t.Errorf("empty reward: got %d", got)
+	}
+}
+
+func TestCoinbaseFeesRejectsBadParams(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	s.chain.AddBlock()
+
+	for query, status := range map[string]int{
+		"start=0&end=1":                              http.StatusBadRequest,
+		"addresses=bogus&start=0&end=1":              http.StatusBadRequest,
+		"addresses=" + address + "&start=1&end=0":    http.StatusBadRequest,
+		"addresses=" + address + "&start=0&end=1000": http.StatusBadRequest,
+		"addresses=" + address + "&start=0&end=2":    http.StatusNotFound,
+	} {
+		if w := s.do(http.MethodGet, "/coinbase/fees?"+query, nil); w.Code != status {
+			t.Errorf("%s: status %d, want %d", query, w.Code, status)
+		}
+	}
+}
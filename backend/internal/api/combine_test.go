@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// txHex serializes a transaction
+func txHex(t *testing.T, tx *wire.MsgTx) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func TestCombineTxMergesMultisigSignatures(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+
+	key1, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32))
+	key2, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{2}, 32))
+	var pubKeys []*btcutil.AddressPubKey
+	for _, key := range []*btcec.PrivateKey{key1, key2} {
+		pubKey, err := btcutil.NewAddressPubKey(key.PubKey().SerializeCompressed(), testParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	redeem, err := txscript.MultiSigScript(pubKeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := btcutil.NewAddressScriptHash(redeem, testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fund := s.chain.NewTx(nil, rpctest.PayTo(address, 100000))
+	s.chain.AddBlock(fund)
+	spend := s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 99000))
+
+	// Each cosigner signs their own copy
+	signWith := func(key *btcec.PrivateKey) *wire.MsgTx {
+		tx := spend.Copy()
+		script, err := txscript.SignTxOutput(testParams, tx, 0, pkScript, txscript.SigHashAll,
+			txscript.KeyClosure(func(a btcutil.Address) (*btcec.PrivateKey, bool, error) {
+				if pubKey, ok := a.(*btcutil.AddressPubKey); ok && pubKey.PubKey().IsEqual(key.PubKey()) {
+					return key, true, nil
+				}
+				return nil, false, errors.New("no key")
+			}),
+			txscript.ScriptClosure(func(btcutil.Address) ([]byte, error) { return redeem, nil }), nil)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		tx.TxIn[0].SignatureScript = script
+		return tx
+	}
+	partial1, partial2 := signWith(key1), signWith(key2)
+
+	// A single signature does not satisfy the 2-of-2 script
+	if execute(partial1, pkScript) == nil {
+		t.Fatal("a partially signed copy spends the multisig output")
+	}
+
+	w := s.do(http.MethodPost, "/tx/combine", map[string]interface{}{"raw_txs": []string{txHex(t, partial1), txHex(t, partial2)}})
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Hex string `json:"hex"`
+	}
+	decode(t, w, &resp)
+
+	raw, err := hex.DecodeString(resp.Hex)
+	if err != nil {
+		t.Fatalf("combined hex: %v", err)
+	}
+	var combined wire.MsgTx
+	if err := combined.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("combined tx: %v", err)
+	}
+	unsigned := combined.Copy()
+	unsigned.TxIn[0].SignatureScript = nil
+	if unsigned.TxHash() != spend.TxHash() {
+		t.Errorf("combined tx does not sign the spend %s", spend.TxHash())
+	}
+	if err := execute(&combined, pkScript); err != nil {
+		t.Errorf("combined tx does not spend the multisig output: %v", err)
+	}
+	if s.node.Calls("combinerawtransaction") != 1 {
+		t.Errorf("combinerawtransaction called %d times, want 1", s.node.Calls("combinerawtransaction"))
+	}
+}
+
+// execute runs a transaction's first input against the output it spends
+func execute(tx *wire.MsgTx, pkScript []byte) error {
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 100000)
+	engine, err := txscript.NewEngine(pkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(tx, fetcher), 100000, fetcher)
+	if err != nil {
+		return err
+	}
+	return engine.Execute()
+}
+
+func TestCombineTxRejectsBadInput(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	tx := txHex(t, s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000)))
+
+	for name, rawTxs := range map[string][]string{
+		"one transaction":  {tx},
+		"invalid hex":      {tx, "zz"},
+		"not transactions": {tx, "0200"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/tx/combine", map[string]interface{}{"raw_txs": rawTxs})
+			expectStatus(t, w, http.StatusBadRequest)
+		})
+	}
+	if s.node.Calls("combinerawtransaction") != 0 {
+		t.Error("invalid requests reached the node")
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/contract"
+	"spv-backend/internal/rpctest"
+)
+
+const (
+	tokenContract   = "5c26651e9c97db61d8b5ca31f34d4ebae8498b12c3213797036657b176fe2583"
+	escrowContract  = "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	defaultContract = "0f0e0d0c0b0a09080706050403020100f0e0d0c0b0a090807060504030201000"
+	explicitAddress = "9999999999999999999999999999999999999999999999999999999999999999"
+)
+
+// newContractServer serves the API with named contracts and an optional
+// default, recording the contract address each callcontract and
+// dumpcontractmessage call reaches
+func newContractServer(t *testing.T, defaultContract string) (*testServer, *[]string) {
+	t.Helper()
+	s := newTestServer(t, nil, nil, func(s *testServer) {
+		s.handler.contractService = contract.NewService(s.handler.rpcClient, defaultContract)
+		s.handler.contractService.SetNamedContracts(map[string]string{"token": tokenContract, "escrow": escrowContract})
+	})
+	var called []string
+	record := func(params []json.RawMessage) (interface{}, error) {
+		var address string
+		if _, err := rpctest.Param(params, 0, &address); err != nil {
+			return nil, err
+		}
+		called = append(called, address)
+		return map[string]string{"contract": address}, nil
+	}
+	s.node.Handle("callcontract", record)
+	s.node.Handle("dumpcontractmessage", record)
+	return s, &called
+}
+
+func TestContractCallByName(t *testing.T) {
+	s, called := newContractServer(t, "")
+
+	tests := []struct {
+		path, name, want string
+	}{
+		{"/contract/call", "escrow", escrowContract},
+		{"/contract/query", "token", tokenContract},
+	}
+	for _, tt := range tests {
+		w := s.do(http.MethodPost, tt.path, map[string]interface{}{"contract": tt.name, "method": "balanceOf", "params": []string{"alice"}})
+		expectStatus(t, w, http.StatusOK)
+		var resp struct {
+			Result struct {
+				Contract string `json:"contract"`
+			} `json:"result"`
+		}
+		decode(t, w, &resp)
+		if resp.Result.Contract != tt.want {
+			t.Errorf("%s by name %q reached %s, want %s", tt.path, tt.name, resp.Result.Contract, tt.want)
+		}
+	}
+	if len(*called) != 2 {
+		t.Errorf("node received %d contract calls, want 2", len(*called))
+	}
+}
+
+func TestContractCallUnknownName(t *testing.T) {
+	s, called := newContractServer(t, "")
+
+	for _, path := range []string{"/contract/call", "/contract/query"} {
+		w := s.do(http.MethodPost, path, map[string]interface{}{"contract": "missing", "method": "balanceOf"})
+		expectStatus(t, w, http.StatusBadRequest)
+	}
+	if len(*called) != 0 {
+		t.Errorf("unknown contract names reached the node: %v", *called)
+	}
+}
+
+func TestContractResolutionOrder(t *testing.T) {
+	s, called := newContractServer(t, defaultContract)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want string
+	}{
+		{"explicit address wins over a name", map[string]interface{}{"address": explicitAddress, "contract": "token"}, explicitAddress},
+		{"name wins over the default", map[string]interface{}{"contract": "escrow"}, escrowContract},
+		{"default when neither is given", map[string]interface{}{}, defaultContract},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.body["method"] = "balanceOf"
+			for _, path := range []string{"/contract/call", "/contract/query"} {
+				*called = nil
+				expectStatus(t, s.do(http.MethodPost, path, tt.body), http.StatusOK)
+				if len(*called) != 1 || (*called)[0] != tt.want {
+					t.Errorf("%s reached %v, want %s", path, *called, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestContractResolutionWithoutDefault(t *testing.T) {
+	s, called := newContractServer(t, "")
+
+	w := s.do(http.MethodPost, "/contract/call", map[string]interface{}{"method": "balanceOf"})
+	expectStatus(t, w, http.StatusBadRequest)
+	var resp struct {
+		Error string `json:"error"`
+	}
+	decode(t, w, &resp)
+	if resp.Error != contract.ErrNoContract.Error() {
+		t.Errorf("error %q, want %q", resp.Error, contract.ErrNoContract)
+	}
+	if len(*called) != 0 {
+		t.Errorf("an unresolved contract reached the node: %v", *called)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMiddleware returns a CORS middleware for the given allowed origins.
+// Preflight responses are cacheable for maxAge seconds, and Vary: Origin is
+// always set so shared caches don't serve one origin's headers to another.
+func corsMiddleware(allowedOrigins []string, maxAge int) gin.HandlerFunc {
+	allowAny := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[origin] = true
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		origin := c.Request.Header.Get("Origin")
+		switch {
+		case allowAny:
+			header.Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		header.Set("Access-Control-Allow-Credentials", "true")
+		header.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		header.Set("Access-Control-Expose-Headers", "X-Request-ID")
+		header.Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+
+		if c.Request.Method == http.MethodOptions {
+			if maxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/config"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	s := newTestServer(t, &config.Config{CORSAllowedOrigins: []string{"https://wallet.example"}, CORSMaxAge: 600}, nil, nil)
+
+	w := s.do(http.MethodOptions, "/utxos/scan", nil, "Origin", "https://wallet.example", "Access-Control-Request-Method", "POST")
+	expectStatus(t, w, http.StatusNoContent)
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age %q, want 600", got)
+	}
+	if got := w.Header().Values("Vary"); len(got) != 1 || got[0] != "Origin" {
+		t.Errorf("Vary %q, want Origin", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://wallet.example" {
+		t.Errorf("Access-Control-Allow-Origin %q, want the allowed origin", got)
+	}
+
+	// Other origins are not allowed, but the response still varies by origin
+	w = s.do(http.MethodOptions, "/utxos/scan", nil, "Origin", "https://other.example")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin %q for an origin not allowed", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary %q for an origin not allowed, want Origin", got)
+	}
+
+	// Plain requests vary by origin too, and carry no Max-Age
+	w = s.do(http.MethodGet, "/health", nil, "Origin", "https://wallet.example")
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary %q on a GET, want Origin", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age %q on a GET", got)
+	}
+}
+
+func TestCORSMaxAgeDisabled(t *testing.T) {
+	s := newTestServer(t, &config.Config{CORSAllowedOrigins: []string{"*"}}, nil, nil)
+
+	w := s.do(http.MethodOptions, "/utxos/scan", nil, "Origin", "https://wallet.example")
+	expectStatus(t, w, http.StatusNoContent)
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age %q with no max age configured", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin %q, want *", got)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"spv-backend/internal/filter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csvContentType is the Accept value that selects a CSV scan response
+const csvContentType = "text/csv"
+
+// scanErrorTrailer reports a scan that failed after CSV rows were sent
+const scanErrorTrailer = "X-Scan-Error"
+
+// csvHeader is the header row of a CSV scan response
+var csvHeader = []string{"txid", "vout", "address", "satoshis", "height", "confirmations"}
+
+// wantsCSV reports whether the client asked for a CSV response
+func wantsCSV(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), csvContentType)
+}
+
+// csvStream writes a scan as CSV, one row per UTXO, flushing after each row.
+// As with ndjsonStream, a scan that fails before any row is answered with a
+// JSON error. A failure after rows were sent can only be reported in the
+// X-Scan-Error trailer, so clients must check it before trusting the rows.
+type csvStream struct {
+	c       *gin.Context
+	writer  *csv.Writer
+	started bool
+}
+
+func newCSVStream(c *gin.Context) *csvStream {
+	return &csvStream{c: c, writer: csv.NewWriter(c.Writer)}
+}
+
+// start sends the headers and the header row
+func (s *csvStream) start() error {
+	s.started = true
+	s.c.Header("Content-Type", csvContentType+"; charset=utf-8")
+	s.c.Header("Content-Disposition", `attachment; filename="utxos.csv"`)
+	s.c.Header("Trailer", scanErrorTrailer)
+	s.c.Status(http.StatusOK)
+	return s.write(csvHeader)
+}
+
+// write sends one row
+func (s *csvStream) write(row []string) error {
+	if err := s.writer.Write(row); err != nil {
+		return err
+	}
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// SendUTXO writes a UTXO row; it is used as filter.ScanOptions.OnUTXO
+func (s *csvStream) SendUTXO(utxo filter.UTXO) error {
+	if !s.started {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	return s.write([]string{
+		utxo.TxID,
+		strconv.Itoa(utxo.Vout),
+		utxo.Address,
+		strconv.FormatInt(utxo.Satoshis, 10),
+		strconv.FormatInt(utxo.Height, 10),
+		strconv.FormatInt(utxo.Confirmations, 10),
+	})
+}
+
+// Finish ends the response: a scan without UTXOs still gets the header row
+func (s *csvStream) Finish(result *filter.UTXOScanResult, err error) {
+	if err != nil {
+		if !s.started {
+			line := gin.H{"error": err.Error()}
+			if result != nil {
+				line["summary"] = scanStreamSummary{UTXOScanResult: result}
+			}
+			s.c.JSON(http.StatusInternalServerError, line)
+			return
+		}
+		s.c.Writer.Header().Set(scanErrorTrailer, err.Error())
+		return
+	}
+	if !s.started {
+		s.start()
+	}
+}
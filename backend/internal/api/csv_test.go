@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanStreamsCSV(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2pkh", 2)
+
+	var funds []*wire.MsgTx
+	for i := 0; i < 5; i++ {
+		tx := s.chain.NewTx(nil, rpctest.PayTo(a, int64(1000+i)), rpctest.PayTo(b, int64(2000+i)))
+		funds = append(funds, tx)
+		s.chain.AddBlock(tx)
+	}
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funds[1], 0)}))
+
+	body := scanBody([]string{a.EncodeAddress(), b.EncodeAddress()}, 0, s.chain.Height(), nil)
+	w := s.do(http.MethodPost, "/utxos/scan", body)
+	expectStatus(t, w, http.StatusOK)
+	var buffered filter.UTXOScanResult
+	decode(t, w, &buffered)
+	if buffered.TotalUTXOs != 9 {
+		t.Fatalf("buffered scan found %d UTXOs, want 9", buffered.TotalUTXOs)
+	}
+
+	w = s.do(http.MethodPost, "/utxos/scan", body, "Accept", csvContentType)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, csvContentType) {
+		t.Errorf("content type %q", ct)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if len(rows) == 0 || !reflect.DeepEqual(rows[0], []string{"txid", "vout", "address", "satoshis", "height", "confirmations"}) {
+		t.Fatalf("header row %v", rows)
+	}
+
+	// One row per UTXO, matching the JSON result
+	want := make(map[string][]string)
+	for _, utxo := range buffered.UTXOs {
+		want[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)] = []string{
+			utxo.TxID,
+			strconv.Itoa(utxo.Vout),
+			utxo.Address,
+			strconv.FormatInt(utxo.Satoshis, 10),
+			strconv.FormatInt(utxo.Height, 10),
+			strconv.FormatInt(utxo.Confirmations, 10),
+		}
+	}
+	if len(rows)-1 != len(want) {
+		t.Fatalf("%d rows, want %d", len(rows)-1, len(want))
+	}
+	for _, row := range rows[1:] {
+		key := row[0] + ":" + row[1]
+		if !reflect.DeepEqual(row, want[key]) {
+			t.Errorf("row %v, want %v", row, want[key])
+		}
+		delete(want, key)
+	}
+}
+
+func TestScanCSVWithoutUTXOsHasHeader(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, s.chain.Height(), nil), "Accept", csvContentType)
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); got != "txid,vout,address,satoshis,height,confirmations\n" {
+		t.Errorf("body %q, want only the header row", got)
+	}
+}
+
+func TestScanCSVRejectsBufferedOptions(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 0, map[string]interface{}{"sort": "height"}), "Accept", csvContentType)
+	expectStatus(t, w, http.StatusBadRequest)
+}
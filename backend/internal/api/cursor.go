@@ -0,0 +1,207 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"spv-backend/internal/filter"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// maxScanPageSize caps the number of UTXOs returned per scan page
+const maxScanPageSize = 1000
+
+// scanCursor is the resume point of a paginated scan
+type scanCursor struct {
+	LastHeight  int64  `json:"h"`
+	LastTxID    string `json:"t"`
+	LastVout    int    `json:"v"`
+	RequestHash string `json:"r"` // Binds the cursor to the request parameters
+
+	// Totals of the whole result as of the first page, which later pages
+	// report again since they only scan from LastHeight
+	TotalUTXOs    int   `json:"n"`
+	TotalSatoshis int64 `json:"s"`
+}
+
+// errInvalidCursor is returned for malformed, tampered, or mismatched cursors
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor serializes the cursor as "payload.signature", both base64url
+// encoded, where the signature is an HMAC-SHA256 of the payload
+func encodeCursor(secret []byte, cursor scanCursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// decodeCursor verifies the cursor signature and that it was issued for a
+// request with the same parameters
+func decodeCursor(secret []byte, token string, requestHash string) (*scanCursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errInvalidCursor
+	}
+
+	var cursor scanCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	if cursor.RequestHash != requestHash {
+		return nil, fmt.Errorf("%w: cursor does not match request parameters", errInvalidCursor)
+	}
+
+	return &cursor, nil
+}
+
+// scanRequestHash hashes the parameters that define a scan's result set,
+// with the addresses given by their filter.Service.AddressSetKey.
+// Pagination fields are excluded so every page of a scan shares the hash.
+func scanRequestHash(req *UTXOScanRequest, addressSetKey string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "a:%s\n", addressSetKey)
+	for _, d := range req.Descriptors {
+		fmt.Fprintf(h, "d:%s:%d:%d\n", d.Descriptor, d.RangeStart, d.RangeEnd)
+	}
+	if req.StartHeight != nil && req.EndHeight != nil {
+		fmt.Fprintf(h, "r:%d:%d\n", *req.StartHeight, *req.EndHeight)
+	}
+	if req.IncludeMempoolSpends != nil && !*req.IncludeMempoolSpends {
+		fmt.Fprintf(h, "m:confirmed\n")
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Scan result orders accepted by UTXOScanRequest.Sort
+const (
+	scanSortHeight    = "height"     // Chain order: (height, txid, vout)
+	scanSortValueDesc = "value_desc" // Largest first
+	scanSortValueAsc  = "value_asc"  // Smallest first
+)
+
+// validScanSort reports whether order is a supported scan sort ("" means height)
+func validScanSort(order string) bool {
+	switch order {
+	case "", scanSortHeight, scanSortValueDesc, scanSortValueAsc:
+		return true
+	}
+	return false
+}
+
+// utxoChainLess orders UTXOs by (height, txid, vout)
+func utxoChainLess(a, b filter.UTXO) bool {
+	if a.Height != b.Height {
+		return a.Height < b.Height
+	}
+	if a.TxID != b.TxID {
+		return a.TxID < b.TxID
+	}
+	return a.Vout < b.Vout
+}
+
+// sortUTXOs sorts the UTXOs in place by the given order. Equal values fall
+// back to chain order so every order is deterministic.
+func sortUTXOs(utxos []filter.UTXO, order string) {
+	sort.Slice(utxos, func(i, j int) bool {
+		a, b := utxos[i], utxos[j]
+		switch order {
+		case scanSortValueDesc:
+			if a.Satoshis != b.Satoshis {
+				return a.Satoshis > b.Satoshis
+			}
+		case scanSortValueAsc:
+			if a.Satoshis != b.Satoshis {
+				return a.Satoshis < b.Satoshis
+			}
+		}
+		return utxoChainLess(a, b)
+	})
+}
+
+// utxoAfter reports whether the UTXO sorts after the cursor position
+func utxoAfter(utxo filter.UTXO, cursor *scanCursor) bool {
+	if utxo.Height != cursor.LastHeight {
+		return utxo.Height > cursor.LastHeight
+	}
+	if utxo.TxID != cursor.LastTxID {
+		return utxo.TxID > cursor.LastTxID
+	}
+	return utxo.Vout > cursor.LastVout
+}
+
+// paginateScanResult orders the UTXOs by (height, txid, vout), keeps the page
+// following the cursor, and sets the next cursor when more UTXOs remain. The
+// totals stay those of the whole result, carried by the cursor after the
+// first page.
+func paginateScanResult(secret []byte, result *filter.UTXOScanResult, after *scanCursor, limit int, requestHash string) error {
+	sortUTXOs(result.UTXOs, scanSortHeight)
+
+	totalUTXOs, totalSatoshis := result.TotalUTXOs, result.TotalSatoshis
+	if after != nil {
+		totalUTXOs, totalSatoshis = after.TotalUTXOs, after.TotalSatoshis
+	}
+
+	page := result.UTXOs
+	if after != nil {
+		start := sort.Search(len(page), func(i int) bool { return utxoAfter(page[i], after) })
+		page = page[start:]
+	}
+
+	result.NextCursor = ""
+	if len(page) > limit {
+		page = page[:limit]
+		last := page[len(page)-1]
+		next, err := encodeCursor(secret, scanCursor{
+			LastHeight:  last.Height,
+			LastTxID:    last.TxID,
+			LastVout:    last.Vout,
+			RequestHash: requestHash,
+
+			TotalUTXOs:    totalUTXOs,
+			TotalSatoshis: totalSatoshis,
+		})
+		if err != nil {
+			return err
+		}
+		result.NextCursor = next
+	}
+
+	result.UTXOs = page
+	result.TotalUTXOs = totalUTXOs
+	result.TotalSatoshis = totalSatoshis
+	result.TotalAmount = float64(totalSatoshis) / btcutil.SatoshiPerBitcoin
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+// newPagingServer serves a chain with five UTXOs paying address over
+// heights 1-4
+func newPagingServer(t *testing.T) (*testServer, string) {
+	address := rpctest.Address(testParams, "p2wsh", 1)
+	s := newTestServer(t, &config.Config{CursorSecret: "cursor secret"}, nil, nil)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 100), rpctest.PayTo(address, 200)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 300)))
+	s.chain.AddBlock()
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 400), rpctest.PayTo(address, 500)))
+	return s, address.EncodeAddress()
+}
+
+func TestScanPagesKeepTotals(t *testing.T) {
+	s, address := newPagingServer(t)
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 4, nil))
+	expectStatus(t, w, http.StatusOK)
+	var full filter.UTXOScanResult
+	decode(t, w, &full)
+	if full.TotalUTXOs != 5 || full.TotalSatoshis != 1500 {
+		t.Fatalf("full scan: got %d UTXOs, %d sats", full.TotalUTXOs, full.TotalSatoshis)
+	}
+
+	var paged []filter.UTXO
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages == 5 {
+			t.Fatal("pagination does not end")
+		}
+		extra := map[string]interface{}{"limit": 2}
+		if cursor != "" {
+			extra["cursor"] = cursor
+		}
+		w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 4, extra))
+		expectStatus(t, w, http.StatusOK)
+		var page filter.UTXOScanResult
+		decode(t, w, &page)
+
+		if page.TotalUTXOs != 5 || page.TotalSatoshis != 1500 || page.TotalAmount != 0.000015 {
+			t.Fatalf("page %d: got totals %d UTXOs, %d sats, %v BTC", pages, page.TotalUTXOs, page.TotalSatoshis, page.TotalAmount)
+		}
+		if len(page.UTXOs) > 2 {
+			t.Fatalf("page %d has %d UTXOs", pages, len(page.UTXOs))
+		}
+		paged = append(paged, page.UTXOs...)
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	if len(paged) != len(full.UTXOs) {
+		t.Fatalf("pages hold %d UTXOs, the full scan %d", len(paged), len(full.UTXOs))
+	}
+	for i := range paged {
+		if paged[i].TxID != full.UTXOs[i].TxID || paged[i].Vout != full.UTXOs[i].Vout {
+			t.Fatalf("UTXO %d: paged %s:%d, full %s:%d", i, paged[i].TxID, paged[i].Vout, full.UTXOs[i].TxID, full.UTXOs[i].Vout)
+		}
+	}
+}
+
+func TestScanRejectsBadCursors(t *testing.T) {
+	s, address := newPagingServer(t)
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 4, map[string]interface{}{"limit": 2}))
+	expectStatus(t, w, http.StatusOK)
+	var first filter.UTXOScanResult
+	decode(t, w, &first)
+	if first.NextCursor == "" {
+		t.Fatal("no cursor after the first page")
+	}
+
+	// Rewrite the payload to skip ahead, keeping the signature
+	parts := strings.Split(first.NextCursor, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	payload = []byte(strings.Replace(string(payload), `"h":1`, `"h":4`, 1))
+	tampered := base64.RawURLEncoding.EncodeToString(payload) + "." + parts[1]
+
+	other := rpctest.Address(testParams, "p2wsh", 2).EncodeAddress()
+	tests := map[string]map[string]interface{}{
+		"tampered":        scanBody([]string{address}, 0, 4, map[string]interface{}{"limit": 2, "cursor": tampered}),
+		"garbage":         scanBody([]string{address}, 0, 4, map[string]interface{}{"limit": 2, "cursor": "not a cursor"}),
+		"other range":     scanBody([]string{address}, 0, 3, map[string]interface{}{"limit": 2, "cursor": first.NextCursor}),
+		"other addresses": scanBody([]string{address, other}, 0, 4, map[string]interface{}{"limit": 2, "cursor": first.NextCursor}),
+		"confirmed only":  scanBody([]string{address}, 0, 4, map[string]interface{}{"limit": 2, "cursor": first.NextCursor, "include_mempool_spends": false}),
+		"without a limit": scanBody([]string{address}, 0, 4, map[string]interface{}{"cursor": first.NextCursor}),
+	}
+	for name, body := range tests {
+		w := s.do(http.MethodPost, "/utxos/scan", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	// A cursor issued under another secret does not verify
+	s.handler.config.CursorSecret = "rotated"
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 4, map[string]interface{}{"limit": 2, "cursor": first.NextCursor}))
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestScanCursorIgnoresAddressOrder(t *testing.T) {
+	s, address := newPagingServer(t)
+	other := rpctest.Address(testParams, "p2wpkh", 2).EncodeAddress()
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address, other}, 0, 4, map[string]interface{}{"limit": 2}))
+	expectStatus(t, w, http.StatusOK)
+	var first filter.UTXOScanResult
+	decode(t, w, &first)
+	if first.NextCursor == "" {
+		t.Fatal("no cursor after the first page")
+	}
+
+	// The same set reordered, duplicated and upper-cased continues the scan
+	for _, addresses := range [][]string{
+		{other, address},
+		{strings.ToUpper(other), address, address},
+	} {
+		w = s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, 4, map[string]interface{}{"limit": 2, "cursor": first.NextCursor}))
+		expectStatus(t, w, http.StatusOK)
+	}
+
+	// Another set does not
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 4, map[string]interface{}{"limit": 2, "cursor": first.NextCursor}))
+	expectStatus(t, w, http.StatusBadRequest)
+}
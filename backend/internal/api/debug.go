@@ -0,0 +1,225 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// processStart is when the server started, for the bundle's uptime
+var processStart = time.Now()
+
+// maxRecentScans is how many finished scan requests GET /debug/bundle lists
+const maxRecentScans = 20
+
+// recentScan is a finished request to a scan route
+type recentScan struct {
+	Route      string    `json:"route"`
+	Status     int       `json:"status"`
+	DurationMs int64     `json:"duration_ms"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// scanHistory keeps the most recent scan requests. It is safe for concurrent use.
+type scanHistory struct {
+	mu    sync.Mutex
+	scans []recentScan // Oldest first
+}
+
+// Record adds a finished scan, dropping the oldest beyond maxRecentScans
+func (s *scanHistory) Record(scan recentScan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scans = append(s.scans, scan)
+	if len(s.scans) > maxRecentScans {
+		s.scans = s.scans[len(s.scans)-maxRecentScans:]
+	}
+}
+
+// Recent returns a copy of the recorded scans, newest first
+func (s *scanHistory) Recent() []recentScan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make([]recentScan, len(s.scans))
+	for i, scan := range s.scans {
+		recent[len(s.scans)-1-i] = scan
+	}
+	return recent
+}
+
+// scanHistoryMiddleware records requests to routes in the scan timeout
+// category (see routeTimeoutCategories)
+func scanHistoryMiddleware(history *scanHistory) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		if routeTimeoutCategories[route] != timeoutScan {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+		history.Record(recentScan{
+			Route:      route,
+			Status:     c.Writer.Status(),
+			DurationMs: time.Since(start).Milliseconds(),
+			FinishedAt: time.Now(),
+		})
+	}
+}
+
+// debugKeyHeader carries DEBUG_API_KEY. It is not X-API-Key, which API key
+// authentication reads for the same request, so both keys can be sent.
+const debugKeyHeader = "X-Debug-Key"
+
+// debugGate restricts /debug/* routes to deployments that enable them, and
+// to callers presenting the configured key when one is set
+func (h *Handler) debugGate(c *gin.Context) {
+	if !h.config.DebugEndpoints {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	if h.config.DebugAPIKey != "" {
+		key := c.GetHeader(debugKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(key), []byte(h.config.DebugAPIKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing " + debugKeyHeader})
+			return
+		}
+	}
+
+	c.Next()
+}
+
+// GetDebugConfig handles GET /debug/config
+// Returns the effective configuration with secrets redacted
+func (h *Handler) GetDebugConfig(c *gin.Context) {
+	params := h.filterService.ChainParams()
+
+	c.JSON(http.StatusOK, gin.H{
+		"config": h.config.Redacted(),
+		"chain_params": gin.H{
+			"name":         params.Name,
+			"bech32_hrp":   params.Bech32HRPSegwit,
+			"default_port": params.DefaultPort,
+		},
+	})
+}
+
+// GetDebugBundle handles GET /debug/bundle
+// Returns a one-shot diagnostic for bug reports: the redacted configuration,
+// the node's version, chain and sync state, recent scan requests, filter
+// cache sizes, watch, tracked transaction and event counts, and process
+// runtime stats. A section that cannot be read carries an error instead.
+func (h *Handler) GetDebugBundle(c *gin.Context) {
+	params := h.filterService.ChainParams()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	c.JSON(http.StatusOK, gin.H{
+		"generated_at": time.Now().UTC(),
+		"config":       h.config.Redacted(),
+		"chain_params": gin.H{
+			"name":         params.Name,
+			"bech32_hrp":   params.Bech32HRPSegwit,
+			"default_port": params.DefaultPort,
+		},
+		"node":         h.bundleNode(c),
+		"recent_scans": h.scans.Recent(),
+		"cache":        h.bundleCache(),
+		"subscribers":  h.bundleSubscribers(),
+		"runtime": gin.H{
+			"go_version":     runtime.Version(),
+			"goroutines":     runtime.NumGoroutine(),
+			"uptime_seconds": int64(time.Since(processStart).Seconds()),
+			"heap_alloc":     memStats.HeapAlloc,
+			"num_gc":         memStats.NumGC,
+		},
+	})
+}
+
+// bundleNode reads the node's version and chain state for the bundle
+func (h *Handler) bundleNode(c *gin.Context) gin.H {
+	node := gin.H{}
+
+	if data, err := h.rpcFor(c).GetNetworkInfo(); err != nil {
+		node["network_error"] = err.Error()
+	} else {
+		var info struct {
+			Version         int    `json:"version"`
+			Subversion      string `json:"subversion"`
+			ProtocolVersion int    `json:"protocolversion"`
+			Connections     int    `json:"connections"`
+		}
+		if err := json.Unmarshal(data, &info); err != nil {
+			node["network_error"] = "failed to parse network info"
+		} else {
+			node["version"] = info.Version
+			node["subversion"] = info.Subversion
+			node["protocol_version"] = info.ProtocolVersion
+			node["connections"] = info.Connections
+		}
+	}
+
+	if data, err := h.rpcFor(c).GetBlockchainInfo(); err != nil {
+		node["chain_error"] = err.Error()
+	} else {
+		var info struct {
+			Chain                string  `json:"chain"`
+			Blocks               int64   `json:"blocks"`
+			Headers              int64   `json:"headers"`
+			BestBlockHash        string  `json:"bestblockhash"`
+			VerificationProgress float64 `json:"verificationprogress"`
+			InitialBlockDownload bool    `json:"initialblockdownload"`
+			Pruned               bool    `json:"pruned"`
+		}
+		if err := json.Unmarshal(data, &info); err != nil {
+			node["chain_error"] = "failed to parse blockchain info"
+		} else {
+			node["chain"] = info.Chain
+			node["blocks"] = info.Blocks
+			node["headers"] = info.Headers
+			node["best_block_hash"] = info.BestBlockHash
+			node["verification_progress"] = info.VerificationProgress
+			node["initial_block_download"] = info.InitialBlockDownload
+			node["pruned"] = info.Pruned
+		}
+	}
+
+	return node
+}
+
+// bundleCache reports the filter cache's size per namespace
+func (h *Handler) bundleCache() gin.H {
+	store := h.filterService.Cache()
+	if store == nil {
+		return gin.H{"enabled": false}
+	}
+	stats, err := store.Stats()
+	if err != nil {
+		return gin.H{"enabled": true, "error": err.Error()}
+	}
+	return gin.H{"enabled": true, "namespaces": stats}
+}
+
+// bundleSubscribers counts what the background workers are following; a
+// nil count means the feature is disabled
+func (h *Handler) bundleSubscribers() gin.H {
+	subscribers := gin.H{"watches": nil, "tracked_transactions": nil, "events": nil}
+	if h.watchManager != nil {
+		subscribers["watches"] = h.watchManager.Count()
+	}
+	if h.txTracker != nil {
+		subscribers["tracked_transactions"] = h.txTracker.Count()
+	}
+	if h.events != nil {
+		subscribers["events"] = h.events.Len()
+	}
+	return subscribers
+}
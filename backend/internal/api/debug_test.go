@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/auth"
+	"spv-backend/internal/rpctest"
+)
+
+func TestDebugConfigRedactsSecrets(t *testing.T) {
+	cfg := &config.Config{
+		RPCHost:        "node.internal",
+		RPCPassword:    "rpc password",
+		JWTSecret:      "jwt secret",
+		DebugEndpoints: true,
+	}
+	s := newTestServer(t, cfg, nil, nil)
+
+	w := s.do(http.MethodGet, "/debug/config", nil)
+	expectStatus(t, w, http.StatusOK)
+	var body struct {
+		Config      map[string]interface{} `json:"config"`
+		ChainParams map[string]interface{} `json:"chain_params"`
+	}
+	decode(t, w, &body)
+
+	for _, field := range []string{"RPCPassword", "JWTSecret"} {
+		if body.Config[field] != "[REDACTED]" {
+			t.Errorf("%s: got %v", field, body.Config[field])
+		}
+	}
+	if body.Config["RPCHost"] != "node.internal" || body.Config["DebugEndpoints"] != true {
+		t.Errorf("non-secret fields missing: %v", body.Config)
+	}
+	if body.ChainParams["name"] != testParams.Name {
+		t.Errorf("chain params %v", body.ChainParams)
+	}
+}
+
+func TestDebugGate(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		s := newTestServer(t, nil, nil, nil)
+		expectStatus(t, s.do(http.MethodGet, "/debug/config", nil), http.StatusNotFound)
+	})
+
+	// With API key authentication, a caller sends its key and the debug key
+	// side by side
+	cfg := &config.Config{DebugEndpoints: true, DebugAPIKey: "debug key"}
+	s := newTestServer(t, cfg, auth.NewAPIKeyAuthenticator([]string{"api key"}), nil)
+	tests := []struct {
+		name    string
+		headers []string
+		status  int
+	}{
+		{"both keys", []string{"X-API-Key", "api key", "X-Debug-Key", "debug key"}, http.StatusOK},
+		{"bearer and debug key", []string{"Authorization", "Bearer api key", "X-Debug-Key", "debug key"}, http.StatusOK},
+		{"no debug key", []string{"X-API-Key", "api key"}, http.StatusUnauthorized},
+		{"wrong debug key", []string{"X-API-Key", "api key", "X-Debug-Key", "api key"}, http.StatusUnauthorized},
+		{"debug key as API key", []string{"X-API-Key", "debug key"}, http.StatusUnauthorized},
+		{"no API key", []string{"X-Debug-Key", "debug key"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := s.do(http.MethodGet, "/debug/config", nil, tt.headers...)
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
+
+func TestDebugBundle(t *testing.T) {
+	secrets := []string{"rpc password", "api key", "jwt secret", "debug key", "cursor secret", strings.Repeat("07", 32)}
+	cfg := &config.Config{
+		RPCHost:            "node.internal",
+		RPCPassword:        secrets[0],
+		APIKeys:            []string{secrets[1]},
+		JWTSecret:          secrets[2],
+		DebugEndpoints:     true,
+		DebugAPIKey:        secrets[3],
+		CursorSecret:       secrets[4],
+		SnapshotSigningKey: secrets[5],
+	}
+	s := newTestServer(t, cfg, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	expectStatus(t, s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, 1, nil)), http.StatusOK)
+
+	w := s.do(http.MethodGet, "/debug/bundle", nil, "X-Debug-Key", "debug key")
+	expectStatus(t, w, http.StatusOK)
+	for _, secret := range secrets {
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("bundle leaks %q", secret)
+		}
+	}
+
+	var bundle map[string]json.RawMessage
+	decode(t, w, &bundle)
+	for _, section := range []string{"generated_at", "config", "chain_params", "node", "recent_scans", "cache", "subscribers", "runtime"} {
+		if _, ok := bundle[section]; !ok {
+			t.Errorf("bundle has no %s section", section)
+		}
+	}
+
+	var sections struct {
+		Config      map[string]interface{} `json:"config"`
+		ChainParams map[string]interface{} `json:"chain_params"`
+		Node        map[string]interface{} `json:"node"`
+		RecentScans []recentScan           `json:"recent_scans"`
+		Cache       map[string]interface{} `json:"cache"`
+		Subscribers map[string]interface{} `json:"subscribers"`
+	}
+	decode(t, w, &sections)
+	if sections.Config["RPCHost"] != "node.internal" || sections.Config["RPCPassword"] != "[REDACTED]" {
+		t.Errorf("config %v", sections.Config)
+	}
+	if sections.ChainParams["name"] != testParams.Name {
+		t.Errorf("chain params %v", sections.ChainParams)
+	}
+	if sections.Node["version"] != float64(rpctest.NodeVersion) || sections.Node["blocks"] != float64(1) || sections.Node["chain"] != "regtest" {
+		t.Errorf("node %v", sections.Node)
+	}
+	if len(sections.RecentScans) != 1 || sections.RecentScans[0].Route != "POST /utxos/scan" || sections.RecentScans[0].Status != http.StatusOK {
+		t.Errorf("recent scans %+v, want the scan", sections.RecentScans)
+	}
+	if sections.Cache["enabled"] != false {
+		t.Errorf("cache %v, want disabled", sections.Cache)
+	}
+	for _, name := range []string{"watches", "tracked_transactions", "events"} {
+		if value, ok := sections.Subscribers[name]; !ok || value != nil {
+			t.Errorf("subscribers %v, want %s disabled", sections.Subscribers, name)
+		}
+	}
+
+	// Gated like the other debug routes
+	expectStatus(t, s.do(http.MethodGet, "/debug/bundle", nil), http.StatusUnauthorized)
+}
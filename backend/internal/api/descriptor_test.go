@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+func TestDescriptorInfoEndpoint(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+
+	w := s.do(http.MethodPost, "/descriptor/info", map[string]string{"descriptor": "raw(deadbeef)"})
+	expectStatus(t, w, http.StatusOK)
+	var info filter.DescriptorInfo
+	decode(t, w, &info)
+	if info.Descriptor != "raw(deadbeef)#89f8spxm" || info.Checksum != "89f8spxm" {
+		t.Errorf("got %+v, want the descriptor with checksum 89f8spxm", info)
+	}
+
+	// A checksum the node rejects is the client's mistake
+	w = s.do(http.MethodPost, "/descriptor/info", map[string]string{"descriptor": "raw(deadbeef)#aaaaaaaa"})
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestScanRejectsDerivationsOverCap(t *testing.T) {
+	s := newTestServer(t, nil, nil, func(s *testServer) {
+		s.handler.filterService.SetMaxDerivedAddresses(100)
+	})
+	xpub := "tpubD6NzVbkrYhZ4XgiXtGrdW5XDAPFCL9h7we1vwNCpn8tGbBcgfVYjXyhWo4E1xkh56hjod1RhGjxbaTLV3X4FyWuejifB9jusQ46QzG87VKp"
+
+	w := s.do(http.MethodPost, "/utxos/scan", map[string]interface{}{
+		"descriptors": []map[string]interface{}{
+			{"descriptor": "wpkh(" + xpub + "/0/*)", "range_start": 0, "range_end": 60},
+			{"descriptor": "wpkh(" + xpub + "/1/*)", "range_start": 0, "range_end": 60},
+		},
+		"start_height": 0,
+		"end_height":   0,
+	})
+	expectStatus(t, w, http.StatusBadRequest)
+	var resp struct {
+		Error string `json:"error"`
+	}
+	decode(t, w, &resp)
+	if !strings.Contains(resp.Error, "too many derived addresses") {
+		t.Errorf("error %q, want the derivation cap", resp.Error)
+	}
+	if s.node.Calls("deriveaddresses") != 0 {
+		t.Error("addresses were derived past the cap")
+	}
+}
+
+func TestScanReturnScripts(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+	xpub := "tpubD6NzVbkrYhZ4XgiXtGrdW5XDAPFCL9h7we1vwNCpn8tGbBcgfVYjXyhWo4E1xkh56hjod1RhGjxbaTLV3X4FyWuejifB9jusQ46QzG87VKp"
+	descriptor := "wpkh(" + xpub + "/0/*)"
+
+	data, err := s.handler.rpcClient.Call("deriveaddresses", descriptor, []int{0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var derived []string
+	if err := json.Unmarshal(data, &derived); err != nil {
+		t.Fatal(err)
+	}
+	explicit := rpctest.Address(testParams, "p2tr", 1)
+
+	// One derived address is also listed explicitly, in upper case
+	w := s.do(http.MethodPost, "/utxos/scan", map[string]interface{}{
+		"addresses":      []string{explicit.EncodeAddress(), strings.ToUpper(derived[1])},
+		"descriptors":    []map[string]interface{}{{"descriptor": descriptor, "range_start": 0, "range_end": 2}},
+		"start_height":   0,
+		"end_height":     1,
+		"return_scripts": true,
+	})
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Scripts     []string `json:"scripts"`
+		ScriptCount int      `json:"script_count"`
+	}
+	decode(t, w, &resp)
+
+	want := map[string]bool{}
+	for _, address := range append([]string{explicit.EncodeAddress()}, derived...) {
+		script, err := s.handler.filterService.AddressToScriptPubKey(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[hex.EncodeToString(script)] = true
+	}
+	got := map[string]bool{}
+	for _, script := range resp.Scripts {
+		got[script] = true
+	}
+	if resp.ScriptCount != len(want) || len(resp.Scripts) != len(want) || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d scripts %v, want %v", resp.ScriptCount, resp.Scripts, want)
+	}
+
+	// Nothing is scanned
+	if s.node.Calls("getblock") != 0 || s.node.Calls("getblockfilter") != 0 || s.node.Calls("gettxout") != 0 {
+		t.Error("return_scripts ran the scan")
+	}
+}
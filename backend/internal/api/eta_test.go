@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+// blockETA is the GET /block/eta/:height response
+type blockETA struct {
+	Height           int64 `json:"height"`
+	EstimatedTime    int64 `json:"estimated_time"`
+	IsEstimate       bool  `json:"is_estimate"`
+	TipHeight        int64 `json:"tip_height"`
+	AvgBlockInterval int64 `json:"avg_block_interval"`
+}
+
+func TestBlockETAPastHeight(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 5; i++ {
+		s.chain.AddBlock()
+	}
+
+	w := s.do(http.MethodGet, "/block/eta/3", nil)
+	expectStatus(t, w, http.StatusOK)
+	var eta blockETA
+	decode(t, w, &eta)
+	if want := s.chain.BlockAt(3).Time(); eta.Height != 3 || eta.EstimatedTime != want || eta.IsEstimate {
+		t.Errorf("got %+v, want the actual time %d of block 3", eta, want)
+	}
+}
+
+func TestBlockETAFutureHeight(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 5; i++ {
+		s.chain.AddBlock()
+	}
+	tip := s.chain.Tip()
+
+	w := s.do(http.MethodGet, "/block/eta/15", nil)
+	expectStatus(t, w, http.StatusOK)
+	var eta blockETA
+	decode(t, w, &eta)
+	// Too few blocks to average over, so the 10 minute target is used
+	want := tip.Time() + 10*600
+	if eta.Height != 15 || !eta.IsEstimate || eta.EstimatedTime != want {
+		t.Errorf("got %+v, want an estimate of %d", eta, want)
+	}
+	if eta.TipHeight != tip.Height || eta.AvgBlockInterval != 600 {
+		t.Errorf("estimated from tip %d at %ds intervals, want tip %d at 600s", eta.TipHeight, eta.AvgBlockInterval, tip.Height)
+	}
+}
+
+func TestBlockETAAveragesRecentIntervals(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 150; i++ {
+		s.chain.AddBlock()
+	}
+
+	w := s.do(http.MethodGet, "/block/eta/160", nil)
+	expectStatus(t, w, http.StatusOK)
+	var eta blockETA
+	decode(t, w, &eta)
+	if eta.AvgBlockInterval != rpctest.BlockInterval || eta.EstimatedTime != s.chain.Tip().Time()+10*rpctest.BlockInterval {
+		t.Errorf("got %+v, want %ds intervals from the tip", eta, rpctest.BlockInterval)
+	}
+	if s.node.Calls("getblockheader") != 2 {
+		t.Errorf("read %d headers, want the tip and the first sampled block", s.node.Calls("getblockheader"))
+	}
+}
+
+func TestBlockETARejectsBadHeight(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for _, height := range []string{"-1", "abc"} {
+		expectStatus(t, s.do(http.MethodGet, "/block/eta/"+height, nil), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes of GET /events
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// broadcastEvent is the payload of an events.TypeBroadcast event
+type broadcastEvent struct {
+	TxID string `json:"txid"`
+}
+
+// GetEvents handles GET /events?since=&limit=&cursor=
+// Returns recent new blocks, broadcasts and watched-address matches in time
+// order, oldest first, from Unix time since (default: the oldest kept).
+// When more remain, next_cursor is passed as cursor to fetch the next page.
+// Block and match events are only recorded while address watching is on.
+func (h *Handler) GetEvents(c *gin.Context) {
+	if h.events == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the event feed is disabled (EVENT_LOG_SIZE=0)"})
+		return
+	}
+
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since parameter (Unix time)"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultEventsLimit)))
+	if err != nil || limit < 1 || limit > maxEventsLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit parameter (1-%d)", maxEventsLimit)})
+		return
+	}
+
+	var afterID int64
+	if cursor := c.Query("cursor"); cursor != "" {
+		afterID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || afterID < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor parameter"})
+			return
+		}
+	}
+
+	events, more := h.events.Since(time.Unix(since, 0), afterID, limit)
+	response := gin.H{
+		"events": events,
+		"count":  len(events),
+	}
+	if more {
+		response["next_cursor"] = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"spv-backend/internal/events"
+	"spv-backend/internal/rpctest"
+	"spv-backend/internal/watch"
+)
+
+// feedEvent is an event as GET /events returns it
+type feedEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func TestEventsInterleaveSources(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	log := events.NewLog(100)
+	var m *watch.Manager
+	s := newTestServer(t, nil, nil, func(s *testServer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		m = watch.NewManager(s.handler.rpcClient, s.handler.filterService, false, 0)
+		m.SetEventLog(log)
+		if err := m.Start(ctx, 5*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		s.handler.watchManager = m
+		s.handler.events = log
+	})
+	if _, err := m.Add([]string{address.EncodeAddress()}, nil, m.Tip(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcast := func(sats int64) string {
+		tx := s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 9), sats))
+		w := s.do(http.MethodPost, "/broadcast", map[string]interface{}{"raw_tx": txHex(t, tx)})
+		expectStatus(t, w, http.StatusOK)
+		return tx.TxHash().String()
+	}
+	waitForEvents := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for log.Len() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %d events, have %d", n, log.Len())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// A broadcast, a block paying the watch, an unrelated block, a broadcast
+	first := broadcast(1000)
+	paying := s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 5000)))
+	waitForEvents(3)
+	empty := s.chain.AddBlock()
+	waitForEvents(4)
+	second := broadcast(2000)
+
+	w := s.do(http.MethodGet, "/events", nil)
+	expectStatus(t, w, http.StatusOK)
+	var feed struct {
+		Events     []feedEvent `json:"events"`
+		Count      int         `json:"count"`
+		NextCursor string      `json:"next_cursor"`
+	}
+	decode(t, w, &feed)
+
+	want := []struct {
+		eventType string
+		key       string
+	}{
+		{events.TypeBroadcast, first},
+		{events.TypeBlock, paying.Hash},
+		{events.TypeMatch, paying.Hash},
+		{events.TypeBlock, empty.Hash},
+		{events.TypeBroadcast, second},
+	}
+	if feed.Count != len(want) || len(feed.Events) != len(want) || feed.NextCursor != "" {
+		t.Fatalf("got %d events, cursor %q, want %d: %s", feed.Count, feed.NextCursor, len(want), w.Body.String())
+	}
+	for i, event := range feed.Events {
+		var data struct {
+			TxID      string `json:"txid"`
+			Hash      string `json:"hash"`
+			BlockHash string `json:"block_hash"`
+		}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if key := data.TxID + data.Hash + data.BlockHash; event.Type != want[i].eventType || key != want[i].key {
+			t.Errorf("event %d is %s %s, want %s %s", i, event.Type, key, want[i].eventType, want[i].key)
+		}
+		if i > 0 && (event.ID <= feed.Events[i-1].ID || event.Timestamp.Before(feed.Events[i-1].Timestamp)) {
+			t.Errorf("event %d (id %d, %s) is out of order", i, event.ID, event.Timestamp)
+		}
+	}
+
+	// Pages continue where the previous one stopped
+	w = s.do(http.MethodGet, "/events?limit=2", nil)
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &feed)
+	if feed.Count != 2 || feed.Events[0].Type != events.TypeBroadcast || feed.NextCursor == "" {
+		t.Fatalf("first page: %s", w.Body.String())
+	}
+	w = s.do(http.MethodGet, "/events?limit=2&cursor="+feed.NextCursor, nil)
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &feed)
+	if feed.Count != 2 || feed.Events[0].Type != events.TypeMatch || feed.Events[1].Type != events.TypeBlock {
+		t.Errorf("second page: %s", w.Body.String())
+	}
+}
+
+func TestEventsDisabled(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	w := s.do(http.MethodGet, "/events", nil)
+	expectStatus(t, w, http.StatusNotFound)
+}
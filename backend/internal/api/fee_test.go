@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"spv-backend/internal/fee"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// noSmartFee is estimatesmartfee's answer on a chain without fee data
+func noSmartFee(params []json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{"errors": []string{"Insufficient data or no feerate found"}, "blocks": 0}, nil
+}
+
+func TestFeesReportFallbackSource(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.node.Handle("estimatesmartfee", noSmartFee)
+
+	w := s.do(http.MethodGet, "/fees?conf_target=3", nil)
+	expectStatus(t, w, http.StatusOK)
+	var estimate fee.Estimate
+	decode(t, w, &estimate)
+	if estimate.Source != fee.SourceFallback || estimate.FeeRate != 1 || estimate.ConfTarget != 3 {
+		t.Errorf("got %+v, want the 1 sat/vB fallback for target 3", estimate)
+	}
+}
+
+func TestEstimateTxFeeUsesVSize(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.node.Handle("estimatesmartfee", func(params []json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"feerate": json.Number("0.00002"), "blocks": 2}, nil
+	})
+
+	// A P2WPKH spend of 192 bytes, 110 vB
+	tx := wire.NewMsgTx(2)
+	in := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil)
+	in.Witness = wire.TxWitness{bytes.Repeat([]byte{0x30}, 72), bytes.Repeat([]byte{0x02}, 33)}
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(50000, append([]byte{0x00, 0x14}, make([]byte, 20)...)))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	w := s.do(http.MethodPost, "/fees/estimate", map[string]interface{}{"raw_tx": hex.EncodeToString(buf.Bytes())})
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Size    fee.TxSize `json:"size"`
+		FeeSats int64      `json:"fee_sats"`
+		Source  string     `json:"source"`
+	}
+	decode(t, w, &resp)
+	// 2 sat/vB on the vsize, not the full size
+	if resp.Size.VSize != 110 || resp.FeeSats != 220 || resp.Source != fee.SourceSmart {
+		t.Errorf("got vsize %d, fee %d sats from %s; want 110, 220 from smart", resp.Size.VSize, resp.FeeSats, resp.Source)
+	}
+}
+
+func TestConfirmProbabilityForHighFee(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	// Two blocks' worth of 2 sat/vB transactions
+	entries := make(map[string]interface{})
+	for i := 0; i < 2000; i++ {
+		entries[strconv.Itoa(i)] = map[string]interface{}{"vsize": 1000, "fees": map[string]interface{}{"base": 0.00002}}
+	}
+	s.node.Handle("getrawmempool", func([]json.RawMessage) (interface{}, error) { return entries, nil })
+
+	w := s.do(http.MethodGet, "/confirm-probability?feerate=25", nil)
+	expectStatus(t, w, http.StatusOK)
+	var high fee.ConfirmProbability
+	decode(t, w, &high)
+	if high.Blocks != 1 || high.VSizeAhead != 0 || high.MempoolVSize != 2000000 || high.Probability < 0.99 {
+		t.Errorf("got %+v, want a near certain next block", high)
+	}
+
+	w = s.do(http.MethodGet, "/confirm-probability?feerate=1&blocks=1", nil)
+	expectStatus(t, w, http.StatusOK)
+	var low fee.ConfirmProbability
+	decode(t, w, &low)
+	if low.VSizeAhead != 2000000 || low.Probability > 0.01 {
+		t.Errorf("got %+v, want little chance behind two blocks", low)
+	}
+}
+
+func TestConfirmProbabilityRejectsBadParams(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for _, query := range []string{"", "feerate=0", "feerate=abc", "feerate=5&blocks=0", "feerate=5&blocks=2000"} {
+		w := s.do(http.MethodGet, "/confirm-probability?"+query, nil)
+		expectStatus(t, w, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyFilter(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	block := s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 1), 1000)))
+
+	w := s.do(http.MethodPost, "/filter/verify", gin.H{"block_hash": block.Hash, "filter_hex": hex.EncodeToString(block.Filter)})
+	expectStatus(t, w, http.StatusOK)
+	var match filter.FilterComparison
+	decode(t, w, &match)
+	if !match.Match || match.Diff != nil || match.NodeHeader != block.FilterHeader {
+		t.Errorf("matching filter: got %+v", match)
+	}
+
+	// Same length, last byte flipped
+	mismatched := append([]byte(nil), block.Filter...)
+	mismatched[len(mismatched)-1] ^= 0xff
+	w = s.do(http.MethodPost, "/filter/verify", gin.H{"block_hash": block.Hash, "filter_hex": hex.EncodeToString(mismatched)})
+	expectStatus(t, w, http.StatusOK)
+	var mismatch filter.FilterComparison
+	decode(t, w, &mismatch)
+	if mismatch.Match || mismatch.Diff == nil {
+		t.Fatalf("mismatched filter: got %+v", mismatch)
+	}
+	if mismatch.Diff.FirstDiffOffset != len(block.Filter)-1 || mismatch.Diff.ClientLength != len(block.Filter) {
+		t.Errorf("mismatched filter diff: got %+v", mismatch.Diff)
+	}
+
+	tests := []struct {
+		name      string
+		blockHash string
+		filterHex string
+		status    int
+	}{
+		{"filter not hex", block.Hash, "zz", http.StatusBadRequest},
+		{"filter odd length", block.Hash, "abc", http.StatusBadRequest},
+		{"block hash not hex", "not a hash", "00", http.StatusBadRequest},
+		{"block hash short", block.Hash[:62], "00", http.StatusBadRequest},
+		{"unknown block", strings.Repeat("ab", 32), "00", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := s.do(http.MethodPost, "/filter/verify", gin.H{"block_hash": tt.blockHash, "filter_hex": tt.filterHex})
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
+
+func TestAnalyzeFilters(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	paying := s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000)))
+	s.chain.AddBlock()
+
+	// The end defaults to the tip
+	w := s.do(http.MethodPost, "/filter/analyze", gin.H{"addresses": []string{address}, "start": 1})
+	expectStatus(t, w, http.StatusOK)
+	var analysis filter.FilterAnalysis
+	decode(t, w, &analysis)
+	if analysis.EndHeight != 2 || analysis.BlocksFiltered != 2 || analysis.BlocksMatched != 1 || analysis.FalsePositives != 0 {
+		t.Errorf("got %+v, want one true match over two blocks", analysis)
+	}
+	if len(analysis.Blocks) != 1 || analysis.Blocks[0].Hash != paying.Hash || analysis.Blocks[0].FalsePositive {
+		t.Errorf("blocks %+v, want the paying block as a true match", analysis.Blocks)
+	}
+
+	for name, body := range map[string]gin.H{
+		"no start":        {"addresses": []string{address}},
+		"no addresses":    {"addresses": []string{}, "start": 0},
+		"invalid address": {"addresses": []string{"bogus"}, "start": 0},
+		"start after end": {"addresses": []string{address}, "start": 2, "end": 1},
+	} {
+		if w := s.do(http.MethodPost, "/filter/analyze", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+}
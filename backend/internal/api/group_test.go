@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanGroupsUTXOsByAddress(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	c := rpctest.Address(testParams, "p2pkh", 3)
+	other := rpctest.Address(testParams, "p2wpkh", 9)
+
+	fund := s.chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 3000), rpctest.PayTo(c, 4000), rpctest.PayTo(a, 9000))
+	s.chain.AddBlock(fund)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(a, 2000), rpctest.PayTo(c, 5000)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(c, 6000)))
+	// a's 9000 output is spent and not part of its balance
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 3)}, rpctest.PayTo(other, 8000)))
+
+	addresses := []string{a.EncodeAddress(), b.EncodeAddress(), c.EncodeAddress()}
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), map[string]interface{}{"group_by": "address"}))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+
+	if len(result.UTXOs) != 0 {
+		t.Errorf("grouped result also lists %d UTXOs", len(result.UTXOs))
+	}
+	if result.TotalUTXOs != 6 || result.TotalSatoshis != 21000 {
+		t.Errorf("totals %d UTXOs, %d sats, want 6 and 21000", result.TotalUTXOs, result.TotalSatoshis)
+	}
+	want := map[string]struct {
+		count int
+		sats  int64
+	}{
+		a.EncodeAddress(): {2, 3000},
+		b.EncodeAddress(): {1, 3000},
+		c.EncodeAddress(): {3, 15000},
+	}
+	if len(result.ByAddress) != len(want) {
+		t.Fatalf("%d groups, want %d: %+v", len(result.ByAddress), len(want), result.ByAddress)
+	}
+	for address, exp := range want {
+		group := result.ByAddress[address]
+		if group == nil {
+			t.Errorf("no group for %s", address)
+			continue
+		}
+		if group.UTXOCount != exp.count || len(group.UTXOs) != exp.count || group.ConfirmedSats != exp.sats || group.TotalSats != exp.sats {
+			t.Errorf("%s: %d UTXOs (count %d), %d confirmed of %d sats; want %d UTXOs, %d sats",
+				address, len(group.UTXOs), group.UTXOCount, group.ConfirmedSats, group.TotalSats, exp.count, exp.sats)
+		}
+		for _, utxo := range group.UTXOs {
+			if utxo.Address != address {
+				t.Errorf("UTXO of %s grouped under %s", utxo.Address, address)
+			}
+		}
+	}
+}
+
+func TestScanGroupByRejectsBadInput(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+	addresses := []string{rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()}
+
+	for name, extra := range map[string]map[string]interface{}{
+		"unknown grouping": {"group_by": "height"},
+		"balance only":     {"group_by": "address", "balance_only": true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, 1, extra)), http.StatusBadRequest)
+		})
+	}
+}
@@ -2,16 +2,35 @@
 package api
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"spv-backend/config"
 	"spv-backend/internal/contract"
+	"spv-backend/internal/events"
+	"spv-backend/internal/fee"
 	"spv-backend/internal/filter"
+	"spv-backend/internal/merkle"
+	"spv-backend/internal/ot"
 	"spv-backend/internal/rpc"
+	"spv-backend/internal/txstatus"
+	"spv-backend/internal/watch"
 
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,256 +39,2210 @@ type Handler struct {
 	rpcClient       *rpc.Client
 	filterService   *filter.Service
 	contractService *contract.Service
-	config          *config.Config // Global configuration
+	feeService      *fee.Service
+	otService       *ot.Service
+	watchManager    *watch.Manager    // Nil when watching is disabled
+	txTracker       *txstatus.Tracker // Nil when transaction tracking is disabled
+	proxyMetrics    *proxyMetrics     // Nil when PROXY_METRICS is off
+	config          *config.Config    // Global configuration
+	events          *events.Log       // Nil when EVENT_LOG_SIZE=0
+	scans           *scanHistory      // Recent scan requests, for GET /debug/bundle
+
+	// Signs scan snapshots, nil when SNAPSHOT_SIGNING_KEY is unset
+	snapshotKey ed25519.PrivateKey
+}
+
+// NewHandler creates a new API handler
+func NewHandler(rpcClient *rpc.Client, filterService *filter.Service, contractService *contract.Service, feeService *fee.Service, otService *ot.Service, watchManager *watch.Manager, txTracker *txstatus.Tracker, eventLog *events.Log, cfg *config.Config) *Handler {
+	h := &Handler{
+		rpcClient:       rpcClient,
+		filterService:   filterService,
+		contractService: contractService,
+		feeService:      feeService,
+		otService:       otService,
+		watchManager:    watchManager,
+		txTracker:       txTracker,
+		config:          cfg,
+		events:          eventLog,
+		scans:           &scanHistory{},
+	}
+	if cfg.ProxyMetrics {
+		h.proxyMetrics = newProxyMetrics()
+	}
+	if cfg.SnapshotSigningKey != "" {
+		seed, _ := hex.DecodeString(cfg.SnapshotSigningKey) // Validated by config.Load
+		h.snapshotKey = ed25519.NewKeyFromSeed(seed)
+	}
+	return h
+}
+
+// fetchHeadersSequentially fetches multiple block headers in order
+// Simple and reliable - fetches headers one by one
+func (h *Handler) fetchHeadersSequentially(c *gin.Context, startHeight int64, count int) []map[string]interface{} {
+	var headers []map[string]interface{}
+	
+	// Get current blockchain height to avoid out-of-range errors
+	blockCount, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		log.Printf("Error getting block count: %v", err)
+		return headers
+	}
+	
+	// Adjust count if it exceeds available blocks
+	maxAvailable := blockCount - startHeight + 1
+	if int64(count) > maxAvailable {
+		count = int(maxAvailable)
+		log.Printf("Adjusted count to %d (blockchain height: %d, start: %d)", 
+			count, blockCount, startHeight)
+	}
+	
+	// Fetch headers sequentially
+	for i := 0; i < count; i++ {
+		height := startHeight + int64(i)
+		
+		// Get block hash at height
+		blockHash, err := h.rpcFor(c).GetBlockHash(height)
+		if err != nil {
+			log.Printf("Error getting block hash at height %d: %v", height, err)
+			break // Stop on first error
+		}
+		
+		// Get block header
+		headerData, err := h.rpcFor(c).GetBlockHeader(blockHash, true)
+		if err != nil {
+			log.Printf("Error getting block header at height %d: %v", height, err)
+			break // Stop on first error
+		}
+		
+		// Parse header
+		var header map[string]interface{}
+		if err := json.Unmarshal(headerData, &header); err != nil {
+			log.Printf("Error parsing header at height %d: %v", height, err)
+			break // Stop on first error
+		}
+		
+		headers = append(headers, header)
+	}
+	
+	return headers
+}
+
+// GetBlockchainInfo handles GET /blockchaininfo
+func (h *Handler) GetBlockchainInfo(c *gin.Context) {
+	result, err := h.rpcFor(c).GetBlockchainInfo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var info map[string]interface{}
+	if err := json.Unmarshal(result, &info); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse blockchain info"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// ChainParamsInfo summarizes the chain parameters the server decodes addresses with
+type ChainParamsInfo struct {
+	Name               string `json:"name"`
+	Magic              string `json:"magic"` // Network magic, hex encoded as it appears on the wire
+	GenesisHash        string `json:"genesis_hash"`
+	Bech32HRP          string `json:"bech32_hrp"`
+	PubKeyHashAddrID   byte   `json:"pubkey_hash_addr_id"`
+	ScriptHashAddrID   byte   `json:"script_hash_addr_id"`
+	PrivateKeyID       byte   `json:"private_key_id"`
+	DefaultPort        string `json:"default_port"`
+	CoinbaseMaturity   uint16 `json:"coinbase_maturity"`
+	TargetBlockTimeSec int64  `json:"target_block_time_sec"`
+}
+
+// GetChainParams handles GET /chainparams
+func (h *Handler) GetChainParams(c *gin.Context) {
+	params := h.filterService.ChainParams()
+
+	magic := make([]byte, 4)
+	binary.LittleEndian.PutUint32(magic, uint32(params.Net))
+
+	c.JSON(http.StatusOK, ChainParamsInfo{
+		Name:               params.Name,
+		Magic:              hex.EncodeToString(magic),
+		GenesisHash:        params.GenesisHash.String(),
+		Bech32HRP:          params.Bech32HRPSegwit,
+		PubKeyHashAddrID:   params.PubKeyHashAddrID,
+		ScriptHashAddrID:   params.ScriptHashAddrID,
+		PrivateKeyID:       params.PrivateKeyID,
+		DefaultPort:        params.DefaultPort,
+		CoinbaseMaturity:   params.CoinbaseMaturity,
+		TargetBlockTimeSec: int64(params.TargetTimePerBlock.Seconds()),
+	})
+}
+
+// headerFields are the fields of a verbose getblockheader result
+var headerFields = map[string]bool{
+	"hash": true, "confirmations": true, "height": true, "version": true,
+	"versionHex": true, "merkleroot": true, "time": true, "mediantime": true,
+	"nonce": true, "bits": true, "target": true, "difficulty": true,
+	"chainwork": true, "nTx": true, "previousblockhash": true, "nextblockhash": true,
+}
+
+// parseHeaderFields parses the comma-separated fields query parameter.
+// A nil result means every field is returned.
+func parseHeaderFields(c *gin.Context) ([]string, error) {
+	value := c.Query("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !headerFields[field] {
+			return nil, fmt.Errorf("unknown header field: %s", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// projectHeader keeps only the requested fields of a header
+func projectHeader(header map[string]interface{}, fields []string) map[string]interface{} {
+	if fields == nil {
+		return header
+	}
+	projected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, ok := header[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+// GetHeader handles GET /header/:hash
+// Supports a fields projection, e.g. ?fields=hash,height,time,previousblockhash
+func (h *Handler) GetHeader(c *gin.Context) {
+	fields, err := parseHeaderFields(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	headerData, err := h.rpcFor(c).GetBlockHeader(c.Param("hash"), true)
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey {
+			c.JSON(http.StatusNotFound, gin.H{"error": "block not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse header"})
+		return
+	}
+
+	c.JSON(http.StatusOK, projectHeader(header, fields))
+}
+
+// GetHeaders handles GET /headers
+// Supports a fields projection, e.g. ?fields=hash,height,time,previousblockhash
+func (h *Handler) GetHeaders(c *gin.Context) {
+	startHash := c.Query("start_hash")
+	countStr := c.DefaultQuery("count", "10")
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 || count > 2000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid count parameter (1-2000)"})
+		return
+	}
+
+	fields, err := parseHeaderFields(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get starting block header
+	var startHeight int64
+	if startHash == "" {
+		// Start from tip
+		bestHash, err := h.rpcFor(c).GetBestBlockHash()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		startHash = bestHash
+	}
+
+	// Get start block header to find height
+	headerData, err := h.rpcFor(c).GetBlockHeader(startHash, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse header"})
+		return
+	}
+
+	startHeight = int64(header["height"].(float64))
+
+	// Fetch headers sequentially (simple and reliable)
+	headers := h.fetchHeadersSequentially(c, startHeight, count)
+	for i := range headers {
+		headers[i] = projectHeader(headers[i], fields)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"headers":      headers,
+		"start_height": startHeight,
+		"count":        len(headers),
+	})
+}
+
+// GetHeaderProof handles GET /header-proof/:height
+// Returns the chain of serialized headers connecting the block at height to
+// a checkpoint height the client already trusts (?checkpoint=, default the
+// tip). Clients verify each header's hash and its link to the previous one.
+func (h *Handler) GetHeaderProof(c *gin.Context) {
+	height, err := strconv.ParseInt(c.Param("height"), 10, 64)
+	if err != nil || height < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid height parameter"})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkpoint := tip
+	if value := c.Query("checkpoint"); value != "" {
+		checkpoint, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || checkpoint < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checkpoint parameter"})
+			return
+		}
+	}
+
+	if height > tip || checkpoint > tip {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("height beyond the chain tip %d", tip)})
+		return
+	}
+
+	// The checkpoint may be either side of the requested height
+	start, end := height, checkpoint
+	if start > end {
+		start, end = end, start
+	}
+	if end-start+1 > filter.MaxHeaderChainLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("height is more than %d headers from the checkpoint", filter.MaxHeaderChainLength-1)})
+		return
+	}
+
+	chain, err := h.filtersFor(c).HeaderChain(start, end)
+	if err != nil {
+		if errors.Is(err, filter.ErrHeaderChainChanged) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	target := chain[height-start]
+	anchor := chain[checkpoint-start]
+	headers := make([]string, len(chain))
+	for i, header := range chain {
+		headers[i] = header.Header
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"height":            target.Height,
+		"hash":              target.Hash,
+		"header":            target.Header,
+		"checkpoint_height": anchor.Height,
+		"checkpoint_hash":   anchor.Hash,
+		"start_height":      start,
+		"headers":           headers, // Ascending from start_height, each linking to the one before
+	})
+}
+
+// GetBlock handles GET /block/:hash
+func (h *Handler) GetBlock(c *gin.Context) {
+	blockHash := c.Param("hash")
+	if blockHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "block hash is required"})
+		return
+	}
+
+	blockData, err := h.rpcFor(c).GetBlock(blockHash, 2) // verbosity=2 for full details
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var block map[string]interface{}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse block"})
+		return
+	}
+
+	c.JSON(http.StatusOK, block)
+}
+
+// medianTimeSpan is how many blocks the median time past is taken over: the
+// block itself and the ten before it (BIP113)
+const medianTimeSpan = 11
+
+// MedianTimePast is a block's median time past, the time CLTV and CSV
+// timelocks are evaluated against instead of the block's own timestamp
+type MedianTimePast struct {
+	Hash           string  `json:"hash"`
+	Height         int64   `json:"height"`
+	Time           int64   `json:"time"`
+	MedianTimePast int64   `json:"median_time_past"`
+	Timestamps     []int64 `json:"timestamps"` // Of the blocks the median is taken over, oldest first
+}
+
+// GetBlockMTP handles GET /block/:hash/mtp
+// Computes the median of the timestamps of the block and up to ten blocks
+// before it, following previousblockhash so stale blocks are handled too.
+// Header and block responses carry the node's own value as mediantime.
+func (h *Handler) GetBlockMTP(c *gin.Context) {
+	var timestamps []int64
+	var mtp MedianTimePast
+	hash := c.Param("hash")
+	for len(timestamps) < medianTimeSpan && hash != "" {
+		headerData, err := h.rpcFor(c).GetBlockHeader(hash, true)
+		if err != nil {
+			var rpcErr *rpc.RPCError
+			if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey {
+				c.JSON(http.StatusNotFound, gin.H{"error": "block not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var header struct {
+			Hash              string `json:"hash"`
+			Height            int64  `json:"height"`
+			Time              int64  `json:"time"`
+			PreviousBlockHash string `json:"previousblockhash"` // Empty for the genesis block
+		}
+		if err := json.Unmarshal(headerData, &header); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse header"})
+			return
+		}
+
+		if len(timestamps) == 0 {
+			mtp.Hash = header.Hash
+			mtp.Height = header.Height
+			mtp.Time = header.Time
+		}
+		timestamps = append(timestamps, header.Time)
+		hash = header.PreviousBlockHash
+	}
+
+	// Collected newest first
+	for i, j := 0, len(timestamps)-1; i < j; i, j = i+1, j-1 {
+		timestamps[i], timestamps[j] = timestamps[j], timestamps[i]
+	}
+	mtp.Timestamps = timestamps
+	mtp.MedianTimePast = medianTime(timestamps)
+
+	c.JSON(http.StatusOK, mtp)
+}
+
+// medianTime returns the median of timestamps as Bitcoin Core takes it: the
+// middle one once sorted, the upper middle for an even count
+func medianTime(timestamps []int64) int64 {
+	sorted := append([]int64(nil), timestamps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// BlockSummary represents summary statistics of a block
+type BlockSummary struct {
+	Hash     string `json:"hash"`
+	Height   int64  `json:"height"`
+	Size     int64  `json:"size"`
+	Weight   int64  `json:"weight"`
+	TxCount  int    `json:"tx_count"`
+	TotalOut int64  `json:"total_out"` // Satoshis, excluding the coinbase
+	TotalFee *int64 `json:"total_fee"` // Satoshis, nil when prevouts are unavailable
+	Source   string `json:"source"`    // "getblockstats" or "block"
+}
+
+// GetBlockSummary handles GET /block/:hash/summary
+// Size, weight and tx count come from the node's block data; output and fee
+// totals come from getblockstats, falling back to summing the block's
+// transactions when stats are unavailable (e.g. pruned undo data)
+func (h *Handler) GetBlockSummary(c *gin.Context) {
+	blockHash := c.Param("hash")
+	if blockHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "block hash is required"})
+		return
+	}
+
+	blockData, err := h.rpcFor(c).GetBlock(blockHash, 1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var block struct {
+		Hash   string `json:"hash"`
+		Height int64  `json:"height"`
+		Size   int64  `json:"size"`
+		Weight int64  `json:"weight"`
+		NTx    int    `json:"nTx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse block"})
+		return
+	}
+
+	summary := BlockSummary{
+		Hash:    block.Hash,
+		Height:  block.Height,
+		Size:    block.Size,
+		Weight:  block.Weight,
+		TxCount: block.NTx,
+		Source:  "getblockstats",
+	}
+
+	statsData, err := h.rpcFor(c).GetBlockStats(blockHash, []string{"total_out", "totalfee"})
+	if err == nil {
+		var stats struct {
+			TotalOut int64 `json:"total_out"`
+			TotalFee int64 `json:"totalfee"`
+		}
+		if err := json.Unmarshal(statsData, &stats); err == nil {
+			summary.TotalOut = stats.TotalOut
+			summary.TotalFee = &stats.TotalFee
+			c.JSON(http.StatusOK, summary)
+			return
+		}
+	}
+
+	// Fall back to computing the totals from the full block
+	totalOut, totalFee, err := h.sumBlockOutputs(c, blockHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summary.TotalOut = totalOut
+	summary.TotalFee = totalFee
+	summary.Source = "block"
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// sumBlockOutputs totals the non-coinbase outputs of a block and, when the
+// node reports a fee for every transaction, the block's total fee
+func (h *Handler) sumBlockOutputs(c *gin.Context, blockHash string) (int64, *int64, error) {
+	blockData, err := h.rpcFor(c).GetBlock(blockHash, 2)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var block struct {
+		Tx []struct {
+			Fee  *json.Number `json:"fee"` // BTC, present when undo data is available
+			Vout []struct {
+				Value json.Number `json:"value"`
+			} `json:"vout"`
+		} `json:"tx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		return 0, nil, fmt.Errorf("failed to parse block: %w", err)
+	}
+
+	var totalOut, totalFee int64
+	feesKnown := true
+	for i, tx := range block.Tx {
+		if i == 0 {
+			continue // Coinbase
+		}
+		for _, vout := range tx.Vout {
+			sats, err := filter.ParseSatoshis(vout.Value)
+			if err != nil {
+				return 0, nil, err
+			}
+			totalOut += sats
+		}
+		if tx.Fee == nil {
+			feesKnown = false
+			continue
+		}
+		fee, err := filter.ParseSatoshis(*tx.Fee)
+		if err != nil {
+			return 0, nil, err
+		}
+		totalFee += fee
+	}
+
+	if !feesKnown {
+		return totalOut, nil, nil
+	}
+	return totalOut, &totalFee, nil
+}
+
+// maxStatsRange caps the blocks a /stats/range request aggregates, and
+// statsBatchSize how many getblockstats calls go in each batch
+const (
+	maxStatsRange  = 1000
+	statsBatchSize = 100
+)
+
+// RangeStats aggregates getblockstats over an inclusive height range
+type RangeStats struct {
+	StartHeight int64 `json:"start_height"`
+	EndHeight   int64 `json:"end_height"`
+	Blocks      int   `json:"blocks"`
+	TxCount     int64 `json:"tx_count"`  // Including coinbases
+	TotalOut    int64 `json:"total_out"` // Satoshis, excluding coinbases
+	TotalFee    int64 `json:"total_fee"` // Satoshis
+}
+
+// GetRangeStats handles GET /stats/range?start=&end=
+// Sums tx counts, output values and fees over at most 1000 blocks from
+// getblockstats, batched. Fee totals need the blocks' undo data, so a range
+// reaching into pruned blocks fails.
+func (h *Handler) GetRangeStats(c *gin.Context) {
+	startHeight, err := strconv.ParseInt(c.Query("start"), 10, 64)
+	if err != nil || startHeight < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start parameter"})
+		return
+	}
+	endHeight, err := strconv.ParseInt(c.Query("end"), 10, 64)
+	if err != nil || endHeight < startHeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end parameter (start or above)"})
+		return
+	}
+	if endHeight-startHeight+1 > maxStatsRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range too large, max %d blocks", maxStatsRange)})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if endHeight > tip {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("end beyond the chain tip %d", tip)})
+		return
+	}
+
+	stats := RangeStats{StartHeight: startHeight, EndHeight: endHeight}
+	for batchStart := startHeight; batchStart <= endHeight; batchStart += statsBatchSize {
+		batchEnd := batchStart + statsBatchSize - 1
+		if batchEnd > endHeight {
+			batchEnd = endHeight
+		}
+		if err := h.addRangeStats(c, &stats, batchStart, batchEnd); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// addRangeStats adds the blocks from startHeight to endHeight to stats with
+// one batch of getblockstats calls
+func (h *Handler) addRangeStats(c *gin.Context, stats *RangeStats, startHeight, endHeight int64) error {
+	requests := make([]rpc.RPCRequest, 0, endHeight-startHeight+1)
+	for height := startHeight; height <= endHeight; height++ {
+		requests = append(requests, rpc.RPCRequest{
+			Jsonrpc: "1.0",
+			Method:  "getblockstats",
+			Params:  []interface{}{height, []string{"txs", "total_out", "totalfee"}},
+			ID:      len(requests),
+		})
+	}
+
+	responses, err := h.rpcFor(c).BatchCall(requests)
+	if err != nil {
+		return err
+	}
+
+	answered := make([]bool, len(requests))
+	for _, resp := range responses {
+		if resp.ID < 0 || resp.ID >= len(requests) || answered[resp.ID] {
+			continue
+		}
+		height := startHeight + int64(resp.ID)
+		if resp.Error != nil {
+			return fmt.Errorf("getblockstats failed at height %d: %s", height, resp.Error.Message)
+		}
+
+		var block struct {
+			Txs      int64 `json:"txs"`
+			TotalOut int64 `json:"total_out"`
+			TotalFee int64 `json:"totalfee"`
+		}
+		if err := json.Unmarshal(resp.Result, &block); err != nil {
+			return fmt.Errorf("failed to parse block stats at height %d: %w", height, err)
+		}
+		answered[resp.ID] = true
+		stats.Blocks++
+		stats.TxCount += block.Txs
+		stats.TotalOut += block.TotalOut
+		stats.TotalFee += block.TotalFee
+	}
+
+	for i, ok := range answered {
+		if !ok {
+			return fmt.Errorf("no block stats for height %d", startHeight+int64(i))
+		}
+	}
+	return nil
+}
+
+const (
+	// targetBlockInterval is the expected time between blocks, in seconds
+	targetBlockInterval = 600
+	// etaSampleBlocks is how many recent blocks the average interval is taken over
+	etaSampleBlocks = 144
+)
+
+// blockTimeAtHeight returns the header timestamp of the block at a height
+func (h *Handler) blockTimeAtHeight(c *gin.Context, height int64) (int64, error) {
+	blockHash, err := h.rpcFor(c).GetBlockHash(height)
+	if err != nil {
+		return 0, err
+	}
+
+	headerData, err := h.rpcFor(c).GetBlockHeader(blockHash, true)
+	if err != nil {
+		return 0, err
+	}
+
+	var header struct {
+		Time int64 `json:"time"`
+	}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return 0, fmt.Errorf("failed to parse header at height %d: %w", height, err)
+	}
+
+	return header.Time, nil
+}
+
+// estimateBlockTime returns the time of the block at height: the actual
+// header time up to the tip, otherwise an estimate from the tip using the
+// average interval of the last etaSampleBlocks blocks (or the 10 minute
+// target when the chain is too short). interval is 0 for past heights.
+func (h *Handler) estimateBlockTime(c *gin.Context, height, tip int64) (blockTime, interval int64, err error) {
+	if height <= tip {
+		blockTime, err = h.blockTimeAtHeight(c, height)
+		return blockTime, 0, err
+	}
+
+	tipTime, err := h.blockTimeAtHeight(c, tip)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	interval = int64(targetBlockInterval)
+	if tip >= etaSampleBlocks {
+		if sampleTime, err := h.blockTimeAtHeight(c, tip-etaSampleBlocks); err == nil && tipTime > sampleTime {
+			interval = (tipTime - sampleTime) / etaSampleBlocks
+		}
+	}
+
+	return tipTime + (height-tip)*interval, interval, nil
+}
+
+// GetBlockETA handles GET /block/eta/:height
+// Past heights return the block's actual time; future heights are estimated
+// from the tip (see estimateBlockTime)
+func (h *Handler) GetBlockETA(c *gin.Context) {
+	height, err := strconv.ParseInt(c.Param("height"), 10, 64)
+	if err != nil || height < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid height parameter"})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	blockTime, interval, err := h.estimateBlockTime(c, height, tip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if height <= tip {
+		c.JSON(http.StatusOK, gin.H{
+			"height":         height,
+			"estimated_time": blockTime,
+			"is_estimate":    false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"height":             height,
+		"estimated_time":     blockTime,
+		"is_estimate":        true,
+		"tip_height":         tip,
+		"avg_block_interval": interval,
+	})
+}
+
+// baseSubsidy is the reward of a block before any halving, in satoshis
+const baseSubsidy = 50 * btcutil.SatoshiPerBitcoin
+
+// blockSubsidy returns the subsidy of the block at height, halved every
+// interval blocks until it reaches zero. A zero interval never halves.
+func blockSubsidy(height, interval int64) (subsidy, halvings int64) {
+	if interval <= 0 {
+		return baseSubsidy, 0
+	}
+	halvings = height / interval
+	if halvings >= 64 {
+		return 0, halvings
+	}
+	return baseSubsidy >> uint(halvings), halvings
+}
+
+// GetSubsidy handles GET /subsidy/:height
+// Returns the block subsidy at a height from the chain parameters' halving
+// interval, and the height and time of the following halving. The time is
+// the actual block time if that block exists, otherwise an estimate.
+func (h *Handler) GetSubsidy(c *gin.Context) {
+	height, err := strconv.ParseInt(c.Param("height"), 10, 64)
+	if err != nil || height < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid height parameter"})
+		return
+	}
+
+	interval := int64(h.filterService.ChainParams().SubsidyReductionInterval)
+	subsidy, halvings := blockSubsidy(height, interval)
+
+	response := gin.H{
+		"height":           height,
+		"subsidy_sats":     subsidy,
+		"subsidy_btc":      btcutil.Amount(subsidy).ToBTC(),
+		"halvings":         halvings,
+		"halving_interval": interval,
+	}
+
+	// Past the last halving that changes the subsidy there is nothing to predict
+	if interval <= 0 || subsidy == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	nextHalving := (halvings + 1) * interval
+	nextSubsidy, _ := blockSubsidy(nextHalving, interval)
+	response["next_halving_height"] = nextHalving
+	response["next_halving_subsidy_sats"] = nextSubsidy
+	response["blocks_until_halving"] = nextHalving - height
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	halvingTime, _, err := h.estimateBlockTime(c, nextHalving, tip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response["next_halving_time"] = halvingTime
+	response["next_halving_is_estimate"] = nextHalving > tip
+	response["tip_height"] = tip
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetHashrate handles GET /hashrate
+// Estimates network hashes per second over the last blocks blocks before
+// height. By default blocks covers the current difficulty period (-1) and
+// height is the tip (-1).
+func (h *Handler) GetHashrate(c *gin.Context) {
+	blocks, err := strconv.Atoi(c.DefaultQuery("blocks", "-1"))
+	if err != nil || blocks < -1 || blocks == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blocks parameter (-1 for the difficulty period, or > 0)"})
+		return
+	}
+
+	height, err := strconv.Atoi(c.DefaultQuery("height", "-1"))
+	if err != nil || height < -1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid height parameter (-1 for the tip, or >= 0)"})
+		return
+	}
+
+	hashPS, err := h.rpcFor(c).GetNetworkHashPS(blocks, height)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hashes_per_second": hashPS,
+		"blocks":            blocks,
+		"height":            height,
+	})
+}
+
+// GetBlockMerkleBranches handles GET /block/:hash/merkle-branches
+// Returns the merkle branch and index of every transaction in the block,
+// computed once from the block's transaction list
+func (h *Handler) GetBlockMerkleBranches(c *gin.Context) {
+	blockHash := c.Param("hash")
+	if blockHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "block hash is required"})
+		return
+	}
+
+	blockData, err := h.rpcFor(c).GetBlock(blockHash, 1) // verbosity=1 for txids only
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var block struct {
+		Hash       string   `json:"hash"`
+		MerkleRoot string   `json:"merkleroot"`
+		Tx         []string `json:"tx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse block"})
+		return
+	}
+
+	root, branches, err := merkle.BuildBranches(block.Tx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if root != block.MerkleRoot {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "computed merkle root does not match block header"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hash":       block.Hash,
+		"merkleroot": block.MerkleRoot,
+		"tx_count":   len(block.Tx),
+		"branches":   branches,
+	})
+}
+
+// VerifyMerkleProofRequest represents a merkle proof to verify
+type VerifyMerkleProofRequest struct {
+	Proof string `json:"proof" binding:"required"` // Hex, as returned by gettxoutproof
+}
+
+// VerifyMerkleProof handles POST /merkle/verify
+// Checks a merkle proof against the node's active chain and returns the
+// txids it commits to
+func (h *Handler) VerifyMerkleProof(c *gin.Context) {
+	var req VerifyMerkleProofRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := hex.DecodeString(req.Proof); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "proof must be hex encoded", "valid": false})
+		return
+	}
+
+	txids, err := h.rpcFor(c).VerifyTxOutProof(req.Proof)
+	if err != nil {
+		// The node rejects malformed proofs and ones for unknown blocks
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": rpcErr.Message, "valid": false})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(txids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "proof does not commit to a block in the active chain", "valid": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid": true,
+		"txids": txids,
+	})
+}
+
+// DecodeOPReturnRequest represents an OP_RETURN script to decode
+type DecodeOPReturnRequest struct {
+	ScriptPubKey string `json:"script_pubkey" binding:"required"`
+}
+
+// DecodeOPReturn handles POST /script/decode-opreturn
+// Extracts the data pushed by an OP_RETURN scriptPubKey as hex and, when it
+// is valid UTF-8, as text. A payload in the OT pipe format is also split
+// into its type and fields. Decoding is local; the node is not called.
+func (h *Handler) DecodeOPReturn(c *gin.Context) {
+	var req DecodeOPReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	script, err := hex.DecodeString(req.ScriptPubKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid script_pubkey hex"})
+		return
+	}
+
+	data, err := filter.DecodeOPReturn(script)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"payload":   data.Payload,
+		"pushes":    data.Pushes,
+		"push_only": data.PushOnly,
+	}
+	if data.PayloadText != nil {
+		response["payload_text"] = *data.PayloadText
+		if payload, ok := ot.ParsePayload([]byte(*data.PayloadText)); ok {
+			response["ot"] = payload
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// BroadcastRequest represents a transaction broadcast request
+type BroadcastRequest struct {
+	RawTx string `json:"raw_tx" binding:"required"`
+	// Follow the transaction for GET /tx/:txid/status (default true when
+	// tracking is enabled)
+	Track *bool `json:"track"`
+}
+
+// BroadcastTx handles POST /broadcast
+// The transaction is tracked unless track is false, so clients can learn if
+// it is dropped from the mempool before confirming
+func (h *Handler) BroadcastTx(c *gin.Context) {
+	var req BroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	txid, err := h.rpcFor(c).SendRawTransaction(req.RawTx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.events.Record(events.TypeBroadcast, broadcastEvent{TxID: txid})
+
+	response := gin.H{"txid": txid}
+	if h.txTracker != nil && (req.Track == nil || *req.Track) {
+		// The broadcast succeeded either way; only report whether it is tracked
+		if err := h.txTracker.Track(txid); err != nil {
+			log.Printf("Not tracking transaction %s: %v", txid, err)
+			response["tracked"] = false
+		} else {
+			response["tracked"] = true
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetTxStatus handles GET /tx/:txid/status
+// Returns whether a transaction tracked since its broadcast is in_mempool,
+// confirmed or dropped; untracked transactions are unknown
+func (h *Handler) GetTxStatus(c *gin.Context) {
+	if h.txTracker == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction tracking is disabled (TX_STATUS_POLL_INTERVAL=0)"})
+		return
+	}
+
+	txid := c.Param("txid")
+	if len(txid) != 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid txid"})
+		return
+	}
+	if _, err := hex.DecodeString(txid); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid txid"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.txTracker.Status(txid))
+}
+
+// CombineTxRequest represents a request to combine partially signed transactions
+type CombineTxRequest struct {
+	RawTxs []string `json:"raw_txs" binding:"required"`
+}
+
+// CombineTx handles POST /tx/combine
+// Merges signatures from partially signed copies of a legacy multisig
+// transaction. Every input must decode as a transaction before the node is called.
+func (h *Handler) CombineTx(c *gin.Context) {
+	var req CombineTxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.RawTxs) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least two transactions are required"})
+		return
+	}
+
+	for i, rawTx := range req.RawTxs {
+		txBytes, err := hex.DecodeString(rawTx)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("raw_txs[%d]: invalid hex", i)})
+			return
+		}
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("raw_txs[%d]: invalid transaction: %v", i, err)})
+			return
+		}
+	}
+
+	combined, err := h.rpcFor(c).CombineRawTransaction(req.RawTxs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hex": combined})
+}
+
+// GetMempoolChain handles GET /tx/:txid/mempool-chain
+// Returns the transaction's unconfirmed ancestors and descendants with
+// aggregate fee and vsize, for CPFP fee bumping
+func (h *Handler) GetMempoolChain(c *gin.Context) {
+	txid := c.Param("txid")
+	if len(txid) != 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid txid"})
+		return
+	}
+
+	chain, err := h.feesFor(c).MempoolChain(txid)
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey {
+			c.JSON(http.StatusNotFound, gin.H{"error": "transaction not in mempool"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chain)
+}
+
+// noTxIndexMessage explains a transaction lookup the node could not answer
+// for lack of -txindex
+const noTxIndexMessage = "transaction not found in the mempool; the node runs without -txindex, so confirmed transactions can only be found with a blockhash hint"
+
+// txLookupError maps a getrawtransaction failure to a response: unknown
+// transactions are 404s, with a hint when the node lacks -txindex
+func txLookupError(err error) (int, gin.H) {
+	if rpc.IsNoTxIndex(err) {
+		return http.StatusNotFound, gin.H{"error": noTxIndexMessage, "txindex_required": true}
+	}
+	var rpcErr *rpc.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey {
+		return http.StatusNotFound, gin.H{"error": "transaction not found: " + rpcErr.Message}
+	}
+	return http.StatusInternalServerError, gin.H{"error": err.Error()}
+}
+
+// GetTransaction handles GET /tx/:txid?blockhash=&verbose=
+// Returns a transaction's hex, or its decoded form with verbose=true. Without
+// -txindex on the node, confirmed transactions need the blockhash hint.
+func (h *Handler) GetTransaction(c *gin.Context) {
+	txid := strings.ToLower(c.Param("txid"))
+	if _, err := hex.DecodeString(txid); err != nil || len(txid) != 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid txid"})
+		return
+	}
+	blockHash := c.Query("blockhash")
+	if blockHash != "" {
+		if _, err := hex.DecodeString(blockHash); err != nil || len(blockHash) != 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blockhash parameter"})
+			return
+		}
+	}
+	verbose := c.Query("verbose") == "true"
+
+	var txData json.RawMessage
+	var err error
+	if blockHash != "" {
+		txData, err = h.rpcFor(c).GetRawTransactionInBlock(txid, verbose, blockHash)
+	} else {
+		txData, err = h.rpcFor(c).GetRawTransaction(txid, verbose)
+	}
+	if err != nil {
+		c.JSON(txLookupError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"txid": txid, "tx": txData})
+}
+
+// maxBatchTxids caps the transactions fetched by one POST /txs request
+const maxBatchTxids = 100
+
+// GetTransactionsRequest represents a batch transaction lookup
+type GetTransactionsRequest struct {
+	TxIDs   []string `json:"txids" binding:"required"`
+	Verbose bool     `json:"verbose"` // Decoded transactions instead of hex
+	// Block the transactions are in, needed for confirmed ones when the
+	// node runs without -txindex
+	BlockHash string `json:"blockhash"`
+}
+
+// TransactionResult is one transaction of a batch lookup
+type TransactionResult struct {
+	TxID  string          `json:"txid"`
+	Tx    json.RawMessage `json:"tx,omitempty"`    // Hex, or the decoded transaction when verbose
+	Error string          `json:"error,omitempty"` // Set if the node could not return the transaction
+
+	TxIndexRequired bool `json:"txindex_required,omitempty"` // Not found for lack of -txindex, see BlockHash
+}
+
+// GetTransactions handles POST /txs
+// Fetches many transactions in one batched getrawtransaction request. Results
+// follow the order of the deduplicated txids; unknown transactions carry an
+// error instead of failing the request.
+func (h *Handler) GetTransactions(c *gin.Context) {
+	var req GetTransactionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	seen := make(map[string]bool, len(req.TxIDs))
+	var txids []string
+	for _, txid := range req.TxIDs {
+		txid = strings.ToLower(strings.TrimSpace(txid))
+		if _, err := hex.DecodeString(txid); err != nil || len(txid) != 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid txid: %s", txid)})
+			return
+		}
+		if !seen[txid] {
+			seen[txid] = true
+			txids = append(txids, txid)
+		}
+	}
+
+	if len(txids) == 0 || len(txids) > maxBatchTxids {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("between 1 and %d txids are required", maxBatchTxids)})
+		return
+	}
+
+	if req.BlockHash != "" {
+		if _, err := hex.DecodeString(req.BlockHash); err != nil || len(req.BlockHash) != 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blockhash"})
+			return
+		}
+	}
+
+	requests := make([]rpc.RPCRequest, len(txids))
+	for i, txid := range txids {
+		params := []interface{}{txid, req.Verbose}
+		if req.BlockHash != "" {
+			params = append(params, req.BlockHash)
+		}
+		requests[i] = rpc.RPCRequest{
+			Jsonrpc: "1.0",
+			Method:  "getrawtransaction",
+			Params:  params,
+			ID:      i,
+		}
+	}
+
+	responses, err := h.rpcFor(c).BatchCall(requests)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]TransactionResult, len(txids))
+	for i, txid := range txids {
+		results[i] = TransactionResult{TxID: txid, Error: "no response from node"}
+	}
+	for _, resp := range responses {
+		if resp.ID < 0 || resp.ID >= len(txids) {
+			continue
+		}
+		result := &results[resp.ID]
+		if resp.Error != nil {
+			result.Error = resp.Error.Message
+			if rpc.IsNoTxIndex(resp.Error) {
+				result.Error = noTxIndexMessage
+				result.TxIndexRequired = true
+			}
+			continue
+		}
+		result.Tx = resp.Result
+		result.Error = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": results,
+		"count":        len(results),
+	})
+}
+
+// GetFees handles GET /fees
+// Always returns a usable fee rate, flagging whether it came from the node's
+// smart estimator, the current mempool, or the configured fallback
+func (h *Handler) GetFees(c *gin.Context) {
+	confTarget, err := strconv.Atoi(c.DefaultQuery("conf_target", "6"))
+	if err != nil || confTarget < 1 || confTarget > 1008 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conf_target parameter (1-1008)"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.feesFor(c).EstimateFee(confTarget))
+}
+
+// GetConfirmProbability handles GET /confirm-probability
+// Estimates the chance a transaction paying feerate (sat/vB) is mined within
+// blocks blocks (default 1) from the current mempool; see fee.ConfirmProbability
+// for the model's assumptions
+func (h *Handler) GetConfirmProbability(c *gin.Context) {
+	feeRate, err := strconv.ParseFloat(c.Query("feerate"), 64)
+	if err != nil || feeRate <= 0 || math.IsInf(feeRate, 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "feerate parameter (sat/vB, > 0) is required"})
+		return
+	}
+
+	blocks, err := strconv.Atoi(c.DefaultQuery("blocks", "1"))
+	if err != nil || blocks < 1 || blocks > 1008 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blocks parameter (1-1008)"})
+		return
+	}
+
+	probability, err := h.feesFor(c).ConfirmProbability(feeRate, blocks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, probability)
+}
+
+// FeeEstimateRequest represents a transaction fee estimate request
+type FeeEstimateRequest struct {
+	RawTx      string `json:"raw_tx" binding:"required"`
+	ConfTarget int    `json:"conf_target"`
+}
+
+// EstimateTxFee handles POST /fees/estimate
+// Computes the fee for a raw transaction from its weight-based vsize, so
+// SegWit transactions get the witness discount
+func (h *Handler) EstimateTxFee(c *gin.Context) {
+	var req FeeEstimateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ConfTarget == 0 {
+		req.ConfTarget = 6
+	}
+	if req.ConfTarget < 1 || req.ConfTarget > 1008 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conf_target parameter (1-1008)"})
+		return
+	}
+
+	size, err := fee.TxSizeFromHex(req.RawTx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	estimate := h.feesFor(c).EstimateFee(req.ConfTarget)
+
+	c.JSON(http.StatusOK, gin.H{
+		"size":           size,
+		"feerate_sat_vb": estimate.FeeRate,
+		"source":         estimate.Source,
+		"fee_sats":       fee.FeeForVSize(size.VSize, estimate.FeeRate),
+	})
+}
+
+// HealthCheck handles GET /health
+func (h *Handler) HealthCheck(c *gin.Context) {
+	// Try to get block count to verify RPC connection
+	_, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unhealthy",
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "healthy",
+	})
+}
+
+// UTXOScanRequest represents a UTXO scan request
+type UTXOScanRequest struct {
+	Addresses    []string                 `json:"addresses"`
+	Descriptors  []filter.DescriptorRange `json:"descriptors"` // Ranged descriptors expanded via deriveaddresses
+	StartHeight  *int64                   `json:"start_height"`
+	EndHeight    *int64                   `json:"end_height"`
+	Limit        int                      `json:"limit"`          // Page size, 0 returns all UTXOs
+	Cursor       string                   `json:"cursor"`         // Opaque next_cursor from the previous page
+	BalanceOnly  bool                     `json:"balance_only"`   // Return totals without per-UTXO detail
+	IncludeRawTx bool                     `json:"include_raw_tx"` // Attach creating transaction hex (capped)
+	// Whether outputs spent by unconfirmed transactions count as spent (default true)
+	IncludeMempoolSpends *bool `json:"include_mempool_spends"`
+	DebugFilters         bool  `json:"debug_filters"`  // Include matched blocks' filters in statistics (spv mode)
+	IncludeProofs        bool  `json:"include_proofs"` // Attach a merkle inclusion proof and header to each UTXO (capped)
+	// Fail on any invalid address (default true); false skips and reports them
+	StrictAddresses *bool `json:"strict_addresses"`
+	// Unix time range, resolved to heights in place of start_height/end_height
+	StartTime *int64 `json:"start_time"`
+	EndTime   *int64 `json:"end_time"`
+	// "address" returns UTXOs grouped per address with balances in by_address
+	GroupBy string `json:"group_by"`
+	// "height" (default), "value_desc" or "value_asc"; value orders suit coin selection
+	Sort string `json:"sort"`
+	// Whether the block at end_height is scanned (default true); false scans
+	// [start_height, end_height), so consecutive ranges can share bounds
+	EndInclusive *bool `json:"end_inclusive"`
+	// Also return outputs created and spent within the range in
+	// spent_outputs, with spent_by_txid and spent_at_height
+	IncludeSpent bool `json:"include_spent"`
+	// Return the result as a snapshot signed together with the normalized
+	// request and the tip it was verified against (requires SNAPSHOT_SIGNING_KEY)
+	Signed bool `json:"signed"`
+	// Return the scriptPubKeys the scan would match, after descriptor
+	// expansion and deduplication, without scanning
+	ReturnScripts bool `json:"return_scripts"`
+}
+
+// ScanUTXOs handles POST /utxos/scan
+// Uses the global SPV_MODE configuration to determine scan method.
+// With "Accept: application/x-ndjson" each UTXO is streamed as its own line,
+// followed by a {"summary": ...} line. "Accept: text/csv" streams a CSV of
+// txid, vout, address, satoshis, height and confirmations instead. Lines are
+// written as the verification pass confirms each UTXO, so neither the result
+// nor its encoding is held; the block walk before it still keeps every
+// candidate output in memory, as spends later in the range can remove them.
+func (h *Handler) ScanUTXOs(c *gin.Context) {
+	var req UTXOScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Addresses) == 0 && len(req.Descriptors) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address or descriptor is required"})
+		return
+	}
+
+	if req.GroupBy != "" && req.GroupBy != "address" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group_by parameter (address)"})
+		return
+	}
+	if req.GroupBy != "" && req.BalanceOnly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by is not supported with balance_only"})
+		return
+	}
+
+	if !validScanSort(req.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort parameter (height, value_desc, value_asc)"})
+		return
+	}
+	// Cursors resume from a height, so pages must stay in chain order
+	if (req.Sort == scanSortValueDesc || req.Sort == scanSortValueAsc) && (req.Limit != 0 || req.Cursor != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value sorts are not supported with limit or cursor"})
+		return
+	}
+	// Spent outputs are not paginated, so every page would repeat them
+	if req.IncludeSpent && (req.Limit != 0 || req.Cursor != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include_spent is not supported with limit or cursor"})
+		return
+	}
+	// A signature vouches for the whole range's UTXO set, not one page of it
+	if req.Signed && (req.Limit != 0 || req.Cursor != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signed is not supported with limit or cursor"})
+		return
+	}
+
+	if req.Signed && h.snapshotKey == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signed snapshots are disabled (SNAPSHOT_SIGNING_KEY is not set)"})
+		return
+	}
+
+	// Streaming sends UTXOs as they are verified, so options that need the
+	// whole verified set first are not available
+	var stream scanStream
+	if wantsNDJSON(c) || wantsCSV(c) {
+		if req.Limit != 0 || req.Cursor != "" || req.BalanceOnly || req.IncludeRawTx || req.IncludeProofs || req.GroupBy != "" || req.Sort != "" || req.IncludeSpent || req.Signed || req.ReturnScripts {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit, cursor, balance_only, include_raw_tx, include_proofs, group_by, sort, include_spent, signed and return_scripts are not supported with application/x-ndjson or text/csv"})
+			return
+		}
+		if wantsNDJSON(c) {
+			stream = newNDJSONStream(c)
+		} else {
+			stream = newCSVStream(c)
+		}
+	}
+
+	// Resolve a time range to the heights of the blocks it covers
+	var timeRange *filter.HeightRange
+	if req.StartTime != nil || req.EndTime != nil {
+		if req.StartTime == nil || req.EndTime == nil || req.StartHeight != nil || req.EndHeight != nil || req.EndInclusive != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time must both be set, without start_height, end_height or end_inclusive"})
+			return
+		}
+
+		tip, err := h.rpcFor(c).GetBlockCount()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		start, end, err := h.filtersFor(c).ResolveTimeRange(*req.StartTime, *req.EndTime, tip)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		timeRange = &filter.HeightRange{StartHeight: start, EndHeight: end}
+
+		// No blocks in the time range
+		if start > end {
+			result := &filter.UTXOScanResult{HeightRange: timeRange}
+			result.SetUTXOs([]filter.UTXO{})
+			if stream != nil {
+				stream.Finish(result, nil)
+				return
+			}
+			c.JSON(http.StatusOK, result)
+			return
+		}
+		req.StartHeight, req.EndHeight = &start, &end
+	}
+
+	if req.StartHeight == nil || req.EndHeight == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_height and end_height (or start_time and end_time) are required"})
+		return
+	}
+
+	// An exclusive end is turned into the inclusive one the scan uses
+	if req.EndInclusive != nil && !*req.EndInclusive {
+		if *req.StartHeight >= *req.EndHeight {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_height must be below end_height when end_inclusive is false"})
+			return
+		}
+		end := *req.EndHeight - 1
+		req.EndHeight = &end
+	}
+
+	// start_height == end_height scans exactly that one block
+	if *req.StartHeight < 0 || *req.StartHeight > *req.EndHeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_height must be between 0 and end_height"})
+		return
+	}
+
+	if req.Limit < 0 || req.Limit > maxScanPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit parameter (0-%d)", maxScanPageSize)})
+		return
+	}
+
+	// Resume from the cursor, rejecting ones not issued for these parameters
+	requestHash := scanRequestHash(&req, h.filterService.AddressSetKey(req.Addresses))
+	startHeight := *req.StartHeight
+	var cursor *scanCursor
+	if req.Cursor != "" {
+		var err error
+		cursor, err = decodeCursor([]byte(h.config.CursorSecret), req.Cursor, requestHash)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Limit == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit is required when using a cursor"})
+			return
+		}
+		startHeight = cursor.LastHeight
+	}
+
+	// Expand ranged descriptors into addresses on the node, remembering
+	// each one's derivation path for the UTXOs paying it
+	var derivedAddresses map[string]filter.DerivedAddress
+	if len(req.Descriptors) > 0 {
+		derived, err := h.filtersFor(c).ExpandDescriptors(req.Descriptors)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		derivedAddresses = make(map[string]filter.DerivedAddress, len(derived))
+		for _, d := range derived {
+			req.Addresses = append(req.Addresses, d.Address)
+			derivedAddresses[d.Address] = d
+		}
+	}
+
+	// Strict scans reject the request on any invalid address; lenient scans
+	// leave them out and report them with the result
+	validAddresses, skipped := h.filterService.PartitionAddresses(req.Addresses)
+	if len(skipped) > 0 && (req.StrictAddresses == nil || *req.StrictAddresses) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             fmt.Sprintf("invalid address %s: %s", skipped[0].Address, skipped[0].Error),
+			"invalid_addresses": skipped,
+		})
+		return
+	}
+	req.Addresses = validAddresses
+
+	// Addresses paying the same script share its UTXOs: they are reported
+	// under the first such address and list the others, or rejected
+	aliases := h.filterService.ScriptAliases(req.Addresses)
+	if len(aliases) > 0 && h.config.DuplicateAddressScripts == "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "several addresses pay the same script",
+			"duplicate_scripts": aliases,
+		})
+		return
+	}
+	annotate := func(utxos []filter.UTXO) {
+		filter.AnnotateDerivations(utxos, derivedAddresses)
+		filter.AnnotateAliases(utxos, aliases)
+	}
+
+	// Preview what the scan matches, so clients can check the expansion
+	if req.ReturnScripts {
+		scripts, err := h.filterService.ScanScripts(req.Addresses)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		response := gin.H{
+			"scripts":      scripts,
+			"script_count": len(scripts),
+		}
+		if len(skipped) > 0 {
+			response["invalid_addresses"] = skipped
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Use global SPV_MODE configuration
+	mode := "direct"
+	if h.config.SPVMode {
+		mode = "spv"
+	}
+
+	if err := h.checkScanCost(mode, *req.EndHeight-startHeight+1, len(req.Addresses)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[UTXO Scan] Using mode: %s (from config), Addresses: %d, Range: %d-%d", 
+		mode, len(req.Addresses), startHeight, *req.EndHeight)
+
+	opts := filter.ScanOptions{
+		BalanceOnly:         req.BalanceOnly,
+		IgnoreMempoolSpends: req.IncludeMempoolSpends != nil && !*req.IncludeMempoolSpends,
+		DebugFilters:        req.DebugFilters,
+		IncludeSpent:        req.IncludeSpent,
+		RecordTip:           req.Signed,
+	}
+	if stream != nil {
+		opts.OnUTXO = func(utxo filter.UTXO) error {
+			utxos := []filter.UTXO{utxo}
+			annotate(utxos)
+			return stream.SendUTXO(utxos[0])
+		}
+	}
+
+	result, err := h.filtersFor(c).ScanUTXOsHybrid(req.Addresses, startHeight, *req.EndHeight, mode, opts)
+	if stream != nil {
+		if result != nil {
+			if result.Partial != nil {
+				annotate(result.Partial.UnverifiedUTXOs)
+			}
+			result.SkippedAddresses = skipped
+			result.HeightRange = timeRange
+		}
+		stream.Finish(result, err)
+		return
+	}
+	if err != nil {
+		// Out of RPC calls: return what was found, see result.partial
+		if errors.Is(err, rpc.ErrCallBudgetExceeded) && result != nil {
+			annotate(result.UTXOs)
+			if result.Partial != nil {
+				annotate(result.Partial.UnverifiedUTXOs)
+			}
+			result.SkippedAddresses = skipped
+			result.HeightRange = timeRange
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "partial_result": result})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result.SkippedAddresses = skipped
+	result.HeightRange = timeRange
+
+	if req.Limit > 0 && !req.BalanceOnly {
+		if err := paginateScanResult([]byte(h.config.CursorSecret), result, cursor, req.Limit, requestHash); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		sortUTXOs(result.UTXOs, req.Sort)
+	}
+
+	annotate(result.UTXOs)
+	for i := range result.SpentOutputs {
+		utxos := []filter.UTXO{result.SpentOutputs[i].UTXO}
+		annotate(utxos)
+		result.SpentOutputs[i].UTXO = utxos[0]
+	}
+
+	// Attach creating transactions for the UTXOs being returned
+	if req.IncludeRawTx && !req.BalanceOnly {
+		if err := h.filtersFor(c).AttachRawTransactions(result.UTXOs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Attach inclusion proofs for the UTXOs being returned
+	if req.IncludeProofs && !req.BalanceOnly {
+		if err := h.filtersFor(c).AttachProofs(result.UTXOs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.GroupBy == "address" {
+		result.GroupByAddress()
+	}
+
+	// Log statistics
+	if result.Statistics != nil {
+		log.Printf("[UTXO Scan] Stats: mode=%s, filtered=%d, scanned=%d, hit_rate=%.2f%%, time=%dms",
+			result.Statistics.Mode,
+			result.Statistics.BlocksFiltered,
+			result.Statistics.BlocksScanned,
+			result.Statistics.FilterHitRate*100,
+			result.Statistics.ScanTimeMs)
+	}
+
+	if req.Signed {
+		snapshot, err := h.signScanResult(SnapshotRequest{
+			AddressSetKey:        h.filterService.AddressSetKey(req.Addresses),
+			StartHeight:          *req.StartHeight,
+			EndHeight:            *req.EndHeight,
+			IncludeMempoolSpends: !opts.IgnoreMempoolSpends,
+		}, result)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, snapshot)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// IncrementalScanRequest represents a request to update a scanned UTXO set
+type IncrementalScanRequest struct {
+	Addresses     []string      `json:"addresses"`
+	FromHeight    *int64        `json:"from_height" binding:"required"` // First block not covered by previous_utxos
+	PreviousUTXOs []filter.UTXO `json:"previous_utxos"`
+	// Whether outputs spent by unconfirmed transactions count as spent (default true)
+	IncludeMempoolSpends *bool `json:"include_mempool_spends"`
+}
+
+// ScanUTXOsIncremental handles POST /utxos/scan/incremental
+// Scans only [from_height, tip] and applies the result to previous_utxos,
+// adding new outputs and removing spent ones. At most MaxScanRange+1 blocks
+// are scanned per call; caught_up is false until to_height reaches the tip.
+func (h *Handler) ScanUTXOsIncremental(c *gin.Context) {
+	var req IncrementalScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Addresses) == 0 && len(req.PreviousUTXOs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address or previous UTXO is required"})
+		return
+	}
+
+	if *req.FromHeight < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_height must not be negative"})
+		return
+	}
+
+	// The previous UTXOs' addresses are scanned too, so they are validated
+	// and count against the address limit
+	tracked := filter.IncrementalAddresses(req.Addresses, req.PreviousUTXOs)
+	if _, skipped := h.filterService.PartitionAddresses(tracked); len(skipped) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             fmt.Sprintf("invalid address %s: %s", skipped[0].Address, skipped[0].Error),
+			"invalid_addresses": skipped,
+		})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Already up to date: nothing new to scan
+	if *req.FromHeight > tip {
+		result := &filter.UTXOScanResult{}
+		result.SetUTXOs(req.PreviousUTXOs)
+		c.JSON(http.StatusOK, &filter.IncrementalScanResult{
+			UTXOScanResult: result,
+			FromHeight:     *req.FromHeight,
+			ToHeight:       tip,
+			Removed:        []filter.UTXO{},
+			TipHeight:      tip,
+			CaughtUp:       true,
+		})
+		return
+	}
+
+	// A wallet further behind than one scan covers catches up over several
+	// calls, each resuming from the last one's to_height+1
+	toHeight := tip
+	if toHeight-*req.FromHeight > filter.MaxScanRange {
+		toHeight = *req.FromHeight + filter.MaxScanRange
+	}
+
+	mode := "direct"
+	if h.config.SPVMode {
+		mode = "spv"
+	}
+
+	if err := h.checkScanCost(mode, toHeight-*req.FromHeight+1, len(tracked)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[UTXO Scan] Incremental, mode: %s, Addresses: %d, Previous: %d, Range: %d-%d",
+		mode, len(req.Addresses), len(req.PreviousUTXOs), *req.FromHeight, toHeight)
+
+	opts := filter.ScanOptions{
+		IgnoreMempoolSpends: req.IncludeMempoolSpends != nil && !*req.IncludeMempoolSpends,
+	}
+
+	result, err := h.filtersFor(c).ScanIncremental(req.Addresses, req.PreviousUTXOs, *req.FromHeight, toHeight, mode, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result.TipHeight = tip
+	result.CaughtUp = toHeight == tip
+
+	c.JSON(http.StatusOK, result)
 }
 
-// NewHandler creates a new API handler
-func NewHandler(rpcClient *rpc.Client, filterService *filter.Service, contractService *contract.Service, cfg *config.Config) *Handler {
-	return &Handler{
-		rpcClient:       rpcClient,
-		filterService:   filterService,
-		contractService: contractService,
-		config:          cfg,
+// NewUTXOsResult is the UTXOs confirmed after since_height. TipHeight is the
+// since_height of the next poll.
+type NewUTXOsResult struct {
+	*filter.UTXOScanResult
+	SinceHeight int64 `json:"since_height"`
+	TipHeight   int64 `json:"tip_height"`
+}
+
+// GetNewUTXOs handles GET /utxos/new?addresses=&since_height=
+// Scans only [since_height+1, tip] for the comma separated addresses and
+// returns the unspent outputs created there, not the full set, so deposit
+// pollers get a delta. Outputs created and spent within the range are left out.
+func (h *Handler) GetNewUTXOs(c *gin.Context) {
+	addresses := parseAddressList(c.Query("addresses"))
+	if len(addresses) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address is required"})
+		return
+	}
+	if _, skipped := h.filterService.PartitionAddresses(addresses); len(skipped) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             fmt.Sprintf("invalid address %s: %s", skipped[0].Address, skipped[0].Error),
+			"invalid_addresses": skipped,
+		})
+		return
+	}
+
+	sinceHeight, err := strconv.ParseInt(c.Query("since_height"), 10, 64)
+	if err != nil || sinceHeight < -1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since_height parameter (-1 scans from genesis)"})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Nothing confirmed since the last poll
+	if sinceHeight >= tip {
+		result := &filter.UTXOScanResult{}
+		result.SetUTXOs([]filter.UTXO{})
+		c.JSON(http.StatusOK, &NewUTXOsResult{UTXOScanResult: result, SinceHeight: sinceHeight, TipHeight: tip})
+		return
+	}
+
+	startHeight := sinceHeight + 1
+	if tip-startHeight > filter.MaxScanRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("since_height is more than %d blocks behind the tip %d", filter.MaxScanRange, tip)})
+		return
+	}
+
+	aliases := h.filterService.ScriptAliases(addresses)
+	if len(aliases) > 0 && h.config.DuplicateAddressScripts == "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "several addresses pay the same script",
+			"duplicate_scripts": aliases,
+		})
+		return
+	}
+
+	mode := "direct"
+	if h.config.SPVMode {
+		mode = "spv"
+	}
+	if err := h.checkScanCost(mode, tip-startHeight+1, len(addresses)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-}
 
-// fetchHeadersSequentially fetches multiple block headers in order
-// Simple and reliable - fetches headers one by one
-func (h *Handler) fetchHeadersSequentially(startHeight int64, count int) []map[string]interface{} {
-	var headers []map[string]interface{}
-	
-	// Get current blockchain height to avoid out-of-range errors
-	blockCount, err := h.rpcClient.GetBlockCount()
+	result, err := h.filtersFor(c).ScanUTXOsHybrid(addresses, startHeight, tip, mode, filter.ScanOptions{})
 	if err != nil {
-		log.Printf("Error getting block count: %v", err)
-		return headers
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	
-	// Adjust count if it exceeds available blocks
-	maxAvailable := blockCount - startHeight + 1
-	if int64(count) > maxAvailable {
-		count = int(maxAvailable)
-		log.Printf("Adjusted count to %d (blockchain height: %d, start: %d)", 
-			count, blockCount, startHeight)
+	filter.AnnotateAliases(result.UTXOs, aliases)
+
+	c.JSON(http.StatusOK, &NewUTXOsResult{UTXOScanResult: result, SinceHeight: sinceHeight, TipHeight: tip})
+}
+
+// ValidateAddress handles GET /address/:address/validate
+// Always returns 200; valid=false comes with an error distinguishing a
+// malformed address from one for another network
+func (h *Handler) ValidateAddress(c *gin.Context) {
+	c.JSON(http.StatusOK, h.filtersFor(c).ValidateAddress(c.Param("address")))
+}
+
+// maxValidateAddresses caps the addresses checked by one POST /addresses/validate request
+const maxValidateAddresses = 1000
+
+// ValidateAddressesRequest represents a batch address validation request
+type ValidateAddressesRequest struct {
+	Addresses []string `json:"addresses" binding:"required"`
+}
+
+// ValidateAddresses handles POST /addresses/validate
+// Validates each address like GET /address/:address/validate, locally
+// without calling the node. Results follow the request order.
+func (h *Handler) ValidateAddresses(c *gin.Context) {
+	var req ValidateAddressesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	
-	// Fetch headers sequentially
-	for i := 0; i < count; i++ {
-		height := startHeight + int64(i)
-		
-		// Get block hash at height
-		blockHash, err := h.rpcClient.GetBlockHash(height)
-		if err != nil {
-			log.Printf("Error getting block hash at height %d: %v", height, err)
-			break // Stop on first error
-		}
-		
-		// Get block header
-		headerData, err := h.rpcClient.GetBlockHeader(blockHash, true)
-		if err != nil {
-			log.Printf("Error getting block header at height %d: %v", height, err)
-			break // Stop on first error
-		}
-		
-		// Parse header
-		var header map[string]interface{}
-		if err := json.Unmarshal(headerData, &header); err != nil {
-			log.Printf("Error parsing header at height %d: %v", height, err)
-			break // Stop on first error
-		}
-		
-		headers = append(headers, header)
+	if len(req.Addresses) == 0 || len(req.Addresses) > maxValidateAddresses {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("between 1 and %d addresses are required", maxValidateAddresses)})
+		return
 	}
-	
-	return headers
+
+	results := make([]*filter.AddressInfo, len(req.Addresses))
+	for i, address := range req.Addresses {
+		results[i] = h.filterService.ValidateAddress(address)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
 }
 
-// GetBlockchainInfo handles GET /blockchaininfo
-func (h *Handler) GetBlockchainInfo(c *gin.Context) {
-	result, err := h.rpcClient.GetBlockchainInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// DescriptorInfoRequest represents a descriptor analysis request
+type DescriptorInfoRequest struct {
+	Descriptor string `json:"descriptor" binding:"required"`
+}
+
+// GetDescriptorInfo handles POST /descriptor/info
+// Returns the canonical descriptor with checksum, for building scan requests
+func (h *Handler) GetDescriptorInfo(c *gin.Context) {
+	var req DescriptorInfoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var info map[string]interface{}
-	if err := json.Unmarshal(result, &info); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse blockchain info"})
+	info, err := h.filtersFor(c).DescriptorInfo(req.Descriptor)
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": rpcErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, info)
 }
 
-// GetHeaders handles GET /headers
-func (h *Handler) GetHeaders(c *gin.Context) {
-	startHash := c.Query("start_hash")
-	countStr := c.DefaultQuery("count", "10")
-
-	count, err := strconv.Atoi(countStr)
-	if err != nil || count <= 0 || count > 2000 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid count parameter (1-2000)"})
+// GetAddressUsed handles GET /address/:address/used
+// Runs only the BIP158 filter pass over [start, end], at most 2000 blocks.
+// end defaults to the tip and start to 2000 blocks before end, so the
+// default covers the most recent blocks; older history needs an explicit range.
+// Filters can produce false positives, so ever_matched=true means the address
+// was "possibly used"; pass verify=true to confirm against the matched blocks.
+func (h *Handler) GetAddressUsed(c *gin.Context) {
+	address := c.Param("address")
+	if _, err := h.filterService.AddressToScriptPubKey(address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid address %s: %v", address, err)})
 		return
 	}
 
-	// Get starting block header
-	var startHeight int64
-	if startHash == "" {
-		// Start from tip
-		bestHash, err := h.rpcClient.GetBestBlockHash()
+	var endHeight int64
+	var err error
+	if endStr := c.Query("end"); endStr != "" {
+		endHeight, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || endHeight < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end parameter"})
+			return
+		}
+	} else {
+		endHeight, err = h.rpcFor(c).GetBlockCount()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		startHash = bestHash
 	}
 
-	// Get start block header to find height
-	headerData, err := h.rpcClient.GetBlockHeader(startHash, true)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	startHeight := endHeight - filter.MaxScanRange
+	if startHeight < 0 {
+		startHeight = 0
+	}
+	if startStr := c.Query("start"); startStr != "" {
+		startHeight, err = strconv.ParseInt(startStr, 10, 64)
+		if err != nil || startHeight < 0 || startHeight > endHeight {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start parameter (0-end)"})
+			return
+		}
+	}
+	if endHeight-startHeight > filter.MaxScanRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scan range too large, max %d blocks", filter.MaxScanRange)})
 		return
 	}
 
-	var header map[string]interface{}
-	if err := json.Unmarshal(headerData, &header); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse header"})
+	verify := c.Query("verify") == "true"
+
+	result, err := h.filtersFor(c).CheckAddressUsed(address, startHeight, endHeight, verify)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	startHeight = int64(header["height"].(float64))
+	c.JSON(http.StatusOK, result)
+}
 
-	// Fetch headers sequentially (simple and reliable)
-	headers := h.fetchHeadersSequentially(startHeight, count)
+// GetAddressBalanceAt handles GET /address/:address/balance-at/:height
+// Returns the address's balance as of a past height, ignoring later spends.
+// Blocks from ?from_height= (default 0) are scanned, at most 2000 of them.
+func (h *Handler) GetAddressBalanceAt(c *gin.Context) {
+	address := c.Param("address")
+	if _, err := h.filterService.AddressToScriptPubKey(address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid address %s: %v", address, err)})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"headers":      headers,
-		"start_height": startHeight,
-		"count":        len(headers),
-	})
-}
+	height, err := strconv.ParseInt(c.Param("height"), 10, 64)
+	if err != nil || height < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid height parameter"})
+		return
+	}
 
-// GetBlock handles GET /block/:hash
-func (h *Handler) GetBlock(c *gin.Context) {
-	blockHash := c.Param("hash")
-	if blockHash == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "block hash is required"})
+	fromHeight, err := strconv.ParseInt(c.DefaultQuery("from_height", "0"), 10, 64)
+	if err != nil || fromHeight < 0 || fromHeight > height {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from_height parameter (0-height)"})
+		return
+	}
+	if height-fromHeight > filter.MaxScanRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("height is more than %d blocks after from_height; set from_height to a height before the address's first use", filter.MaxScanRange)})
 		return
 	}
 
-	blockData, err := h.rpcClient.GetBlock(blockHash, 2) // verbosity=2 for full details
+	tip, err := h.rpcFor(c).GetBlockCount()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if height > tip {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("height beyond the chain tip %d", tip)})
+		return
+	}
 
-	var block map[string]interface{}
-	if err := json.Unmarshal(blockData, &block); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse block"})
+	mode := "direct"
+	if h.config.SPVMode {
+		mode = "spv"
+	}
+	if err := h.checkScanCost(mode, height-fromHeight+1, 1); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, block)
+	balance, err := h.filtersFor(c).BalanceAt(address, fromHeight, height, mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
 }
 
-// BroadcastRequest represents a transaction broadcast request
-type BroadcastRequest struct {
-	RawTx string `json:"raw_tx" binding:"required"`
+// FilterVerifyRequest represents a filter comparison request
+type FilterVerifyRequest struct {
+	BlockHash string `json:"block_hash" binding:"required"`
+	FilterHex string `json:"filter_hex" binding:"required"`
 }
 
-// BroadcastTx handles POST /broadcast
-func (h *Handler) BroadcastTx(c *gin.Context) {
-	var req BroadcastRequest
+// VerifyFilter handles POST /filter/verify
+// Compares a client-computed BIP158 filter with the node's filter for the block
+func (h *Handler) VerifyFilter(c *gin.Context) {
+	var req FilterVerifyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-
-	txid, err := h.rpcClient.SendRawTransaction(req.RawTx)
+	if hash, err := hex.DecodeString(req.BlockHash); err != nil || len(hash) != 32 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid block_hash"})
+		return
+	}
+	clientFilter, err := hex.DecodeString(req.FilterHex)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filter_hex"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"txid": txid})
-}
-
-// HealthCheck handles GET /health
-func (h *Handler) HealthCheck(c *gin.Context) {
-	// Try to get block count to verify RPC connection
-	_, err := h.rpcClient.GetBlockCount()
+	result, err := h.filtersFor(c).CompareFilter(req.BlockHash, clientFilter)
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "unhealthy",
-			"error":  err.Error(),
-		})
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey {
+			c.JSON(http.StatusNotFound, gin.H{"error": "block not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
-	})
+	c.JSON(http.StatusOK, result)
 }
 
-// UTXOScanRequest represents a UTXO scan request
-type UTXOScanRequest struct {
-	Addresses   []string `json:"addresses" binding:"required"`
-	StartHeight *int64   `json:"start_height" binding:"required"`
-	EndHeight   *int64   `json:"end_height" binding:"required"`
+// FilterAnalyzeRequest represents a filter false-positive analysis request
+type FilterAnalyzeRequest struct {
+	Addresses []string `json:"addresses" binding:"required"`
+	Start     *int64   `json:"start" binding:"required"`
+	End       *int64   `json:"end"` // Default: the tip
 }
 
-// ScanUTXOs handles POST /utxos/scan
-// Uses the global SPV_MODE configuration to determine scan method
-func (h *Handler) ScanUTXOs(c *gin.Context) {
-	var req UTXOScanRequest
+// AnalyzeFilters handles POST /filter/analyze
+// Runs the filter pass for the addresses over [start, end] and checks every
+// matched block for a real output or spend, reporting which matches were
+// false positives per block and per address
+func (h *Handler) AnalyzeFilters(c *gin.Context) {
+	var req FilterAnalyzeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
 	if len(req.Addresses) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address is required"})
 		return
 	}
-
-	if req.StartHeight == nil || req.EndHeight == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "start_height and end_height are required"})
+	if _, skipped := h.filterService.PartitionAddresses(req.Addresses); len(skipped) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             fmt.Sprintf("invalid address %s: %s", skipped[0].Address, skipped[0].Error),
+			"invalid_addresses": skipped,
+		})
 		return
 	}
 
-	// Use global SPV_MODE configuration
-	mode := "direct"
-	if h.config.SPVMode {
-		mode = "spv"
+	var end int64
+	if req.End != nil {
+		end = *req.End
+	} else {
+		tip, err := h.rpcFor(c).GetBlockCount()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		end = tip
+	}
+	if *req.Start < 0 || *req.Start > end {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be between 0 and end"})
+		return
+	}
+	if end-*req.Start > filter.MaxScanRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scan range too large, max %d blocks", filter.MaxScanRange)})
+		return
+	}
+	if err := h.checkScanCost("spv", end-*req.Start+1, len(req.Addresses)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	log.Printf("[UTXO Scan] Using mode: %s (from config), Addresses: %d, Range: %d-%d", 
-		mode, len(req.Addresses), *req.StartHeight, *req.EndHeight)
-
-	result, err := h.filterService.ScanUTXOsHybrid(req.Addresses, *req.StartHeight, *req.EndHeight, mode)
+	result, err := h.filtersFor(c).AnalyzeFilters(req.Addresses, *req.Start, end)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Log statistics
-	if result.Statistics != nil {
-		log.Printf("[UTXO Scan] Stats: mode=%s, filtered=%d, scanned=%d, hit_rate=%.2f%%, time=%dms",
-			result.Statistics.Mode,
-			result.Statistics.BlocksFiltered,
-			result.Statistics.BlocksScanned,
-			result.Statistics.FilterHitRate*100,
-			result.Statistics.ScanTimeMs)
-	}
-
 	c.JSON(http.StatusOK, result)
 }
 
 // CallContractRequest represents a contract call request
 type CallContractRequest struct {
-	Method string   `json:"method" binding:"required"`
-	Params []string `json:"params"`
+	Address  string   `json:"address"`  // Explicit contract address, takes precedence over contract
+	Contract string   `json:"contract"` // Configured contract name, default contract if both are empty
+	Method   string   `json:"method" binding:"required"`
+	Params   []string `json:"params"`
 }
 
 // CallContract handles POST /contract/call
@@ -290,7 +2263,13 @@ func (h *Handler) CallContract(c *gin.Context) {
 		req.Params = []string{}
 	}
 
-	result, err := h.contractService.CallContract(req.Method, req.Params)
+	address, err := h.contractService.ResolveContract(req.Address, req.Contract)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.contractsFor(c).CallContract(address, req.Method, req.Params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -309,8 +2288,10 @@ func (h *Handler) CallContract(c *gin.Context) {
 
 // QueryContractRequest represents a contract query request
 type QueryContractRequest struct {
-	Method string   `json:"method" binding:"required"`
-	Params []string `json:"params"`
+	Address  string   `json:"address"`  // Explicit contract address, takes precedence over contract
+	Contract string   `json:"contract"` // Configured contract name, default contract if both are empty
+	Method   string   `json:"method" binding:"required"`
+	Params   []string `json:"params"`
 }
 
 // QueryContract handles POST /contract/query
@@ -331,7 +2312,13 @@ func (h *Handler) QueryContract(c *gin.Context) {
 		req.Params = []string{}
 	}
 
-	result, err := h.contractService.DumpContractMessage(req.Method, req.Params)
+	address, err := h.contractService.ResolveContract(req.Address, req.Contract)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.contractsFor(c).DumpContractMessage(address, req.Method, req.Params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -350,14 +2337,32 @@ func (h *Handler) QueryContract(c *gin.Context) {
 
 // otrequest
 
+// validateOTAmount checks an OT amount in satoshis against the configured bounds
+func (h *Handler) validateOTAmount(amount *int64) error {
+	if amount == nil {
+		return fmt.Errorf("amount is required")
+	}
+	if *amount < 0 {
+		return fmt.Errorf("amount must not be negative")
+	}
+	if *amount < h.config.OTMinAmount {
+		return fmt.Errorf("amount must be at least %d satoshis", h.config.OTMinAmount)
+	}
+	if *amount > h.config.OTMaxAmount {
+		return fmt.Errorf("amount must be at most %d satoshis", h.config.OTMaxAmount)
+	}
+	return nil
+}
+
 // SendOTRequest handles POST /ot/send
 // Broadcasts the fully signed raw transaction received from the Flutter wallet.
 func (h *Handler) SendOTRequest(c *gin.Context) {
 	// 1. Define input structure
+	// Amount is a pointer so an explicit 0 is not mistaken for a missing field
 	var req struct {
 		FromAID string `json:"from_aid" binding:"required"`
 		ToAID   string `json:"to_aid" binding:"required"`
-		Amount  int64  `json:"amount" binding:"required"`
+		Amount  *int64 `json:"amount"` // Satoshis
 		RawTx   string `json:"raw_tx" binding:"required"`
 	}
 
@@ -367,8 +2372,13 @@ func (h *Handler) SendOTRequest(c *gin.Context) {
 		return
 	}
 
+	if err := h.validateOTAmount(req.Amount); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "success": false})
+		return
+	}
+
 	// 3. Call C++ RPC to broadcast transaction
-	txid, err := h.rpcClient.SendRawTransaction(req.RawTx)
+	txid, err := h.rpcFor(c).SendRawTransaction(req.RawTx)
 	if err != nil {
 
 		log.Println("!!! [DEBUG] SendOTRequest: error: h.rpcClient.SendRawTransaction failed:", err)
@@ -387,32 +2397,59 @@ func (h *Handler) SendOTRequest(c *gin.Context) {
 	})
 }
 
+// HandleRpcProxy forwards a JSON-RPC request to the node. The response
+// envelope is PROXY_ENVELOPE unless the request sets ?envelope=; batches are
+// answered with the node's response array.
 func (h *Handler) HandleRpcProxy(c *gin.Context) {
+	envelope := h.proxyEnvelope(c)
+	if !validProxyEnvelope(envelope) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"result": nil,
+			"error":  proxyFailure("invalid envelope parameter (rpc, jsonrpc, result)"),
+		})
+		return
+	}
+
+	// Buffer the body so the method can be read for metrics before forwarding
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		writeProxyError(c, http.StatusBadRequest, envelope, nil, proxyFailure("failed to read request: "+err.Error()))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
 	// directly proxy the request body to the C++ RPC server
-	result, rpcErr, err := h.rpcClient.ProxyRPC(c.Request.Body)
+	start := time.Now()
+	result, rpcErr, err := h.rpcFor(c).ProxyRPC(c.Request.Body)
+	if h.proxyMetrics != nil {
+		h.proxyMetrics.Record(proxiedMethod(body), time.Since(start), err != nil || rpcErr != nil)
+	}
+	if errors.Is(err, rpc.ErrMethodNotAllowed) {
+		writeProxyError(c, http.StatusForbidden, envelope, body, proxyFailure(err.Error()))
+		return
+	}
 	if err != nil {
 		// This is a network or Go internal error
 		log.Println("!!! [DEBUG] HandleRpcProxy: transport error:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"result": nil,
-			"error":  gin.H{"code": -500, "message": err.Error()},
-		})
+		writeProxyError(c, http.StatusInternalServerError, envelope, body, proxyFailure(err.Error()))
 		return
 	}
 	if rpcErr != nil {
 		// This is an error returned by the C++ node (e.g. "Invalid params")
+		// C++ errors should still return 200 OK, but with an error object
 		log.Println("!!! [DEBUG] HandleRpcProxy: C++ RPC error:", rpcErr.Message)
-		c.JSON(http.StatusOK, gin.H{ // C++ errors should still return 200 OK, but with an error object
-			"result": nil,
-			"error":  rpcErr,
-		})
+		writeProxyError(c, http.StatusOK, envelope, body, rpcErr)
+		return
+	}
+
+	// A batch is answered with the node's response array, whose elements
+	// already carry their own result, error and id
+	if rpc.IsBatch(body) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", result)
 		return
 	}
 
 	// success, return the "result" object from C++
 	log.Println("--- [DEBUG] HandleRpcProxy: C++ RPC success")
-	c.JSON(http.StatusOK, gin.H{
-		"result": result,
-		"error":  nil,
-	})
+	writeProxyResult(c, envelope, body, result)
 }
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestGetHashrate(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	var params [][2]int
+	s.node.Handle("getnetworkhashps", func(p []json.RawMessage) (interface{}, error) {
+		var blocks, height int
+		if _, err := rpctest.Param(p, 0, &blocks); err != nil {
+			return nil, err
+		}
+		if _, err := rpctest.Param(p, 1, &height); err != nil {
+			return nil, err
+		}
+		params = append(params, [2]int{blocks, height})
+		return 1.5e18, nil
+	})
+
+	var resp struct {
+		HashesPerSecond float64 `json:"hashes_per_second"`
+		Blocks          int     `json:"blocks"`
+		Height          int     `json:"height"`
+	}
+	w := s.do(http.MethodGet, "/hashrate", nil)
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &resp)
+	if resp.HashesPerSecond != 1.5e18 || resp.Blocks != -1 || resp.Height != -1 {
+		t.Errorf("default hashrate %+v, want the difficulty period at the tip", resp)
+	}
+
+	w = s.do(http.MethodGet, "/hashrate?blocks=120&height=800000", nil)
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &resp)
+	if resp.Blocks != 120 || resp.Height != 800000 {
+		t.Errorf("hashrate %+v, want 120 blocks before 800000", resp)
+	}
+	if len(params) != 2 || params[0] != [2]int{-1, -1} || params[1] != [2]int{120, 800000} {
+		t.Errorf("node called with %v", params)
+	}
+
+	for _, query := range []string{"blocks=0", "blocks=-2", "blocks=x", "height=-2"} {
+		expectStatus(t, s.do(http.MethodGet, "/hashrate?"+query, nil), http.StatusBadRequest)
+	}
+	if len(params) != 2 {
+		t.Error("invalid requests reached the node")
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// keys returns a map's keys in order
+func keys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestHeaderFieldSelection(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 3; i++ {
+		s.chain.AddBlock()
+	}
+	block := s.chain.BlockAt(2)
+
+	w := s.do(http.MethodGet, "/header/"+block.Hash+"?fields=hash,height,time,previousblockhash", nil)
+	expectStatus(t, w, http.StatusOK)
+	var header map[string]interface{}
+	decode(t, w, &header)
+	if got, want := keys(header), []string{"hash", "height", "previousblockhash", "time"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields %v, want only %v", got, want)
+	}
+	if header["hash"] != block.Hash || header["height"] != float64(2) || header["time"] != float64(block.Time()) ||
+		header["previousblockhash"] != s.chain.BlockAt(1).Hash {
+		t.Errorf("projected header %v does not match block 2", header)
+	}
+
+	// Without a projection every field is returned
+	w = s.do(http.MethodGet, "/header/"+block.Hash, nil)
+	expectStatus(t, w, http.StatusOK)
+	var full map[string]interface{}
+	decode(t, w, &full)
+	for _, field := range []string{"chainwork", "mediantime", "nTx", "merkleroot"} {
+		if _, ok := full[field]; !ok {
+			t.Errorf("full header lacks %s", field)
+		}
+	}
+}
+
+func TestHeadersFieldSelection(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 5; i++ {
+		s.chain.AddBlock()
+	}
+
+	w := s.do(http.MethodGet, "/headers?start_hash="+s.chain.BlockAt(1).Hash+"&count=3&fields=hash,%20height", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Headers []map[string]interface{} `json:"headers"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Headers) != 3 {
+		t.Fatalf("got %d headers, want 3", len(resp.Headers))
+	}
+	for i, header := range resp.Headers {
+		if got := keys(header); !reflect.DeepEqual(got, []string{"hash", "height"}) {
+			t.Errorf("header %d has fields %v, want hash and height", i, got)
+		}
+		if header["hash"] != s.chain.BlockAt(int64(i+1)).Hash {
+			t.Errorf("header %d is %v, want block %d", i, header["hash"], i+1)
+		}
+	}
+
+	expectStatus(t, s.do(http.MethodGet, "/headers?fields=hash,coinbase", nil), http.StatusBadRequest)
+	expectStatus(t, s.do(http.MethodGet, "/header/"+s.chain.Tip().Hash+"?fields=coinbase", nil), http.StatusBadRequest)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// headerProof is the body of GET /header-proof/:height
+type headerProof struct {
+	Height           int64    `json:"height"`
+	Hash             string   `json:"hash"`
+	Header           string   `json:"header"`
+	CheckpointHeight int64    `json:"checkpoint_height"`
+	CheckpointHash   string   `json:"checkpoint_hash"`
+	StartHeight      int64    `json:"start_height"`
+	Headers          []string `json:"headers"`
+}
+
+// connectedHashes parses a header chain, checking each header links to the
+// one before, and returns the headers' hashes
+func connectedHashes(t *testing.T, headers []string) []string {
+	t.Helper()
+	hashes := make([]string, len(headers))
+	for i, headerHex := range headers {
+		raw, err := hex.DecodeString(headerHex)
+		if err != nil || len(raw) != wire.MaxBlockHeaderPayload {
+			t.Fatalf("header %d is not 80 bytes of hex: %s", i, headerHex)
+		}
+		var header wire.BlockHeader
+		if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && header.PrevBlock.String() != hashes[i-1] {
+			t.Fatalf("header %d does not link to header %d", i, i-1)
+		}
+		hashes[i] = header.BlockHash().String()
+	}
+	return hashes
+}
+
+func TestHeaderProofConnectsHeightToTip(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 20; i++ {
+		s.chain.AddBlock()
+	}
+
+	w := s.do(http.MethodGet, "/header-proof/5", nil)
+	expectStatus(t, w, http.StatusOK)
+	var proof headerProof
+	decode(t, w, &proof)
+
+	hashes := connectedHashes(t, proof.Headers)
+	if proof.StartHeight != 5 || len(hashes) != 16 {
+		t.Fatalf("chain of %d headers from %d, want 16 from 5", len(hashes), proof.StartHeight)
+	}
+	// The chain starts at the requested block and ends at the tip the
+	// client trusts
+	target := s.chain.BlockAt(5)
+	if proof.Height != 5 || proof.Hash != target.Hash || hashes[0] != target.Hash || proof.Header != proof.Headers[0] {
+		t.Errorf("proof for %d %s, want block 5 %s", proof.Height, proof.Hash, target.Hash)
+	}
+	tip := s.chain.Tip()
+	if proof.CheckpointHeight != tip.Height || proof.CheckpointHash != tip.Hash || hashes[len(hashes)-1] != tip.Hash {
+		t.Errorf("checkpoint %d %s, want the tip %d %s", proof.CheckpointHeight, proof.CheckpointHash, tip.Height, tip.Hash)
+	}
+}
+
+func TestHeaderProofFromEarlierCheckpoint(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 20; i++ {
+		s.chain.AddBlock()
+	}
+
+	// A client trusting block 10 verifies block 15 by walking forward
+	w := s.do(http.MethodGet, "/header-proof/15?checkpoint=10", nil)
+	expectStatus(t, w, http.StatusOK)
+	var proof headerProof
+	decode(t, w, &proof)
+
+	hashes := connectedHashes(t, proof.Headers)
+	if proof.StartHeight != 10 || len(hashes) != 6 {
+		t.Fatalf("chain of %d headers from %d, want 6 from 10", len(hashes), proof.StartHeight)
+	}
+	if hashes[0] != s.chain.BlockAt(10).Hash || proof.CheckpointHash != hashes[0] {
+		t.Errorf("chain starts at %s, want the checkpoint %s", hashes[0], s.chain.BlockAt(10).Hash)
+	}
+	if hashes[5] != s.chain.BlockAt(15).Hash || proof.Hash != hashes[5] {
+		t.Errorf("chain ends at %s, want block 15 %s", hashes[5], s.chain.BlockAt(15).Hash)
+	}
+}
+
+func TestHeaderProofRejectsBadHeights(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 5; i++ {
+		s.chain.AddBlock()
+	}
+
+	for path, status := range map[string]int{
+		"/header-proof/x":              http.StatusBadRequest,
+		"/header-proof/-1":             http.StatusBadRequest,
+		"/header-proof/2?checkpoint=x": http.StatusBadRequest,
+		"/header-proof/6":              http.StatusNotFound,
+		"/header-proof/2?checkpoint=6": http.StatusNotFound,
+		"/header-proof/5":              http.StatusOK,
+	} {
+		t.Run(path, func(t *testing.T) {
+			expectStatus(t, s.do(http.MethodGet, path, nil), status)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// customRPCs are the non-standard node RPCs the contract and OT features need
+var customRPCs = []string{"callcontract", "dumpcontractmessage", "validateotrequest"}
+
+// RPCCheck reports whether a node RPC is usable
+type RPCCheck struct {
+	Method    string `json:"method"`
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// probeRPC calls a method without parameters. Nodes answer that with a usage
+// error instead of running it, so the probe has no side effects; only a
+// "method not found" error means the RPC is missing.
+func probeRPC(client *rpc.Client, method string) RPCCheck {
+	check := RPCCheck{Method: method}
+
+	_, err := client.Call(method)
+
+	var rpcErr *rpc.RPCError
+	switch {
+	case err == nil:
+		check.Available = true
+	case errors.As(err, &rpcErr) && rpcErr.Code != rpc.ErrCodeMethodNotFound:
+		check.Available = true
+	default:
+		check.Error = err.Error()
+	}
+
+	return check
+}
+
+// HealthCheckDetailed handles GET /health/detailed
+// Reports the node connection and, when HEALTH_DEEP_CHECKS is enabled, the
+// availability of each custom contract and OT RPC
+func (h *Handler) HealthCheckDetailed(c *gin.Context) {
+	client := h.rpcFor(c)
+
+	status := "healthy"
+	node := gin.H{"connected": true}
+
+	blockCount, err := client.GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unhealthy",
+			"node":   gin.H{"connected": false, "error": err.Error()},
+		})
+		return
+	}
+	node["blocks"] = blockCount
+
+	response := gin.H{"node": node}
+
+	if !h.config.HealthDeepChecks {
+		response["rpcs"] = "skipped (HEALTH_DEEP_CHECKS disabled)"
+	} else {
+		checks := make([]RPCCheck, 0, len(customRPCs))
+		for _, method := range customRPCs {
+			check := probeRPC(client, method)
+			if !check.Available {
+				status = "degraded"
+			}
+			checks = append(checks, check)
+		}
+		response["rpcs"] = checks
+	}
+
+	response["status"] = status
+	c.JSON(http.StatusOK, response)
+}
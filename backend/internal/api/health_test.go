@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpc"
+)
+
+// usageError is how a node answers an RPC called without its parameters
+func usageError(params []json.RawMessage) (interface{}, error) {
+	return nil, &rpc.RPCError{Code: -1, Message: "usage: missing parameters"}
+}
+
+// detailedHealth is the GET /health/detailed response with deep checks
+type detailedHealth struct {
+	Status string     `json:"status"`
+	RPCs   []RPCCheck `json:"rpcs"`
+}
+
+func TestHealthDetailedFlagsMissingRPC(t *testing.T) {
+	s := newTestServer(t, &config.Config{HealthDeepChecks: true}, nil, nil)
+	// callcontract is left unhandled, so the node reports it as not found
+	s.node.Handle("dumpcontractmessage", usageError)
+	s.node.Handle("validateotrequest", usageError)
+
+	w := s.do(http.MethodGet, "/health/detailed", nil)
+	expectStatus(t, w, http.StatusOK)
+	var health detailedHealth
+	decode(t, w, &health)
+	if health.Status != "degraded" {
+		t.Errorf("status %q, want degraded", health.Status)
+	}
+	available := make(map[string]bool)
+	for _, check := range health.RPCs {
+		available[check.Method] = check.Available
+		if !check.Available && check.Error == "" {
+			t.Errorf("%s is unavailable without an error", check.Method)
+		}
+	}
+	want := map[string]bool{"callcontract": false, "dumpcontractmessage": true, "validateotrequest": true}
+	for method, ok := range want {
+		if got, probed := available[method]; !probed || got != ok {
+			t.Errorf("%s available %v (probed %v), want %v", method, got, probed, ok)
+		}
+	}
+}
+
+func TestHealthDetailedAllAvailable(t *testing.T) {
+	s := newTestServer(t, &config.Config{HealthDeepChecks: true}, nil, nil)
+	for _, method := range customRPCs {
+		s.node.Handle(method, usageError)
+	}
+
+	w := s.do(http.MethodGet, "/health/detailed", nil)
+	expectStatus(t, w, http.StatusOK)
+	var health detailedHealth
+	decode(t, w, &health)
+	if health.Status != "healthy" || len(health.RPCs) != len(customRPCs) {
+		t.Errorf("got %+v, want every RPC available", health)
+	}
+}
+
+func TestHealthDetailedSkipsProbesByDefault(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+
+	w := s.do(http.MethodGet, "/health/detailed", nil)
+	expectStatus(t, w, http.StatusOK)
+	var health map[string]interface{}
+	decode(t, w, &health)
+	if health["status"] != "healthy" {
+		t.Errorf("status %v, want healthy", health["status"])
+	}
+	for _, method := range customRPCs {
+		if s.node.Calls(method) != 0 {
+			t.Errorf("%s probed with deep checks disabled", method)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/auth"
+	"spv-backend/internal/contract"
+	"spv-backend/internal/fee"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/ot"
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/gin-gonic/gin"
+)
+
+// testParams is the chain the API tests run on
+var testParams = &chaincfg.RegressionNetParams
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testServer is the API over an in-memory node
+type testServer struct {
+	chain   *rpctest.Chain
+	node    *rpctest.Node
+	handler *Handler
+	router  *gin.Engine
+}
+
+// newTestServer serves the API with cfg against a fresh regtest chain. The
+// router is built after setup, which may adjust the handler and its services.
+func newTestServer(t *testing.T, cfg *config.Config, authenticator auth.Authenticator, setup func(*testServer), opts ...rpc.Option) *testServer {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+
+	chain := rpctest.NewChain(testParams)
+	node := rpctest.NewNode(t, chain)
+	client := node.Client(opts...)
+
+	s := &testServer{
+		chain: chain,
+		node:  node,
+		handler: NewHandler(client, filter.NewService(client, testParams), contract.NewService(client, ""),
+			fee.NewService(client, 1), ot.NewService(client), nil, nil, nil, cfg),
+	}
+	if setup != nil {
+		setup(s)
+	}
+	s.router = SetupRouter(s.handler, authenticator)
+	return s
+}
+
+// do serves a request, marshalling a non-nil body that is not already bytes
+func (s *testServer) do(method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+	case string:
+		reader = bytes.NewReader([]byte(body))
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals a response body, failing the test if it does not parse
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+// expectStatus fails the test unless the response has the status
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+}
+
+// scanBody is a POST /utxos/scan body over an inclusive height range
+func scanBody(addresses []string, start, end int64, extra map[string]interface{}) map[string]interface{} {
+	body := map[string]interface{}{
+		"addresses":    addresses,
+		"start_height": start,
+		"end_height":   end,
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return body
+}
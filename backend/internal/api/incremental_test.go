@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIncrementalScanRemovesSpentPreviousUTXO(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(address, 1000), rpctest.PayTo(address, 2000))
+	s.chain.AddBlock(fund)
+	s.chain.AddBlock()
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, 2, nil))
+	expectStatus(t, w, http.StatusOK)
+	var previous filter.UTXOScanResult
+	decode(t, w, &previous)
+	if previous.TotalUTXOs != 2 {
+		t.Fatalf("initial scan found %d UTXOs", previous.TotalUTXOs)
+	}
+
+	// Block 3 spends the 1000 sat output, block 4 pays a new one
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}))
+	paid := s.chain.NewTx(nil, rpctest.PayTo(address, 3000))
+	s.chain.AddBlock(paid)
+
+	w = s.do(http.MethodPost, "/utxos/scan/incremental", gin.H{
+		"addresses":      []string{address.EncodeAddress()},
+		"from_height":    3,
+		"previous_utxos": previous.UTXOs,
+	})
+	expectStatus(t, w, http.StatusOK)
+	var result filter.IncrementalScanResult
+	decode(t, w, &result)
+
+	if len(result.Removed) != 1 || result.Removed[0].TxID != fund.TxHash().String() || result.Removed[0].Vout != 0 {
+		t.Errorf("removed %+v, want the spent output", result.Removed)
+	}
+	if result.Added != 1 || result.TotalUTXOs != 2 || result.TotalSatoshis != 5000 {
+		t.Errorf("added %d, now %d UTXOs with %d sats", result.Added, result.TotalUTXOs, result.TotalSatoshis)
+	}
+	if result.FromHeight != 3 || result.ToHeight != 4 || result.TipHeight != 4 || !result.CaughtUp {
+		t.Errorf("range %d-%d, tip %d, caught up %v", result.FromHeight, result.ToHeight, result.TipHeight, result.CaughtUp)
+	}
+}
+
+func TestIncrementalScanResumesFarBehind(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2tr", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	for s.chain.Height() < filter.MaxScanRange+50 {
+		s.chain.AddBlock()
+	}
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 2000)))
+	tip := s.chain.Height()
+
+	scan := func(from int64, previous []filter.UTXO) filter.IncrementalScanResult {
+		t.Helper()
+		w := s.do(http.MethodPost, "/utxos/scan/incremental", gin.H{
+			"addresses":      []string{address.EncodeAddress()},
+			"from_height":    from,
+			"previous_utxos": previous,
+		})
+		expectStatus(t, w, http.StatusOK)
+		var result filter.IncrementalScanResult
+		decode(t, w, &result)
+		return result
+	}
+
+	// More than one scan's range behind: the first call stops short
+	first := scan(0, nil)
+	if first.CaughtUp || first.ToHeight != filter.MaxScanRange || first.TipHeight != tip || first.TotalUTXOs != 1 {
+		t.Fatalf("first call: to %d, tip %d, caught up %v, %d UTXOs", first.ToHeight, first.TipHeight, first.CaughtUp, first.TotalUTXOs)
+	}
+
+	second := scan(first.ToHeight+1, first.UTXOs)
+	if !second.CaughtUp || second.ToHeight != tip || second.TotalUTXOs != 2 || second.TotalSatoshis != 3000 {
+		t.Fatalf("second call: to %d, caught up %v, %d UTXOs with %d sats", second.ToHeight, second.CaughtUp, second.TotalUTXOs, second.TotalSatoshis)
+	}
+}
+
+func TestIncrementalScanRejectsInvalidPreviousAddresses(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+	valid := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	mainnet := rpctest.Address(&chaincfg.MainNetParams, "p2wpkh", 2).EncodeAddress()
+
+	w := s.do(http.MethodPost, "/utxos/scan/incremental", gin.H{
+		"addresses":   []string{valid},
+		"from_height": 1,
+		"previous_utxos": []filter.UTXO{
+			{TxID: strings.Repeat("ab", 32), Vout: 0, Address: valid, Satoshis: 1000},
+			{TxID: strings.Repeat("cd", 32), Vout: 1, Address: mainnet, Satoshis: 2000},
+		},
+	})
+	expectStatus(t, w, http.StatusBadRequest)
+	var resp struct {
+		InvalidAddresses []filter.SkippedAddress `json:"invalid_addresses"`
+	}
+	decode(t, w, &resp)
+	if len(resp.InvalidAddresses) != 1 || resp.InvalidAddresses[0].Address != mainnet {
+		t.Errorf("invalid addresses %+v, want %s", resp.InvalidAddresses, mainnet)
+	}
+	if s.node.Calls("getblockcount") != 0 {
+		t.Error("rejected scan reached the node")
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanMempoolSpends(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(address, 1000), rpctest.PayTo(address, 2000))
+	s.chain.AddBlock(fund)
+	// The first output is spent only by an unconfirmed transaction
+	s.chain.AddToMempool(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 9), 900)))
+	addresses := []string{address.EncodeAddress()}
+
+	tests := []struct {
+		name  string
+		extra map[string]interface{}
+		want  []int64
+	}{
+		{"default", nil, []int64{2000}},
+		{"included", map[string]interface{}{"include_mempool_spends": true}, []int64{2000}},
+		{"confirmed only", map[string]interface{}{"include_mempool_spends": false}, []int64{1000, 2000}},
+	}
+	for _, tt := range tests {
+		w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), tt.extra))
+		expectStatus(t, w, http.StatusOK)
+		var result filter.UTXOScanResult
+		decode(t, w, &result)
+		var got []int64
+		for _, utxo := range result.UTXOs {
+			got = append(got, utxo.Satoshis)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got UTXOs of %v sats, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/merkle"
+	"spv-backend/internal/rpctest"
+)
+
+func TestBlockMerkleBranches(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	block := s.chain.AddBlock(
+		s.chain.NewTx(nil, rpctest.PayTo(address, 1000)),
+		s.chain.NewTx(nil, rpctest.PayTo(address, 2000)),
+		s.chain.NewTx(nil, rpctest.PayTo(address, 3000)),
+	)
+	root := block.Msg.Header.MerkleRoot.String()
+
+	w := s.do(http.MethodGet, "/block/"+block.Hash+"/merkle-branches", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		MerkleRoot string          `json:"merkleroot"`
+		TxCount    int             `json:"tx_count"`
+		Branches   []merkle.Branch `json:"branches"`
+	}
+	decode(t, w, &resp)
+
+	txids := block.TxIDs()
+	if resp.MerkleRoot != root || resp.TxCount != len(txids) || len(resp.Branches) != len(txids) {
+		t.Fatalf("got root %s, %d txs, %d branches; want %s, %d", resp.MerkleRoot, resp.TxCount, len(resp.Branches), root, len(txids))
+	}
+	for i, b := range resp.Branches {
+		if b.TxID != txids[i] || b.Index != i {
+			t.Errorf("branch %d is for tx %s at %d, want %s", i, b.TxID, b.Index, txids[i])
+		}
+		if !merkle.VerifyBranch(b.TxID, b.Index, b.Branch, root) {
+			t.Errorf("branch %d does not reconstruct the header's merkle root", i)
+		}
+	}
+}
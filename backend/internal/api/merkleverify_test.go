@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bloom"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// verifyResponse is the body of POST /merkle/verify
+type verifyResponse struct {
+	Valid bool     `json:"valid"`
+	TxIDs []string `json:"txids"`
+	Error string   `json:"error"`
+}
+
+// txOutProof asks the node for a proof of txids
+func txOutProof(t *testing.T, s *testServer, txids ...string) string {
+	t.Helper()
+	result, err := s.node.Client().Call("gettxoutproof", txids)
+	if err != nil {
+		t.Fatalf("gettxoutproof: %v", err)
+	}
+	var proof string
+	if err := json.Unmarshal(result, &proof); err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestVerifyMerkleProof(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	payee := rpctest.Address(testParams, "p2wpkh", 1)
+	var txs []*wire.MsgTx
+	for i := 0; i < 4; i++ {
+		txs = append(txs, s.chain.NewTx(nil, rpctest.PayTo(payee, int64(1000+i))))
+	}
+	block := s.chain.AddBlock(txs...)
+	proven := []string{txs[1].TxHash().String(), txs[3].TxHash().String()}
+	proof := txOutProof(t, s, proven...)
+
+	// The node's proof matches an independent BIP37 merkle block
+	filter := bloom.NewFilter(2, 0, 0.000001, wire.BloomUpdateNone)
+	for _, i := range []int{1, 3} {
+		hash := txs[i].TxHash()
+		filter.AddHash(&hash)
+	}
+	merkleBlock, _ := bloom.NewMerkleBlock(btcutil.NewBlock(block.Msg), filter)
+	var buf bytes.Buffer
+	if err := merkleBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		t.Fatal(err)
+	}
+	if proof != hex.EncodeToString(buf.Bytes()) {
+		t.Fatalf("gettxoutproof %s\ndiffers from the BIP37 merkle block %x", proof, buf.Bytes())
+	}
+
+	w := s.do(http.MethodPost, "/merkle/verify", map[string]string{"proof": proof})
+	expectStatus(t, w, http.StatusOK)
+	var resp verifyResponse
+	decode(t, w, &resp)
+	if !resp.Valid || len(resp.TxIDs) != 2 || resp.TxIDs[0] != proven[0] || resp.TxIDs[1] != proven[1] {
+		t.Errorf("verified %+v, want %v", resp, proven)
+	}
+}
+
+func TestVerifyMerkleProofRejectsInvalid(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	payee := rpctest.Address(testParams, "p2wpkh", 1)
+	tx := s.chain.NewTx(nil, rpctest.PayTo(payee, 1000))
+	s.chain.AddBlock(tx, s.chain.NewTx(nil, rpctest.PayTo(payee, 2000)))
+	proof := txOutProof(t, s, tx.TxHash().String())
+
+	// The first hash follows the 80-byte header, the transaction count and
+	// the hash count
+	raw, _ := hex.DecodeString(proof)
+	raw[80+4+1] ^= 0x01
+	tampered := hex.EncodeToString(raw)
+
+	for name, proof := range map[string]string{
+		"tampered hash": tampered,
+		"truncated":     proof[:len(proof)-2],
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/merkle/verify", map[string]string{"proof": proof})
+			expectStatus(t, w, http.StatusBadRequest)
+			var resp verifyResponse
+			decode(t, w, &resp)
+			if resp.Valid || len(resp.TxIDs) != 0 || resp.Error == "" {
+				t.Errorf("invalid proof answered %+v", resp)
+			}
+		})
+	}
+
+	calls := s.node.Calls("verifytxoutproof")
+	expectStatus(t, s.do(http.MethodPost, "/merkle/verify", map[string]string{"proof": "not hex"}), http.StatusBadRequest)
+	if s.node.Calls("verifytxoutproof") != calls {
+		t.Error("non-hex proof reached the node")
+	}
+}
+
+func TestVerifyMerkleProofOfStaleBlock(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	tx := s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000))
+	s.chain.AddBlock(tx)
+	proof := txOutProof(t, s, tx.TxHash().String())
+
+	// The proof is well formed but its block was reorganized away
+	s.chain.Disconnect()
+	s.chain.AddBlock()
+
+	w := s.do(http.MethodPost, "/merkle/verify", map[string]string{"proof": proof})
+	expectStatus(t, w, http.StatusBadRequest)
+	var resp verifyResponse
+	decode(t, w, &resp)
+	if resp.Valid || resp.Error != "Block not found in chain" {
+		t.Errorf("stale proof answered %+v", resp)
+	}
+}
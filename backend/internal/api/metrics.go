@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxProxyMetricMethods bounds the distinct method labels kept, since the
+// proxied method name is client-supplied. Further methods count as "other".
+const maxProxyMetricMethods = 256
+
+// proxyMethodStats accumulates the calls made for one proxied method
+type proxyMethodStats struct {
+	Calls        int64
+	Errors       int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// proxyMetrics records per-method call counts, errors and latency for the
+// RPC proxy routes. It is safe for concurrent use.
+type proxyMetrics struct {
+	mu      sync.Mutex
+	methods map[string]*proxyMethodStats
+}
+
+func newProxyMetrics() *proxyMetrics {
+	return &proxyMetrics{methods: make(map[string]*proxyMethodStats)}
+}
+
+// Record adds one proxied call for method
+func (m *proxyMetrics) Record(method string, latency time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.methods[method]
+	if !ok {
+		if len(m.methods) >= maxProxyMetricMethods {
+			method = "other"
+			stats = m.methods[method]
+		}
+		if stats == nil {
+			stats = &proxyMethodStats{}
+			m.methods[method] = stats
+		}
+	}
+
+	stats.Calls++
+	if failed {
+		stats.Errors++
+	}
+	stats.TotalLatency += latency
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+}
+
+// Snapshot returns a copy of the per-method stats
+func (m *proxyMetrics) Snapshot() map[string]proxyMethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]proxyMethodStats, len(m.methods))
+	for method, stats := range m.methods {
+		snapshot[method] = *stats
+	}
+	return snapshot
+}
+
+// proxiedMethod extracts the JSON-RPC method from a proxied request body,
+// "batch" for a batch, or "unknown" if the body is not a JSON-RPC request
+func proxiedMethod(body []byte) string {
+	if rpc.IsBatch(body) {
+		return "batch"
+	}
+	var request struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Method == "" {
+		return "unknown"
+	}
+	return request.Method
+}
+
+// GetProxyMetrics handles GET /metrics/proxy
+// Returns call counts, error rates and latency per proxied RPC method
+func (h *Handler) GetProxyMetrics(c *gin.Context) {
+	if h.proxyMetrics == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proxy metrics are disabled"})
+		return
+	}
+
+	snapshot := h.proxyMetrics.Snapshot()
+	names := make([]string, 0, len(snapshot))
+	for method := range snapshot {
+		names = append(names, method)
+	}
+	sort.Strings(names)
+
+	methods := make([]gin.H, 0, len(names))
+	for _, method := range names {
+		stats := snapshot[method]
+		methods = append(methods, gin.H{
+			"method":         method,
+			"calls":          stats.Calls,
+			"errors":         stats.Errors,
+			"error_rate":     float64(stats.Errors) / float64(stats.Calls),
+			"avg_latency_ms": float64(stats.TotalLatency.Microseconds()) / float64(stats.Calls) / 1000,
+			"max_latency_ms": float64(stats.MaxLatency.Microseconds()) / 1000,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"methods": methods})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"spv-backend/config"
+)
+
+// methodMetrics is one method of GET /metrics/proxy
+type methodMetrics struct {
+	Method    string  `json:"method"`
+	Calls     int64   `json:"calls"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// proxyMetricsByMethod fetches GET /metrics/proxy keyed by method
+func proxyMetricsByMethod(t *testing.T, s *testServer) map[string]methodMetrics {
+	t.Helper()
+	w := s.do(http.MethodGet, "/metrics/proxy", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Methods []methodMetrics `json:"methods"`
+	}
+	decode(t, w, &resp)
+	byMethod := make(map[string]methodMetrics)
+	for _, m := range resp.Methods {
+		byMethod[m.Method] = m
+	}
+	return byMethod
+}
+
+func TestProxyRecordsMethodMetrics(t *testing.T) {
+	s := newTestServer(t, &config.Config{ProxyMetrics: true}, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		w := s.do(http.MethodPost, "/ot/list_requests", `{"jsonrpc":"1.0","id":1,"method":"getblockcount","params":[]}`)
+		expectStatus(t, w, http.StatusOK)
+	}
+	// The body read for the method label is still forwarded whole
+	if s.node.Calls("getblockcount") != 2 {
+		t.Fatalf("node answered %d getblockcount calls, want 2", s.node.Calls("getblockcount"))
+	}
+	// The node does not know this method
+	s.do(http.MethodPost, "/ot/list_requests", `{"jsonrpc":"1.0","id":1,"method":"listotrequests","params":[]}`)
+	s.do(http.MethodPost, "/ot/list_requests", `[{"jsonrpc":"1.0","id":1,"method":"getblockcount","params":[]}]`)
+	s.do(http.MethodPost, "/ot/list_requests", `not json`)
+
+	metrics := proxyMetricsByMethod(t, s)
+	if m := metrics["getblockcount"]; m.Calls != 2 || m.Errors != 0 || m.ErrorRate != 0 {
+		t.Errorf("getblockcount metrics %+v, want 2 calls without errors", m)
+	}
+	if m := metrics["listotrequests"]; m.Calls != 1 || m.Errors != 1 || m.ErrorRate != 1 {
+		t.Errorf("listotrequests metrics %+v, want 1 failed call", m)
+	}
+	if m := metrics["batch"]; m.Calls != 1 {
+		t.Errorf("batch metrics %+v, want 1 call", m)
+	}
+	if m := metrics["unknown"]; m.Calls != 1 || m.Errors != 1 {
+		t.Errorf("unknown metrics %+v, want 1 failed call", m)
+	}
+	if len(metrics) != 4 {
+		t.Errorf("metrics for %d methods, want 4: %+v", len(metrics), metrics)
+	}
+}
+
+func TestProxyMetricsDisabled(t *testing.T) {
+	s := newTestServer(t, &config.Config{}, nil, nil)
+	w := s.do(http.MethodPost, "/ot/list_requests", `{"jsonrpc":"1.0","id":1,"method":"getblockcount","params":[]}`)
+	expectStatus(t, w, http.StatusOK)
+	expectStatus(t, s.do(http.MethodGet, "/metrics/proxy", nil), http.StatusNotFound)
+}
+
+func TestProxyMetricsBoundMethodLabels(t *testing.T) {
+	m := newProxyMetrics()
+	for i := 0; i < maxProxyMetricMethods+10; i++ {
+		m.Record(fmt.Sprintf("method%d", i), time.Millisecond, false)
+	}
+	// Methods already labelled keep their label
+	m.Record("method0", 3*time.Millisecond, true)
+
+	snapshot := m.Snapshot()
+	if len(snapshot) != maxProxyMetricMethods+1 {
+		t.Errorf("%d method labels, want %d and other", len(snapshot), maxProxyMetricMethods)
+	}
+	if other := snapshot["other"]; other.Calls != 10 {
+		t.Errorf("other counted %d calls, want 10", other.Calls)
+	}
+	if first := snapshot["method0"]; first.Calls != 2 || first.Errors != 1 || first.MaxLatency != 3*time.Millisecond {
+		t.Errorf("method0 stats %+v", first)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestBlockMTPIsMedianOfElevenBlocks(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 15; i++ {
+		s.chain.AddBlock()
+	}
+
+	// Miners may stamp blocks out of order, so the median is not simply the
+	// sixth newest block's time
+	skew := []int64{0, 700, -300, 1200, -900, 50, 2000, -1500, 400, -100, 900, -700, 300, 1500, -400, 600}
+	times := map[string]int64{}
+	for height := int64(0); height <= s.chain.Height(); height++ {
+		block := s.chain.BlockAt(height)
+		times[block.Hash] = block.Time() + skew[height]
+	}
+	s.node.Wrap("getblockheader", func(next rpctest.Handler) rpctest.Handler {
+		return func(params []json.RawMessage) (interface{}, error) {
+			result, err := next(params)
+			if fields, ok := result.(map[string]interface{}); ok {
+				fields["time"] = times[fields["hash"].(string)]
+			}
+			return result, err
+		}
+	})
+
+	for _, height := range []int64{15, 10, 3, 0} {
+		var window []int64
+		for h := height - 10; h <= height; h++ {
+			if h >= 0 {
+				window = append(window, times[s.chain.BlockAt(h).Hash])
+			}
+		}
+		sorted := append([]int64(nil), window...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		block := s.chain.BlockAt(height)
+		w := s.do(http.MethodGet, "/block/"+block.Hash+"/mtp", nil)
+		expectStatus(t, w, http.StatusOK)
+		var mtp MedianTimePast
+		decode(t, w, &mtp)
+		if mtp.Hash != block.Hash || mtp.Height != height || mtp.Time != times[block.Hash] {
+			t.Errorf("height %d: got block %s at %d, time %d", height, mtp.Hash, mtp.Height, mtp.Time)
+		}
+		if mtp.MedianTimePast != sorted[len(sorted)/2] {
+			t.Errorf("height %d: MTP %d, want %d, the median of %v", height, mtp.MedianTimePast, sorted[len(sorted)/2], window)
+		}
+		if len(mtp.Timestamps) != len(window) || mtp.Timestamps[0] != window[0] || mtp.Timestamps[len(window)-1] != window[len(window)-1] {
+			t.Errorf("height %d: timestamps %v, want %v", height, mtp.Timestamps, window)
+		}
+	}
+
+	w := s.do(http.MethodGet, "/block/00000000000000000000000000000000000000000000000000000000deadbeef/mtp", nil)
+	expectStatus(t, w, http.StatusNotFound)
+}
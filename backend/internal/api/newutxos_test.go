@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestNewUTXOsOnlySinceHeight(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2tr", 2)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(other, 1500)))
+	lastPoll := s.chain.Height()
+	deposits := []int64{2000, 3000}
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, deposits[0])))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(other, deposits[1])))
+
+	poll := func(since int64) NewUTXOsResult {
+		t.Helper()
+		w := s.do(http.MethodGet, "/utxos/new?addresses="+address.EncodeAddress()+","+other.EncodeAddress()+"&since_height="+strconv.FormatInt(since, 10), nil)
+		expectStatus(t, w, http.StatusOK)
+		var result NewUTXOsResult
+		decode(t, w, &result)
+		return result
+	}
+
+	result := poll(lastPoll)
+	if result.SinceHeight != lastPoll || result.TipHeight != 4 {
+		t.Errorf("since %d, tip %d; want %d and 4", result.SinceHeight, result.TipHeight, lastPoll)
+	}
+	if result.TotalUTXOs != 2 || result.TotalSatoshis != deposits[0]+deposits[1] {
+		t.Fatalf("found %d UTXOs, %d sats; want only the two deposits", result.TotalUTXOs, result.TotalSatoshis)
+	}
+	for _, utxo := range result.UTXOs {
+		if utxo.Height <= lastPoll {
+			t.Errorf("UTXO at height %d predates the poll", utxo.Height)
+		}
+	}
+
+	// Polling again from the returned tip finds nothing new
+	result = poll(result.TipHeight)
+	if result.TotalUTXOs != 0 || result.UTXOs == nil || result.TipHeight != 4 {
+		t.Errorf("got %d UTXOs (%v), tip %d; want an empty delta", result.TotalUTXOs, result.UTXOs, result.TipHeight)
+	}
+
+	// -1 scans from genesis
+	if result = poll(-1); result.TotalUTXOs != 4 {
+		t.Errorf("found %d UTXOs from genesis, want 4", result.TotalUTXOs)
+	}
+}
+
+func TestNewUTXOsRejectsBadParams(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	s.chain.AddBlock()
+
+	for _, query := range []string{
+		"since_height=0",
+		"addresses=" + address,
+		"addresses=" + address + "&since_height=-2",
+		"addresses=" + address + "&since_height=x",
+		"addresses=" + address + ",bogus&since_height=0",
+	} {
+		w := s.do(http.MethodGet, "/utxos/new?"+query, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/ot"
+)
+
+// decodeOPReturnResponse is the body of POST /script/decode-opreturn
+type decodeOPReturnResponse struct {
+	Payload     string      `json:"payload"`
+	PayloadText *string     `json:"payload_text"`
+	Pushes      []string    `json:"pushes"`
+	PushOnly    bool        `json:"push_only"`
+	OT          *ot.Payload `json:"ot"`
+}
+
+func TestDecodeOPReturnOTPayload(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	data := "OT_REQUEST|3f2a|bcrt1qexample|5000"
+	script := hex.EncodeToString(opReturn(t, data).PkScript)
+
+	w := s.do(http.MethodPost, "/script/decode-opreturn", map[string]string{"script_pubkey": script})
+	expectStatus(t, w, http.StatusOK)
+	var resp decodeOPReturnResponse
+	decode(t, w, &resp)
+	if resp.Payload != hex.EncodeToString([]byte(data)) || resp.PayloadText == nil || *resp.PayloadText != data || !resp.PushOnly || len(resp.Pushes) != 1 {
+		t.Errorf("got %+v, want the payload %q", resp, data)
+	}
+	want := &ot.Payload{Type: "OT_REQUEST", Fields: []string{"3f2a", "bcrt1qexample", "5000"}}
+	if !reflect.DeepEqual(resp.OT, want) {
+		t.Errorf("ot %+v, want %+v", resp.OT, want)
+	}
+	if s.node.Requests() != 0 {
+		t.Error("decoding called the node")
+	}
+}
+
+func TestDecodeOPReturnOtherPayloads(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+
+	// Text that is not in the OT format, and binary data
+	for payload, text := range map[string]bool{"hello|world": true, "\xff\x00\x01": false} {
+		w := s.do(http.MethodPost, "/script/decode-opreturn", map[string]string{"script_pubkey": hex.EncodeToString(opReturn(t, payload).PkScript)})
+		expectStatus(t, w, http.StatusOK)
+		var resp decodeOPReturnResponse
+		decode(t, w, &resp)
+		if resp.OT != nil || (resp.PayloadText != nil) != text || resp.Payload != hex.EncodeToString([]byte(payload)) {
+			t.Errorf("%q: got %+v", payload, resp)
+		}
+	}
+}
+
+func TestDecodeOPReturnRejectsOtherScripts(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for name, script := range map[string]string{
+		"not hex":   "zz",
+		"p2wpkh":    "0014" + hex.EncodeToString(make([]byte, 20)),
+		"truncated": "6a1401",
+		"missing":   "",
+	} {
+		w := s.do(http.MethodPost, "/script/decode-opreturn", map[string]string{"script_pubkey": script})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+}
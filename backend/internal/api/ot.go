@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/ot"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultCyclePageSize is the page size when no limit is given
+	defaultCyclePageSize = 50
+	// maxCyclePageSize is the largest page of cycles returned at once
+	maxCyclePageSize = 500
+)
+
+// parseOptionalHeight parses an optional non-negative height query parameter
+func parseOptionalHeight(c *gin.Context, name string) (*int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	height, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || height < 0 {
+		return nil, fmt.Errorf("invalid %s parameter", name)
+	}
+	return &height, nil
+}
+
+// ListOTCycles handles GET /ot/cycles
+// Typed, paginated form of the listotcycles RPC: cycles are parsed from the
+// node's response and returned limit at a time starting at offset
+func (h *Handler) ListOTCycles(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultCyclePageSize)))
+	if err != nil || limit < 1 || limit > maxCyclePageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit parameter (1-%d)", maxCyclePageSize)})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset parameter"})
+		return
+	}
+
+	filter := ot.CycleFilter{
+		AID:       c.Query("aid"),
+		ShowPaths: c.Query("show_paths") == "true",
+	}
+	if filter.MinHeight, err = parseOptionalHeight(c, "min_height"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.MaxHeight, err = parseOptionalHeight(c, "max_height"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cycles, err := h.otFor(c).ListCycles(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	pageCycles, page := ot.PageCycles(cycles, limit, offset)
+
+	c.JSON(http.StatusOK, gin.H{
+		"cycles": pageCycles,
+		"page":   page,
+	})
+}
+
+// defaultFindBlocks is how many recent blocks /ot/find searches by default
+const defaultFindBlocks = 144
+
+// FindOTRequest represents a search for the transaction carrying an OT request
+type FindOTRequest struct {
+	Data    string `json:"data"` // OP_RETURN data string, as returned by validateotrequest
+	FromAID string `json:"from_aid"`
+	ToAID   string `json:"to_aid"`
+	Amount  *int64 `json:"amount"` // Satoshis
+	Blocks  int    `json:"blocks"` // Recent blocks to search, default 144
+}
+
+// FindOT handles POST /ot/find
+// Searches the most recent blocks for OP_RETURN outputs carrying an OT
+// request, identified by its data string or by from_aid, to_aid and amount
+func (h *Handler) FindOT(c *gin.Context) {
+	var req FindOTRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var match func(payload []byte) bool
+	switch {
+	case req.Data != "":
+		match = ot.MatchData(req.Data)
+	case req.FromAID != "" && req.ToAID != "" && req.Amount != nil:
+		if err := h.validateOTAmount(req.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		match = ot.MatchRequest(req.FromAID, req.ToAID, *req.Amount)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "data, or from_aid, to_aid and amount, are required"})
+		return
+	}
+
+	if req.Blocks == 0 {
+		req.Blocks = defaultFindBlocks
+	}
+	if req.Blocks < 1 || req.Blocks > filter.MaxScanRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid blocks parameter (1-%d)", filter.MaxScanRange)})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	startHeight := tip - int64(req.Blocks) + 1
+	if startHeight < 0 {
+		startHeight = 0
+	}
+
+	matches, err := h.filtersFor(c).FindOPReturn(startHeight, tip, match)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matches":      matches,
+		"found":        len(matches) > 0,
+		"start_height": startHeight,
+		"end_height":   tip,
+	})
+}
+
+// GetOTRequest handles GET /ot/request/:id
+// Aggregates listotrequests, getrequestcycles and validateotrequest into the
+// lifecycle of one request, identified by request ID or txid. ?aid= narrows
+// the lookup to one AID's requests.
+func (h *Handler) GetOTRequest(c *gin.Context) {
+	lifecycle, err := h.otFor(c).Lifecycle(c.Param("id"), c.Query("aid"))
+	if err != nil {
+		if errors.Is(err, ot.ErrRequestNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lifecycle)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/ot"
+	"spv-backend/internal/rpctest"
+)
+
+// otCycles returns n cycles as listotcycles reports them
+func otCycles(n int) []map[string]interface{} {
+	cycles := make([]map[string]interface{}, n)
+	for i := range cycles {
+		cycles[i] = map[string]interface{}{
+			"cycle No.":        fmt.Sprint(i + 1),
+			"participantCount": 2,
+			"minAmountBTC":     "0.00010000",
+			"totalAmountBTC":   "0.00020000",
+			"timestamp":        1700000000 + i,
+			"blockHash":        fmt.Sprintf("%064x", i+1),
+			"participants":     []string{"alice", "bob"},
+			"requests": []map[string]interface{}{
+				{"from": "alice", "to": "bob", "amountBTC": "0.00010000", "time": 1700000000 + i},
+				{"from": "bob", "to": "alice", "amountBTC": "0.00010000", "time": 1700000000 + i},
+			},
+		}
+	}
+	return cycles
+}
+
+func TestListOTCyclesPaginates(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	var aids []string
+	s.node.Handle("listotcycles", func(params []json.RawMessage) (interface{}, error) {
+		var aid string
+		if _, err := rpctest.Param(params, 0, &aid); err != nil {
+			return nil, err
+		}
+		aids = append(aids, aid)
+		return otCycles(23), nil
+	})
+
+	var seen []string
+	offset := 0
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatalf("more than 3 pages of 10 for 23 cycles")
+		}
+		w := s.do(http.MethodGet, fmt.Sprintf("/ot/cycles?aid=alice&limit=10&offset=%d", offset), nil)
+		expectStatus(t, w, http.StatusOK)
+		var resp struct {
+			Cycles []ot.Cycle `json:"cycles"`
+			Page   ot.Page    `json:"page"`
+		}
+		decode(t, w, &resp)
+		if resp.Page.Total != 23 || resp.Page.Limit != 10 || resp.Page.Offset != offset {
+			t.Fatalf("page %+v at offset %d", resp.Page, offset)
+		}
+		for _, cycle := range resp.Cycles {
+			seen = append(seen, cycle.ID)
+			if cycle.ParticipantCount != 2 || len(cycle.Requests) != 2 || cycle.Requests[0].AmountBTC != "0.00010000" || cycle.TotalAmountBTC != "0.00020000" {
+				t.Errorf("cycle %s parsed as %+v", cycle.ID, cycle)
+			}
+		}
+		if resp.Page.NextOffset == nil {
+			if len(resp.Cycles) != 3 {
+				t.Errorf("last page has %d cycles, want 3", len(resp.Cycles))
+			}
+			break
+		}
+		if len(resp.Cycles) != 10 || *resp.Page.NextOffset != offset+10 {
+			t.Fatalf("page of %d cycles continues at %d", len(resp.Cycles), *resp.Page.NextOffset)
+		}
+		offset = *resp.Page.NextOffset
+	}
+
+	if len(seen) != 23 {
+		t.Fatalf("paged through %d cycles, want 23", len(seen))
+	}
+	for i, id := range seen {
+		if id != fmt.Sprint(i+1) {
+			t.Fatalf("cycle %d is %s, want the node's order", i, id)
+		}
+	}
+	for _, aid := range aids {
+		if aid != "alice" {
+			t.Errorf("listotcycles called for %q, want alice", aid)
+		}
+	}
+}
+
+func TestListOTCyclesOffsetPastEnd(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.node.Handle("listotcycles", func(params []json.RawMessage) (interface{}, error) {
+		return otCycles(5), nil
+	})
+
+	w := s.do(http.MethodGet, "/ot/cycles?offset=10", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Cycles []ot.Cycle `json:"cycles"`
+		Page   ot.Page    `json:"page"`
+	}
+	decode(t, w, &resp)
+	if resp.Cycles == nil || len(resp.Cycles) != 0 || resp.Page.Total != 5 || resp.Page.NextOffset != nil {
+		t.Errorf("got %d cycles, page %+v, want an empty last page", len(resp.Cycles), resp.Page)
+	}
+
+	for _, query := range []string{"limit=0", "limit=501", "offset=-1"} {
+		expectStatus(t, s.do(http.MethodGet, "/ot/cycles?"+query, nil), http.StatusBadRequest)
+	}
+}
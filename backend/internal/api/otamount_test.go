@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpctest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendOTRequest calls SendOTRequest, which has no route of its own, with
+// amount (omitted when nil)
+func sendOTRequest(t *testing.T, s *testServer, rawTx string, amount interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	body := map[string]interface{}{"from_aid": "alice", "to_aid": "bob", "raw_tx": rawTx}
+	if amount != nil {
+		body["amount"] = amount
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/ot/send", bytes.NewReader(data))
+	c.Request.Header.Set("Content-Type", "application/json")
+	s.handler.SendOTRequest(c)
+	return w
+}
+
+func TestSendOTRequestAmountValidation(t *testing.T) {
+	s := newTestServer(t, &config.Config{OTMaxAmount: 21000000 * 100000000}, nil, nil)
+	rawTx := txHex(t, s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000)))
+
+	tests := []struct {
+		name   string
+		amount interface{}
+		status int
+	}{
+		{"zero", 0, http.StatusOK},
+		{"positive", 150000, http.StatusOK},
+		{"negative", -1, http.StatusBadRequest},
+		{"above supply", int64(21000000*100000000 + 1), http.StatusBadRequest},
+		{"missing", nil, http.StatusBadRequest},
+	}
+	broadcasts := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendOTRequest(t, s, rawTx, tt.amount)
+			expectStatus(t, w, tt.status)
+			var resp struct {
+				Success bool   `json:"success"`
+				Error   string `json:"error"`
+			}
+			decode(t, w, &resp)
+			if tt.status == http.StatusOK {
+				broadcasts++
+				if !resp.Success {
+					t.Errorf("valid amount %v failed: %s", tt.amount, resp.Error)
+				}
+			} else if resp.Success || resp.Error == "" {
+				t.Errorf("amount %v accepted", tt.amount)
+			}
+		})
+	}
+	if s.node.Calls("sendrawtransaction") != broadcasts {
+		t.Errorf("broadcast %d times, want only the %d valid requests", s.node.Calls("sendrawtransaction"), broadcasts)
+	}
+}
+
+func TestSendOTRequestConfiguredMinimum(t *testing.T) {
+	s := newTestServer(t, &config.Config{OTMinAmount: 546, OTMaxAmount: 100000}, nil, nil)
+	rawTx := txHex(t, s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000)))
+
+	expectStatus(t, sendOTRequest(t, s, rawTx, 545), http.StatusBadRequest)
+	expectStatus(t, sendOTRequest(t, s, rawTx, 100001), http.StatusBadRequest)
+	expectStatus(t, sendOTRequest(t, s, rawTx, 546), http.StatusOK)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// opReturn returns an output carrying data after OP_RETURN
+func opReturn(t *testing.T, data string) *wire.TxOut {
+	t.Helper()
+	script, err := txscript.NullDataScript([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wire.NewTxOut(0, script)
+}
+
+// findResponse is the body of POST /ot/find
+type findResponse struct {
+	Matches     []filter.OPReturnMatch `json:"matches"`
+	Found       bool                   `json:"found"`
+	StartHeight int64                  `json:"start_height"`
+	EndHeight   int64                  `json:"end_height"`
+}
+
+func TestFindOTRequest(t *testing.T) {
+	s := newTestServer(t, &config.Config{OTMaxAmount: 100000000}, nil, nil)
+	payee := rpctest.Address(testParams, "p2wpkh", 1)
+
+	const data = "OT_REQUEST|alice|bob|25000|1700000000"
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(payee, 1000), opReturn(t, "OT_REQUEST|alice|bob|30000|1700000000")))
+	tx := s.chain.NewTx(nil, rpctest.PayTo(payee, 1000), opReturn(t, data))
+	block := s.chain.AddBlock(tx)
+	s.chain.AddBlock(s.chain.NewTx(nil, opReturn(t, "unrelated")))
+	s.chain.AddBlock()
+
+	for name, body := range map[string]map[string]interface{}{
+		"data":    {"data": data},
+		"request": {"from_aid": "alice", "to_aid": "bob", "amount": 25000},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/ot/find", body)
+			expectStatus(t, w, http.StatusOK)
+			var resp findResponse
+			decode(t, w, &resp)
+			if !resp.Found || len(resp.Matches) != 1 {
+				t.Fatalf("found %+v, want the one request", resp.Matches)
+			}
+			match := resp.Matches[0]
+			if match.TxID != tx.TxHash().String() || match.Vout != 1 || match.Height != block.Height || match.BlockHash != block.Hash {
+				t.Errorf("match %+v, want %s:1 in block %d", match, tx.TxHash(), block.Height)
+			}
+			if match.Confirmations != 3 || match.PayloadText != data {
+				t.Errorf("match has %d confirmations, payload %q", match.Confirmations, match.PayloadText)
+			}
+		})
+	}
+
+	// The request lies outside the two most recent blocks
+	w := s.do(http.MethodPost, "/ot/find", map[string]interface{}{"data": data, "blocks": 2})
+	expectStatus(t, w, http.StatusOK)
+	var resp findResponse
+	decode(t, w, &resp)
+	if resp.Found || len(resp.Matches) != 0 || resp.StartHeight != s.chain.Height()-1 {
+		t.Errorf("search of the last 2 blocks found %+v from %d", resp.Matches, resp.StartHeight)
+	}
+
+	// Another amount is another request
+	w = s.do(http.MethodPost, "/ot/find", map[string]interface{}{"from_aid": "alice", "to_aid": "bob", "amount": 26000})
+	expectStatus(t, w, http.StatusOK)
+	resp = findResponse{}
+	decode(t, w, &resp)
+	if resp.Found {
+		t.Errorf("request for 26000 sats matched %+v", resp.Matches)
+	}
+}
+
+func TestFindOTRejectsBadRequest(t *testing.T) {
+	s := newTestServer(t, &config.Config{OTMaxAmount: 100000000}, nil, nil)
+
+	for name, body := range map[string]map[string]interface{}{
+		"no search":       {},
+		"missing amount":  {"from_aid": "alice", "to_aid": "bob"},
+		"negative amount": {"from_aid": "alice", "to_aid": "bob", "amount": -1},
+		"too many blocks": {"data": "OT_REQUEST|x", "blocks": filter.MaxScanRange + 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/ot/find", body)
+			expectStatus(t, w, http.StatusBadRequest)
+		})
+	}
+	if s.node.Calls("getblock") != 0 {
+		t.Error("invalid searches fetched blocks")
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/ot"
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+)
+
+// otRequests are two pending requests as listotrequests reports them; the
+// second is still unconfirmed
+var otRequests = []map[string]interface{}{
+	{"request_id": "req-1", "txid": "aa11", "from_aid": "alice", "to_aid": "bob", "amount": 25000, "amountBTC": "0.00025000", "time": 1700000000},
+	{"request_id": "req-2", "txid": "bb22", "from_aid": "bob", "to_aid": "carol", "amount": 40000, "amountBTC": "0.00040000", "time": 0},
+}
+
+// newOTRequestServer answers the OT RPCs: listotrequests with otRequests,
+// getrequestcycles with cycles for alice to bob only, and validateotrequest
+// rejecting amounts over 30000 sats. It returns the calls' params.
+func newOTRequestServer(t *testing.T) (*testServer, map[string][]json.RawMessage) {
+	t.Helper()
+	s := newTestServer(t, nil, nil, nil)
+	params := make(map[string][]json.RawMessage)
+	s.node.Handle("listotrequests", func(p []json.RawMessage) (interface{}, error) {
+		params["listotrequests"] = p
+		return otRequests, nil
+	})
+	s.node.Handle("getrequestcycles", func(p []json.RawMessage) (interface{}, error) {
+		params["getrequestcycles"] = p
+		var from, to string
+		if _, err := rpctest.Param(p, 0, &from); err != nil {
+			return nil, err
+		}
+		if _, err := rpctest.Param(p, 1, &to); err != nil {
+			return nil, err
+		}
+		if from == "alice" && to == "bob" {
+			return map[string]interface{}{"cycles": otCycles(2)}, nil
+		}
+		return map[string]interface{}{"cycles": []interface{}{}}, nil
+	})
+	s.node.Handle("validateotrequest", func(p []json.RawMessage) (interface{}, error) {
+		params["validateotrequest"] = p
+		var amount int64
+		if _, err := rpctest.Param(p, 2, &amount); err != nil {
+			return nil, err
+		}
+		if amount > 30000 {
+			return nil, &rpc.RPCError{Code: -8, Message: "amount exceeds the sender's credit"}
+		}
+		return map[string]interface{}{"valid": true, "data": "OT_REQUEST|alice|bob|25000", "timestamp": 1700000000}, nil
+	})
+	return s, params
+}
+
+func TestOTRequestLifecycleCombinesRPCs(t *testing.T) {
+	s, params := newOTRequestServer(t)
+
+	w := s.do(http.MethodGet, "/ot/request/req-1", nil)
+	expectStatus(t, w, http.StatusOK)
+	var lifecycle ot.Lifecycle
+	decode(t, w, &lifecycle)
+
+	// From listotrequests
+	if lifecycle.Request.RequestID != "req-1" || lifecycle.Request.Amount != 25000 || lifecycle.Request.AmountBTC != "0.00025000" {
+		t.Errorf("request %+v", lifecycle.Request)
+	}
+	if len(lifecycle.BroadcastTxIDs) != 1 || lifecycle.BroadcastTxIDs[0] != "aa11" {
+		t.Errorf("broadcast txids %v, want the request's transaction", lifecycle.BroadcastTxIDs)
+	}
+	// From getrequestcycles, asked about the request's AIDs
+	if string(params["getrequestcycles"][0]) != `"alice"` || string(params["getrequestcycles"][1]) != `"bob"` {
+		t.Errorf("getrequestcycles called with %s", params["getrequestcycles"])
+	}
+	if len(lifecycle.Cycles) != 2 || lifecycle.Cycles[0].ID != "1" || len(lifecycle.Cycles[0].Requests) != 2 {
+		t.Errorf("cycles %+v, want the node's two cycles", lifecycle.Cycles)
+	}
+	if lifecycle.State != ot.StateCycleFound {
+		t.Errorf("state %s, want %s", lifecycle.State, ot.StateCycleFound)
+	}
+	// From validateotrequest, asked about the request's fields
+	if string(params["validateotrequest"][2]) != "25000" || !lifecycle.Validation.Valid {
+		t.Errorf("validation %+v from params %s", lifecycle.Validation, params["validateotrequest"])
+	}
+}
+
+func TestOTRequestLifecycleByTxID(t *testing.T) {
+	s, params := newOTRequestServer(t)
+
+	// The unconfirmed request, narrowed to bob's requests
+	w := s.do(http.MethodGet, "/ot/request/bb22?aid=bob", nil)
+	expectStatus(t, w, http.StatusOK)
+	var lifecycle ot.Lifecycle
+	decode(t, w, &lifecycle)
+
+	if len(params["listotrequests"]) != 1 || string(params["listotrequests"][0]) != `"bob"` {
+		t.Errorf("listotrequests called with %s, want the aid", params["listotrequests"])
+	}
+	if lifecycle.Request.RequestID != "req-2" || lifecycle.State != ot.StateUnconfirmed {
+		t.Errorf("request %s in state %s, want req-2 unconfirmed", lifecycle.Request.RequestID, lifecycle.State)
+	}
+	if len(lifecycle.Cycles) != 0 {
+		t.Errorf("cycles %+v, want none", lifecycle.Cycles)
+	}
+	// The node's rejection is reported, not failed on
+	if lifecycle.Validation.Valid || lifecycle.Validation.Error != "amount exceeds the sender's credit" {
+		t.Errorf("validation %+v, want the node's rejection", lifecycle.Validation)
+	}
+}
+
+func TestOTRequestLifecycleErrors(t *testing.T) {
+	s, _ := newOTRequestServer(t)
+	expectStatus(t, s.do(http.MethodGet, "/ot/request/req-9", nil), http.StatusNotFound)
+
+	s.node.Handle("getrequestcycles", func([]json.RawMessage) (interface{}, error) {
+		return nil, &rpc.RPCError{Code: -1, Message: "scanner not ready"}
+	})
+	expectStatus(t, s.do(http.MethodGet, "/ot/request/req-1", nil), http.StatusInternalServerError)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the RFC 7807 media type for error responses
+const problemContentType = "application/problem+json"
+
+// problemDetails is an RFC 7807 problem. Fields of the original error body
+// other than "error" are kept as extension members.
+type problemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]json.RawMessage
+}
+
+// MarshalJSON flattens the extension members into the problem object
+func (p problemDetails) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		fields[name] = value
+	}
+	fields["type"] = p.Type
+	fields["title"] = p.Title
+	fields["status"] = p.Status
+	fields["detail"] = p.Detail
+	fields["instance"] = p.Instance
+	return json.Marshal(fields)
+}
+
+// problemMiddleware rewrites error responses (status 400 and above) as
+// application/problem+json when the client accepts it, or for every request
+// when always is set. Handlers keep writing {"error": ...}; the body is
+// buffered and converted once the handler returns. Streams that already
+// started with a 200 are left alone.
+func problemMiddleware(always bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !always && !strings.Contains(c.GetHeader("Accept"), problemContentType) {
+			c.Next()
+			return
+		}
+
+		writer := &problemWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.body.Len() == 0 {
+			return
+		}
+		problem := newProblem(writer.Status(), writer.body.Bytes(), c.Request.URL.Path)
+		body, err := json.Marshal(problem)
+		if err != nil {
+			body = writer.body.Bytes()
+		} else {
+			c.Header("Content-Type", problemContentType)
+		}
+		c.Writer.Write(body)
+	}
+}
+
+// newProblem converts an error body, normally {"error": "..."}, to a problem
+func newProblem(status int, body []byte, instance string) problemDetails {
+	problem := problemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: instance,
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		problem.Detail = strings.TrimSpace(string(body))
+		return problem
+	}
+	if message, ok := fields["error"]; ok {
+		if err := json.Unmarshal(message, &problem.Detail); err != nil {
+			problem.Detail = string(message)
+		}
+		delete(fields, "error")
+	}
+	// The standard members are set by the problem itself
+	for _, name := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(fields, name)
+	}
+	if len(fields) > 0 {
+		problem.Extensions = fields
+	}
+	return problem
+}
+
+// problemWriter holds back the body of an error response so it can be
+// rewritten; other responses pass through
+type problemWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *problemWriter) Write(data []byte) (int, error) {
+	if w.Status() < http.StatusBadRequest || w.ResponseWriter.Written() {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *problemWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports a held back error body as written, so later middleware
+// does not add a response of its own
+func (w *problemWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
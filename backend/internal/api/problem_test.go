@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+)
+
+// expectProblem checks that a response is an RFC 7807 problem for status and
+// returns its members
+func expectProblem(t *testing.T, w *httptest.ResponseRecorder, status int, instance string) map[string]interface{} {
+	t.Helper()
+	expectStatus(t, w, status)
+	if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("content type %q, want %s", ct, problemContentType)
+	}
+	var problem map[string]interface{}
+	decode(t, w, &problem)
+	if problem["type"] != "about:blank" || problem["title"] != http.StatusText(status) ||
+		problem["status"] != float64(status) || problem["instance"] != instance {
+		t.Errorf("got %v, want an about:blank %d problem at %s", problem, status, instance)
+	}
+	if detail, ok := problem["detail"].(string); !ok || detail == "" {
+		t.Errorf("detail %v, want the error message", problem["detail"])
+	}
+	if _, ok := problem["error"]; ok {
+		t.Error("the error member was kept")
+	}
+	return problem
+}
+
+func TestProblemJSONWhenNegotiated(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	path := "/address/notanaddress/balance-at/1"
+
+	// Without the Accept header the error keeps its usual shape
+	w := s.do(http.MethodGet, path, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+	var plain map[string]interface{}
+	decode(t, w, &plain)
+	if _, ok := plain["error"]; !ok || len(plain) != 1 {
+		t.Fatalf("got %v, want {\"error\": ...}", plain)
+	}
+
+	w = s.do(http.MethodGet, path, nil, "Accept", problemContentType)
+	problem := expectProblem(t, w, http.StatusBadRequest, path)
+	if problem["detail"] != plain["error"] {
+		t.Errorf("detail %v, want the error %v", problem["detail"], plain["error"])
+	}
+
+	// Successful responses are untouched
+	w = s.do(http.MethodGet, "/health", nil, "Accept", problemContentType+", application/json")
+	if w.Code >= http.StatusBadRequest || w.Header().Get("Content-Type") == problemContentType {
+		t.Errorf("health check answered %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestProblemJSONWhenConfigured(t *testing.T) {
+	s := newTestServer(t, &config.Config{ErrorFormat: "problem"}, nil, nil)
+
+	// Errors of each status written by handlers
+	for path, status := range map[string]int{
+		"/address/notanaddress/balance-at/1":          http.StatusBadRequest,
+		"/tx/" + strings.Repeat("00", 32) + "/status": http.StatusNotFound,
+	} {
+		w := s.do(http.MethodGet, path, nil)
+		expectProblem(t, w, status, path)
+	}
+}
+
+func TestProblemKeepsExtensionMembers(t *testing.T) {
+	problem := newProblem(http.StatusTooManyRequests, []byte(`{"error":"call budget exceeded","partial_result":{"total_utxos":3},"status":"ignored"}`), "/utxos/scan")
+	if problem.Detail != "call budget exceeded" || problem.Status != http.StatusTooManyRequests || problem.Title != "Too Many Requests" {
+		t.Errorf("got %+v", problem)
+	}
+	if string(problem.Extensions["partial_result"]) != `{"total_utxos":3}` || len(problem.Extensions) != 1 {
+		t.Errorf("extensions %v, want only partial_result", problem.Extensions)
+	}
+
+	// A body that is not JSON becomes the detail
+	if problem := newProblem(http.StatusNotFound, []byte("404 page not found\n"), "/x"); problem.Detail != "404 page not found" {
+		t.Errorf("detail %q", problem.Detail)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/merkle"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanProofsVerifyAgainstHeaders(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2tr", 2)
+	// Several transactions per block so the branches are not trivial
+	s.chain.AddBlock(
+		s.chain.NewTx(nil, rpctest.PayTo(other, 500)),
+		s.chain.NewTx(nil, rpctest.PayTo(address, 1000)),
+		s.chain.NewTx(nil, rpctest.PayTo(other, 600)),
+	)
+	s.chain.AddBlock(
+		s.chain.NewTx(nil, rpctest.PayTo(address, 2000), rpctest.PayTo(address, 3000)),
+		s.chain.NewTx(nil, rpctest.PayTo(other, 700)),
+		s.chain.NewTx(nil, rpctest.PayTo(other, 800)),
+		s.chain.NewTx(nil, rpctest.PayTo(address, 4000)),
+	)
+
+	for _, mode := range []string{"spv", "direct"} {
+		t.Run(mode, func(t *testing.T) {
+			body := scanBody([]string{address.EncodeAddress()}, 0, s.chain.Height(), map[string]interface{}{"mode": mode, "include_proofs": true})
+			w := s.do(http.MethodPost, "/utxos/scan", body)
+			expectStatus(t, w, http.StatusOK)
+			var result filter.UTXOScanResult
+			decode(t, w, &result)
+			if len(result.UTXOs) != 4 {
+				t.Fatalf("got %d UTXOs, want 4", len(result.UTXOs))
+			}
+
+			for _, utxo := range result.UTXOs {
+				proof := utxo.Proof
+				if proof == nil {
+					t.Fatalf("UTXO %s:%d has no proof", utxo.TxID, utxo.Vout)
+				}
+				raw, err := hex.DecodeString(proof.BlockHeader)
+				if err != nil || len(raw) != 80 {
+					t.Fatalf("header %q is not 80 bytes of hex", proof.BlockHeader)
+				}
+				var header wire.BlockHeader
+				if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+					t.Fatal(err)
+				}
+				if header.BlockHash().String() != utxo.BlockHash || proof.BlockHash != utxo.BlockHash {
+					t.Errorf("proof header is for block %s, UTXO is in %s", header.BlockHash(), utxo.BlockHash)
+				}
+				// The client trusts only the header, so fold the branch
+				// against the root it commits to
+				root := header.MerkleRoot.String()
+				if proof.MerkleRoot != root {
+					t.Errorf("proof root %s, header root %s", proof.MerkleRoot, root)
+				}
+				if !merkle.VerifyBranch(utxo.TxID, proof.Index, proof.Branch, root) {
+					t.Errorf("proof of %s does not verify against its block's merkle root", utxo.TxID)
+				}
+				if block := s.chain.Block(utxo.BlockHash); block.TxIDs()[proof.Index] != utxo.TxID {
+					t.Errorf("proof index %d does not hold %s", proof.Index, utxo.TxID)
+				}
+			}
+		})
+	}
+}
+
+func TestScanWithoutProofs(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, s.chain.Height(), nil))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if len(result.UTXOs) != 1 || result.UTXOs[0].Proof != nil {
+		t.Errorf("got %+v, want one UTXO without a proof", result.UTXOs)
+	}
+}
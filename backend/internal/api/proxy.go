@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response envelopes of the RPC proxy (see PROXY_ENVELOPE)
+const (
+	// proxyEnvelopeRPC answers {"result": ..., "error": ...}, one of them null
+	proxyEnvelopeRPC = "rpc"
+	// proxyEnvelopeJSONRPC answers a JSON-RPC 2.0 response echoing the
+	// request's id, with either result or error
+	proxyEnvelopeJSONRPC = "jsonrpc"
+	// proxyEnvelopeResult answers the bare result on success, as a JSON-RPC
+	// client library returns it; errors keep the rpc envelope
+	proxyEnvelopeResult = "result"
+)
+
+// validProxyEnvelope reports whether envelope names a proxy response envelope
+func validProxyEnvelope(envelope string) bool {
+	switch envelope {
+	case proxyEnvelopeRPC, proxyEnvelopeJSONRPC, proxyEnvelopeResult:
+		return true
+	}
+	return false
+}
+
+// proxyEnvelope returns the envelope for a proxy request: ?envelope= if set,
+// else the configured default
+func (h *Handler) proxyEnvelope(c *gin.Context) string {
+	if envelope := c.Query("envelope"); envelope != "" {
+		return envelope
+	}
+	if h.config.ProxyEnvelope != "" {
+		return h.config.ProxyEnvelope
+	}
+	return proxyEnvelopeRPC
+}
+
+// proxiedID returns the id of a proxied request, null if it has none
+func proxiedID(body []byte) json.RawMessage {
+	var request struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || len(request.ID) == 0 {
+		return json.RawMessage("null")
+	}
+	return request.ID
+}
+
+// writeProxyResult answers a successful proxied call in the given envelope
+func writeProxyResult(c *gin.Context, envelope string, body []byte, result json.RawMessage) {
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+
+	switch envelope {
+	case proxyEnvelopeResult:
+		c.Data(http.StatusOK, "application/json; charset=utf-8", result)
+	case proxyEnvelopeJSONRPC:
+		c.JSON(http.StatusOK, gin.H{"jsonrpc": "2.0", "id": proxiedID(body), "result": result})
+	default:
+		c.JSON(http.StatusOK, gin.H{"result": result, "error": nil})
+	}
+}
+
+// writeProxyError answers a proxied call the node rejected, or one that
+// failed before reaching it, in the given envelope
+func writeProxyError(c *gin.Context, status int, envelope string, body []byte, rpcErr interface{}) {
+	if envelope == proxyEnvelopeJSONRPC {
+		c.JSON(status, gin.H{"jsonrpc": "2.0", "id": proxiedID(body), "error": rpcErr})
+		return
+	}
+	c.JSON(status, gin.H{"result": nil, "error": rpcErr})
+}
+
+// proxyFailure is the error object of a proxied call that failed in the
+// backend rather than on the node
+func proxyFailure(message string) *rpc.RPCError {
+	return &rpc.RPCError{Code: -500, Message: message}
+}
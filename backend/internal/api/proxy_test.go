@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpc"
+)
+
+func TestProxyBatch(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil, rpc.WithMethodAllowlist([]string{"getblockcount", "getbestblockhash"}))
+	s.chain.AddBlock()
+
+	w := s.do(http.MethodPost, "/ot/list_requests", `[{"id":"a","method":"getblockcount"},{"id":"b","method":"getbestblockhash"}]`)
+	expectStatus(t, w, http.StatusOK)
+	var responses []struct {
+		ID     string          `json:"id"`
+		Result json.RawMessage `json:"result"`
+	}
+	decode(t, w, &responses)
+	if len(responses) != 2 || responses[0].ID != "a" || string(responses[0].Result) != "1" || responses[1].ID != "b" {
+		t.Errorf("got %s", w.Body.String())
+	}
+
+	// The disallowed method never reaches the node
+	requests := s.node.Requests()
+	w = s.do(http.MethodPost, "/ot/list_requests", `[{"id":1,"method":"getblockcount"},{"id":2,"method":"stop"}]`)
+	expectStatus(t, w, http.StatusForbidden)
+	if s.node.Requests() != requests || s.node.Calls("stop") != 0 {
+		t.Error("batch with a disallowed method was forwarded")
+	}
+}
+
+func TestProxyEnvelopes(t *testing.T) {
+	const call = `{"jsonrpc":"1.0","id":7,"method":"getblockcount","params":[]}`
+	for _, tc := range []struct {
+		name       string
+		configured string
+		query      string
+		want       string
+	}{
+		{"default", "", "", `{"error":null,"result":2}`},
+		{"rpc", proxyEnvelopeRPC, "", `{"error":null,"result":2}`},
+		{"result", proxyEnvelopeResult, "", `2`},
+		{"jsonrpc", proxyEnvelopeJSONRPC, "", `{"id":7,"jsonrpc":"2.0","result":2}`},
+		{"request override", proxyEnvelopeRPC, "?envelope=result", `2`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t, &config.Config{ProxyEnvelope: tc.configured}, nil, nil)
+			s.chain.AddBlock()
+			s.chain.AddBlock()
+
+			w := s.do(http.MethodPost, "/ot/list_requests"+tc.query, call)
+			expectStatus(t, w, http.StatusOK)
+			if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProxyResultEnvelopeKeepsErrors(t *testing.T) {
+	s := newTestServer(t, &config.Config{ProxyEnvelope: proxyEnvelopeResult}, nil, nil)
+
+	w := s.do(http.MethodPost, "/ot/list_requests", `{"id":1,"method":"getblockhash","params":[99]}`)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpc.RPCError   `json:"error"`
+	}
+	decode(t, w, &resp)
+	if resp.Error == nil || string(resp.Result) != "null" {
+		t.Errorf("got %s, want the rpc envelope with the node's error", w.Body.String())
+	}
+
+	w = s.do(http.MethodPost, "/ot/list_requests?envelope=bare", `{"id":1,"method":"getblockcount"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestProxyRejectsDeniedMethod(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil, rpc.WithMethodAllowlist([]string{"getblockcount", "getpeerinfo"}), rpc.WithMethodDenylist([]string{"getpeerinfo"}))
+
+	w := s.do(http.MethodPost, "/ot/list_requests", `{"id":1,"method":"getpeerinfo"}`)
+	expectStatus(t, w, http.StatusForbidden)
+	if s.node.Requests() != 0 {
+		t.Error("denied method was forwarded")
+	}
+
+	w = s.do(http.MethodPost, "/ot/list_requests", `{"id":1,"method":"getblockcount"}`)
+	expectStatus(t, w, http.StatusOK)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+func TestScanIncludesRawTransactions(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	shared := s.chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 2000), rpctest.PayTo(a, 3000))
+	single := s.chain.NewTx(nil, rpctest.PayTo(b, 4000))
+	s.chain.AddBlock(shared)
+	s.chain.AddBlock(single)
+	rawTx := map[string]string{
+		shared.TxHash().String(): rpctest.Hex(shared),
+		single.TxHash().String(): rpctest.Hex(single),
+	}
+
+	addresses := []string{a.EncodeAddress(), b.EncodeAddress()}
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), map[string]interface{}{"include_raw_tx": true}))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+
+	if len(result.UTXOs) != 4 {
+		t.Fatalf("scan found %d UTXOs, want 4", len(result.UTXOs))
+	}
+	for _, utxo := range result.UTXOs {
+		if utxo.RawTx != rawTx[utxo.TxID] {
+			t.Errorf("UTXO %s:%d has raw tx %q, want its creating transaction", utxo.TxID, utxo.Vout, utxo.RawTx)
+		}
+	}
+	// Three UTXOs share a transaction, which is fetched once
+	if calls := s.node.Calls("getrawtransaction"); calls != 2 {
+		t.Errorf("getrawtransaction called %d times, want once per distinct txid", calls)
+	}
+
+	// Without the flag no raw transactions are fetched
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), nil))
+	expectStatus(t, w, http.StatusOK)
+	var plain filter.UTXOScanResult
+	decode(t, w, &plain)
+	for _, utxo := range plain.UTXOs {
+		if utxo.RawTx != "" {
+			t.Errorf("UTXO %s:%d has a raw tx without include_raw_tx", utxo.TxID, utxo.Vout)
+		}
+	}
+	if calls := s.node.Calls("getrawtransaction"); calls != 2 {
+		t.Errorf("getrawtransaction called %d times in all, want no more without include_raw_tx", calls)
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader carries the request's correlation ID in both directions
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits client-supplied IDs to short, log-safe strings
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// requestIDMiddleware gives each request a correlation ID, keeping a valid
+// X-Request-ID from the client and generating one otherwise, and echoes it
+// in the response. With propagate set, the request's RPC calls carry
+// JSON-RPC ids derived from it (see rpc.WithRequestID).
+func requestIDMiddleware(propagate bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(requestIDHeader, id)
+		if propagate {
+			c.Request = c.Request.WithContext(rpc.WithRequestID(c.Request.Context(), id))
+		}
+		c.Next()
+	}
+}
+
+// newRequestID returns a random 16-character hex ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
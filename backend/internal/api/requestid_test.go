@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpctest"
+)
+
+// nodeIDs returns the JSON-RPC ids the node received as strings, failing
+// the test on any other id
+func nodeIDs(t *testing.T, s *testServer) []string {
+	t.Helper()
+	var ids []string
+	for _, raw := range s.node.IDs() {
+		var id string
+		if err := json.Unmarshal(raw, &id); err != nil {
+			t.Fatalf("node received id %s, want a string", raw)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestRPCIDsDeriveFromRequestID(t *testing.T) {
+	s := newTestServer(t, &config.Config{RPCRequestIDs: true}, nil, func(s *testServer) {
+		s.handler.filterService.SetWorkers(4, 4)
+	})
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 8; i++ {
+		s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	}
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, 8, nil), requestIDHeader, "trace-42")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get(requestIDHeader); got != "trace-42" {
+		t.Errorf("response carries request ID %q, want the client's", got)
+	}
+
+	// Calls made concurrently still get distinct ids, numbered from 1
+	ids := nodeIDs(t, s)
+	if len(ids) < 8 {
+		t.Fatalf("node received %d calls", len(ids))
+	}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("id %s sent twice", id)
+		}
+		seen[id] = true
+	}
+	for n := 1; n <= len(ids); n++ {
+		if !seen[fmt.Sprintf("trace-42-%d", n)] {
+			t.Errorf("no call has id trace-42-%d: %v", n, ids)
+		}
+	}
+}
+
+func TestRPCIDsUseGeneratedRequestID(t *testing.T) {
+	s := newTestServer(t, &config.Config{RPCRequestIDs: true}, nil, nil)
+
+	// An ID unsafe to log is replaced
+	w := s.do(http.MethodGet, "/health", nil, requestIDHeader, "not a valid id")
+	expectStatus(t, w, http.StatusOK)
+	generated := w.Header().Get(requestIDHeader)
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(generated) {
+		t.Fatalf("generated request ID %q", generated)
+	}
+	ids := nodeIDs(t, s)
+	if len(ids) == 0 {
+		t.Fatal("no calls reached the node")
+	}
+	for _, id := range ids {
+		if !regexp.MustCompile(`^` + generated + `-\d+$`).MatchString(id) {
+			t.Errorf("call id %s does not derive from %s", id, generated)
+		}
+	}
+}
+
+func TestRPCIDsDisabled(t *testing.T) {
+	s := newTestServer(t, &config.Config{}, nil, nil)
+
+	w := s.do(http.MethodGet, "/health", nil, requestIDHeader, "trace-42")
+	expectStatus(t, w, http.StatusOK)
+	// The ID is still echoed for the client's own correlation
+	if w.Header().Get(requestIDHeader) != "trace-42" {
+		t.Errorf("response carries request ID %q", w.Header().Get(requestIDHeader))
+	}
+	if len(s.node.IDs()) == 0 {
+		t.Fatal("no calls reached the node")
+	}
+	for _, raw := range s.node.IDs() {
+		var id int
+		if err := json.Unmarshal(raw, &id); err != nil {
+			t.Errorf("call id %s, want the client's numeric id", raw)
+		}
+	}
+}
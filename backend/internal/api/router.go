@@ -2,45 +2,131 @@
 package api
 
 import (
+	"fmt"
+	"time"
+
+	"spv-backend/internal/auth"
+
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRouter configures the API routes
-func SetupRouter(handler *Handler) *gin.Engine {
+// A nil authenticator leaves the API unauthenticated
+func SetupRouter(handler *Handler, authenticator auth.Authenticator) *gin.Engine {
 	router := gin.Default()
 
+	// Only honor X-Forwarded-For from configured proxies (none by default);
+	// the entries were checked when the config was loaded
+	if err := router.SetTrustedProxies(handler.config.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("invalid trusted proxies: %v", err))
+	}
+
+	// Assign each request a correlation ID
+	router.Use(requestIDMiddleware(handler.config.RPCRequestIDs))
+
 	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	router.Use(corsMiddleware(handler.config.CORSAllowedOrigins, handler.config.CORSMaxAge))
+
+	// Answer errors as RFC 7807 problems when negotiated or configured
+	router.Use(problemMiddleware(handler.config.ErrorFormat == "problem"))
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	// Authenticate requests (after CORS so preflights are answered)
+	if authenticator != nil {
+		router.Use(authMiddleware(authenticator))
+	}
 
-		c.Next()
-	})
+	// Limit the RPC calls each request may make
+	router.Use(callBudgetMiddleware(handler.config.MaxRPCCallsPerRequest))
+
+	// Bound each request by its endpoint category's timeout
+	router.Use(timeoutMiddleware(map[string]time.Duration{
+		timeoutFast:      time.Duration(handler.config.TimeoutFast) * time.Second,
+		timeoutScan:      time.Duration(handler.config.TimeoutScan) * time.Second,
+		timeoutBroadcast: time.Duration(handler.config.TimeoutBroadcast) * time.Second,
+	}))
+
+	// Keep recent scans for the diagnostic bundle
+	router.Use(scanHistoryMiddleware(handler.scans))
 
 	// Health check
 	router.GET("/health", handler.HealthCheck)
+	router.GET("/health/detailed", handler.HealthCheckDetailed)
+
+	// Metrics
+	router.GET("/metrics/proxy", handler.GetProxyMetrics)
+
+	// Route discovery
+	router.GET("/routes", listRoutes(router, authenticator != nil))
 
 	// Blockchain info
 	router.GET("/blockchaininfo", handler.GetBlockchainInfo)
 
+	// Chain parameters
+	router.GET("/chainparams", handler.GetChainParams)
+
+	// Network hashrate
+	router.GET("/hashrate", handler.GetHashrate)
+
 	// Headers
 	router.GET("/headers", handler.GetHeaders)
+	router.GET("/header/:hash", handler.GetHeader)
+	router.GET("/header-proof/:height", handler.GetHeaderProof)
 
 	// Blocks
 	router.GET("/block/:hash", handler.GetBlock)
+	router.GET("/block/eta/:height", handler.GetBlockETA)
+	router.GET("/subsidy/:height", handler.GetSubsidy)
+	router.GET("/block/:hash/merkle-branches", handler.GetBlockMerkleBranches)
+	router.GET("/block/:hash/summary", handler.GetBlockSummary)
+	router.GET("/block/:hash/mtp", handler.GetBlockMTP)
+	router.GET("/stats/range", handler.GetRangeStats)
+	router.GET("/coinbase/fees", handler.GetCoinbaseFees)
+
+	// Merkle proofs
+	router.POST("/merkle/verify", handler.VerifyMerkleProof)
 
 	// Transactions
 	router.POST("/broadcast", handler.BroadcastTx)
+	router.POST("/script/decode-opreturn", handler.DecodeOPReturn)
+	router.POST("/tx/combine", handler.CombineTx)
+	router.GET("/tx/:txid/mempool-chain", handler.GetMempoolChain)
+	router.GET("/tx/:txid/status", handler.GetTxStatus)
+	router.GET("/tx/:txid", handler.GetTransaction)
+	router.POST("/txs", handler.GetTransactions)
+
+	// Fee estimation
+	router.GET("/fees", handler.GetFees)
+	router.POST("/fees/estimate", handler.EstimateTxFee)
+	router.GET("/confirm-probability", handler.GetConfirmProbability)
 
 	// UTXO scanning - automatically uses SPV mode (BIP158 filters) or direct scan based on SPV_MODE config
 	router.POST("/utxos/scan", handler.ScanUTXOs)
+	router.POST("/utxos/scan/incremental", handler.ScanUTXOsIncremental)
+	router.GET("/utxos/new", handler.GetNewUTXOs)
+	router.GET("/utxos", handler.GetUTXOs)
+	router.POST("/snapshot/verify", handler.VerifySnapshot)
+
+	// Address usage check (filter pass only, may report false positives) and validation
+	router.GET("/address/:address/used", handler.GetAddressUsed)
+	router.GET("/address/:address/validate", handler.ValidateAddress)
+	router.POST("/addresses/validate", handler.ValidateAddresses)
+	router.GET("/address/:address/balance-at/:height", handler.GetAddressBalanceAt)
+
+	// Address watching, kept up to date as blocks arrive
+	router.POST("/watch", handler.CreateWatch)
+	router.GET("/watch/:id/utxos", handler.GetWatchUTXOs)
+	router.DELETE("/watch/:id", handler.DeleteWatch)
+	router.GET("/watch/deadletters", handler.GetWebhookDeadLetters)
+
+	// Activity feed of new blocks, broadcasts and watched-address matches
+	router.GET("/events", handler.GetEvents)
+
+	// Descriptors
+	router.POST("/descriptor/info", handler.GetDescriptorInfo)
+
+	// Filters
+	router.POST("/filter/verify", handler.VerifyFilter)
+	router.POST("/filter/analyze", handler.AnalyzeFilters)
 
 	// Smart contract interactions
 	router.POST("/contract/call", handler.CallContract)
@@ -51,6 +137,7 @@ func SetupRouter(handler *Handler) *gin.Engine {
 	router.POST("/ot/broadcast_signed", handler.HandleRpcProxy)
 	router.POST("/ot/list_requests", handler.HandleRpcProxy)
 	router.POST("/ot/get_request_cycles", handler.HandleRpcProxy)
+	router.GET("/ot/request/:id", handler.GetOTRequest)
 
 	// A2U (Address to UTXO) APIs
 	router.POST("/ot/build_a2u_sighashes", handler.HandleRpcProxy)
@@ -62,6 +149,13 @@ func SetupRouter(handler *Handler) *gin.Engine {
 
 	// OT Scanner APIs
 	router.POST("/ot/list_cycles", handler.HandleRpcProxy)
+	router.GET("/ot/cycles", handler.ListOTCycles)
+	router.POST("/ot/find", handler.FindOT)
+
+	// Diagnostics (disabled unless DEBUG_ENDPOINTS is set)
+	debug := router.Group("/debug", handler.debugGate)
+	debug.GET("/config", handler.GetDebugConfig)
+	debug.GET("/bundle", handler.GetDebugBundle)
 
 	return router
 }
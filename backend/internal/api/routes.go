@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeDoc describes a registered route for GET /routes
+type routeDoc struct {
+	Description  string
+	AuthRequired bool
+	ReadOnly     bool
+}
+
+// routeDocs documents the routes registered in SetupRouter, keyed by "METHOD path"
+var routeDocs = map[string]routeDoc{
+	"GET /health":                              {Description: "Health check against the Bitcoin Core RPC", ReadOnly: true},
+	"GET /health/detailed":                     {Description: "Node status and, if enabled, contract/OT RPC availability", ReadOnly: true},
+	"GET /routes":                              {Description: "List the available API routes", ReadOnly: true},
+	"GET /blockchaininfo":                      {Description: "Blockchain information from the node", ReadOnly: true},
+	"GET /chainparams":                         {Description: "Network name, magic, address prefixes and default port", ReadOnly: true},
+	"GET /metrics/proxy":                       {Description: "Per-method call counts, error rates and latency of the RPC proxy routes", ReadOnly: true},
+	"GET /header-proof/:height":                {Description: "Header chain connecting a height to a checkpoint height (default the tip)", ReadOnly: true},
+	"GET /hashrate":                            {Description: "Estimated network hashes per second over blocks before height", ReadOnly: true},
+	"GET /headers":                             {Description: "Block headers starting at start_hash (default tip), optional fields projection", ReadOnly: true},
+	"GET /header/:hash":                        {Description: "Single block header, optional fields projection", ReadOnly: true},
+	"GET /block/:hash":                         {Description: "Full block with transaction details", ReadOnly: true},
+	"GET /subsidy/:height":                     {Description: "Block subsidy at a height with the next halving's height and time", ReadOnly: true},
+	"GET /block/eta/:height":                   {Description: "Actual time of a past height or estimated time of a future one", ReadOnly: true},
+	"GET /block/:hash/merkle-branches":         {Description: "Merkle branch and index for every transaction in a block", ReadOnly: true},
+	"GET /block/:hash/summary":                 {Description: "Block size, weight, tx count, output and fee totals", ReadOnly: true},
+	"GET /block/:hash/mtp":                     {Description: "Median time past of a block, the time CLTV and CSV timelocks use", ReadOnly: true},
+	"GET /stats/range":                         {Description: "Tx count, output and fee totals over a height range", ReadOnly: true},
+	"GET /coinbase/fees":                       {Description: "Coinbase payments to addresses over a height range, split into subsidy and fees", ReadOnly: true},
+	"POST /merkle/verify":                      {Description: "Verify a gettxoutproof merkle proof and list the txids it commits to", ReadOnly: true},
+	"POST /tx/combine":                         {Description: "Combine partially signed raw transactions", ReadOnly: true},
+	"GET /tx/:txid/mempool-chain":              {Description: "Unconfirmed ancestors and descendants with aggregate fee and vsize", ReadOnly: true},
+	"POST /txs":                                {Description: "Batch transaction lookup with per-txid errors", ReadOnly: true},
+	"GET /tx/:txid/status":                     {Description: "Status of a broadcast transaction: confirmed, in_mempool, dropped or unknown", ReadOnly: true},
+	"GET /tx/:txid":                            {Description: "Transaction hex or decoded form, with a blockhash hint for nodes without -txindex", ReadOnly: true},
+	"POST /script/decode-opreturn":             {Description: "Decode the data of an OP_RETURN scriptPubKey, including the OT pipe format", ReadOnly: true},
+	"POST /broadcast":                          {Description: "Broadcast a signed raw transaction"},
+	"GET /fees":                                {Description: "Fee rate estimate with smart, mempool or fallback source", ReadOnly: true},
+	"GET /confirm-probability":                 {Description: "Modeled probability that a fee rate confirms within a number of blocks, from the mempool", ReadOnly: true},
+	"POST /fees/estimate":                      {Description: "Fee for a raw transaction using its weight-based vsize", ReadOnly: true},
+	"POST /utxos/scan":                         {Description: "Scan a block range for UTXOs of addresses or ranged descriptors, optionally streamed as NDJSON", ReadOnly: true},
+	"POST /utxos/scan/incremental":             {Description: "Update a scanned UTXO set with the blocks since from_height", ReadOnly: true},
+	"GET /utxos/new":                           {Description: "Unspent outputs confirmed after since_height, with the tip for the next poll", ReadOnly: true},
+	"GET /utxos":                               {Description: "UTXOs of addresses, from the node's UTXO set at the tip or a block scan for historical ranges", ReadOnly: true},
+	"POST /snapshot/verify":                    {Description: "Check the signature of a signed scan snapshot", ReadOnly: true},
+	"GET /address/:address/used":               {Description: "Filter-only check whether an address was possibly used", ReadOnly: true},
+	"GET /address/:address/balance-at/:height": {Description: "Address balance as of a past height, ignoring later spends", ReadOnly: true},
+	"GET /address/:address/validate":           {Description: "Validate an address and report its type and network", ReadOnly: true},
+	"POST /watch":                              {Description: "Watch addresses, optionally seeded by a scan from from_height and posting matches to webhook_url"},
+	"GET /watch/:id/utxos":                     {Description: "Current UTXO set of a watch, updated on each new block", ReadOnly: true},
+	"DELETE /watch/:id":                        {Description: "Stop a watch"},
+	"GET /watch/deadletters":                   {Description: "Webhook deliveries that failed on every retry", ReadOnly: true},
+	"GET /events":                              {Description: "Time-ordered feed of new blocks, broadcasts and watched-address matches", ReadOnly: true},
+	"POST /addresses/validate":                 {Description: "Validate up to 1000 addresses locally against the configured network", ReadOnly: true},
+	"POST /descriptor/info":                    {Description: "Canonical descriptor with checksum, range and solvability", ReadOnly: true},
+	"POST /filter/verify":                      {Description: "Compare a client-computed filter with the node's filter", ReadOnly: true},
+	"POST /filter/analyze":                     {Description: "Filter false-positive report for addresses over a block range, per block and per address", ReadOnly: true},
+	"POST /contract/call":                      {Description: "Call a smart contract method"},
+	"POST /contract/query":                     {Description: "Query smart contract data", ReadOnly: true},
+	"POST /ot/build_sighashes":                 {Description: "OT request: build sighashes (JSON-RPC proxy)", ReadOnly: true},
+	"POST /ot/broadcast_signed":                {Description: "OT request: broadcast signed transaction (JSON-RPC proxy)"},
+	"POST /ot/list_requests":                   {Description: "OT request: list requests (JSON-RPC proxy)", ReadOnly: true},
+	"POST /ot/get_request_cycles":              {Description: "OT request: get request cycles (JSON-RPC proxy)", ReadOnly: true},
+	"POST /ot/build_a2u_sighashes":             {Description: "A2U: build sighashes (JSON-RPC proxy)", ReadOnly: true},
+	"POST /ot/broadcast_a2u":                   {Description: "A2U: broadcast signed transaction (JSON-RPC proxy)"},
+	"POST /ot/build_proof_sighashes":           {Description: "OT proof: build sighashes (JSON-RPC proxy)", ReadOnly: true},
+	"POST /ot/broadcast_proof_signed":          {Description: "OT proof: broadcast signed transaction (JSON-RPC proxy)"},
+	"POST /ot/list_cycles":                     {Description: "OT scanner: list cycles (JSON-RPC proxy)", ReadOnly: true},
+	"POST /ot/find":                            {Description: "Find the transaction carrying an OT request's OP_RETURN in recent blocks", ReadOnly: true},
+	"GET /ot/request/:id":                      {Description: "OT request lifecycle: state, validation, cycles and broadcast txids", ReadOnly: true},
+	"GET /ot/cycles":                           {Description: "OT scanner: cycles parsed and paginated with limit/offset", ReadOnly: true},
+	"GET /debug/config":                        {Description: "Effective configuration with secrets redacted", AuthRequired: true, ReadOnly: true},
+	"GET /debug/bundle":                        {Description: "Diagnostic bundle: redacted config, node state, recent scans, cache and subscriber counts", AuthRequired: true, ReadOnly: true},
+}
+
+// RouteInfo describes an API route
+type RouteInfo struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Description  string `json:"description"`
+	AuthRequired bool   `json:"auth_required"`
+	ReadOnly     bool   `json:"read_only"`
+}
+
+// listRoutes handles GET /routes
+// Lists the routes registered on the engine, annotated from routeDocs
+func listRoutes(router *gin.Engine, authEnabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registered := router.Routes()
+		routes := make([]RouteInfo, 0, len(registered))
+		for _, r := range registered {
+			doc := routeDocs[r.Method+" "+r.Path]
+			routes = append(routes, RouteInfo{
+				Method:       r.Method,
+				Path:         r.Path,
+				Description:  doc.Description,
+				AuthRequired: doc.AuthRequired || (authEnabled && !publicRoutes[r.Path]),
+				ReadOnly:     doc.ReadOnly,
+			})
+		}
+
+		sort.Slice(routes, func(i, j int) bool {
+			if routes[i].Path != routes[j].Path {
+				return routes[i].Path < routes[j].Path
+			}
+			return routes[i].Method < routes[j].Method
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"routes": routes,
+			"count":  len(routes),
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/auth"
+)
+
+func TestListRoutes(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	w := s.do(http.MethodGet, "/routes", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Routes []RouteInfo `json:"routes"`
+		Count  int         `json:"count"`
+	}
+	decode(t, w, &resp)
+	if resp.Count != len(resp.Routes) {
+		t.Errorf("count %d for %d routes", resp.Count, len(resp.Routes))
+	}
+
+	listed := make(map[string]RouteInfo, len(resp.Routes))
+	for _, r := range resp.Routes {
+		listed[r.Method+" "+r.Path] = r
+	}
+	known := []struct {
+		route    string
+		readOnly bool
+	}{
+		{"GET /health", true},
+		{"POST /utxos/scan", true},
+		{"GET /block/:hash", true},
+		{"POST /broadcast", false},
+		{"DELETE /watch/:id", false},
+	}
+	for _, k := range known {
+		r, ok := listed[k.route]
+		if !ok {
+			t.Errorf("%s is not listed", k.route)
+			continue
+		}
+		if r.Description == "" || r.ReadOnly != k.readOnly || r.AuthRequired {
+			t.Errorf("%s listed as %+v", k.route, r)
+		}
+	}
+	if _, ok := listed["POST /health"]; ok {
+		t.Error("POST /health is listed")
+	}
+
+	// Every registered route is documented, and every documented one registered
+	for route, r := range listed {
+		if r.Description == "" {
+			t.Errorf("%s has no description in routeDocs", route)
+		}
+	}
+	for route := range routeDocs {
+		if _, ok := listed[route]; !ok {
+			t.Errorf("routeDocs documents %s, which is not registered", route)
+		}
+	}
+}
+
+func TestListRoutesMarksAuthenticatedRoutes(t *testing.T) {
+	authenticator := auth.NewAPIKeyAuthenticator([]string{"secret"})
+	s := newTestServer(t, nil, authenticator, nil)
+	w := s.do(http.MethodGet, "/routes", nil, "X-API-Key", "secret")
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Routes []RouteInfo `json:"routes"`
+	}
+	decode(t, w, &resp)
+
+	for _, r := range resp.Routes {
+		if want := !publicRoutes[r.Path]; r.AuthRequired != want {
+			t.Errorf("%s %s has auth_required %v, want %v", r.Method, r.Path, r.AuthRequired, want)
+		}
+	}
+}
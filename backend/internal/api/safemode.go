@@ -0,0 +1,24 @@
+package api
+
+import "fmt"
+
+// checkScanCost rejects, in safe mode, scans predicted to overload the node:
+// direct-mode scans fetch every block in the range, and every address adds
+// filter matching and block parsing work
+func (h *Handler) checkScanCost(mode string, blocks int64, addresses int) error {
+	if !h.config.SafeMode {
+		return nil
+	}
+
+	if addresses > h.config.SafeModeMaxAddresses {
+		return fmt.Errorf("safe mode: scan of %d addresses exceeds the limit of %d, split the addresses across requests",
+			addresses, h.config.SafeModeMaxAddresses)
+	}
+
+	if mode == "direct" && blocks > int64(h.config.SafeModeMaxDirectBlocks) {
+		return fmt.Errorf("safe mode: direct scan of %d blocks exceeds the limit of %d, scan a smaller range or enable SPV mode",
+			blocks, h.config.SafeModeMaxDirectBlocks)
+	}
+
+	return nil
+}
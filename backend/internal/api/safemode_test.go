@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+// safeModeConfig allows direct scans of 10 blocks and 2 addresses in safe mode
+func safeModeConfig(safeMode, spvMode bool) *config.Config {
+	return &config.Config{
+		SafeMode:                safeMode,
+		SafeModeMaxDirectBlocks: 10,
+		SafeModeMaxAddresses:    2,
+		SPVMode:                 spvMode,
+	}
+}
+
+func TestSafeModeRejectsLongDirectScan(t *testing.T) {
+	for _, safeMode := range []bool{true, false} {
+		s := newTestServer(t, safeModeConfig(safeMode, false), nil, nil)
+		address := rpctest.Address(testParams, "p2wpkh", 1)
+		for i := 0; i < 12; i++ {
+			s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+		}
+		addresses := []string{address.EncodeAddress()}
+
+		full := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 1, 12, nil))
+		incremental := s.do(http.MethodPost, "/utxos/scan/incremental", map[string]interface{}{
+			"addresses":   addresses,
+			"from_height": 1,
+		})
+		if !safeMode {
+			expectStatus(t, full, http.StatusOK)
+			expectStatus(t, incremental, http.StatusOK)
+			continue
+		}
+
+		for _, w := range []*httptest.ResponseRecorder{full, incremental} {
+			expectStatus(t, w, http.StatusBadRequest)
+			var resp struct {
+				Error string `json:"error"`
+			}
+			decode(t, w, &resp)
+			if !strings.Contains(resp.Error, "12 blocks") || !strings.Contains(resp.Error, "SPV mode") {
+				t.Errorf("error %q does not explain the limit", resp.Error)
+			}
+		}
+		if s.node.Calls("getblock") != 0 {
+			t.Error("rejected scans fetched blocks")
+		}
+
+		// Within the limit is fine
+		expectStatus(t, s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 3, 12, nil)), http.StatusOK)
+	}
+}
+
+func TestSafeModeAllowsLongSPVScan(t *testing.T) {
+	s := newTestServer(t, safeModeConfig(true, true), nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 12; i++ {
+		s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	}
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 1, 12, nil))
+	expectStatus(t, w, http.StatusOK)
+}
+
+func TestSafeModeRejectsManyAddresses(t *testing.T) {
+	var addresses []string
+	for seed := byte(1); seed <= 3; seed++ {
+		addresses = append(addresses, rpctest.Address(testParams, "p2wpkh", seed).EncodeAddress())
+	}
+	for _, safeMode := range []bool{true, false} {
+		// The address limit applies in SPV mode too
+		s := newTestServer(t, safeModeConfig(safeMode, true), nil, nil)
+		s.chain.AddBlock()
+
+		w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 1, 1, nil))
+		if safeMode {
+			expectStatus(t, w, http.StatusBadRequest)
+			if !strings.Contains(w.Body.String(), "3 addresses") {
+				t.Errorf("error %s does not explain the limit", w.Body)
+			}
+		} else {
+			expectStatus(t, w, http.StatusOK)
+		}
+	}
+}
+
+func TestSafeModeCountsPreviousUTXOAddresses(t *testing.T) {
+	// No addresses of its own, but the previous UTXOs' three addresses are
+	// scanned too
+	var previous []filter.UTXO
+	for seed := byte(1); seed <= 3; seed++ {
+		address := rpctest.Address(testParams, "p2wpkh", seed).EncodeAddress()
+		previous = append(previous, filter.UTXO{TxID: strings.Repeat("ab", 32), Vout: int(seed), Address: address, Satoshis: 1000})
+	}
+	for _, safeMode := range []bool{true, false} {
+		s := newTestServer(t, safeModeConfig(safeMode, true), nil, nil)
+		s.chain.AddBlock()
+
+		w := s.do(http.MethodPost, "/utxos/scan/incremental", map[string]interface{}{
+			"addresses":      []string{},
+			"from_height":    1,
+			"previous_utxos": previous,
+		})
+		if safeMode {
+			expectStatus(t, w, http.StatusBadRequest)
+			if !strings.Contains(w.Body.String(), "3 addresses") {
+				t.Errorf("error %s does not explain the limit", w.Body)
+			}
+		} else {
+			expectStatus(t, w, http.StatusOK)
+		}
+	}
+}
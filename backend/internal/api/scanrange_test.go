@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+func TestScanSingleBlockRange(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 2000)))
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 2, 2, nil))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if result.TotalUTXOs != 1 || result.TotalSatoshis != 2000 {
+		t.Errorf("found %d UTXOs, %d sats, want only block 2's output", result.TotalUTXOs, result.TotalSatoshis)
+	}
+
+	// A start after the end is not an empty range but a mistake
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 2, 1, nil))
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestScanTimeRangeResolvesHeights(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for height := int64(1); height <= 60; height++ {
+		s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000+height)))
+	}
+	blockTime := func(height int64) int64 { return s.chain.BlockAt(height).Time() }
+
+	// Blocks are 10 minutes apart, so from height 10 on the median time past
+	// of a block is the time of the block five below it. The two hour
+	// tolerance widens the range by 12 blocks each way: the first height
+	// whose median time is at or after T(18) is 23, and the last whose
+	// median time is at or before T(44) is 49.
+	w := s.do(http.MethodPost, "/utxos/scan", map[string]interface{}{
+		"addresses":  []string{address.EncodeAddress()},
+		"start_time": blockTime(30),
+		"end_time":   blockTime(32),
+	})
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if result.HeightRange == nil || result.HeightRange.StartHeight != 23 || result.HeightRange.EndHeight != 49 {
+		t.Fatalf("time range resolved to %+v, want heights 23 to 49", result.HeightRange)
+	}
+	if result.TotalUTXOs != 27 {
+		t.Errorf("found %d UTXOs, want one per block from 23 to 49", result.TotalUTXOs)
+	}
+	for _, utxo := range result.UTXOs {
+		if utxo.Height < 23 || utxo.Height > 49 {
+			t.Errorf("UTXO at height %d is outside the resolved range", utxo.Height)
+		}
+	}
+}
+
+func TestScanTimeRangeWithoutBlocks(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	future := s.chain.Tip().Time() + 24*60*60
+
+	w := s.do(http.MethodPost, "/utxos/scan", map[string]interface{}{
+		"addresses":  []string{address.EncodeAddress()},
+		"start_time": future,
+		"end_time":   future + 600,
+	})
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if result.TotalUTXOs != 0 || result.HeightRange == nil || result.HeightRange.StartHeight <= result.HeightRange.EndHeight {
+		t.Errorf("got %d UTXOs over %+v, want an empty range", result.TotalUTXOs, result.HeightRange)
+	}
+	if s.node.Calls("getblock") != 0 {
+		t.Error("blocks were fetched for a range without blocks")
+	}
+}
+
+func TestScanTimeRangeRejectsMixedBounds(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	for _, body := range []map[string]interface{}{
+		{"addresses": []string{address}, "start_time": 1000},
+		{"addresses": []string{address}, "start_time": 1000, "end_time": 2000, "start_height": 0},
+		{"addresses": []string{address}, "start_time": 2000, "end_time": 1000},
+	} {
+		expectStatus(t, s.do(http.MethodPost, "/utxos/scan", body), http.StatusBadRequest)
+	}
+}
+
+func TestScanEndInclusive(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for height := int64(1); height <= 5; height++ {
+		s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000*height)))
+	}
+
+	tests := []struct {
+		name    string
+		extra   map[string]interface{}
+		heights []int64
+	}{
+		{"default", nil, []int64{2, 3, 4}},
+		{"inclusive", map[string]interface{}{"end_inclusive": true}, []int64{2, 3, 4}},
+		{"exclusive", map[string]interface{}{"end_inclusive": false}, []int64{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 2, 4, tt.extra))
+			expectStatus(t, w, http.StatusOK)
+			var result filter.UTXOScanResult
+			decode(t, w, &result)
+			if result.BlocksScanned != len(tt.heights) || len(result.UTXOs) != len(tt.heights) {
+				t.Fatalf("scanned %d blocks, found %d UTXOs; want heights %v", result.BlocksScanned, len(result.UTXOs), tt.heights)
+			}
+			found := make(map[int64]bool)
+			for _, utxo := range result.UTXOs {
+				found[utxo.Height] = true
+			}
+			for _, height := range tt.heights {
+				if !found[height] {
+					t.Errorf("no UTXO from height %d", height)
+				}
+			}
+		})
+	}
+
+	// Exclusive ranges sharing a bound cover each block once
+	var total int
+	for _, bounds := range [][2]int64{{1, 3}, {3, 6}} {
+		w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, bounds[0], bounds[1], map[string]interface{}{"end_inclusive": false}))
+		expectStatus(t, w, http.StatusOK)
+		var result filter.UTXOScanResult
+		decode(t, w, &result)
+		total += result.TotalUTXOs
+	}
+	if total != 5 {
+		t.Errorf("adjacent exclusive ranges found %d UTXOs, want 5", total)
+	}
+
+	// An exclusive range must not be empty
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 2, 2, map[string]interface{}{"end_inclusive": false}))
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"spv-backend/internal/filter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotAlgorithm is the signature scheme of signed scan snapshots
+const snapshotAlgorithm = "ed25519"
+
+// SnapshotPayload is what a signed scan snapshot commits to: the scanned
+// request, its result and the tip at the start of the verification pass,
+// the chain state the UTXOs were checked against
+type SnapshotPayload struct {
+	Request   SnapshotRequest        `json:"request"`
+	Result    *filter.UTXOScanResult `json:"result"`
+	TipHash   string                 `json:"tip_hash"`
+	TipHeight int64                  `json:"tip_height"`
+	SignedAt  int64                  `json:"signed_at"` // Unix time
+}
+
+// SnapshotRequest is the scan a snapshot answers, after descriptor expansion,
+// address validation and range normalization
+type SnapshotRequest struct {
+	// SHA-256 of the sorted, deduplicated addresses scanned, one per line
+	// (bech32 lowercased), so a verifier can check it against its own list
+	AddressSetKey        string `json:"address_set_key"`
+	StartHeight          int64  `json:"start_height"`
+	EndHeight            int64  `json:"end_height"` // Inclusive
+	IncludeMempoolSpends bool   `json:"include_mempool_spends"`
+}
+
+// SignedSnapshot is a scan result signed with SNAPSHOT_SIGNING_KEY. Snapshot
+// holds the exact signed bytes, a JSON encoded SnapshotPayload, so verifiers
+// check the signature over it as is rather than over a re-encoding.
+type SignedSnapshot struct {
+	Snapshot  string `json:"snapshot"`
+	Signature string `json:"signature"`  // Hex encoded
+	PublicKey string `json:"public_key"` // Hex encoded
+	Algorithm string `json:"algorithm"`
+}
+
+// signScanResult signs a scan result together with its request and the tip
+// it was verified against. The scan must have run with ScanOptions.RecordTip.
+func (h *Handler) signScanResult(request SnapshotRequest, result *filter.UTXOScanResult) (*SignedSnapshot, error) {
+	if result.Verification == nil || result.Verification.TipHash == "" {
+		return nil, fmt.Errorf("scan result has no verification tip to sign")
+	}
+
+	payload, err := json.Marshal(SnapshotPayload{
+		Request:   request,
+		Result:    result,
+		TipHash:   result.Verification.TipHash,
+		TipHeight: result.Verification.TipHeight,
+		SignedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	return &SignedSnapshot{
+		Snapshot:  string(payload),
+		Signature: hex.EncodeToString(ed25519.Sign(h.snapshotKey, payload)),
+		PublicKey: hex.EncodeToString(h.snapshotKey.Public().(ed25519.PublicKey)),
+		Algorithm: snapshotAlgorithm,
+	}, nil
+}
+
+// VerifySnapshotRequest is a signed snapshot to check
+type VerifySnapshotRequest struct {
+	Snapshot  string `json:"snapshot" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+// VerifySnapshot handles POST /snapshot/verify
+// Reports whether a snapshot from a signed scan is unmodified and was signed
+// with this server's key. Anyone with public_key can check it offline too.
+func (h *Handler) VerifySnapshot(c *gin.Context) {
+	if h.snapshotKey == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "signed snapshots are disabled (SNAPSHOT_SIGNING_KEY is not set)"})
+		return
+	}
+
+	var req VerifySnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	signature, err := hex.DecodeString(req.Signature)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid signature hex"})
+		return
+	}
+
+	publicKey := h.snapshotKey.Public().(ed25519.PublicKey)
+	c.JSON(http.StatusOK, gin.H{
+		"valid":      ed25519.Verify(publicKey, []byte(req.Snapshot), signature),
+		"public_key": hex.EncodeToString(publicKey),
+		"algorithm":  snapshotAlgorithm,
+	})
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpctest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotSeed is the SNAPSHOT_SIGNING_KEY of the snapshot tests
+const snapshotSeed = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestSignedScanVerifiesAndDetectsTampering(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s := newTestServer(t, &config.Config{SnapshotSigningKey: snapshotSeed}, nil, nil)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	s.chain.AddBlock()
+	tip := s.chain.Tip()
+
+	// The tip signed is the one the UTXOs were checked against, so a block
+	// connected during the pass must not replace it
+	var once sync.Once
+	s.node.Handle("gettxout", func(params []json.RawMessage) (interface{}, error) {
+		once.Do(func() { s.chain.AddBlock() })
+		return map[string]interface{}{"confirmations": 2, "value": 0.00001}, nil
+	})
+
+	// Mixed case and a duplicate normalize to the same address set
+	upper := strings.ToUpper(address.EncodeAddress())
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{upper, address.EncodeAddress()}, 0, 2, map[string]interface{}{"signed": true}))
+	expectStatus(t, w, http.StatusOK)
+	var signed SignedSnapshot
+	decode(t, w, &signed)
+
+	// Anyone can check the signature offline
+	publicKey, _ := hex.DecodeString(signed.PublicKey)
+	signature, _ := hex.DecodeString(signed.Signature)
+	if !ed25519.Verify(publicKey, []byte(signed.Snapshot), signature) {
+		t.Fatal("signature does not verify")
+	}
+
+	var payload SnapshotPayload
+	if err := json.Unmarshal([]byte(signed.Snapshot), &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := SnapshotRequest{
+		AddressSetKey:        s.handler.filterService.AddressSetKey([]string{address.EncodeAddress()}),
+		StartHeight:          0,
+		EndHeight:            2,
+		IncludeMempoolSpends: true,
+	}
+	if payload.Request != want {
+		t.Errorf("signed request %+v, want %+v", payload.Request, want)
+	}
+	if payload.TipHash != tip.Hash || payload.TipHeight != tip.Height {
+		t.Errorf("signed tip %s at %d, want the pass's tip %s at %d", payload.TipHash, payload.TipHeight, tip.Hash, tip.Height)
+	}
+	if !payload.Result.Verification.TipChanged {
+		t.Error("block connected during the pass not reported")
+	}
+	if payload.Result.TotalUTXOs != 1 || payload.Result.TotalSatoshis != 1000 {
+		t.Errorf("signed result has %d UTXOs, %d sats", payload.Result.TotalUTXOs, payload.Result.TotalSatoshis)
+	}
+
+	verify := func(snapshot string) bool {
+		t.Helper()
+		w := s.do(http.MethodPost, "/snapshot/verify", gin.H{"snapshot": snapshot, "signature": signed.Signature})
+		expectStatus(t, w, http.StatusOK)
+		var result struct {
+			Valid bool `json:"valid"`
+		}
+		decode(t, w, &result)
+		return result.Valid
+	}
+	if !verify(signed.Snapshot) {
+		t.Fatal("server rejects its own snapshot")
+	}
+
+	tampered := map[string]string{
+		"result":  strings.Replace(signed.Snapshot, `"total_satoshis":1000`, `"total_satoshis":9000`, 1),
+		"request": strings.Replace(signed.Snapshot, `"end_height":2`, `"end_height":3`, 1),
+		"tip":     strings.Replace(signed.Snapshot, `"tip_height":2`, `"tip_height":3`, 1),
+	}
+	for name, snapshot := range tampered {
+		if snapshot == signed.Snapshot {
+			t.Fatalf("%s: tampering did not change the snapshot", name)
+		}
+		if verify(snapshot) {
+			t.Errorf("%s: tampered snapshot verifies", name)
+		}
+	}
+}
+
+func TestSignedScanRejectsPagination(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	s := newTestServer(t, &config.Config{SnapshotSigningKey: snapshotSeed}, nil, nil)
+	s.chain.AddBlock()
+
+	// A page, or a scan resumed from a cursor, is not the range's UTXO set
+	for _, extra := range []map[string]interface{}{
+		{"signed": true, "limit": 10},
+		{"signed": true, "cursor": "anything"},
+	} {
+		w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 1, extra))
+		expectStatus(t, w, http.StatusBadRequest)
+		if !strings.Contains(w.Body.String(), "signed is not supported with limit or cursor") {
+			t.Errorf("%v: error %s does not explain the rejection", extra, w.Body)
+		}
+	}
+	if s.node.Calls("getblock") != 0 {
+		t.Error("rejected scans fetched blocks")
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+)
+
+func TestScanSortOrders(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 5000), rpctest.PayTo(address, 1000)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 3000)), s.chain.NewTx(nil, rpctest.PayTo(address, 5000)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 2000)))
+	addresses := []string{address.EncodeAddress()}
+
+	// Equal values fall back to chain order: the 5000 at height 1 comes first
+	for _, tc := range []struct {
+		sort    string
+		heights []int64
+		sats    []int64
+	}{
+		{"", []int64{1, 1, 2, 2, 3}, nil},
+		{"height", []int64{1, 1, 2, 2, 3}, nil},
+		{"value_desc", []int64{1, 2, 2, 3, 1}, []int64{5000, 5000, 3000, 2000, 1000}},
+		{"value_asc", []int64{1, 3, 2, 1, 2}, []int64{1000, 2000, 3000, 5000, 5000}},
+	} {
+		t.Run("sort="+tc.sort, func(t *testing.T) {
+			var extra map[string]interface{}
+			if tc.sort != "" {
+				extra = map[string]interface{}{"sort": tc.sort}
+			}
+			w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), extra))
+			expectStatus(t, w, http.StatusOK)
+			var result filter.UTXOScanResult
+			decode(t, w, &result)
+			if len(result.UTXOs) != 5 {
+				t.Fatalf("found %d UTXOs, want 5", len(result.UTXOs))
+			}
+
+			for i, utxo := range result.UTXOs {
+				if utxo.Height != tc.heights[i] || (tc.sats != nil && utxo.Satoshis != tc.sats[i]) {
+					t.Errorf("UTXO %d: %d sats at height %d", i, utxo.Satoshis, utxo.Height)
+				}
+				if i == 0 {
+					continue
+				}
+				prev := result.UTXOs[i-1]
+				if prev.Height == utxo.Height && prev.Satoshis == utxo.Satoshis && prev.TxID > utxo.TxID {
+					t.Errorf("UTXOs %d and %d are not in chain order", i-1, i)
+				}
+				if tc.sats == nil && prev.Height == utxo.Height && prev.TxID == utxo.TxID && prev.Vout > utxo.Vout {
+					t.Errorf("UTXOs %d and %d are not in output order", i-1, i)
+				}
+			}
+		})
+	}
+}
+
+func TestScanSortRejectsBadInput(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+	addresses := []string{rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()}
+
+	for name, extra := range map[string]map[string]interface{}{
+		"unknown order":      {"sort": "amount"},
+		"value with a limit": {"sort": "value_desc", "limit": 10},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, 1, extra)), http.StatusBadRequest)
+		})
+	}
+}
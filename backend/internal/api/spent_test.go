@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanIncludeSpentReportsSpender(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(address, 1000), rpctest.PayTo(address, 2000))
+	s.chain.AddBlock(fund)
+	spend := s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 9), 1900))
+	s.chain.AddBlock(spend)
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, 2, map[string]interface{}{"include_spent": true}))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if result.TotalUTXOs != 1 || result.TotalSatoshis != 1000 {
+		t.Errorf("found %d UTXOs, %d sats, want only the unspent output", result.TotalUTXOs, result.TotalSatoshis)
+	}
+	if len(result.SpentOutputs) != 1 {
+		t.Fatalf("spent outputs %+v, want one", result.SpentOutputs)
+	}
+	got := result.SpentOutputs[0]
+	if got.TxID != fund.TxHash().String() || got.Vout != 1 {
+		t.Errorf("spent output %s:%d, want %s:1", got.TxID, got.Vout, fund.TxHash())
+	}
+	if got.SpentByTxID != spend.TxHash().String() || got.SpentAtHeight != 2 {
+		t.Errorf("spent by %s at %d, want %s at 2", got.SpentByTxID, got.SpentAtHeight, spend.TxHash())
+	}
+
+	// Spent outputs are not paged
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, 2, map[string]interface{}{"include_spent": true, "limit": 1}))
+	expectStatus(t, w, http.StatusBadRequest)
+}
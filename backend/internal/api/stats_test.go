@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestRangeStatsSumsBlocks(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(address, 100000), rpctest.PayTo(address, 50000))
+	s.chain.AddBlock(fund)
+	s.chain.AddBlock(
+		s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(address, 90000)),
+		s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(address, 30000), rpctest.PayTo(address, 19500)),
+	)
+	s.chain.AddBlock()
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 7000)))
+
+	w := s.do(http.MethodGet, "/stats/range?start=1&end=3", nil)
+	expectStatus(t, w, http.StatusOK)
+	var stats RangeStats
+	decode(t, w, &stats)
+	want := RangeStats{StartHeight: 1, EndHeight: 3, Blocks: 3, TxCount: 6, TotalOut: 289500, TotalFee: 10500}
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+	if s.node.Calls("getblockstats") != 3 || s.node.Batches() != 1 {
+		t.Errorf("%d getblockstats calls in %d batches, want 3 in one", s.node.Calls("getblockstats"), s.node.Batches())
+	}
+}
+
+func TestRangeStatsBatchesLongRanges(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for height := 1; height <= statsBatchSize+50; height++ {
+		s.chain.AddBlock()
+	}
+
+	w := s.do(http.MethodGet, "/stats/range?start=1&end=150", nil)
+	expectStatus(t, w, http.StatusOK)
+	var stats RangeStats
+	decode(t, w, &stats)
+	if stats.Blocks != 150 || stats.TxCount != 150 || stats.TotalOut != 0 || stats.TotalFee != 0 {
+		t.Errorf("got %+v, want 150 coinbase-only blocks", stats)
+	}
+	if s.node.Batches() != 2 {
+		t.Errorf("sent %d batches, want 2", s.node.Batches())
+	}
+}
+
+func TestRangeStatsRejectsBadRanges(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	s.chain.AddBlock()
+
+	for query, status := range map[string]int{
+		"start=x&end=1":    http.StatusBadRequest,
+		"start=-1&end=1":   http.StatusBadRequest,
+		"start=1&end=0":    http.StatusBadRequest,
+		"start=0&end=1000": http.StatusBadRequest,
+		"start=0&end=2":    http.StatusNotFound,
+	} {
+		w := s.do(http.MethodGet, "/stats/range?"+query, nil)
+		if w.Code != status {
+			t.Errorf("%s: status %d, want %d", query, w.Code, status)
+		}
+	}
+	if s.node.Calls("getblockstats") != 0 {
+		t.Error("invalid ranges reached getblockstats")
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"spv-backend/internal/filter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ndjsonContentType is the Accept value that selects a streamed scan response
+const ndjsonContentType = "application/x-ndjson"
+
+// wantsNDJSON reports whether the client asked for a newline-delimited JSON response
+func wantsNDJSON(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), ndjsonContentType)
+}
+
+// scanStream sends a scan's UTXOs as they are verified (see ScanOptions.OnUTXO)
+type scanStream interface {
+	SendUTXO(utxo filter.UTXO) error
+	Finish(result *filter.UTXOScanResult, err error)
+}
+
+// scanStreamSummary is the final line of a streamed scan: the scan result
+// without its UTXOs, which were already sent one per line
+type scanStreamSummary struct {
+	*filter.UTXOScanResult
+	UTXOs []filter.UTXO `json:"utxos,omitempty"`
+}
+
+// ndjsonStream writes one JSON object per line, flushing after each so the
+// client can process a scan incrementally. The status line and headers are
+// only sent with the first object, so a scan that fails before emitting
+// anything can still answer with a regular JSON error.
+type ndjsonStream struct {
+	c       *gin.Context
+	encoder *json.Encoder
+	started bool
+}
+
+func newNDJSONStream(c *gin.Context) *ndjsonStream {
+	return &ndjsonStream{c: c, encoder: json.NewEncoder(c.Writer)}
+}
+
+// Started reports whether any line has been written
+func (s *ndjsonStream) Started() bool {
+	return s.started
+}
+
+// Send writes v as a single line
+func (s *ndjsonStream) Send(v interface{}) error {
+	if !s.started {
+		s.started = true
+		s.c.Header("Content-Type", ndjsonContentType)
+		s.c.Status(http.StatusOK)
+	}
+	if err := s.encoder.Encode(v); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// SendUTXO writes a UTXO line; it is used as filter.ScanOptions.OnUTXO
+func (s *ndjsonStream) SendUTXO(utxo filter.UTXO) error {
+	return s.Send(utxo)
+}
+
+// Finish writes the summary line, or an error line if the scan failed after
+// the stream started. A failure before any line is answered with a JSON error.
+// A scan cut short with a partial result carries it as the error's summary.
+func (s *ndjsonStream) Finish(result *filter.UTXOScanResult, err error) {
+	if err != nil {
+		line := gin.H{"error": err.Error()}
+		if result != nil {
+			line["summary"] = scanStreamSummary{UTXOScanResult: result}
+		}
+		if !s.started {
+			s.c.JSON(http.StatusInternalServerError, line)
+			return
+		}
+		s.Send(line)
+		return
+	}
+	s.Send(gin.H{"summary": scanStreamSummary{UTXOScanResult: result}})
+}
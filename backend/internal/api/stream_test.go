@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestScanStreamsNDJSON(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2pkh", 2)
+
+	// Twenty outputs over ten blocks, some spent within the range and one in
+	// the mempool
+	var funds []*wire.MsgTx
+	for i := 0; i < 10; i++ {
+		tx := s.chain.NewTx(nil, rpctest.PayTo(a, int64(1000+i)), rpctest.PayTo(b, int64(2000+i)))
+		funds = append(funds, tx)
+		s.chain.AddBlock(tx)
+	}
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funds[2], 0), rpctest.OutPoint(funds[5], 1)}))
+	s.chain.AddToMempool(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funds[7], 0)}))
+
+	body := scanBody([]string{a.EncodeAddress(), b.EncodeAddress()}, 0, s.chain.Height(), nil)
+	w := s.do(http.MethodPost, "/utxos/scan", body)
+	expectStatus(t, w, http.StatusOK)
+	var buffered filter.UTXOScanResult
+	decode(t, w, &buffered)
+	if buffered.TotalUTXOs != 17 {
+		t.Fatalf("buffered scan found %d UTXOs, want 17", buffered.TotalUTXOs)
+	}
+
+	w = s.do(http.MethodPost, "/utxos/scan", body, "Accept", ndjsonContentType)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("content type %q", ct)
+	}
+
+	// Every line but the last is a UTXO, the last the summary
+	streamed := make(map[string]filter.UTXO)
+	var summary *filter.UTXOScanResult
+	lines := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+	for lines.Scan() {
+		if summary != nil {
+			t.Fatalf("line after the summary: %s", lines.Text())
+		}
+		var line struct {
+			filter.UTXO
+			Summary *filter.UTXOScanResult `json:"summary"`
+			Error   string                 `json:"error"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &line); err != nil {
+			t.Fatalf("line %q: %v", lines.Text(), err)
+		}
+		switch {
+		case line.Error != "":
+			t.Fatalf("error line: %s", line.Error)
+		case line.Summary != nil:
+			summary = line.Summary
+		default:
+			key := fmt.Sprintf("%s:%d", line.TxID, line.Vout)
+			if _, ok := streamed[key]; ok {
+				t.Fatalf("%s streamed twice", key)
+			}
+			streamed[key] = line.UTXO
+		}
+	}
+	if summary == nil {
+		t.Fatal("no summary line")
+	}
+
+	if len(streamed) != len(buffered.UTXOs) {
+		t.Fatalf("streamed %d UTXOs, buffered %d", len(streamed), len(buffered.UTXOs))
+	}
+	for _, utxo := range buffered.UTXOs {
+		if got, ok := streamed[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)]; !ok || !reflect.DeepEqual(got, utxo) {
+			t.Errorf("streamed %+v, buffered %+v", got, utxo)
+		}
+	}
+	if len(summary.UTXOs) != 0 || summary.TotalUTXOs != buffered.TotalUTXOs || summary.TotalSatoshis != buffered.TotalSatoshis {
+		t.Errorf("summary %d UTXOs, %d sats, %d listed; buffered %d, %d", summary.TotalUTXOs, summary.TotalSatoshis, len(summary.UTXOs), buffered.TotalUTXOs, buffered.TotalSatoshis)
+	}
+}
+
+func TestScanStreamRejectsBufferedOptions(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address}, 0, 0, map[string]interface{}{"limit": 10}), "Accept", ndjsonContentType)
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// mixedAddresses returns two paid regtest addresses mixed with a malformed
+// and a mainnet address
+func mixedAddresses(s *testServer) (valid, invalid []string) {
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 2000)))
+	valid = []string{a.EncodeAddress(), b.EncodeAddress()}
+	invalid = []string{"notanaddress", rpctest.Address(&chaincfg.MainNetParams, "p2wpkh", 3).EncodeAddress()}
+	return valid, invalid
+}
+
+func TestScanStrictAddressesRejectsMixedList(t *testing.T) {
+	for name, extra := range map[string]map[string]interface{}{
+		"default":  nil,
+		"explicit": {"strict_addresses": true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, nil, nil, nil)
+			valid, invalid := mixedAddresses(s)
+			addresses := []string{valid[0], invalid[0], valid[1], invalid[1]}
+
+			w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), extra))
+			expectStatus(t, w, http.StatusBadRequest)
+			var resp struct {
+				Error            string                  `json:"error"`
+				InvalidAddresses []filter.SkippedAddress `json:"invalid_addresses"`
+			}
+			decode(t, w, &resp)
+			if len(resp.InvalidAddresses) != 2 || resp.InvalidAddresses[0].Address != invalid[0] || resp.InvalidAddresses[1].Address != invalid[1] {
+				t.Errorf("invalid addresses %+v, want %v", resp.InvalidAddresses, invalid)
+			}
+			if s.node.Calls("getblock") != 0 || s.node.Calls("getblockfilter") != 0 {
+				t.Error("a rejected scan fetched blocks or filters")
+			}
+		})
+	}
+}
+
+func TestScanLenientAddressesSkipsInvalid(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	valid, invalid := mixedAddresses(s)
+	addresses := []string{valid[0], invalid[0], valid[1], invalid[1]}
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody(addresses, 0, s.chain.Height(), map[string]interface{}{"strict_addresses": false}))
+	expectStatus(t, w, http.StatusOK)
+	var result filter.UTXOScanResult
+	decode(t, w, &result)
+	if result.TotalUTXOs != 2 || result.TotalSatoshis != 3000 {
+		t.Errorf("found %d UTXOs, %d sats, want both valid addresses' outputs", result.TotalUTXOs, result.TotalSatoshis)
+	}
+	if len(result.SkippedAddresses) != 2 {
+		t.Fatalf("skipped %+v, want %v", result.SkippedAddresses, invalid)
+	}
+	for i, skipped := range result.SkippedAddresses {
+		if skipped.Address != invalid[i] || skipped.Error == "" {
+			t.Errorf("skipped %+v, want %s with its error", skipped, invalid[i])
+		}
+	}
+
+	// Valid lists report nothing skipped
+	w = s.do(http.MethodPost, "/utxos/scan", scanBody(valid, 0, s.chain.Height(), map[string]interface{}{"strict_addresses": false}))
+	expectStatus(t, w, http.StatusOK)
+	var clean filter.UTXOScanResult
+	decode(t, w, &clean)
+	if clean.SkippedAddresses != nil {
+		t.Errorf("skipped %+v from a valid list", clean.SkippedAddresses)
+	}
+}
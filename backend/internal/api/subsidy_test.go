@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// subsidyResponse is the GET /subsidy/:height response
+type subsidyResponse struct {
+	Height                 int64   `json:"height"`
+	SubsidySats            int64   `json:"subsidy_sats"`
+	SubsidyBTC             float64 `json:"subsidy_btc"`
+	Halvings               int64   `json:"halvings"`
+	HalvingInterval        int64   `json:"halving_interval"`
+	NextHalvingHeight      int64   `json:"next_halving_height"`
+	NextHalvingSubsidySats int64   `json:"next_halving_subsidy_sats"`
+	BlocksUntilHalving     int64   `json:"blocks_until_halving"`
+	NextHalvingTime        int64   `json:"next_halving_time"`
+	NextHalvingIsEstimate  bool    `json:"next_halving_is_estimate"`
+	TipHeight              int64   `json:"tip_height"`
+}
+
+func TestSubsidyAtHeights(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 5; i++ {
+		s.chain.AddBlock()
+	}
+
+	// Regtest halves every 150 blocks
+	tests := []struct {
+		height, subsidy, halvings, next int64
+	}{
+		{0, 5000000000, 0, 150},
+		{149, 5000000000, 0, 150},
+		{150, 2500000000, 1, 300},
+		{400, 1250000000, 2, 450},
+	}
+	for _, tt := range tests {
+		w := s.do(http.MethodGet, "/subsidy/"+strconv.FormatInt(tt.height, 10), nil)
+		expectStatus(t, w, http.StatusOK)
+		var resp subsidyResponse
+		decode(t, w, &resp)
+		if resp.Height != tt.height || resp.SubsidySats != tt.subsidy || resp.SubsidyBTC != float64(tt.subsidy)/1e8 ||
+			resp.Halvings != tt.halvings || resp.HalvingInterval != 150 {
+			t.Errorf("height %d: got %+v, want %d sats after %d halvings", tt.height, resp, tt.subsidy, tt.halvings)
+		}
+		if resp.NextHalvingHeight != tt.next || resp.NextHalvingSubsidySats != tt.subsidy/2 || resp.BlocksUntilHalving != tt.next-tt.height {
+			t.Errorf("height %d: next halving %+v, want at %d", tt.height, resp, tt.next)
+		}
+		// The chain is five blocks long, so every halving is ahead of it
+		if !resp.NextHalvingIsEstimate || resp.TipHeight != 5 || resp.NextHalvingTime <= s.chain.Tip().Time() {
+			t.Errorf("height %d: halving time %d (estimate %v), want an estimate after the tip", tt.height, resp.NextHalvingTime, resp.NextHalvingIsEstimate)
+		}
+	}
+}
+
+func TestSubsidyPastHalvingTime(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for i := 0; i < 160; i++ {
+		s.chain.AddBlock()
+	}
+
+	w := s.do(http.MethodGet, "/subsidy/10", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp subsidyResponse
+	decode(t, w, &resp)
+	if resp.NextHalvingIsEstimate || resp.NextHalvingTime != s.chain.BlockAt(150).Time() {
+		t.Errorf("got %+v, want the actual time %d of block 150", resp, s.chain.BlockAt(150).Time())
+	}
+}
+
+func TestBlockSubsidyMainnet(t *testing.T) {
+	interval := int64(chaincfg.MainNetParams.SubsidyReductionInterval)
+	for height, want := range map[int64]int64{
+		0:        5000000000,
+		209999:   5000000000,
+		210000:   2500000000,
+		420000:   1250000000,
+		840000:   312500000,
+		13440000: 0, // 64 halvings
+	} {
+		if subsidy, _ := blockSubsidy(height, interval); subsidy != want {
+			t.Errorf("subsidy at %d: got %d, want %d", height, subsidy, want)
+		}
+	}
+
+	// Regtest's subsidy is gone after 64 halvings, with no next halving
+	s := newTestServer(t, nil, nil, nil)
+	w := s.do(http.MethodGet, "/subsidy/100000", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp subsidyResponse
+	decode(t, w, &resp)
+	if resp.SubsidySats != 0 || resp.NextHalvingHeight != 0 {
+		t.Errorf("got %+v, want no subsidy and no next halving", resp)
+	}
+
+	w = s.do(http.MethodGet, "/subsidy/-1", nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
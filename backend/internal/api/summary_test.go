@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestBlockSummary(t *testing.T) {
+	for _, source := range []string{"getblockstats", "block"} {
+		s := newTestServer(t, nil, nil, nil)
+		if source == "block" {
+			// Nodes without getblockstats fall back to the full block
+			s.node.Handle("getblockstats", func(params []json.RawMessage) (interface{}, error) {
+				return nil, &rpc.RPCError{Code: rpc.ErrCodeMethodNotFound, Message: "Method not found"}
+			})
+		}
+		address := rpctest.Address(testParams, "p2wpkh", 1)
+		fund := s.chain.NewTx(nil, rpctest.PayTo(address, 100000), rpctest.PayTo(address, 50000))
+		s.chain.AddBlock(fund)
+		block := s.chain.AddBlock(
+			s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(address, 90000)),
+			s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(address, 30000), rpctest.PayTo(address, 19500)),
+		)
+
+		w := s.do(http.MethodGet, "/block/"+block.Hash+"/summary", nil)
+		expectStatus(t, w, http.StatusOK)
+		var summary BlockSummary
+		decode(t, w, &summary)
+
+		size := int64(block.Msg.SerializeSize())
+		weight := int64(block.Msg.SerializeSizeStripped()*3) + size
+		if summary.Hash != block.Hash || summary.Height != 2 || summary.Size != size || summary.Weight != weight || summary.TxCount != 3 {
+			t.Errorf("%s: got %+v, want height 2, size %d, weight %d, 3 txs", source, summary, size, weight)
+		}
+		if summary.TotalOut != 139500 || summary.TotalFee == nil || *summary.TotalFee != 10500 || summary.Source != source {
+			t.Errorf("%s: got %d sats out, fee %v from %s; want 139500 out, 10500 fee", source, summary.TotalOut, summary.TotalFee, summary.Source)
+		}
+	}
+}
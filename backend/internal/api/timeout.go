@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"spv-backend/internal/contract"
+	"spv-backend/internal/fee"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/ot"
+	"spv-backend/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout categories for routes
+const (
+	timeoutFast      = "fast"
+	timeoutScan      = "scan"
+	timeoutBroadcast = "broadcast"
+)
+
+// routeTimeoutCategories assigns routes, keyed by "METHOD path", to a timeout
+// category. Unlisted routes are fast.
+var routeTimeoutCategories = map[string]string{
+	"POST /utxos/scan":                         timeoutScan,
+	"POST /utxos/scan/incremental":             timeoutScan,
+	"GET /utxos/new":                           timeoutScan,
+	"GET /utxos":                               timeoutScan,
+	"POST /filter/analyze":                     timeoutScan,
+	"GET /address/:address/used":               timeoutScan,
+	"GET /address/:address/balance-at/:height": timeoutScan,
+	"POST /watch":                              timeoutScan,
+	"GET /stats/range":                         timeoutScan,
+	"GET /coinbase/fees":                       timeoutScan,
+	"POST /ot/find":                            timeoutScan,
+	"POST /broadcast":                          timeoutBroadcast,
+	"POST /contract/call":                      timeoutBroadcast,
+	"POST /ot/broadcast_signed":                timeoutBroadcast,
+	"POST /ot/broadcast_a2u":                   timeoutBroadcast,
+	"POST /ot/broadcast_proof_signed":          timeoutBroadcast,
+}
+
+// timeoutMiddleware bounds each request with its category's timeout. The
+// deadline is carried on the request context, which handlers bind to their
+// RPC calls, so a slow request stops calling the node once it expires and
+// its error response is turned into a 504.
+func timeoutMiddleware(timeouts map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		category, ok := routeTimeoutCategories[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			category = timeoutFast
+		}
+
+		timeout := timeouts[category]
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		message := fmt.Sprintf("request exceeded the %s timeout of %s", category, timeout)
+		c.Writer = &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx, message: message}
+
+		c.Next()
+
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": message})
+		}
+	}
+}
+
+// timeoutWriter replaces server error responses written after the deadline
+// with a 504 carrying the timeout message
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	message  string
+	timedOut bool
+	replaced bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError && w.ctx.Err() == context.DeadlineExceeded {
+		w.timedOut = true
+		code = http.StatusGatewayTimeout
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if !w.timedOut {
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.replaced {
+		w.replaced = true
+		body := fmt.Sprintf(`{"error":%q}`, w.message)
+		if _, err := w.ResponseWriter.Write([]byte(body)); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// rpcFor returns the RPC client bound to the request's context
+func (h *Handler) rpcFor(c *gin.Context) *rpc.Client {
+	return h.rpcClient.WithContext(c.Request.Context())
+}
+
+// filtersFor returns the filter service bound to the request's context
+func (h *Handler) filtersFor(c *gin.Context) *filter.Service {
+	return h.filterService.WithContext(c.Request.Context())
+}
+
+// feesFor returns the fee service bound to the request's context
+func (h *Handler) feesFor(c *gin.Context) *fee.Service {
+	return h.feeService.WithContext(c.Request.Context())
+}
+
+// contractsFor returns the contract service bound to the request's context
+func (h *Handler) contractsFor(c *gin.Context) *contract.Service {
+	return h.contractService.WithContext(c.Request.Context())
+}
+
+// otFor returns the OT service bound to the request's context
+func (h *Handler) otFor(c *gin.Context) *ot.Service {
+	return h.otService.WithContext(c.Request.Context())
+}
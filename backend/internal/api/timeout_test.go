@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpctest"
+)
+
+func TestScanPastTimeoutReturns504(t *testing.T) {
+	cfg := &config.Config{TimeoutFast: 5, TimeoutScan: 1}
+	s := newTestServer(t, cfg, nil, nil)
+
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 5000)))
+	s.chain.AddBlock()
+
+	// Verification outlives the scan category's timeout
+	s.node.Handle("gettxout", func(params []json.RawMessage) (interface{}, error) {
+		time.Sleep(1500 * time.Millisecond)
+		return nil, nil
+	})
+
+	w := s.do(http.MethodPost, "/utxos/scan", scanBody([]string{address.EncodeAddress()}, 0, 2, nil))
+	expectStatus(t, w, http.StatusGatewayTimeout)
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	decode(t, w, &body)
+	if body.Error != "request exceeded the scan timeout of 1s" {
+		t.Errorf("got error %q", body.Error)
+	}
+}
+
+func TestHealthUnderTimeoutSucceeds(t *testing.T) {
+	cfg := &config.Config{TimeoutFast: 1, TimeoutScan: 1}
+	s := newTestServer(t, cfg, nil, nil)
+
+	w := s.do(http.MethodGet, "/health", nil)
+	expectStatus(t, w, http.StatusOK)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// txsResponse is the body of POST /txs
+type txsResponse struct {
+	Transactions []TransactionResult `json:"transactions"`
+	Count        int                 `json:"count"`
+}
+
+func TestGetTransactionsMixesKnownAndUnknown(t *testing.T) {
+	s := newTestServer(t, nil, nil, func(s *testServer) { s.node.TxIndex = true })
+	payee := rpctest.Address(testParams, "p2wpkh", 1)
+	confirmed := s.chain.NewTx(nil, rpctest.PayTo(payee, 1000))
+	s.chain.AddBlock(confirmed)
+	unconfirmed := s.chain.NewTx(nil, rpctest.PayTo(payee, 2000))
+	s.chain.AddToMempool(unconfirmed)
+	unknown := strings.Repeat("ab", 32)
+
+	// The repeated txid differs only in case and is fetched once
+	txids := []string{
+		confirmed.TxHash().String(),
+		unknown,
+		unconfirmed.TxHash().String(),
+		strings.ToUpper(confirmed.TxHash().String()),
+	}
+	w := s.do(http.MethodPost, "/txs", map[string]interface{}{"txids": txids})
+	expectStatus(t, w, http.StatusOK)
+	var resp txsResponse
+	decode(t, w, &resp)
+
+	if resp.Count != 3 || len(resp.Transactions) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(resp.Transactions), resp.Transactions)
+	}
+	for i, want := range txids[:3] {
+		if resp.Transactions[i].TxID != want {
+			t.Errorf("result %d is %s, want %s", i, resp.Transactions[i].TxID, want)
+		}
+	}
+	for i, tx := range map[int]string{0: txHex(t, confirmed), 2: txHex(t, unconfirmed)} {
+		var got string
+		if err := json.Unmarshal(resp.Transactions[i].Tx, &got); err != nil || got != tx || resp.Transactions[i].Error != "" {
+			t.Errorf("result %d: tx %s, error %q", i, resp.Transactions[i].Tx, resp.Transactions[i].Error)
+		}
+	}
+	if missing := resp.Transactions[1]; missing.Error == "" || missing.Tx != nil {
+		t.Errorf("unknown txid returned %+v, want an error", missing)
+	}
+
+	if s.node.Batches() != 1 || s.node.Calls("getrawtransaction") != 3 {
+		t.Errorf("%d batches, %d getrawtransaction calls; want one batch of 3", s.node.Batches(), s.node.Calls("getrawtransaction"))
+	}
+}
+
+func TestGetTransactionsVerbose(t *testing.T) {
+	s := newTestServer(t, nil, nil, func(s *testServer) { s.node.TxIndex = true })
+	tx := s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000))
+	s.chain.AddBlock(tx)
+
+	w := s.do(http.MethodPost, "/txs", map[string]interface{}{"txids": []string{tx.TxHash().String()}, "verbose": true})
+	expectStatus(t, w, http.StatusOK)
+	var resp txsResponse
+	decode(t, w, &resp)
+	if len(resp.Transactions) != 1 {
+		t.Fatalf("got %d results, want 1", len(resp.Transactions))
+	}
+	var decoded struct {
+		Txid string `json:"txid"`
+		Vout []struct {
+			N int `json:"n"`
+		} `json:"vout"`
+	}
+	if err := json.Unmarshal(resp.Transactions[0].Tx, &decoded); err != nil {
+		t.Fatalf("verbose tx is not an object: %s", resp.Transactions[0].Tx)
+	}
+	if decoded.Txid != tx.TxHash().String() || len(decoded.Vout) != 1 {
+		t.Errorf("decoded %+v, want %s with one output", decoded, tx.TxHash())
+	}
+}
+
+func TestGetTransactionsRejectsBadInput(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	tooMany := make([]string, maxBatchTxids+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("%064x", i)
+	}
+
+	for name, txids := range map[string][]string{
+		"none":         {},
+		"invalid txid": {"xyz"},
+		"short txid":   {"abcd"},
+		"over the cap": tooMany,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/txs", map[string]interface{}{"txids": txids})
+			expectStatus(t, w, http.StatusBadRequest)
+		})
+	}
+	if s.node.Calls("getrawtransaction") != 0 {
+		t.Error("invalid requests reached the node")
+	}
+}
+
+func TestGetTransactionWithoutTxIndex(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	payee := rpctest.Address(testParams, "p2wpkh", 1)
+	confirmed := s.chain.NewTx(nil, rpctest.PayTo(payee, 1000))
+	block := s.chain.AddBlock(confirmed)
+	unconfirmed := s.chain.NewTx(nil, rpctest.PayTo(payee, 2000))
+	s.chain.AddToMempool(unconfirmed)
+	txid := confirmed.TxHash().String()
+
+	// A confirmed transaction cannot be found without the block
+	w := s.do(http.MethodGet, "/tx/"+txid, nil)
+	expectStatus(t, w, http.StatusNotFound)
+	var notFound struct {
+		Error           string `json:"error"`
+		TxIndexRequired bool   `json:"txindex_required"`
+	}
+	decode(t, w, &notFound)
+	if !notFound.TxIndexRequired || notFound.Error != noTxIndexMessage {
+		t.Errorf("got %s, want the -txindex hint", w.Body.String())
+	}
+
+	// The hint finds it, and mempool transactions need none
+	for path, want := range map[string]*wire.MsgTx{
+		"/tx/" + txid + "?blockhash=" + block.Hash: confirmed,
+		"/tx/" + unconfirmed.TxHash().String():     unconfirmed,
+	} {
+		w := s.do(http.MethodGet, path, nil)
+		expectStatus(t, w, http.StatusOK)
+		var resp struct {
+			Tx string `json:"tx"`
+		}
+		decode(t, w, &resp)
+		if resp.Tx != txHex(t, want) {
+			t.Errorf("%s: got %s, want %s", path, resp.Tx, txHex(t, want))
+		}
+	}
+
+	// The batch lookup flags the transaction instead of failing
+	w = s.do(http.MethodPost, "/txs", map[string]interface{}{"txids": []string{txid, unconfirmed.TxHash().String()}})
+	expectStatus(t, w, http.StatusOK)
+	var resp txsResponse
+	decode(t, w, &resp)
+	if first := resp.Transactions[0]; !first.TxIndexRequired || first.Error != noTxIndexMessage || first.Tx != nil {
+		t.Errorf("got %+v, want the -txindex hint", first)
+	}
+	if second := resp.Transactions[1]; second.TxIndexRequired || second.Error != "" {
+		t.Errorf("got %+v for a mempool transaction", second)
+	}
+	w = s.do(http.MethodPost, "/txs", map[string]interface{}{"txids": []string{txid}, "blockhash": block.Hash})
+	expectStatus(t, w, http.StatusOK)
+	var hinted txsResponse
+	decode(t, w, &hinted)
+	if first := hinted.Transactions[0]; first.TxIndexRequired || first.Error != "" || first.Tx == nil {
+		t.Errorf("got %+v with the block hash", first)
+	}
+}
+
+func TestGetTransactionUnknownWithTxIndex(t *testing.T) {
+	s := newTestServer(t, nil, nil, func(s *testServer) { s.node.TxIndex = true })
+
+	w := s.do(http.MethodGet, "/tx/"+strings.Repeat("ab", 32), nil)
+	expectStatus(t, w, http.StatusNotFound)
+	var resp map[string]interface{}
+	decode(t, w, &resp)
+	if _, hinted := resp["txindex_required"]; hinted {
+		t.Errorf("got %s, want a plain 404 from a node with -txindex", w.Body.String())
+	}
+
+	w = s.do(http.MethodGet, "/tx/xyz", nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+	"spv-backend/internal/txstatus"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestBroadcastTracksTransaction(t *testing.T) {
+	s := newTestServer(t, nil, nil, func(s *testServer) {
+		s.handler.txTracker = txstatus.NewTracker(s.handler.rpcClient, 0)
+	})
+	fund := s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000), rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000))
+	s.chain.AddBlock(fund)
+	to := rpctest.Address(testParams, "p2tr", 2)
+
+	tracked := s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(to, 900))
+	w := s.do(http.MethodPost, "/broadcast", map[string]interface{}{"raw_tx": txHex(t, tracked)})
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		TxID    string `json:"txid"`
+		Tracked *bool  `json:"tracked"`
+	}
+	decode(t, w, &resp)
+	if resp.TxID != tracked.TxHash().String() || resp.Tracked == nil || !*resp.Tracked {
+		t.Fatalf("got %+v, want %s tracked", resp, tracked.TxHash())
+	}
+
+	w = s.do(http.MethodGet, "/tx/"+resp.TxID+"/status", nil)
+	expectStatus(t, w, http.StatusOK)
+	var status txstatus.Status
+	decode(t, w, &status)
+	if status.TxID != resp.TxID || status.Status != txstatus.StatusInMempool {
+		t.Errorf("got %+v, want in_mempool", status)
+	}
+
+	// Opting out leaves the transaction unknown
+	untracked := s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(to, 900))
+	w = s.do(http.MethodPost, "/broadcast", map[string]interface{}{"raw_tx": txHex(t, untracked), "track": false})
+	expectStatus(t, w, http.StatusOK)
+	w = s.do(http.MethodGet, "/tx/"+untracked.TxHash().String()+"/status", nil)
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &status)
+	if status.Status != txstatus.StatusUnknown {
+		t.Errorf("got %+v, want unknown", status)
+	}
+
+	w = s.do(http.MethodGet, "/tx/nothex/status", nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestTxStatusWithTrackingDisabled(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000))
+	s.chain.AddBlock(fund)
+	tx := s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 2), 900))
+
+	w := s.do(http.MethodPost, "/broadcast", map[string]interface{}{"raw_tx": txHex(t, tx)})
+	expectStatus(t, w, http.StatusOK)
+	var resp map[string]interface{}
+	decode(t, w, &resp)
+	if _, ok := resp["tracked"]; ok {
+		t.Errorf("got %v, want no tracked field", resp)
+	}
+
+	w = s.do(http.MethodGet, "/tx/"+tx.TxHash().String()+"/status", nil)
+	expectStatus(t, w, http.StatusNotFound)
+}
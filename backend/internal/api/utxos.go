@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"spv-backend/internal/filter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UTXO sources of GET /utxos
+const (
+	utxoSourceTxOutSet = "scantxoutset" // The node's UTXO set
+	utxoSourceBlocks   = "blocks"       // A block scan, with or without filters
+)
+
+// UTXOsResult is the answer of GET /utxos: the UTXOs, how they were found
+// and the tip the range was resolved against
+type UTXOsResult struct {
+	*filter.UTXOScanResult
+	Source    string `json:"source"`
+	TipHeight int64  `json:"tip_height"`
+}
+
+// parseAddressList splits a comma separated address list, dropping blanks
+func parseAddressList(value string) []string {
+	var addresses []string
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// GetUTXOs handles GET /utxos?addresses=&start_height=&end_height=
+// Returns the unspent outputs of the comma separated addresses created in
+// [start_height, end_height] (defaults: 0 to the tip), choosing the source:
+//   - end_height at the tip, or omitted: scantxoutset reads the node's UTXO
+//     set once instead of walking the range, which is much faster for current
+//     balances and not limited to the scan range cap
+//   - a historical end_height: a block scan (SPV or direct, per SPV_MODE),
+//     since the UTXO set only describes the tip
+//
+// source reports the one used. Block scanning is also used if the node
+// refuses scantxoutset (e.g. another scan is running), or for every request
+// with UTXOS_AUTO_TXOUTSET=false.
+func (h *Handler) GetUTXOs(c *gin.Context) {
+	addresses := parseAddressList(c.Query("addresses"))
+	if len(addresses) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address is required"})
+		return
+	}
+	if _, skipped := h.filterService.PartitionAddresses(addresses); len(skipped) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             fmt.Sprintf("invalid address %s: %s", skipped[0].Address, skipped[0].Error),
+			"invalid_addresses": skipped,
+		})
+		return
+	}
+
+	tip, err := h.rpcFor(c).GetBlockCount()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	startHeight, err := strconv.ParseInt(c.DefaultQuery("start_height", "0"), 10, 64)
+	if err != nil || startHeight < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_height parameter"})
+		return
+	}
+	endHeight := tip
+	if value := c.Query("end_height"); value != "" {
+		endHeight, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || endHeight < startHeight || endHeight > tip {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid end_height parameter (start_height-%d)", tip)})
+			return
+		}
+	}
+	if startHeight > endHeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("start_height beyond the chain tip %d", tip)})
+		return
+	}
+
+	aliases := h.filterService.ScriptAliases(addresses)
+	if len(aliases) > 0 && h.config.DuplicateAddressScripts == "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "several addresses pay the same script",
+			"duplicate_scripts": aliases,
+		})
+		return
+	}
+
+	var result *filter.UTXOScanResult
+	if endHeight == tip && h.config.UTXOsAutoTxOutSet {
+		if err := h.checkScanCost(utxoSourceTxOutSet, 0, len(addresses)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var ok bool
+		result, ok, err = h.filtersFor(c).ScanTxOutSet(addresses, startHeight, endHeight, filter.ScanOptions{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if ok {
+			filter.AnnotateAliases(result.UTXOs, aliases)
+			c.JSON(http.StatusOK, &UTXOsResult{UTXOScanResult: result, Source: utxoSourceTxOutSet, TipHeight: tip})
+			return
+		}
+	}
+
+	if endHeight-startHeight > filter.MaxScanRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scan range too large, max %d blocks", filter.MaxScanRange)})
+		return
+	}
+	mode := "direct"
+	if h.config.SPVMode {
+		mode = "spv"
+	}
+	if err := h.checkScanCost(mode, endHeight-startHeight+1, len(addresses)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err = h.filtersFor(c).ScanUTXOsHybrid(addresses, startHeight, endHeight, mode, filter.ScanOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	filter.AnnotateAliases(result.UTXOs, aliases)
+
+	// Large address lists may have been switched to the UTXO set anyway
+	// (SCANTXOUTSET_THRESHOLD)
+	source := utxoSourceBlocks
+	if result.Statistics != nil && result.Statistics.Mode == "txoutset" {
+		source = utxoSourceTxOutSet
+	}
+	c.JSON(http.StatusOK, &UTXOsResult{UTXOScanResult: result, Source: source, TipHeight: tip})
+}
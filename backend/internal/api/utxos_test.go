@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"spv-backend/config"
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// utxosServer serves a chain paying an address at heights 1 to 3, with the
+// first payment spent at height 4
+func utxosServer(t *testing.T, cfg *config.Config) (*testServer, string) {
+	t.Helper()
+	s := newTestServer(t, cfg, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	fund := s.chain.NewTx(nil, rpctest.PayTo(address, 1000))
+	s.chain.AddBlock(fund)
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 2000)))
+	s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 3000)))
+	s.chain.AddBlock(s.chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 2), 900)))
+	return s, address.EncodeAddress()
+}
+
+// getUTXOs requests GET /utxos with the query parameters
+func (s *testServer) getUTXOs(t *testing.T, query string) UTXOsResult {
+	t.Helper()
+	w := s.do(http.MethodGet, "/utxos?"+query, nil)
+	expectStatus(t, w, http.StatusOK)
+	var result UTXOsResult
+	decode(t, w, &result)
+	return result
+}
+
+func TestUTXOsAtTipUseTxOutSet(t *testing.T) {
+	s, address := utxosServer(t, &config.Config{UTXOsAutoTxOutSet: true})
+
+	for _, query := range []string{"addresses=" + address, "addresses=" + address + "&end_height=4"} {
+		result := s.getUTXOs(t, query)
+		if result.Source != utxoSourceTxOutSet || result.TipHeight != 4 {
+			t.Errorf("%s: source %s, tip %d; want scantxoutset at 4", query, result.Source, result.TipHeight)
+		}
+		if result.TotalUTXOs != 2 || result.TotalSatoshis != 5000 {
+			t.Errorf("%s: found %d UTXOs, %d sats; want the two unspent payments", query, result.TotalUTXOs, result.TotalSatoshis)
+		}
+	}
+	// A start height narrows the UTXO set's answer
+	if result := s.getUTXOs(t, "addresses="+address+"&start_height=3"); result.Source != utxoSourceTxOutSet || result.TotalSatoshis != 3000 {
+		t.Errorf("from height 3: %s found %d sats, want 3000 from scantxoutset", result.Source, result.TotalSatoshis)
+	}
+	if s.node.Calls("scantxoutset") != 3 || s.node.Calls("getblock") != 0 || s.node.Calls("getblockfilter") != 0 {
+		t.Errorf("%d scantxoutset, %d getblock, %d getblockfilter calls; want no block scan",
+			s.node.Calls("scantxoutset"), s.node.Calls("getblock"), s.node.Calls("getblockfilter"))
+	}
+}
+
+func TestUTXOsHistoricalRangeScansBlocks(t *testing.T) {
+	s, address := utxosServer(t, &config.Config{UTXOsAutoTxOutSet: true})
+
+	result := s.getUTXOs(t, "addresses="+address+"&end_height=2")
+	if result.Source != utxoSourceBlocks || result.TipHeight != 4 {
+		t.Errorf("source %s, tip %d; want a block scan", result.Source, result.TipHeight)
+	}
+	if result.TotalSatoshis != 2000 {
+		t.Errorf("found %d sats, want the unspent payment up to height 2", result.TotalSatoshis)
+	}
+	if s.node.Calls("scantxoutset") != 0 || s.node.Calls("getblock") == 0 {
+		t.Errorf("%d scantxoutset, %d getblock calls; want a block scan", s.node.Calls("scantxoutset"), s.node.Calls("getblock"))
+	}
+}
+
+func TestUTXOsFallBackToBlocks(t *testing.T) {
+	// Switched off
+	s, address := utxosServer(t, &config.Config{UTXOsAutoTxOutSet: false})
+	if result := s.getUTXOs(t, "addresses="+address); result.Source != utxoSourceBlocks || result.TotalSatoshis != 5000 {
+		t.Errorf("disabled: %s found %d sats, want 5000 from blocks", result.Source, result.TotalSatoshis)
+	}
+	if s.node.Calls("scantxoutset") != 0 {
+		t.Error("scantxoutset called while disabled")
+	}
+
+	// Refused by the node
+	s, address = utxosServer(t, &config.Config{UTXOsAutoTxOutSet: true})
+	s.node.Handle("scantxoutset", func(params []json.RawMessage) (interface{}, error) {
+		return nil, &rpc.RPCError{Code: -8, Message: "Scan already in progress, use action \"abort\" or \"status\""}
+	})
+	if result := s.getUTXOs(t, "addresses="+address); result.Source != utxoSourceBlocks || result.TotalSatoshis != 5000 {
+		t.Errorf("refused: %s found %d sats, want 5000 from blocks", result.Source, result.TotalSatoshis)
+	}
+}
+
+func TestUTXOsRejectsBadParams(t *testing.T) {
+	s, address := utxosServer(t, &config.Config{UTXOsAutoTxOutSet: true})
+
+	for _, query := range []string{
+		"",
+		"addresses=bogus",
+		"addresses=" + address + "&start_height=-1",
+		"addresses=" + address + "&start_height=3&end_height=2",
+		"addresses=" + address + "&end_height=5",
+		"addresses=" + address + "&start_height=5",
+	} {
+		if w := s.do(http.MethodGet, "/utxos?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// validateAddress calls GET /address/:address/validate
+func validateAddress(t *testing.T, s *testServer, address string) filter.AddressInfo {
+	t.Helper()
+	w := s.do(http.MethodGet, "/address/"+address+"/validate", nil)
+	expectStatus(t, w, http.StatusOK)
+	var info filter.AddressInfo
+	decode(t, w, &info)
+	return info
+}
+
+func TestValidateAddressTypes(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	tests := []struct {
+		kind    string
+		witness bool
+		version int
+	}{
+		{"p2pkh", false, 0},
+		{"p2sh", false, 0},
+		{"p2wpkh", true, 0},
+		{"p2wsh", true, 0},
+		{"p2tr", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			info := validateAddress(t, s, rpctest.Address(testParams, tt.kind, 1).EncodeAddress())
+			if !info.Valid || info.Type != tt.kind || info.Network != testParams.Name || info.Error != "" {
+				t.Fatalf("got %+v, want a valid %s address on %s", info, tt.kind, testParams.Name)
+			}
+			if info.NetworkMatch == nil || !*info.NetworkMatch {
+				t.Errorf("network_match %v, want true", info.NetworkMatch)
+			}
+			if info.IsWitness != tt.witness {
+				t.Errorf("is_witness %v, want %v", info.IsWitness, tt.witness)
+			}
+			if tt.witness && (info.WitnessVersion == nil || *info.WitnessVersion != tt.version) {
+				t.Errorf("witness_version %v, want %d", info.WitnessVersion, tt.version)
+			} else if !tt.witness && info.WitnessVersion != nil {
+				t.Errorf("witness_version %d set for a legacy address", *info.WitnessVersion)
+			}
+		})
+	}
+	if s.node.Requests() != 0 {
+		t.Error("validation called the node")
+	}
+}
+
+func TestValidateAddressInvalidFormat(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	valid := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	// A changed character breaks the bech32 checksum
+	last := "q"
+	if strings.HasSuffix(valid, last) {
+		last = "p"
+	}
+	corrupted := valid[:len(valid)-1] + last
+
+	for _, address := range []string{"notanaddress", corrupted} {
+		info := validateAddress(t, s, address)
+		if info.Valid || info.NetworkMatch != nil || info.Type != "" || !strings.HasPrefix(info.Error, "invalid format") {
+			t.Errorf("%s: got %+v, want an invalid format error", address, info)
+		}
+	}
+}
+
+func TestValidateAddressWrongNetwork(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	for _, kind := range []string{"p2pkh", "p2wpkh", "p2tr"} {
+		address := rpctest.Address(&chaincfg.MainNetParams, kind, 1).EncodeAddress()
+		info := validateAddress(t, s, address)
+		if info.Valid || !strings.HasPrefix(info.Error, "wrong network") {
+			t.Errorf("%s: got %+v, want a wrong network error", address, info)
+			continue
+		}
+		if info.Network != chaincfg.MainNetParams.Name || info.NetworkMatch == nil || *info.NetworkMatch || info.Type != kind {
+			t.Errorf("%s: got %+v, want a %s address on %s", address, info, kind, chaincfg.MainNetParams.Name)
+		}
+	}
+}
+
+func TestValidateAddressesBatch(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	p2wpkh := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	p2tr := rpctest.Address(testParams, "p2tr", 2).EncodeAddress()
+	mainnet := rpctest.Address(&chaincfg.MainNetParams, "p2pkh", 3).EncodeAddress()
+
+	addresses := []string{p2wpkh, "notanaddress", mainnet, p2tr, p2wpkh}
+	w := s.do(http.MethodPost, "/addresses/validate", map[string]interface{}{"addresses": addresses})
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Results []filter.AddressInfo `json:"results"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Results) != len(addresses) {
+		t.Fatalf("got %d results for %d addresses", len(resp.Results), len(addresses))
+	}
+
+	// In request order, duplicates included
+	tests := []struct {
+		valid        bool
+		kind         string
+		networkMatch *bool
+	}{
+		{true, "p2wpkh", boolPtr(true)},
+		{false, "", nil},
+		{false, "p2pkh", boolPtr(false)},
+		{true, "p2tr", boolPtr(true)},
+		{true, "p2wpkh", boolPtr(true)},
+	}
+	for i, tt := range tests {
+		got := resp.Results[i]
+		if got.Address != addresses[i] || got.Valid != tt.valid || got.Type != tt.kind {
+			t.Errorf("result %d: got %+v, want %s valid %v type %q", i, got, addresses[i], tt.valid, tt.kind)
+		}
+		if (got.NetworkMatch == nil) != (tt.networkMatch == nil) || (got.NetworkMatch != nil && *got.NetworkMatch != *tt.networkMatch) {
+			t.Errorf("result %d: network_match %v, want %v", i, got.NetworkMatch, tt.networkMatch)
+		}
+	}
+	if s.node.Requests() != 0 {
+		t.Error("batch validation called the node")
+	}
+}
+
+func TestValidateAddressesBatchSize(t *testing.T) {
+	s := newTestServer(t, nil, nil, nil)
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	tooMany := make([]string, maxValidateAddresses+1)
+	for i := range tooMany {
+		tooMany[i] = address
+	}
+
+	for name, addresses := range map[string][]string{"empty": {}, "over the cap": tooMany} {
+		w := s.do(http.MethodPost, "/addresses/validate", map[string]interface{}{"addresses": addresses})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+	w := s.do(http.MethodPost, "/addresses/validate", map[string]interface{}{"addresses": tooMany[:maxValidateAddresses]})
+	expectStatus(t, w, http.StatusOK)
+}
+
+// boolPtr returns a pointer to b
+func boolPtr(b bool) *bool {
+	return &b
+}
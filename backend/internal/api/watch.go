@@ -0,0 +1,182 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/watch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWatchRequest represents a request to watch addresses
+type CreateWatchRequest struct {
+	Addresses []string `json:"addresses" binding:"required"`
+	// Scan [from_height, tip] for the initial UTXOs; without it only
+	// outputs in blocks after the current tip are tracked
+	FromHeight *int64 `json:"from_height"`
+	// Each match is also POSTed here as JSON (http or https)
+	WebhookURL string `json:"webhook_url"`
+}
+
+// maxWatchSeedAttempts is how many times POST /watch scans for the initial
+// UTXOs when reorgs keep replacing the block it scanned up to
+const maxWatchSeedAttempts = 3
+
+// watchEnabled answers 404 and returns false when watching is disabled
+func (h *Handler) watchEnabled(c *gin.Context) bool {
+	if h.watchManager == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "address watching is disabled (WATCH_POLL_INTERVAL=0)"})
+		return false
+	}
+	return true
+}
+
+// CreateWatch handles POST /watch
+// Starts keeping the UTXO set of the addresses up to date as blocks arrive
+func (h *Handler) CreateWatch(c *gin.Context) {
+	if !h.watchEnabled(c) {
+		return
+	}
+
+	var req CreateWatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addresses, skipped := h.filterService.PartitionAddresses(req.Addresses)
+	if len(skipped) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             fmt.Sprintf("invalid address %s: %s", skipped[0].Address, skipped[0].Error),
+			"invalid_addresses": skipped,
+		})
+		return
+	}
+	if len(addresses) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one address is required"})
+		return
+	}
+	if req.WebhookURL != "" {
+		if h.watchManager.Notifier() == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "webhooks are disabled (WEBHOOK_WORKERS=0)"})
+			return
+		}
+		if err := watch.CheckWebhookURL(c.Request.Context(), req.WebhookURL, h.watchManager.Notifier().AllowPrivate()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	mode := "direct"
+	if h.config.SPVMode {
+		mode = "spv"
+	}
+
+	// Seed the watch with a scan up to the block the watcher has reached
+	anchor := h.watchManager.Tip()
+	if req.FromHeight != nil {
+		if *req.FromHeight < 0 || *req.FromHeight > anchor.Height {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_height must be between 0 and the tip"})
+			return
+		}
+		if anchor.Height-*req.FromHeight > filter.MaxScanRange {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scan range too large, max %d blocks", filter.MaxScanRange)})
+			return
+		}
+		if err := h.checkScanCost(mode, anchor.Height-*req.FromHeight+1, len(addresses)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if err := h.checkScanCost(mode, 0, len(addresses)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Blocks connected during the scan are caught up by Add; only a reorg
+	// of the scanned-to block needs a new scan
+	var id string
+	for attempt := 1; ; attempt++ {
+		initial := []filter.UTXO{}
+		if req.FromHeight != nil {
+			result, err := h.filtersFor(c).ScanUTXOsHybrid(addresses, *req.FromHeight, anchor.Height, mode, filter.ScanOptions{IgnoreMempoolSpends: true})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			initial = result.UTXOs
+		}
+
+		var err error
+		id, err = h.watchManager.Add(addresses, initial, anchor, req.WebhookURL)
+		if errors.Is(err, watch.ErrAnchorLost) && attempt < maxWatchSeedAttempts {
+			anchor = h.watchManager.Tip()
+			continue
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, watch.ErrTooManyWatches):
+				status = http.StatusTooManyRequests
+			case errors.Is(err, watch.ErrAnchorLost):
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		break
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id})
+}
+
+// GetWatchUTXOs handles GET /watch/:id/utxos
+func (h *Handler) GetWatchUTXOs(c *gin.Context) {
+	if !h.watchEnabled(c) {
+		return
+	}
+
+	snapshot, err := h.watchManager.UTXOs(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, watch.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// DeleteWatch handles DELETE /watch/:id
+func (h *Handler) DeleteWatch(c *gin.Context) {
+	if !h.watchEnabled(c) {
+		return
+	}
+
+	if err := h.watchManager.Remove(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// GetWebhookDeadLetters handles GET /watch/deadletters
+// Lists webhook deliveries that failed on every attempt, oldest first
+func (h *Handler) GetWebhookDeadLetters(c *gin.Context) {
+	if !h.watchEnabled(c) {
+		return
+	}
+	notifier := h.watchManager.Notifier()
+	if notifier == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhooks are disabled (WEBHOOK_WORKERS=0)"})
+		return
+	}
+
+	deadLetters := notifier.DeadLetters()
+	c.JSON(http.StatusOK, gin.H{"dead_letters": deadLetters, "count": len(deadLetters)})
+}
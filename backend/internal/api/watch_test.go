@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpctest"
+	"spv-backend/internal/watch"
+)
+
+// withWatches enables address watching on a test server
+func withWatches(t *testing.T) func(*testServer) {
+	return func(s *testServer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		m := watch.NewManager(s.handler.rpcClient, s.handler.filterService, true, 0)
+		if err := m.Start(ctx, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		s.handler.watchManager = m
+	}
+}
+
+func TestCreateWatchSeedsFromHeight(t *testing.T) {
+	address := rpctest.Address(testParams, "p2pkh", 1)
+	var funding string
+	s := newTestServer(t, nil, nil, func(s *testServer) {
+		funding = s.chain.AddBlock(s.chain.NewTx(nil, rpctest.PayTo(address, 2500))).TxIDs()[1]
+		s.chain.AddBlock()
+		withWatches(t)(s)
+	})
+
+	w := s.do(http.MethodPost, "/watch", map[string]interface{}{"addresses": []string{address.EncodeAddress()}, "from_height": 0})
+	expectStatus(t, w, http.StatusCreated)
+	var created struct {
+		ID string `json:"id"`
+	}
+	decode(t, w, &created)
+
+	w = s.do(http.MethodGet, "/watch/"+created.ID+"/utxos", nil)
+	expectStatus(t, w, http.StatusOK)
+	var snapshot watch.Snapshot
+	decode(t, w, &snapshot)
+	if snapshot.TotalUTXOs != 1 || snapshot.UTXOs[0].TxID != funding || snapshot.TipHeight != 2 {
+		t.Fatalf("got %+v", snapshot)
+	}
+}
+
+func TestCreateWatchWebhookDestinations(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	s := newTestServer(t, nil, nil, withWatches(t))
+	body := map[string]interface{}{"addresses": []string{address}, "webhook_url": "http://93.184.215.14/hook"}
+
+	// Webhooks are opt-in
+	w := s.do(http.MethodPost, "/watch", body)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	s.handler.watchManager.SetNotifier(watch.NewNotifier(1, 1, 0, time.Second, 1))
+	w = s.do(http.MethodPost, "/watch", body)
+	expectStatus(t, w, http.StatusCreated)
+
+	body["webhook_url"] = "http://127.0.0.1:8332/"
+	w = s.do(http.MethodPost, "/watch", body)
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,34 @@
+// Package auth provides pluggable request authentication
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Principal identifies an authenticated caller
+type Principal struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"` // "apikey" or "jwt"
+}
+
+// Authenticator authenticates an incoming HTTP request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authentication errors
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTConfig configures JWT validation. Exactly one key source is expected:
+// an HS256 secret, an RS256 public key (PEM), or a JWKS URL for RS256 keys.
+type JWTConfig struct {
+	HS256Secret    string
+	RS256PublicKey string // PEM encoded
+	JWKSURL        string
+	Issuer         string        // Required "iss" claim, if set
+	Audience       string        // Required "aud" claim, if set
+	Leeway         time.Duration // Clock skew tolerance for exp/nbf
+}
+
+// JWTAuthenticator authenticates requests carrying a bearer JWT
+type JWTAuthenticator struct {
+	config  JWTConfig
+	rsaKey  *rsa.PublicKey
+	jwks    *jwksCache
+	nowFunc func() time.Time
+}
+
+// NewJWTAuthenticator creates a JWT authenticator from the configuration
+func NewJWTAuthenticator(config JWTConfig) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{config: config, nowFunc: time.Now}
+
+	switch {
+	case config.HS256Secret != "":
+	case config.RS256PublicKey != "":
+		key, err := parseRSAPublicKey(config.RS256PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		a.rsaKey = key
+	case config.JWKSURL != "":
+		a.jwks = newJWKSCache(config.JWKSURL)
+	default:
+		return nil, errors.New("JWT authentication requires a signing secret, public key, or JWKS URL")
+	}
+
+	return a, nil
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the registered claims checked by the authenticator
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"` // String or array of strings
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+}
+
+// Authenticate implements Authenticator
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	claims, err := a.Verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	return &Principal{Subject: claims.Subject, Method: "jwt"}, nil
+}
+
+// Verify checks the token's signature and registered claims
+func (a *JWTAuthenticator) Verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if err := a.verifySignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	now := a.nowFunc()
+	if claims.ExpiresAt != nil && now.After(time.Unix(*claims.ExpiresAt, 0).Add(a.config.Leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(a.config.Leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if a.config.Issuer != "" && claims.Issuer != a.config.Issuer {
+		return nil, errors.New("unexpected issuer")
+	}
+	if a.config.Audience != "" && !audienceContains(claims.Audience, a.config.Audience) {
+		return nil, errors.New("unexpected audience")
+	}
+
+	return &claims, nil
+}
+
+// verifySignature checks the signature with the configured key source. The
+// algorithm is pinned by the configuration, never chosen by the token.
+func (a *JWTAuthenticator) verifySignature(header jwtHeader, signingInput string, signature []byte) error {
+	switch {
+	case a.config.HS256Secret != "":
+		if header.Alg != "HS256" {
+			return fmt.Errorf("unexpected algorithm %q", header.Alg)
+		}
+		mac := hmac.New(sha256.New, []byte(a.config.HS256Secret))
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	default:
+		if header.Alg != "RS256" {
+			return fmt.Errorf("unexpected algorithm %q", header.Alg)
+		}
+		key := a.rsaKey
+		if a.jwks != nil {
+			var err error
+			key, err = a.jwks.key(header.Kid)
+			if err != nil {
+				return err
+			}
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// audienceContains reports whether the "aud" claim includes the audience
+func audienceContains(raw json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == audience
+	}
+	var multiple []string
+	if err := json.Unmarshal(raw, &multiple); err == nil {
+		for _, aud := range multiple {
+			if aud == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseRSAPublicKey parses a PEM encoded PKIX or PKCS#1 RSA public key
+func parseRSAPublicKey(pemData string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, errors.New("failed to decode RS256 public key PEM")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RS256 public key: %w", err)
+	}
+	return key, nil
+}
+
+// jwksRefreshInterval is how long fetched JWKS keys are reused
+const jwksRefreshInterval = 10 * time.Minute
+
+// jwksMinFetchInterval is the least time between two JWKS fetches, so
+// tokens with made-up key IDs cannot make every request fetch the set
+const jwksMinFetchInterval = time.Minute
+
+// jwksCache fetches and caches RSA keys from a JWKS endpoint
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time     // Start of the last fetch, successful or not
+	fetching    chan struct{} // Closed when the fetch in flight ends, nil if none
+}
+
+func newJWKSCache(url string) *jwksCache {
+	return &jwksCache{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// key returns the key with the given ID, refreshing the set when stale or
+// when the ID is unknown (to pick up rotated keys). Fetches run outside the
+// lock, one at a time, at most once per jwksMinFetchInterval; requests
+// arriving meanwhile wait for the fetch in flight or use the cached keys.
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	if ok && time.Since(c.fetchedAt) < jwksRefreshInterval {
+		c.mu.Unlock()
+		return key, nil
+	}
+
+	fetching := c.fetching
+	var fetchErr error
+	switch {
+	case fetching != nil:
+		c.mu.Unlock()
+		<-fetching
+	case time.Since(c.attemptedAt) < jwksMinFetchInterval:
+		c.mu.Unlock()
+	default:
+		fetching = make(chan struct{})
+		c.fetching = fetching
+		c.attemptedAt = time.Now()
+		c.mu.Unlock()
+
+		var keys map[string]*rsa.PublicKey
+		keys, fetchErr = c.fetch()
+
+		c.mu.Lock()
+		if fetchErr == nil {
+			c.keys = keys
+			c.fetchedAt = time.Now()
+		}
+		c.fetching = nil
+		close(fetching)
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	key, ok = c.keys[kid]
+	c.mu.Unlock()
+	if ok {
+		return key, nil // A stale key is served rather than failing closed on a fetch blip
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// fetch downloads the key set
+func (c *jwksCache) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: HTTP %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testNow is the clock the tests verify tokens at
+var testNow = time.Unix(1700000000, 0)
+
+// segment encodes a token segment
+func segment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signHS256 returns an HS256 token over claims
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	input := segment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signRS256 returns an RS256 token over claims with the key ID kid
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := segment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+var (
+	rsaKeyOnce sync.Once
+	rsaKey     *rsa.PrivateKey
+)
+
+// testRSAKey returns a key shared by the tests, generated once
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	rsaKeyOnce.Do(func() {
+		var err error
+		if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return rsaKey
+}
+
+func newTestJWT(t *testing.T, config JWTConfig) *JWTAuthenticator {
+	t.Helper()
+	a, err := NewJWTAuthenticator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.nowFunc = func() time.Time { return testNow }
+	return a
+}
+
+func TestHS256(t *testing.T) {
+	a := newTestJWT(t, JWTConfig{HS256Secret: "secret", Issuer: "issuer", Audience: "spv"})
+	valid := map[string]interface{}{"sub": "alice", "iss": "issuer", "aud": []string{"other", "spv"}, "exp": testNow.Unix() + 60}
+
+	claims, err := a.Verify(signHS256(t, "secret", valid))
+	if err != nil || claims.Subject != "alice" {
+		t.Fatalf("valid token: got %+v, %v", claims, err)
+	}
+
+	expired := map[string]interface{}{"sub": "alice", "iss": "issuer", "aud": "spv", "exp": testNow.Unix() - 1}
+	wrongAudience := map[string]interface{}{"sub": "alice", "iss": "issuer", "aud": "other"}
+	tests := map[string]struct {
+		token string
+		err   string
+	}{
+		"bad signature":  {signHS256(t, "other secret", valid), "invalid signature"},
+		"expired":        {signHS256(t, "secret", expired), "token expired"},
+		"wrong audience": {signHS256(t, "secret", wrongAudience), "unexpected audience"},
+		"alg none":       {segment(t, map[string]string{"alg": "none"}) + "." + segment(t, valid) + ".", `unexpected algorithm "none"`},
+		"malformed":      {"abc.def", "malformed token"},
+	}
+	for name, test := range tests {
+		if _, err := a.Verify(test.token); err == nil || err.Error() != test.err {
+			t.Errorf("%s: got %v, want %q", name, err, test.err)
+		}
+	}
+}
+
+func TestHS256Leeway(t *testing.T) {
+	a := newTestJWT(t, JWTConfig{HS256Secret: "secret", Leeway: 30 * time.Second})
+	if _, err := a.Verify(signHS256(t, "secret", map[string]interface{}{"exp": testNow.Unix() - 10})); err != nil {
+		t.Fatalf("token expired within the leeway rejected: %v", err)
+	}
+	if _, err := a.Verify(signHS256(t, "secret", map[string]interface{}{"nbf": testNow.Unix() + 60})); err == nil {
+		t.Fatal("token not yet valid accepted")
+	}
+}
+
+func TestRS256PublicKey(t *testing.T) {
+	key := testRSAKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	a := newTestJWT(t, JWTConfig{RS256PublicKey: pemKey})
+
+	claims := map[string]interface{}{"sub": "bob", "exp": testNow.Unix() + 60}
+	if _, err := a.Verify(signRS256(t, key, "", claims)); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+
+	token := signRS256(t, key, "", claims)
+	tampered := strings.Replace(token, segment(t, claims), segment(t, map[string]interface{}{"sub": "mallory", "exp": testNow.Unix() + 60}), 1)
+	if _, err := a.Verify(tampered); err == nil || err.Error() != "invalid signature" {
+		t.Fatalf("tampered token: got %v", err)
+	}
+
+	claims["exp"] = testNow.Unix() - 1
+	if _, err := a.Verify(signRS256(t, key, "", claims)); err == nil || err.Error() != "token expired" {
+		t.Fatalf("expired token: got %v", err)
+	}
+
+	// An HS256 token signed with the public key must not pass
+	hs := signHS256(t, pemKey, map[string]interface{}{"sub": "bob"})
+	if _, err := a.Verify(hs); err == nil {
+		t.Fatal("HS256 token accepted by an RS256 authenticator")
+	}
+}
+
+// jwksServer serves key as the only key of a JWKS, counting fetches
+func jwksServer(t *testing.T, key *rsa.PublicKey, kid string, fetches *atomic.Int32) *httptest.Server {
+	set := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestJWKS(t *testing.T) {
+	key := testRSAKey(t)
+	var fetches atomic.Int32
+	server := jwksServer(t, &key.PublicKey, "k1", &fetches)
+	a := newTestJWT(t, JWTConfig{JWKSURL: server.URL})
+
+	claims := map[string]interface{}{"sub": "carol", "exp": testNow.Unix() + 60}
+	for i := 0; i < 3; i++ {
+		if _, err := a.Verify(signRS256(t, key, "k1", claims)); err != nil {
+			t.Fatalf("valid token: %v", err)
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("got %d JWKS fetches, want 1", fetches.Load())
+	}
+
+	// Unknown key IDs do not refetch within the minimum interval
+	for i := 0; i < 5; i++ {
+		if _, err := a.Verify(signRS256(t, key, "unknown", claims)); err == nil || err.Error() != `unknown key id "unknown"` {
+			t.Fatalf("unknown kid: got %v", err)
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("unknown key IDs caused %d JWKS fetches, want 1", fetches.Load())
+	}
+}
+
+func TestJWKSConcurrentUnknownKids(t *testing.T) {
+	key := testRSAKey(t)
+	var fetches atomic.Int32
+	server := jwksServer(t, &key.PublicKey, "k1", &fetches)
+	a := newTestJWT(t, JWTConfig{JWKSURL: server.URL})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			kid := "k1"
+			if i%2 == 1 {
+				kid = "forged"
+			}
+			_, err := a.Verify(signRS256(t, key, kid, map[string]interface{}{"sub": "dave"}))
+			if (err == nil) != (kid == "k1") {
+				t.Errorf("kid %s: got %v", kid, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if fetches.Load() != 1 {
+		t.Fatalf("got %d JWKS fetches, want 1", fetches.Load())
+	}
+}
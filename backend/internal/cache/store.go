@@ -0,0 +1,192 @@
+// Package cache provides a persistent key-value store for immutable chain data
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Compression algorithms for stored values
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// Value encodings, stored as the first byte of every entry so values written
+// with a different CACHE_COMPRESSION remain readable
+const (
+	encodingRaw  byte = 0
+	encodingGzip byte = 1
+)
+
+// Store keeps values as files under a directory, one subdirectory per
+// namespace. Only data that never changes for its key (e.g. a block's filter,
+// keyed by block hash) should be stored, since entries are never invalidated.
+type Store struct {
+	dir         string
+	compression string
+}
+
+// NewStore opens a store rooted at dir, creating it if needed
+func NewStore(dir, compression string) (*Store, error) {
+	switch compression {
+	case CompressionNone, CompressionGzip:
+	default:
+		return nil, fmt.Errorf("unknown cache compression: %s", compression)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &Store{dir: dir, compression: compression}, nil
+}
+
+// path returns the file of a key, rejecting keys that could escape the namespace
+func (s *Store) path(namespace, key string) (string, error) {
+	for _, part := range []string{namespace, key} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid cache key: %s/%s", namespace, key)
+		}
+	}
+	return filepath.Join(s.dir, namespace, key), nil
+}
+
+// Get returns the value stored for key, and false if there is none
+func (s *Store) Get(namespace, key string) ([]byte, bool, error) {
+	path, err := s.path(namespace, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	value, err := decode(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode cache entry %s/%s: %w", namespace, key, err)
+	}
+	return value, true, nil
+}
+
+// Put stores value for key. The entry is written to a temporary file and
+// renamed into place, so readers never see a partial value.
+func (s *Store) Put(namespace, key string, value []byte) error {
+	path, err := s.path(namespace, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache namespace: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// NamespaceStats is the size of one namespace of a store
+type NamespaceStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"` // As stored, after compression
+}
+
+// Stats returns the entries and bytes stored in each namespace
+func (s *Store) Stats() (map[string]NamespaceStats, error) {
+	stats := make(map[string]NamespaceStats)
+	namespaces, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	for _, namespace := range namespaces {
+		if !namespace.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, namespace.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache namespace: %w", err)
+		}
+		var ns NamespaceStats
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue // Removed meanwhile, or not an entry
+			}
+			ns.Entries++
+			ns.Bytes += info.Size()
+		}
+		stats[namespace.Name()] = ns
+	}
+	return stats, nil
+}
+
+// encode prefixes value with its encoding, compressing it if configured
+func (s *Store) encode(value []byte) ([]byte, error) {
+	if s.compression != CompressionGzip {
+		return append([]byte{encodingRaw}, value...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(encodingGzip)
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(value); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode reverses encode according to the entry's encoding byte
+func decode(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty entry")
+	}
+
+	switch data[0] {
+	case encodingRaw:
+		return data[1:], nil
+	case encodingGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("unknown encoding %d", data[0])
+	}
+}
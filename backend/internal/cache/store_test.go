@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	values := map[string][]byte{
+		"empty":        {},
+		"binary":       {0x00, 0x01, 0xff, 0x1f, 0x8b, 0x00},
+		"compressible": bytes.Repeat([]byte("00140101010101010101"), 500),
+	}
+	for _, compression := range []string{CompressionNone, CompressionGzip} {
+		t.Run(compression, func(t *testing.T) {
+			store, err := NewStore(t.TempDir(), compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range values {
+				if err := store.Put("blocks", key, value); err != nil {
+					t.Fatalf("put %s: %v", key, err)
+				}
+			}
+			for key, value := range values {
+				got, ok, err := store.Get("blocks", key)
+				if err != nil || !ok {
+					t.Fatalf("get %s: found %v, %v", key, ok, err)
+				}
+				if !bytes.Equal(got, value) {
+					t.Errorf("%s read back as %d bytes, wrote %d", key, len(got), len(value))
+				}
+			}
+
+			if _, ok, err := store.Get("blocks", "missing"); ok || err != nil {
+				t.Errorf("missing key found %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestStoreCompressesOnDisk(t *testing.T) {
+	value := bytes.Repeat([]byte("00140101010101010101"), 500)
+	sizes := make(map[string]int64)
+	for _, compression := range []string{CompressionNone, CompressionGzip} {
+		store, err := NewStore(t.TempDir(), compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put("blocks", "key", value); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := store.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats["blocks"].Entries != 1 {
+			t.Errorf("%s store has %d entries, want 1", compression, stats["blocks"].Entries)
+		}
+		sizes[compression] = stats["blocks"].Bytes
+	}
+	if sizes[CompressionGzip] >= sizes[CompressionNone]/10 {
+		t.Errorf("gzip entry is %d bytes, uncompressed %d", sizes[CompressionGzip], sizes[CompressionNone])
+	}
+}
+
+func TestStoreReadsEntriesOfOtherCompression(t *testing.T) {
+	// Changing CACHE_COMPRESSION keeps existing entries readable
+	dir := t.TempDir()
+	value := bytes.Repeat([]byte{0xab}, 1000)
+	gzipped, err := NewStore(dir, CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipped.Put("filters", "a", value); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewStore(dir, CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Put("filters", "b", value); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []*Store{gzipped, plain} {
+		for _, key := range []string{"a", "b"} {
+			got, ok, err := store.Get("filters", key)
+			if err != nil || !ok || !bytes.Equal(got, value) {
+				t.Errorf("%s store read %s: %d bytes, found %v, %v", store.compression, key, len(got), ok, err)
+			}
+		}
+	}
+}
+
+func TestStoreRejectsBadInput(t *testing.T) {
+	if _, err := NewStore(t.TempDir(), "snappy"); err == nil {
+		t.Error("unknown compression accepted")
+	}
+
+	dir := t.TempDir()
+	store, err := NewStore(dir, CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", ".", "..", "../escape", `a\b`} {
+		if err := store.Put("filters", key, []byte{1}); err == nil {
+			t.Errorf("key %q accepted", key)
+		}
+	}
+
+	// A corrupt entry is an error, not a miss
+	if err := os.MkdirAll(filepath.Join(dir, "filters"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "filters", "corrupt"), []byte{encodingGzip, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get("filters", "corrupt"); err == nil {
+		t.Error("corrupt gzip entry decoded")
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	store, err := NewStore(t.TempDir(), CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := store.Stats(); err != nil || len(stats) != 0 {
+		t.Fatalf("empty store: got %v, %v", stats, err)
+	}
+
+	for key, value := range map[string][]byte{"a": make([]byte, 10), "b": make([]byte, 30)} {
+		if err := store.Put("blocks", key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Put("filters", "c", make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats["blocks"].Entries != 2 || stats["filters"].Entries != 1 {
+		t.Errorf("got %+v, want 2 blocks and 1 filter", stats)
+	}
+	if stats["blocks"].Bytes < 40 || stats["filters"].Bytes < 5 {
+		t.Errorf("got %+v, want at least the values' sizes", stats)
+	}
+}
@@ -2,7 +2,9 @@
 package contract
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"spv-backend/internal/rpc"
@@ -11,9 +13,16 @@ import (
 // Service handles smart contract interactions
 type Service struct {
 	rpcClient       *rpc.Client
-	contractAddress string
+	contractAddress string            // Default contract
+	namedContracts  map[string]string // name -> address
 }
 
+// ErrUnknownContract is returned when a contract name is not configured
+var ErrUnknownContract = errors.New("unknown contract")
+
+// ErrNoContract is returned when no contract address can be resolved
+var ErrNoContract = errors.New("no contract address: supply an address or contract name, or configure CONTRACT_ADDRESS")
+
 // NewService creates a new contract service
 func NewService(rpcClient *rpc.Client, contractAddress string) *Service {
 	return &Service{
@@ -22,15 +31,48 @@ func NewService(rpcClient *rpc.Client, contractAddress string) *Service {
 	}
 }
 
-// CallContract calls a contract method with the given parameters
-func (s *Service) CallContract(method string, params []string) (json.RawMessage, error) {
+// SetNamedContracts configures the contracts that can be referenced by name
+func (s *Service) SetNamedContracts(contracts map[string]string) {
+	s.namedContracts = contracts
+}
+
+// ResolveContract picks the contract address for a request, in order:
+// the explicit address, then the named contract, then the configured default
+func (s *Service) ResolveContract(address, name string) (string, error) {
+	if address != "" {
+		return address, nil
+	}
+
+	if name != "" {
+		named, ok := s.namedContracts[name]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnknownContract, name)
+		}
+		return named, nil
+	}
+
+	if s.contractAddress == "" {
+		return "", ErrNoContract
+	}
+	return s.contractAddress, nil
+}
+
+// WithContext returns a copy of the service whose RPC calls are bound to ctx
+func (s *Service) WithContext(ctx context.Context) *Service {
+	bound := *s
+	bound.rpcClient = s.rpcClient.WithContext(ctx)
+	return &bound
+}
+
+// CallContract calls a method on the contract at address with the given parameters
+func (s *Service) CallContract(address, method string, params []string) (json.RawMessage, error) {
 	// Convert string params to interface{} for RPC call
 	rpcParams := make([]interface{}, len(params))
 	for i, p := range params {
 		rpcParams[i] = p
 	}
 
-	result, err := s.rpcClient.CallContract(s.contractAddress, method, rpcParams...)
+	result, err := s.rpcClient.CallContract(address, method, rpcParams...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call contract: %w", err)
 	}
@@ -38,15 +80,15 @@ func (s *Service) CallContract(method string, params []string) (json.RawMessage,
 	return result, nil
 }
 
-// DumpContractMessage queries contract data
-func (s *Service) DumpContractMessage(method string, params []string) (json.RawMessage, error) {
+// DumpContractMessage queries data of the contract at address
+func (s *Service) DumpContractMessage(address, method string, params []string) (json.RawMessage, error) {
 	// Convert string params to interface{} for RPC call
 	rpcParams := make([]interface{}, len(params))
 	for i, p := range params {
 		rpcParams[i] = p
 	}
 
-	result, err := s.rpcClient.DumpContractMessage(s.contractAddress, method, rpcParams...)
+	result, err := s.rpcClient.DumpContractMessage(address, method, rpcParams...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query contract: %w", err)
 	}
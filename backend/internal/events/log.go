@@ -0,0 +1,87 @@
+// Package events keeps a bounded, time-ordered log of recent activity (new
+// blocks, broadcasts and watched-address matches) for the event feed
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	TypeBlock     = "block"     // A block was connected by the address watcher
+	TypeBroadcast = "broadcast" // A transaction was broadcast through the API
+	TypeMatch     = "match"     // A block paid or spent a watched address
+)
+
+// Event is one entry of the log. Data is the type-specific payload.
+type Event struct {
+	ID        int64       `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Log holds the most recent events. Events from every source are appended
+// under one lock, so IDs and timestamps increase together and the log is
+// always in time order. It is safe for concurrent use.
+type Log struct {
+	mu     sync.Mutex
+	events []Event // Oldest first
+	max    int
+	nextID int64
+}
+
+// NewLog creates a log keeping the last max events
+func NewLog(max int) *Log {
+	return &Log{max: max, nextID: 1}
+}
+
+// Record appends an event stamped with the current time. A nil log records
+// nothing, so sources can hold one unconditionally.
+func (l *Log) Record(eventType string, data interface{}) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Never step back if the wall clock does
+	now := time.Now()
+	if n := len(l.events); n > 0 && now.Before(l.events[n-1].Timestamp) {
+		now = l.events[n-1].Timestamp
+	}
+
+	l.events = append(l.events, Event{ID: l.nextID, Type: eventType, Timestamp: now, Data: data})
+	l.nextID++
+	// Dropped events are released when append next reallocates
+	if len(l.events) > l.max {
+		l.events = l.events[len(l.events)-l.max:]
+	}
+}
+
+// Len returns the number of events kept
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events)
+}
+
+// Since returns up to limit events at or after since with an ID above
+// afterID, oldest first, and whether more events follow them
+func (l *Log) Since(since time.Time, afterID int64, limit int) ([]Event, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []Event{}
+	for i, event := range l.events {
+		if event.ID <= afterID || event.Timestamp.Before(since) {
+			continue
+		}
+		if len(events) == limit {
+			return events, true
+		}
+		events = append(events, l.events[i])
+	}
+	return events, false
+}
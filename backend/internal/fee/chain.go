@@ -0,0 +1,104 @@
+package fee
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ChainEntry is a single unconfirmed transaction in a mempool chain
+type ChainEntry struct {
+	TxID    string `json:"txid"`
+	VSize   int64  `json:"vsize"`
+	FeeSats int64  `json:"fee_sats"`
+}
+
+// ChainSet aggregates a set of related unconfirmed transactions
+type ChainSet struct {
+	Count   int          `json:"count"`
+	VSize   int64        `json:"vsize"`
+	FeeSats int64        `json:"fee_sats"`
+	Txs     []ChainEntry `json:"txs"`
+}
+
+// MempoolChain describes a transaction with its unconfirmed ancestors and
+// descendants. The package is the transaction plus its ancestors: a CPFP
+// child has to pay for all of it to get the transaction mined.
+type MempoolChain struct {
+	Tx             ChainEntry `json:"tx"`
+	Ancestors      ChainSet   `json:"ancestors"`
+	Descendants    ChainSet   `json:"descendants"`
+	PackageVSize   int64      `json:"package_vsize"`
+	PackageFeeSats int64      `json:"package_fee_sats"`
+	PackageFeeRate float64    `json:"package_feerate_sat_vb"`
+}
+
+// MempoolChain returns the mempool ancestor/descendant set of a transaction
+// with aggregate fees and sizes
+func (s *Service) MempoolChain(txid string) (*MempoolChain, error) {
+	entryData, err := s.rpcClient.GetMempoolEntry(txid)
+	if err != nil {
+		return nil, err
+	}
+	var entry mempoolEntry
+	if err := json.Unmarshal(entryData, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mempool entry: %w", err)
+	}
+
+	ancestorData, err := s.rpcClient.GetMempoolAncestors(txid, true)
+	if err != nil {
+		return nil, err
+	}
+	ancestors, err := parseChainSet(ancestorData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ancestors: %w", err)
+	}
+
+	descendantData, err := s.rpcClient.GetMempoolDescendants(txid, true)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := parseChainSet(descendantData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse descendants: %w", err)
+	}
+
+	chain := &MempoolChain{
+		Tx:          ChainEntry{TxID: txid, VSize: entry.VSize, FeeSats: btcToSats(entry.Fees.Base)},
+		Ancestors:   ancestors,
+		Descendants: descendants,
+	}
+	chain.PackageVSize = chain.Tx.VSize + ancestors.VSize
+	chain.PackageFeeSats = chain.Tx.FeeSats + ancestors.FeeSats
+	if chain.PackageVSize > 0 {
+		chain.PackageFeeRate = float64(chain.PackageFeeSats) / float64(chain.PackageVSize)
+	}
+
+	return chain, nil
+}
+
+// parseChainSet sums a verbose getmempoolancestors/descendants result
+func parseChainSet(data json.RawMessage) (ChainSet, error) {
+	var entries map[string]mempoolEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return ChainSet{}, err
+	}
+
+	set := ChainSet{Txs: make([]ChainEntry, 0, len(entries))}
+	for txid, entry := range entries {
+		fee := btcToSats(entry.Fees.Base)
+		set.Txs = append(set.Txs, ChainEntry{TxID: txid, VSize: entry.VSize, FeeSats: fee})
+		set.VSize += entry.VSize
+		set.FeeSats += fee
+	}
+	set.Count = len(set.Txs)
+	sort.Slice(set.Txs, func(i, j int) bool { return set.Txs[i].TxID < set.Txs[j].TxID })
+
+	return set, nil
+}
+
+// btcToSats converts a BTC fee amount to satoshis
+func btcToSats(btc float64) int64 {
+	return int64(math.Round(btc * 1e8))
+}
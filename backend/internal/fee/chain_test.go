@@ -0,0 +1,78 @@
+package fee
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// Verbose getmempoolancestors output for a transaction with two unconfirmed
+// parents, as Bitcoin Core returns it (trimmed to the fields read)
+const sampleAncestors = `{
+  "b4749f017444b051c44dfd2720e88f314ff94f3dd6d56d40ef65854fcd7fff6b": {
+    "vsize": 141, "weight": 561,
+    "fees": {"base": 0.00000705, "modified": 0.00000705, "ancestor": 0.00000705, "descendant": 0.00003229}
+  },
+  "4d49a71ec9da436f71ec4ee231d04f292a29cd316f598bb7068feccabdc59485": {
+    "vsize": 110, "weight": 438,
+    "fees": {"base": 0.00000220, "modified": 0.00000220, "ancestor": 0.00000220, "descendant": 0.00002744}
+  }
+}`
+
+func TestParseChainSetAggregatesAncestors(t *testing.T) {
+	set, err := parseChainSet(json.RawMessage(sampleAncestors))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := ChainSet{
+		Count:   2,
+		VSize:   251,
+		FeeSats: 925,
+		Txs: []ChainEntry{
+			{TxID: "4d49a71ec9da436f71ec4ee231d04f292a29cd316f598bb7068feccabdc59485", VSize: 110, FeeSats: 220},
+			{TxID: "b4749f017444b051c44dfd2720e88f314ff94f3dd6d56d40ef65854fcd7fff6b", VSize: 141, FeeSats: 705},
+		},
+	}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("got %+v, want %+v", set, want)
+	}
+
+	empty, err := parseChainSet(json.RawMessage(`{}`))
+	if err != nil || empty.Count != 0 || empty.VSize != 0 || empty.FeeSats != 0 || empty.Txs == nil {
+		t.Errorf("empty set: got %+v, %v", empty, err)
+	}
+}
+
+func TestMempoolChainPackage(t *testing.T) {
+	s, _, node := newTestService(t, 1)
+	const txid = "e4b0b2b7a6c4cfb3c1f4dd0a7dd8b7b3f01cf2e8c2d0f7b2a9b5f7a3c6d2e1f0"
+	node.Handle("getmempoolentry", func(params []json.RawMessage) (interface{}, error) {
+		return json.RawMessage(`{"vsize": 200, "fees": {"base": 0.00000200}}`), nil
+	})
+	node.Handle("getmempoolancestors", func(params []json.RawMessage) (interface{}, error) {
+		return json.RawMessage(sampleAncestors), nil
+	})
+	node.Handle("getmempooldescendants", func(params []json.RawMessage) (interface{}, error) {
+		return json.RawMessage(`{"c3b9a7d1e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8e0f2a4b6": {"vsize": 150, "fees": {"base": 0.00003000}}}`), nil
+	})
+
+	chain, err := s.MempoolChain(txid)
+	if err != nil {
+		t.Fatalf("mempool chain: %v", err)
+	}
+	if chain.Tx != (ChainEntry{TxID: txid, VSize: 200, FeeSats: 200}) {
+		t.Errorf("tx %+v", chain.Tx)
+	}
+	if chain.Ancestors.Count != 2 || chain.Descendants.Count != 1 || chain.Descendants.FeeSats != 3000 {
+		t.Errorf("%d ancestors, %d descendants paying %d sats", chain.Ancestors.Count, chain.Descendants.Count, chain.Descendants.FeeSats)
+	}
+	// The package is the transaction and its ancestors; descendants are
+	// what already bumps it, not part of what a new child pays for
+	if chain.PackageVSize != 451 || chain.PackageFeeSats != 1125 {
+		t.Errorf("package %d vB paying %d sats, want 451 vB paying 1125", chain.PackageVSize, chain.PackageFeeSats)
+	}
+	if math.Abs(chain.PackageFeeRate-1125.0/451) > 1e-9 {
+		t.Errorf("package rate %v sat/vB, want %v", chain.PackageFeeRate, 1125.0/451)
+	}
+}
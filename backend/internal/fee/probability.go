@@ -0,0 +1,57 @@
+package fee
+
+import "math"
+
+// blockVSizeStdDev is the modeled spread of the space one block gives the
+// mempool: blocks vary with miner policy, reserved space and package sizes
+const blockVSizeStdDev = 0.1 * blockVSize
+
+// ConfirmProbability is the modeled chance that a transaction paying FeeRate
+// is mined within Blocks blocks.
+//
+// Model assumptions:
+//   - Miners fill blocks with the highest fee rates first, so the transaction
+//     waits behind the mempool vsize paying at least its fee rate (ties ahead).
+//   - Each block clears a normally distributed amount of that queue, with mean
+//     1 MvB and standard deviation 10%, independently of other blocks.
+//   - The mempool is static: transactions arriving later with higher fee
+//     rates, and ancestor or descendant packages, are not accounted for, so
+//     the probability is optimistic when the mempool is growing.
+type ConfirmProbability struct {
+	FeeRate      float64 `json:"feerate_sat_vb"`
+	Blocks       int     `json:"blocks"`
+	Probability  float64 `json:"probability"`
+	VSizeAhead   int64   `json:"vsize_ahead"`   // Mempool vsize at or above FeeRate
+	MempoolVSize int64   `json:"mempool_vsize"` // Total mempool vsize
+	MempoolCount int     `json:"mempool_count"`
+}
+
+// ConfirmProbability estimates the probability that a transaction paying
+// feeRate sat/vB confirms within blocks blocks given the current mempool
+func (s *Service) ConfirmProbability(feeRate float64, blocks int) (*ConfirmProbability, error) {
+	rates, err := s.mempoolRates()
+	if err != nil {
+		return nil, err
+	}
+
+	return confirmProbability(rates, feeRate, blocks), nil
+}
+
+// confirmProbability applies the model to a mempool sorted by fee rate
+func confirmProbability(rates []mempoolRate, feeRate float64, blocks int) *ConfirmProbability {
+	result := &ConfirmProbability{FeeRate: feeRate, Blocks: blocks, MempoolCount: len(rates)}
+	for _, r := range rates {
+		result.MempoolVSize += r.vsize
+		if r.rate >= feeRate {
+			result.VSizeAhead += r.vsize
+		}
+	}
+
+	// P(capacity of n blocks > vsize ahead), capacity ~ N(n*mean, n*variance)
+	mean := float64(blocks) * blockVSize
+	stdDev := blockVSizeStdDev * math.Sqrt(float64(blocks))
+	z := (mean - float64(result.VSizeAhead)) / stdDev
+	result.Probability = 0.5 * math.Erfc(-z/math.Sqrt2)
+
+	return result
+}
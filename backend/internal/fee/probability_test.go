@@ -0,0 +1,85 @@
+package fee
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// syntheticMempool answers getrawmempool with count transactions of vsize
+// vbytes at each fee rate (sat/vB)
+func syntheticMempool(tiers map[float64]int, vsize int64) func([]json.RawMessage) (interface{}, error) {
+	entries := make(map[string]interface{})
+	for rate, count := range tiers {
+		for i := 0; i < count; i++ {
+			entries[fmt.Sprintf("%v-%d", rate, i)] = map[string]interface{}{
+				"vsize": vsize,
+				"fees":  map[string]interface{}{"base": rate * float64(vsize) / 1e8},
+			}
+		}
+	}
+	return func([]json.RawMessage) (interface{}, error) { return entries, nil }
+}
+
+func TestConfirmProbability(t *testing.T) {
+	s, _, node := newTestService(t, 1)
+	// 0.2 MvB at 50 sat/vB, 0.5 MvB at 10, 2.5 MvB at 2: about three
+	// blocks' worth
+	node.Handle("getrawmempool", syntheticMempool(map[float64]int{50: 200, 10: 500, 2: 2500}, 1000))
+
+	tests := []struct {
+		feeRate    float64
+		blocks     int
+		vsizeAhead int64
+		min, max   float64
+	}{
+		{feeRate: 100, blocks: 1, vsizeAhead: 0, min: 0.99, max: 1},
+		{feeRate: 50, blocks: 1, vsizeAhead: 200000, min: 0.99, max: 1},
+		{feeRate: 10, blocks: 1, vsizeAhead: 700000, min: 0.99, max: 1},
+		{feeRate: 2, blocks: 1, vsizeAhead: 3200000, min: 0, max: 0.01},
+		{feeRate: 2, blocks: 3, vsizeAhead: 3200000, min: 0.05, max: 0.5},
+		{feeRate: 2, blocks: 6, vsizeAhead: 3200000, min: 0.99, max: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v sat/vB in %d", tt.feeRate, tt.blocks), func(t *testing.T) {
+			p, err := s.ConfirmProbability(tt.feeRate, tt.blocks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.VSizeAhead != tt.vsizeAhead || p.MempoolVSize != 3200000 || p.MempoolCount != 3200 {
+				t.Errorf("got %+v, want %d vB ahead of 3.2 MvB", p, tt.vsizeAhead)
+			}
+			if p.Probability < tt.min || p.Probability > tt.max {
+				t.Errorf("probability %v, want between %v and %v", p.Probability, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestConfirmProbabilityIncreasesWithFeeRate(t *testing.T) {
+	s, _, node := newTestService(t, 1)
+	node.Handle("getrawmempool", syntheticMempool(map[float64]int{20: 400, 15: 400, 10: 400, 5: 400}, 1000))
+
+	last := -1.0
+	for _, feeRate := range []float64{1, 5, 10, 15, 20, 30} {
+		p, err := s.ConfirmProbability(feeRate, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Probability < last {
+			t.Errorf("%v sat/vB: probability %v below %v at a lower rate", feeRate, p.Probability, last)
+		}
+		last = p.Probability
+	}
+}
+
+func TestConfirmProbabilityEmptyMempool(t *testing.T) {
+	s, _, _ := newTestService(t, 1)
+	p, err := s.ConfirmProbability(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Probability < 0.99 || p.MempoolCount != 0 {
+		t.Errorf("got %+v, want near certainty with nothing ahead", p)
+	}
+}
@@ -0,0 +1,149 @@
+// Package fee provides fee rate estimation functionality
+package fee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"spv-backend/internal/rpc"
+)
+
+// Fee estimate sources, in order of preference
+const (
+	SourceSmart    = "smart"    // Node's estimatesmartfee
+	SourceMempool  = "mempool"  // Derived from the current mempool
+	SourceFallback = "fallback" // Configured fallback fee rate
+)
+
+// blockVSize is the virtual size available to transactions in one block
+const blockVSize = 1000000
+
+// Service handles fee estimation
+type Service struct {
+	rpcClient       *rpc.Client
+	fallbackFeeRate float64 // sat/vB
+}
+
+// Estimate represents a fee rate estimate
+type Estimate struct {
+	FeeRate    float64 `json:"feerate_sat_vb"`
+	ConfTarget int     `json:"conf_target"`
+	Source     string  `json:"source"` // "smart", "mempool" or "fallback"
+}
+
+// NewService creates a new fee service
+func NewService(rpcClient *rpc.Client, fallbackFeeRate float64) *Service {
+	return &Service{
+		rpcClient:       rpcClient,
+		fallbackFeeRate: fallbackFeeRate,
+	}
+}
+
+// WithContext returns a copy of the service whose RPC calls are bound to ctx
+func (s *Service) WithContext(ctx context.Context) *Service {
+	bound := *s
+	bound.rpcClient = s.rpcClient.WithContext(ctx)
+	return &bound
+}
+
+// EstimateFee estimates a fee rate for confirmation within confTarget blocks.
+// estimatesmartfee returns nothing on low-activity chains, so it falls back to
+// the mempool's fee distribution and finally to the configured fee rate.
+func (s *Service) EstimateFee(confTarget int) *Estimate {
+	if rate, err := s.smartFeeRate(confTarget); err == nil {
+		return &Estimate{FeeRate: rate, ConfTarget: confTarget, Source: SourceSmart}
+	}
+
+	if rate, err := s.mempoolFeeRate(confTarget); err == nil {
+		return &Estimate{FeeRate: rate, ConfTarget: confTarget, Source: SourceMempool}
+	}
+
+	return &Estimate{FeeRate: s.fallbackFeeRate, ConfTarget: confTarget, Source: SourceFallback}
+}
+
+// smartFeeRate returns the node's smart fee estimate in sat/vB
+func (s *Service) smartFeeRate(confTarget int) (float64, error) {
+	result, err := s.rpcClient.EstimateSmartFee(confTarget)
+	if err != nil {
+		return 0, err
+	}
+
+	var estimate struct {
+		FeeRate *float64 `json:"feerate"` // BTC/kvB
+		Errors  []string `json:"errors"`
+	}
+	if err := json.Unmarshal(result, &estimate); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal fee estimate: %w", err)
+	}
+
+	if estimate.FeeRate == nil || *estimate.FeeRate <= 0 {
+		return 0, fmt.Errorf("no smart fee estimate available: %v", estimate.Errors)
+	}
+
+	return *estimate.FeeRate * 1e8 / 1000, nil
+}
+
+// mempoolEntry is the subset of a verbose mempool entry used for estimation
+type mempoolEntry struct {
+	VSize int64 `json:"vsize"`
+	Fees  struct {
+		Base float64 `json:"base"` // BTC
+	} `json:"fees"`
+}
+
+// mempoolRate is the fee rate and size of one mempool transaction
+type mempoolRate struct {
+	rate  float64 // sat/vB
+	vsize int64
+}
+
+// mempoolRates returns the mempool's transactions by fee rate, highest first
+func (s *Service) mempoolRates() ([]mempoolRate, error) {
+	result, err := s.rpcClient.GetRawMempool(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]mempoolEntry
+	if err := json.Unmarshal(result, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mempool: %w", err)
+	}
+
+	rates := make([]mempoolRate, 0, len(entries))
+	for _, entry := range entries {
+		if entry.VSize <= 0 {
+			continue
+		}
+		rates = append(rates, mempoolRate{rate: entry.Fees.Base * 1e8 / float64(entry.VSize), vsize: entry.VSize})
+	}
+
+	sort.Slice(rates, func(i, j int) bool { return rates[i].rate > rates[j].rate })
+	return rates, nil
+}
+
+// mempoolFeeRate returns the fee rate needed to be mined within confTarget
+// blocks, assuming miners fill each block with the highest fee rates first
+func (s *Service) mempoolFeeRate(confTarget int) (float64, error) {
+	rates, err := s.mempoolRates()
+	if err != nil {
+		return 0, err
+	}
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("mempool has no usable entries")
+	}
+
+	// The rate at the edge of the available block space is the one to beat
+	capacity := int64(confTarget) * blockVSize
+	var used int64
+	for _, r := range rates {
+		used += r.vsize
+		if used >= capacity {
+			return r.rate, nil
+		}
+	}
+
+	// Everything fits, so the lowest rate in the mempool is enough
+	return rates[len(rates)-1].rate, nil
+}
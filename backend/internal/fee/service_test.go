@@ -0,0 +1,77 @@
+package fee
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// noSmartFee is estimatesmartfee's answer on a chain without fee data
+func noSmartFee(params []json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{"errors": []string{"Insufficient data or no feerate found"}, "blocks": 0}, nil
+}
+
+func newTestService(t *testing.T, fallback float64) (*Service, *rpctest.Chain, *rpctest.Node) {
+	t.Helper()
+	chain := rpctest.NewChain(&chaincfg.RegressionNetParams)
+	node := rpctest.NewNode(t, chain)
+	return NewService(node.Client(), fallback), chain, node
+}
+
+func TestEstimateFeeSmart(t *testing.T) {
+	s, _, node := newTestService(t, 1)
+	node.Handle("estimatesmartfee", func(params []json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"feerate": json.Number("0.00012"), "blocks": 2}, nil
+	})
+
+	estimate := s.EstimateFee(2)
+	if estimate.Source != SourceSmart || math.Abs(estimate.FeeRate-12) > 1e-9 || estimate.ConfTarget != 2 {
+		t.Errorf("got %+v, want 12 sat/vB from the smart estimate", estimate)
+	}
+}
+
+func TestEstimateFeeFallsBackWithoutSmartFee(t *testing.T) {
+	s, _, node := newTestService(t, 2.5)
+	node.Handle("estimatesmartfee", noSmartFee)
+
+	// No smart estimate and an empty mempool leave the configured rate
+	estimate := s.EstimateFee(6)
+	if estimate.Source != SourceFallback || estimate.FeeRate != 2.5 {
+		t.Errorf("got %+v, want the 2.5 sat/vB fallback", estimate)
+	}
+	if node.Calls("getrawmempool") != 1 {
+		t.Errorf("the mempool was not consulted before falling back")
+	}
+}
+
+func TestEstimateFeeFromMempool(t *testing.T) {
+	s, chain, node := newTestService(t, 1)
+	node.Handle("estimatesmartfee", noSmartFee)
+
+	address := rpctest.Address(&chaincfg.RegressionNetParams, "p2wpkh", 1)
+	fund := chain.NewTx(nil, rpctest.PayTo(address, 100000), rpctest.PayTo(address, 100000))
+	chain.AddBlock(fund)
+	low := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(address, 99000))
+	high := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(address, 90000))
+	chain.AddToMempool(low)
+	chain.AddToMempool(high)
+
+	// Both fit in the next block, so the lowest rate is enough
+	estimate := s.EstimateFee(1)
+	if estimate.Source != SourceMempool {
+		t.Fatalf("got %+v, want a mempool estimate", estimate)
+	}
+	if want := 1000 / vsize(low); math.Abs(estimate.FeeRate-want) > 1e-6 {
+		t.Errorf("got %v sat/vB, want the lowest mempool rate %v", estimate.FeeRate, want)
+	}
+}
+
+// vsize is a transaction's virtual size
+func vsize(tx *wire.MsgTx) float64 {
+	return float64((tx.SerializeSizeStripped()*3 + tx.SerializeSize() + 3) / 4)
+}
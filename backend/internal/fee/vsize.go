@@ -0,0 +1,58 @@
+package fee
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TxSize describes a transaction's serialized size and its weight-based virtual size
+type TxSize struct {
+	Size         int   `json:"size"` // Full serialized size in bytes, including witness
+	StrippedSize int   `json:"stripped_size"`
+	Weight       int64 `json:"weight"` // Weight units (BIP141)
+	VSize        int64 `json:"vsize"`  // ceil(weight / 4)
+	HasWitness   bool  `json:"has_witness"`
+}
+
+// TxSizeFromHex decodes a raw transaction and computes its size using BIP141
+// weight, so witness bytes are discounted instead of counted naively
+func TxSizeFromHex(rawTx string) (*TxSize, error) {
+	txBytes, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode raw transaction hex: %w", err)
+	}
+
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("failed to deserialize transaction: %w", err)
+	}
+
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(&msgTx))
+
+	return &TxSize{
+		Size:         msgTx.SerializeSize(),
+		StrippedSize: msgTx.SerializeSizeStripped(),
+		Weight:       weight,
+		VSize:        (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor,
+		HasWitness:   msgTx.HasWitness(),
+	}, nil
+}
+
+// feeRoundingSlack absorbs float error in fee rates converted from BTC/kvB,
+// e.g. 0.00002 BTC/kvB becoming 2.0000000000000004 sat/vB
+const feeRoundingSlack = 1e-6
+
+// FeeForVSize returns the fee in satoshis for the given virtual size and fee rate
+func FeeForVSize(vsize int64, feeRate float64) int64 {
+	fee := float64(vsize) * feeRate
+	rounded := int64(fee)
+	if float64(rounded) < fee-feeRoundingSlack {
+		rounded++ // Round up so the fee rate is never undershot
+	}
+	return rounded
+}
@@ -0,0 +1,83 @@
+package fee
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// p2wpkhSpend returns a one-input, one-output P2WPKH transaction with a
+// 72-byte signature and a compressed key in the witness
+func p2wpkhSpend(withWitness bool) string {
+	tx := wire.NewMsgTx(2)
+	in := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil)
+	if withWitness {
+		in.Witness = wire.TxWitness{bytes.Repeat([]byte{0x30}, 72), bytes.Repeat([]byte{0x02}, 33)}
+	}
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(50000, append([]byte{0x00, 0x14}, make([]byte, 20)...)))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func TestTxSizeSegWit(t *testing.T) {
+	size, err := TxSizeFromHex(p2wpkhSpend(true))
+	if err != nil {
+		t.Fatalf("size: %v", err)
+	}
+	// 82 non-witness bytes weigh 4 each; the 110 witness bytes (marker,
+	// flag, item count, signature and key with their lengths) weigh 1, for
+	// 438 WU, which Bitcoin Core reports as vsize 110
+	want := TxSize{Size: 192, StrippedSize: 82, Weight: 438, VSize: 110, HasWitness: true}
+	if *size != want {
+		t.Errorf("got %+v, want %+v", *size, want)
+	}
+}
+
+func TestTxSizeLegacy(t *testing.T) {
+	size, err := TxSizeFromHex(p2wpkhSpend(false))
+	if err != nil {
+		t.Fatalf("size: %v", err)
+	}
+	// Without witness data the vsize is the serialized size
+	want := TxSize{Size: 82, StrippedSize: 82, Weight: 328, VSize: 82}
+	if *size != want {
+		t.Errorf("got %+v, want %+v", *size, want)
+	}
+}
+
+func TestTxSizeRejectsBadHex(t *testing.T) {
+	for _, raw := range []string{"zz", "0200"} {
+		if _, err := TxSizeFromHex(raw); err == nil {
+			t.Errorf("TxSizeFromHex(%q) succeeded", raw)
+		}
+	}
+}
+
+// btcPerKvB is a rate as estimatesmartfee reports it, converted at run time
+var btcPerKvB = 0.00002
+
+func TestFeeForVSizeRoundsUp(t *testing.T) {
+	tests := []struct {
+		vsize   int64
+		feeRate float64
+		want    int64
+	}{
+		{110, 1, 110},
+		{110, 1.5, 165},
+		{110, 1.01, 112},
+		{141, 2.5, 353},
+		{110, btcPerKvB * 1e8 / 1000, 220}, // 2.0000000000000004 sat/vB
+	}
+	for _, tt := range tests {
+		if got := FeeForVSize(tt.vsize, tt.feeRate); got != tt.want {
+			t.Errorf("FeeForVSize(%d, %v) = %d, want %d", tt.vsize, tt.feeRate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package filter
+
+// BlockActivity is what a single block does to a set of addresses: the
+// outputs it pays them and every outpoint it spends
+type BlockActivity struct {
+	Hash   string
+	Height int64
+	UTXOs  []UTXO
+	Spends []string // "txid:vout" of every input
+}
+
+// BlockActivityFor fetches a block and extracts its outputs paying addresses
+// and its spends. With useFilter, the block's BIP158 filter is checked first
+// and the block is only fetched if it may involve the addresses; the filter
+// commits to spent scripts too, so spends of their outputs are not missed.
+func (s *Service) BlockActivityFor(blockHash string, height int64, addresses []string, useFilter bool) (*BlockActivity, error) {
+	activity := &BlockActivity{Hash: blockHash, Height: height}
+	if len(addresses) == 0 {
+		return activity, nil
+	}
+
+	if useFilter {
+		filterHex, _, err := s.GetFilterForBlock(blockHash)
+		if err != nil {
+			return nil, err
+		}
+		matched, err := s.MatchAnyAddressInFilter(addresses, filterHex, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			return activity, nil
+		}
+	}
+
+	addressScripts, err := s.buildAddressScripts(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers count confirmations from their own tip
+	block, err := s.fetchScanBlock(blockRef{Hash: blockHash, Height: height}, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := s.extractBlockOutputs(block, addressScripts)
+	if err != nil {
+		return nil, err
+	}
+
+	activity.Height = block.Height
+	activity.UTXOs = outputs.utxos
+	activity.Spends = outputs.spends
+	return activity, nil
+}
@@ -0,0 +1,204 @@
+package filter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// knownNetworks are tried when an address does not decode for the configured
+// network, to tell a wrong-network address apart from a malformed one
+var knownNetworks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SigNetParams,
+}
+
+// AddressInfo describes the result of validating an address
+type AddressInfo struct {
+	Address        string `json:"address"`
+	Valid          bool   `json:"valid"`
+	Type           string `json:"type,omitempty"`    // p2pkh, p2sh, p2wpkh, p2wsh, p2tr or p2pk
+	Network        string `json:"network,omitempty"` // Network the address belongs to
+	NetworkMatch   *bool  `json:"network_match"`     // Whether Network is the configured one, null if malformed
+	IsWitness      bool   `json:"is_witness"`
+	WitnessVersion *int   `json:"witness_version,omitempty"` // Set for segwit addresses
+	Error          string `json:"error,omitempty"`           // "invalid format: ..." or "wrong network: ..."
+}
+
+// ValidateAddress decodes an address against the configured network. An
+// address that decodes for another known network is reported as invalid
+// with its network, rather than as malformed.
+func (s *Service) ValidateAddress(address string) *AddressInfo {
+	info := &AddressInfo{Address: address}
+
+	addr, err := btcutil.DecodeAddress(address, s.chainParams)
+	if err == nil && addr.IsForNet(s.chainParams) {
+		info.Valid = true
+		info.Network = s.chainParams.Name
+		info.NetworkMatch = boolPtr(true)
+		describeAddress(info, addr)
+		return info
+	}
+
+	for _, params := range knownNetworks {
+		if params.Name == s.chainParams.Name {
+			continue
+		}
+		other, otherErr := btcutil.DecodeAddress(address, params)
+		if otherErr != nil || !other.IsForNet(params) {
+			continue
+		}
+		info.Network = params.Name
+		info.NetworkMatch = boolPtr(false)
+		info.Error = fmt.Sprintf("wrong network: address is for %s, expected %s", params.Name, s.chainParams.Name)
+		describeAddress(info, other)
+		return info
+	}
+
+	if err != nil {
+		info.Error = fmt.Sprintf("invalid format: %v", err)
+	} else {
+		info.Error = "invalid format: address is not for any known network"
+	}
+	return info
+}
+
+// describeAddress fills in the address type and witness details
+func describeAddress(info *AddressInfo, addr btcutil.Address) {
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		info.Type = "p2pkh"
+	case *btcutil.AddressScriptHash:
+		info.Type = "p2sh"
+	case *btcutil.AddressWitnessPubKeyHash:
+		info.Type = "p2wpkh"
+	case *btcutil.AddressWitnessScriptHash:
+		info.Type = "p2wsh"
+	case *btcutil.AddressTaproot:
+		info.Type = "p2tr"
+	case *btcutil.AddressPubKey:
+		info.Type = "p2pk"
+	}
+
+	if segwit, ok := addr.(interface{ WitnessVersion() byte }); ok {
+		version := int(segwit.WitnessVersion())
+		info.IsWitness = true
+		info.WitnessVersion = &version
+	}
+}
+
+// boolPtr returns a pointer to b
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// SkippedAddress is an address left out of a lenient scan
+type SkippedAddress struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+// PartitionAddresses splits addresses into those that decode for the
+// configured network and those that do not
+func (s *Service) PartitionAddresses(addresses []string) ([]string, []SkippedAddress) {
+	valid := make([]string, 0, len(addresses))
+	var skipped []SkippedAddress
+	for _, addr := range addresses {
+		if _, err := s.AddressToScriptPubKey(addr); err != nil {
+			skipped = append(skipped, SkippedAddress{Address: addr, Error: err.Error()})
+			continue
+		}
+		valid = append(valid, addr)
+	}
+	return valid, skipped
+}
+
+// ScriptAliases finds addresses paying the same script as an earlier one in
+// the list, such as a bech32 address given in both cases. Scans report such
+// a script's UTXOs under its first address; the result maps that address to
+// the later ones. Addresses that do not decode are ignored.
+func (s *Service) ScriptAliases(addresses []string) map[string][]string {
+	first := make(map[string]string, len(addresses)) // script hex -> first address
+	aliases := make(map[string][]string)
+	for _, address := range uniqueAddresses(addresses) {
+		script, err := s.AddressToScriptPubKey(address)
+		if err != nil {
+			continue
+		}
+		scriptHex := hex.EncodeToString(script)
+		if primary, ok := first[scriptHex]; ok {
+			aliases[primary] = append(aliases[primary], address)
+			continue
+		}
+		first[scriptHex] = address
+	}
+	return aliases
+}
+
+// ScanScripts returns the scriptPubKeys (hex) a scan of addresses matches,
+// each once, in the order of the first address paying it
+func (s *Service) ScanScripts(addresses []string) ([]string, error) {
+	addressScripts, err := s.buildAddressScripts(addresses)
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([]string, 0, len(addressScripts))
+	for _, address := range uniqueAddresses(addresses) {
+		script, _ := s.AddressToScriptPubKey(address) // Decoded by buildAddressScripts
+		scriptHex := hex.EncodeToString(script)
+		if addressScripts[scriptHex] == address {
+			scripts = append(scripts, scriptHex)
+		}
+	}
+	return scripts, nil
+}
+
+// AnnotateAliases sets AliasAddresses on UTXOs whose address has aliases
+// (see ScriptAliases)
+func AnnotateAliases(utxos []UTXO, aliases map[string][]string) {
+	for i := range utxos {
+		if others, ok := aliases[utxos[i].Address]; ok {
+			utxos[i].AliasAddresses = others
+		}
+	}
+}
+
+// CanonicalAddresses returns the address set in a canonical form: each
+// address re-encoded from its decoded form (so bech32 addresses are
+// lowercase, while case-sensitive base58 addresses are kept as they are),
+// deduplicated and sorted. Addresses that do not decode are kept trimmed.
+func (s *Service) CanonicalAddresses(addresses []string) []string {
+	seen := make(map[string]bool, len(addresses))
+	canonical := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if addr, err := btcutil.DecodeAddress(address, s.chainParams); err == nil {
+			address = addr.EncodeAddress()
+		}
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		canonical = append(canonical, address)
+	}
+	sort.Strings(canonical)
+	return canonical
+}
+
+// AddressSetKey returns a key identifying an address set, equal for any
+// ordering, duplication or bech32 casing of the same addresses. Caches of
+// per-address-set results use it as (part of) their key.
+func (s *Service) AddressSetKey(addresses []string) string {
+	h := sha256.New()
+	for _, address := range s.CanonicalAddresses(addresses) {
+		fmt.Fprintf(h, "%s\n", address)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
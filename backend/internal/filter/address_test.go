@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestAddressSetKeyIgnoresOrder(t *testing.T) {
+	s, _, _ := newTestService(t)
+	p2pkh := rpctest.Address(testParams, "p2pkh", 1).EncodeAddress()
+	p2wpkh := rpctest.Address(testParams, "p2wpkh", 2).EncodeAddress()
+	p2tr := rpctest.Address(testParams, "p2tr", 3).EncodeAddress()
+
+	key := s.AddressSetKey([]string{p2pkh, p2wpkh, p2tr})
+	for _, addresses := range [][]string{
+		{p2tr, p2pkh, p2wpkh},
+		{p2wpkh, p2tr, p2pkh},
+		{p2wpkh, p2tr, p2pkh, p2tr},            // Duplicated
+		{strings.ToUpper(p2wpkh), p2tr, p2pkh}, // Bech32 is case-insensitive
+		{" " + p2pkh, p2wpkh + "\n", p2tr, ""}, // Whitespace and empty entries
+	} {
+		if got := s.AddressSetKey(addresses); got != key {
+			t.Errorf("key of %v differs", addresses)
+		}
+	}
+
+	// Other sets, including a subset, get other keys
+	for _, addresses := range [][]string{
+		{p2pkh, p2wpkh},
+		{p2pkh, p2wpkh, rpctest.Address(testParams, "p2tr", 4).EncodeAddress()},
+		// Base58 is case-sensitive: changing the case changes the address
+		{swapCase(p2pkh), p2wpkh, p2tr},
+	} {
+		if got := s.AddressSetKey(addresses); got == key {
+			t.Errorf("key of %v equals the key of another set", addresses)
+		}
+	}
+}
+
+func TestCanonicalAddresses(t *testing.T) {
+	s, _, _ := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	b := rpctest.Address(testParams, "p2pkh", 2).EncodeAddress()
+
+	got := s.CanonicalAddresses([]string{strings.ToUpper(a), b, a, "notanaddress", " notanaddress "})
+	want := []string{a, b, "notanaddress"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// swapCase inverts the case of every letter in s
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return r
+	}, s)
+}
+
+func TestScriptAliases(t *testing.T) {
+	s, _, _ := newTestService(t)
+	segwit := rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()
+	legacy := rpctest.Address(testParams, "p2pkh", 2).EncodeAddress()
+	upper := strings.ToUpper(segwit)
+
+	aliases := s.ScriptAliases([]string{segwit, legacy, upper, segwit, "not-an-address"})
+	if len(aliases) != 1 || len(aliases[segwit]) != 1 || aliases[segwit][0] != upper {
+		t.Errorf("got %v, want %s aliased by %s", aliases, segwit, upper)
+	}
+	if aliases := s.ScriptAliases([]string{segwit, legacy}); len(aliases) != 0 {
+		t.Errorf("got %v for distinct scripts", aliases)
+	}
+}
+
+func TestScanAttributesSharedScriptToFirstAddress(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	lower := address.EncodeAddress()
+	upper := strings.ToUpper(lower)
+
+	for _, mode := range []string{"direct", "spv"} {
+		for _, order := range [][]string{{lower, upper}, {upper, lower}} {
+			s, chain, _ := newTestService(t)
+			chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000), rpctest.PayTo(address, 2000)))
+
+			result, err := s.ScanUTXOsHybrid(order, 0, 1, mode, ScanOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Each output once, not once per address
+			if result.TotalUTXOs != 2 || result.TotalSatoshis != 3000 {
+				t.Errorf("%s %v: found %d UTXOs, %d sats, want 2 and 3000", mode, order, result.TotalUTXOs, result.TotalSatoshis)
+			}
+			AnnotateAliases(result.UTXOs, s.ScriptAliases(order))
+			for _, utxo := range result.UTXOs {
+				if utxo.Address != order[0] || len(utxo.AliasAddresses) != 1 || utxo.AliasAddresses[0] != order[1] {
+					t.Errorf("%s %v: UTXO under %s aliased by %v, want %s aliased by %s", mode, order, utxo.Address, utxo.AliasAddresses, order[0], order[1])
+				}
+			}
+		}
+	}
+}
+
+func TestScanScripts(t *testing.T) {
+	s, _, _ := newTestService(t)
+	p2pkh := rpctest.Address(testParams, "p2pkh", 1)
+	p2wpkh := rpctest.Address(testParams, "p2wpkh", 2)
+	p2tr := rpctest.Address(testParams, "p2tr", 3)
+
+	// Repeats and another encoding of a script add nothing
+	scripts, err := s.ScanScripts([]string{
+		p2pkh.EncodeAddress(),
+		p2wpkh.EncodeAddress(),
+		p2pkh.EncodeAddress(),
+		strings.ToUpper(p2wpkh.EncodeAddress()),
+		p2tr.EncodeAddress(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{scriptHex(t, p2pkh), scriptHex(t, p2wpkh), scriptHex(t, p2tr)}
+	if !reflect.DeepEqual(scripts, want) {
+		t.Errorf("got %v, want %v", scripts, want)
+	}
+
+	if _, err := s.ScanScripts([]string{p2pkh.EncodeAddress(), "bogus"}); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+// AmountFormat describes how the node reports output values in block data
+type AmountFormat string
+
+const (
+	// AmountFormatBTC means outputs only carry a decimal BTC "value" field
+	AmountFormatBTC AmountFormat = "btc"
+	// AmountFormatSats means outputs also carry an integer satoshi field
+	AmountFormatSats AmountFormat = "sats"
+)
+
+// satoshisPerBTC is the number of satoshis in one bitcoin
+const satoshisPerBTC = 100000000
+
+// scanVout is the subset of a verbose block output needed to compute amounts
+type scanVout struct {
+	Value    json.Number `json:"value"`
+	ValueSat *int64      `json:"valueSat,omitempty"` // Reported by some node builds
+	N        int         `json:"n"`
+}
+
+// ParseSatoshis converts a decimal BTC amount into satoshis without going
+// through float64, so trailing precision artifacts from different Core
+// versions (e.g. "0.30000000000000004") still round to the same value
+func ParseSatoshis(value json.Number) (int64, error) {
+	rat, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", value.String())
+	}
+
+	rat.Mul(rat, big.NewRat(satoshisPerBTC, 1))
+
+	// Round half away from zero to the nearest satoshi
+	num := new(big.Int).Set(rat.Num())
+	den := rat.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("amount %q out of range", value.String())
+	}
+
+	return quo.Int64(), nil
+}
+
+// satoshis returns the output value in satoshis using the detected amount format
+func (s *Service) satoshis(vout scanVout) (int64, error) {
+	if s.amountFormat == AmountFormatSats && vout.ValueSat != nil {
+		return *vout.ValueSat, nil
+	}
+	return ParseSatoshis(vout.Value)
+}
+
+// DetectAmountFormat probes the node to choose the amount parsing path: the
+// integer satoshi field when every output of the tip block carries one that
+// agrees with its decimal value, exact decimal parsing otherwise.
+//
+// The node version is logged but does not select the path. No Bitcoin Core
+// release reports satoshis in getblock, and the builds that add a satoshi
+// field report their own version numbers, so the block data is what tells
+// them apart. Decimal parsing is exact for every version either way.
+func (s *Service) DetectAmountFormat() (AmountFormat, error) {
+	version, err := s.rpcClient.GetNodeVersion()
+	if err != nil {
+		return s.amountFormat, fmt.Errorf("failed to get node version: %w", err)
+	}
+
+	bestHash, err := s.rpcClient.GetBestBlockHash()
+	if err != nil {
+		return s.amountFormat, fmt.Errorf("failed to get best block hash: %w", err)
+	}
+
+	blockData, err := s.rpcClient.GetBlock(bestHash, 2)
+	if err != nil {
+		return s.amountFormat, fmt.Errorf("failed to get block %s: %w", bestHash, err)
+	}
+
+	var block struct {
+		Tx []amountTx `json:"tx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		return s.amountFormat, fmt.Errorf("failed to unmarshal block %s: %w", bestHash, err)
+	}
+
+	format := AmountFormatBTC
+	if satoshiFieldsAgree(block.Tx) {
+		format = AmountFormatSats
+	}
+	s.amountFormat = format
+
+	log.Printf("Node version %d reports output amounts as %s", version, format)
+
+	return format, nil
+}
+
+// amountTx is the subset of a verbose block transaction DetectAmountFormat reads
+type amountTx struct {
+	Vout []scanVout `json:"vout"`
+}
+
+// satoshiFieldsAgree reports whether every output of the transactions has a
+// satoshi field equal to its decimal value. A field on only some outputs, or
+// one that disagrees, is not trusted for any.
+func satoshiFieldsAgree(txs []amountTx) bool {
+	outputs := 0
+	for _, tx := range txs {
+		for _, vout := range tx.Vout {
+			if vout.ValueSat == nil {
+				return false
+			}
+			sats, err := ParseSatoshis(vout.Value)
+			if err != nil || sats != *vout.ValueSat {
+				return false
+			}
+			outputs++
+		}
+	}
+	return outputs > 0
+}
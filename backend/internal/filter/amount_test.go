@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestParseSatoshis(t *testing.T) {
+	tests := []struct {
+		value string
+		sats  int64
+	}{
+		{"0.00000001", 1},
+		{"0.3", 30000000},
+		{"0.30000000000000004", 30000000}, // float artifact
+		{"0.29999999999999999", 30000000},
+		{"1e-8", 1},
+		{"50", 5000000000},
+		{"21000000.00000000", 2100000000000000},
+		{"0.000000005", 1}, // half rounds away from zero
+		{"0.0000000049", 0},
+	}
+	for _, tt := range tests {
+		sats, err := ParseSatoshis(json.Number(tt.value))
+		if err != nil || sats != tt.sats {
+			t.Errorf("%s: got %d, %v; want %d", tt.value, sats, err, tt.sats)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "1e400"} {
+		if _, err := ParseSatoshis(json.Number(value)); err == nil {
+			t.Errorf("%q: no error", value)
+		}
+	}
+}
+
+func TestDetectAmountFormat(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+
+	for _, valueSat := range []bool{false, true} {
+		s, chain, _ := newTestService(t)
+		chain.ValueSat = valueSat
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1234)))
+
+		want := AmountFormatBTC
+		if valueSat {
+			want = AmountFormatSats
+		}
+		if format, err := s.DetectAmountFormat(); err != nil || format != want {
+			t.Errorf("valueSat %v: got %s, %v; want %s", valueSat, format, err, want)
+		}
+	}
+
+	// A satoshi field on only some outputs, or one disagreeing with the
+	// decimal value, is not trusted
+	blocks := map[string]string{
+		"partial":    `{"tx":[{"vout":[{"value":0.5,"valueSat":50000000,"n":0}]},{"vout":[{"value":0.1,"n":0}]}]}`,
+		"disagrees":  `{"tx":[{"vout":[{"value":0.5,"valueSat":50000000,"n":0},{"value":0.1,"valueSat":1000000,"n":1}]}]}`,
+		"no outputs": `{"tx":[]}`,
+	}
+	for name, block := range blocks {
+		s, _, node := newTestService(t)
+		block := block
+		node.Handle("getblock", func(params []json.RawMessage) (interface{}, error) {
+			return json.RawMessage(block), nil
+		})
+		if format, err := s.DetectAmountFormat(); err != nil || format != AmountFormatBTC {
+			t.Errorf("%s: got %s, %v", name, format, err)
+		}
+	}
+}
+
+func TestScanAmountsAgreeAcrossFormats(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	values := []int64{1, 30000000, 2099999997690000, 12345678}
+
+	var results []*UTXOScanResult
+	for _, valueSat := range []bool{false, true} {
+		s, chain, _ := newTestService(t)
+		s.SetBlockFetch(BlockFetchVerbose)
+		chain.ValueSat = valueSat
+		for _, value := range values {
+			chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, value)))
+		}
+		if _, err := s.DetectAmountFormat(); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := s.ScanUTXOsHybrid([]string{address.EncodeAddress()}, 0, chain.Height(), "direct", ScanOptions{})
+		if err != nil {
+			t.Fatalf("valueSat %v: %v", valueSat, err)
+		}
+		if len(result.UTXOs) != len(values) {
+			t.Fatalf("valueSat %v: found %d UTXOs", valueSat, len(result.UTXOs))
+		}
+		for i, utxo := range result.UTXOs {
+			if utxo.Satoshis != values[i] {
+				t.Errorf("valueSat %v: UTXO %d has %d sats, want %d", valueSat, i, utxo.Satoshis, values[i])
+			}
+		}
+		results = append(results, result)
+	}
+
+	if results[0].TotalSatoshis != results[1].TotalSatoshis {
+		t.Errorf("totals differ: %d from BTC values, %d from satoshi fields", results[0].TotalSatoshis, results[1].TotalSatoshis)
+	}
+}
@@ -0,0 +1,199 @@
+package filter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// FilterAnalysis reports how often the BIP158 filters of a block range
+// matched a set of addresses without the block actually paying or spending
+// from them, overall and per address, to help size SPV address batches
+type FilterAnalysis struct {
+	StartHeight    int64 `json:"start_height"`
+	EndHeight      int64 `json:"end_height"`
+	BlocksFiltered int   `json:"blocks_filtered"`
+	BlocksMatched  int   `json:"blocks_matched"` // Blocks an SPV scan of all the addresses would fetch
+	// Matched blocks that paid or spent from none of the addresses, and
+	// their share of the blocks filtered
+	FalsePositives    int     `json:"false_positives"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+
+	Addresses []AddressFilterAnalysis `json:"addresses"` // In request order
+	Blocks    []BlockFilterAnalysis   `json:"blocks"`    // Matched blocks in height order
+}
+
+// AddressFilterAnalysis is one address's share of a FilterAnalysis
+type AddressFilterAnalysis struct {
+	Address           string  `json:"address"`
+	Matches           int     `json:"matches"`             // Blocks whose filter matched the address
+	TrueMatches       int     `json:"true_matches"`        // Of those, blocks paying or spending from it
+	FalsePositives    int     `json:"false_positives"`     // Of those, blocks that did neither
+	FalsePositiveRate float64 `json:"false_positive_rate"` // FalsePositives over the blocks filtered
+}
+
+// BlockFilterAnalysis is a block whose filter matched at least one address
+type BlockFilterAnalysis struct {
+	Height        int64    `json:"height"`
+	Hash          string   `json:"hash"`
+	Matched       []string `json:"matched"`        // Addresses the filter matched
+	Used          []string `json:"used"`           // Of those, addresses the block paid or spent from
+	FalsePositive bool     `json:"false_positive"` // No matched address was used
+}
+
+// analysisBlock is the part of a verbosity 3 block needed to tell real
+// matches from false positives: output scripts and the scripts of the
+// outputs each input spends, both of which basic filters commit to
+type analysisBlock struct {
+	Tx []struct {
+		Vin []struct {
+			Prevout *struct {
+				ScriptPubKey struct {
+					Hex string `json:"hex"`
+				} `json:"scriptPubKey"`
+			} `json:"prevout"`
+		} `json:"vin"`
+		Vout []struct {
+			ScriptPubKey struct {
+				Hex string `json:"hex"`
+			} `json:"scriptPubKey"`
+		} `json:"vout"`
+	} `json:"tx"`
+}
+
+// AnalyzeFilters runs the filter pass over [startHeight, endHeight], then
+// matches each address against every matched block's filter on its own and
+// fetches the block to check whether it really pays or spends from the
+// address. Spends are read from the block's prevouts (getblock verbosity 3,
+// Bitcoin Core 22 or later).
+func (s *Service) AnalyzeFilters(addresses []string, startHeight, endHeight int64) (*FilterAnalysis, error) {
+	if startHeight > endHeight {
+		return nil, fmt.Errorf("start height must be less than or equal to end height")
+	}
+	if endHeight-startHeight > MaxScanRange {
+		return nil, fmt.Errorf("scan range too large, max %d blocks", MaxScanRange)
+	}
+
+	addresses = uniqueAddresses(addresses)
+	scripts := make([]string, len(addresses)) // scriptPubKey hex of each address
+	tracked := make(map[string]bool, len(addresses))
+	for i, address := range addresses {
+		script, err := s.AddressToScriptPubKey(address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert address %s: %w", address, err)
+		}
+		scripts[i] = hex.EncodeToString(script)
+		tracked[scripts[i]] = true
+	}
+
+	matchedBlocks, filtered, err := s.filterBlocks(addresses, startHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]BlockFilterAnalysis, len(matchedBlocks))
+	err = runParallel(len(matchedBlocks), s.blockWorkers, func(i int) error {
+		matchedBlock := matchedBlocks[i]
+		matched := make(map[int]bool)
+		for j, address := range addresses {
+			ok, err := s.MatchAddressInFilter(address, matchedBlock.filter, matchedBlock.Hash)
+			if err != nil {
+				return fmt.Errorf("failed to match address in block %s: %w", matchedBlock.Hash, err)
+			}
+			matched[j] = ok
+		}
+
+		var blockData json.RawMessage
+		err := s.withRetry(func() error {
+			var err error
+			blockData, err = s.rpcClient.GetBlock(matchedBlock.Hash, 3)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get block %s: %w", matchedBlock.Hash, err)
+		}
+		var block analysisBlock
+		if err := json.Unmarshal(blockData, &block); err != nil {
+			return fmt.Errorf("failed to unmarshal block %s: %w", matchedBlock.Hash, err)
+		}
+
+		used := make(map[string]bool) // Tracked scripts the block pays or spends from
+		for _, tx := range block.Tx {
+			for _, vin := range tx.Vin {
+				if vin.Prevout != nil && tracked[vin.Prevout.ScriptPubKey.Hex] { // No prevout for a coinbase
+					used[vin.Prevout.ScriptPubKey.Hex] = true
+				}
+			}
+			for _, vout := range tx.Vout {
+				if tracked[vout.ScriptPubKey.Hex] {
+					used[vout.ScriptPubKey.Hex] = true
+				}
+			}
+		}
+
+		analysis := BlockFilterAnalysis{
+			Height:  matchedBlock.Height,
+			Hash:    matchedBlock.Hash,
+			Matched: []string{},
+			Used:    []string{},
+		}
+		for j, address := range addresses {
+			if !matched[j] {
+				continue
+			}
+			analysis.Matched = append(analysis.Matched, address)
+			if used[scripts[j]] {
+				analysis.Used = append(analysis.Used, address)
+			}
+		}
+		analysis.FalsePositive = len(analysis.Used) == 0
+		blocks[i] = analysis
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FilterAnalysis{
+		StartHeight:    startHeight,
+		EndHeight:      endHeight,
+		BlocksFiltered: filtered,
+		BlocksMatched:  len(blocks),
+		Addresses:      make([]AddressFilterAnalysis, len(addresses)),
+		Blocks:         blocks,
+	}
+	index := make(map[string]int, len(addresses))
+	for i, address := range addresses {
+		result.Addresses[i] = AddressFilterAnalysis{Address: address}
+		index[address] = i
+	}
+	for _, block := range blocks {
+		if block.FalsePositive {
+			result.FalsePositives++
+		}
+		used := make(map[string]bool, len(block.Used))
+		for _, address := range block.Used {
+			used[address] = true
+		}
+		for _, address := range block.Matched {
+			stats := &result.Addresses[index[address]]
+			stats.Matches++
+			if used[address] {
+				stats.TrueMatches++
+			} else {
+				stats.FalsePositives++
+			}
+		}
+	}
+	if filtered > 0 {
+		result.FalsePositiveRate = float64(result.FalsePositives) / float64(filtered)
+		for i := range result.Addresses {
+			result.Addresses[i].FalsePositiveRate = float64(result.Addresses[i].FalsePositives) / float64(filtered)
+		}
+	}
+	if result.Blocks == nil {
+		result.Blocks = []BlockFilterAnalysis{}
+	}
+
+	return result, nil
+}
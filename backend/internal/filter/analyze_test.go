@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// forgeFilter serves a filter for the block matching the addresses as well
+// as the block's own scripts, standing in for a filter false positive
+func forgeFilter(t *testing.T, node *rpctest.Node, block *rpctest.Block, addresses ...btcutil.Address) {
+	t.Helper()
+	hash, err := chainhash.NewHashFromStr(block.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := builder.WithKeyHash(hash)
+	for _, tx := range block.Msg.Transactions {
+		for _, out := range tx.TxOut {
+			b.AddEntry(out.PkScript)
+		}
+	}
+	for _, address := range addresses {
+		script, err := txscript.PayToAddrScript(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddEntry(script)
+	}
+	filter, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filterBytes, err := filter.NBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.Wrap("getblockfilter", func(next rpctest.Handler) rpctest.Handler {
+		return func(params []json.RawMessage) (interface{}, error) {
+			var requested string
+			if _, err := rpctest.Param(params, 0, &requested); err != nil || requested != block.Hash {
+				return next(params)
+			}
+			return map[string]string{"filter": hex.EncodeToString(filterBytes), "header": block.FilterHeader}, nil
+		}
+	})
+}
+
+func TestAnalyzeFiltersSeparatesFalsePositives(t *testing.T) {
+	s, chain, node := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	other := rpctest.Address(testParams, "p2pkh", 3)
+
+	fund := chain.NewTx(nil, rpctest.PayTo(a, 5000))
+	paying := chain.AddBlock(fund)
+	unrelated := chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(other, 1000)))
+	spending := chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(other, 4000)))
+	chain.AddBlock()
+	forgeFilter(t, node, unrelated, a, b)
+
+	analysis, err := s.AnalyzeFilters(encodeAddresses(a, b), 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.BlocksFiltered != 4 || analysis.BlocksMatched != 3 || analysis.FalsePositives != 1 || analysis.FalsePositiveRate != 0.25 {
+		t.Errorf("got %d filtered, %d matched, %d false positives (%v); want 4, 3, 1 (0.25)",
+			analysis.BlocksFiltered, analysis.BlocksMatched, analysis.FalsePositives, analysis.FalsePositiveRate)
+	}
+
+	wantBlocks := []BlockFilterAnalysis{
+		{Height: 1, Hash: paying.Hash, Matched: []string{a.EncodeAddress()}, Used: []string{a.EncodeAddress()}},
+		{Height: 2, Hash: unrelated.Hash, Matched: encodeAddresses(a, b), Used: []string{}, FalsePositive: true},
+		{Height: 3, Hash: spending.Hash, Matched: []string{a.EncodeAddress()}, Used: []string{a.EncodeAddress()}},
+	}
+	if !reflect.DeepEqual(analysis.Blocks, wantBlocks) {
+		t.Errorf("blocks %+v, want %+v", analysis.Blocks, wantBlocks)
+	}
+
+	wantAddresses := []AddressFilterAnalysis{
+		{Address: a.EncodeAddress(), Matches: 3, TrueMatches: 2, FalsePositives: 1, FalsePositiveRate: 0.25},
+		{Address: b.EncodeAddress(), Matches: 1, TrueMatches: 0, FalsePositives: 1, FalsePositiveRate: 0.25},
+	}
+	if !reflect.DeepEqual(analysis.Addresses, wantAddresses) {
+		t.Errorf("addresses %+v, want %+v", analysis.Addresses, wantAddresses)
+	}
+}
@@ -0,0 +1,77 @@
+package filter
+
+import "fmt"
+
+// HistoricalBalance is an address's balance as of a past block height. Only
+// outputs created from FromHeight are counted, so it equals the full balance
+// when FromHeight is 0 or precedes the address's first use.
+type HistoricalBalance struct {
+	Address       string  `json:"address"`
+	Height        int64   `json:"height"`
+	FromHeight    int64   `json:"from_height"`
+	BalanceSats   int64   `json:"balance_sats"`
+	Balance       float64 `json:"balance"` // BTC
+	UTXOCount     int     `json:"utxo_count"`
+	UTXOs         []UTXO  `json:"utxos"` // Unspent as of Height
+	BlocksScanned int     `json:"blocks_scanned"`
+}
+
+// BalanceAt computes the balance of an address at height from the blocks in
+// [fromHeight, height]. Spends are tracked by the block walk alone, so spends
+// after height are ignored; the gettxout pass, which reflects the current
+// chain state, is skipped. In spv mode only blocks matching the address's
+// filter are fetched.
+func (s *Service) BalanceAt(address string, fromHeight, height int64, mode string) (*HistoricalBalance, error) {
+	if fromHeight < 0 || fromHeight > height {
+		return nil, fmt.Errorf("from height must be between 0 and height")
+	}
+	if height-fromHeight > MaxScanRange {
+		return nil, fmt.Errorf("scan range too large, max %d blocks", MaxScanRange)
+	}
+
+	matcher, err := s.buildAddressScripts([]string{address})
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []blockRef
+	if mode == "spv" {
+		// Filters also commit to spent scripts, so spending blocks match too
+		matchedBlocks, _, err := s.filterBlocks([]string{address}, fromHeight, height)
+		if err != nil {
+			return nil, err
+		}
+		refs = matchedBlockRefs(matchedBlocks)
+	} else {
+		refs, err = s.blockRefsInRange(fromHeight, height)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	utxos, blocksScanned, err := s.scanBlocks(refs, matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &HistoricalBalance{
+		Address:       address,
+		Height:        height,
+		FromHeight:    fromHeight,
+		UTXOs:         utxos,
+		UTXOCount:     len(utxos),
+		BlocksScanned: blocksScanned,
+	}
+	if balance.UTXOs == nil {
+		balance.UTXOs = []UTXO{}
+	}
+	for _, utxo := range utxos {
+		balance.BalanceSats += utxo.Satoshis
+	}
+	if err := s.checkSupply(balance.BalanceSats); err != nil {
+		return nil, err
+	}
+	balance.Balance = float64(balance.BalanceSats) / satoshisPerBTC
+
+	return balance, nil
+}
@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestBalanceAtExcludesLaterSpends(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2tr", 9)
+
+	// Paid at heights 1, 2 and 4; the first two outputs are spent at 3 and 5
+	first := chain.NewTx(nil, rpctest.PayTo(a, 1000))
+	second := chain.NewTx(nil, rpctest.PayTo(a, 2000))
+	third := chain.NewTx(nil, rpctest.PayTo(a, 4000))
+	chain.AddBlock(first)
+	chain.AddBlock(second)
+	chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(first, 0)}, rpctest.PayTo(other, 900)))
+	chain.AddBlock(third)
+	chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(second, 0)}, rpctest.PayTo(other, 1900)))
+	// Spent in the mempool too: the current state plays no part
+	chain.AddToMempool(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(third, 0)}, rpctest.PayTo(other, 3900)))
+
+	for _, mode := range []string{"direct", "spv"} {
+		for height, want := range map[int64]struct {
+			sats  int64
+			utxos int
+		}{
+			0: {0, 0},
+			1: {1000, 1},
+			2: {3000, 2},
+			3: {2000, 1},
+			4: {6000, 2},
+			5: {4000, 1},
+		} {
+			balance, err := s.BalanceAt(a.EncodeAddress(), 0, height, mode)
+			if err != nil {
+				t.Fatalf("%s balance at %d: %v", mode, height, err)
+			}
+			if balance.BalanceSats != want.sats || balance.UTXOCount != want.utxos || len(balance.UTXOs) != want.utxos {
+				t.Errorf("%s balance at %d: %d sats in %d UTXOs, want %d in %d", mode, height, balance.BalanceSats, balance.UTXOCount, want.sats, want.utxos)
+			}
+		}
+	}
+}
+
+func TestBalanceAtFromHeight(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, 1000)))
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, 2000)))
+
+	// Outputs before from_height are not counted
+	balance, err := s.BalanceAt(a.EncodeAddress(), 2, 2, "direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.BalanceSats != 2000 || balance.BlocksScanned != 1 || balance.FromHeight != 2 {
+		t.Errorf("got %+v, want 2000 sats from one block", balance)
+	}
+
+	if _, err := s.BalanceAt(a.EncodeAddress(), 3, 2, "direct"); err == nil {
+		t.Error("from height above height accepted")
+	}
+}
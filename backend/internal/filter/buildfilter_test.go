@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// filterMatches reports whether a block's filter matches an address's script
+func filterMatches(t *testing.T, f *gcs.Filter, blockHash string, address btcutil.Address) bool {
+	t.Helper()
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := f.Match(builder.DeriveKey(hash), script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matched
+}
+
+func TestCustomFilterSkipsDust(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	dust := rpctest.Address(testParams, "p2wpkh", 1)
+	paid := rpctest.Address(testParams, "p2tr", 2)
+	atThreshold := rpctest.Address(testParams, "p2pkh", 3)
+	block := chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(dust, 300), rpctest.PayTo(paid, 10000), rpctest.PayTo(atThreshold, 546)))
+
+	custom, err := s.BuildCustomFilterFromBlock(block.Hash, 546)
+	if err != nil {
+		t.Fatalf("build custom filter: %v", err)
+	}
+	if filterMatches(t, custom, block.Hash, dust) {
+		t.Error("custom filter matches the 300 sat dust output")
+	}
+	if !filterMatches(t, custom, block.Hash, paid) {
+		t.Error("custom filter misses the 10000 sat output")
+	}
+	if !filterMatches(t, custom, block.Hash, atThreshold) {
+		t.Error("custom filter misses the output at the threshold")
+	}
+
+	// Without a threshold every output script is included
+	standard, err := s.BuildFilterFromBlock(block.Hash)
+	if err != nil {
+		t.Fatalf("build filter: %v", err)
+	}
+	for _, address := range []btcutil.Address{dust, paid, atThreshold} {
+		if !filterMatches(t, standard, block.Hash, address) {
+			t.Errorf("filter without a threshold misses %s", address)
+		}
+	}
+	if custom.N() != standard.N()-1 {
+		t.Errorf("custom filter has %d entries, want one fewer than the %d without a threshold", custom.N(), standard.N())
+	}
+}
@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/cache"
+	"spv-backend/internal/rpctest"
+)
+
+func TestScanServesFiltersFromCompressedCache(t *testing.T) {
+	s, chain, node := newTestService(t)
+	store, err := cache.NewStore(t.TempDir(), cache.CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetCache(store)
+
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2tr", 2)
+	for i := 0; i < 5; i++ {
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, int64(1000+i)), rpctest.PayTo(other, 500)))
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(other, 700)))
+	}
+
+	first, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), "spv", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	fetched := node.Calls("getblockfilter")
+	if fetched == 0 {
+		t.Fatal("no filters fetched")
+	}
+
+	// The second scan reads every filter back from the store
+	second, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), "spv", ScanOptions{})
+	if err != nil {
+		t.Fatalf("cached scan: %v", err)
+	}
+	if node.Calls("getblockfilter") != fetched {
+		t.Errorf("cached scan fetched %d filters from the node", node.Calls("getblockfilter")-fetched)
+	}
+	if second.TotalUTXOs != 5 || !reflect.DeepEqual(second.UTXOs, first.UTXOs) {
+		t.Errorf("cached scan found %+v, uncached %+v", second.UTXOs, first.UTXOs)
+	}
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// FilterComparison is the result of comparing a client filter to the node's filter
+type FilterComparison struct {
+	BlockHash  string      `json:"block_hash"`
+	Match      bool        `json:"match"`
+	NodeFilter string      `json:"node_filter"`
+	NodeHeader string      `json:"node_header"`
+	Diff       *FilterDiff `json:"diff,omitempty"` // Set on mismatch
+}
+
+// FilterDiff summarizes how two serialized filters differ
+type FilterDiff struct {
+	ClientLength    int    `json:"client_length"`     // Bytes
+	NodeLength      int    `json:"node_length"`       // Bytes
+	ClientElements  *int64 `json:"client_elements"`   // N from the compact-size prefix, if parseable
+	NodeElements    *int64 `json:"node_elements"`     // N from the compact-size prefix, if parseable
+	FirstDiffOffset int    `json:"first_diff_offset"` // First differing byte
+}
+
+// filterElementCount reads the element count N prefixed to a serialized BIP158 filter
+func filterElementCount(filter []byte) *int64 {
+	n, err := wire.ReadVarInt(bytes.NewReader(filter), 0)
+	if err != nil {
+		return nil
+	}
+	count := int64(n)
+	return &count
+}
+
+// CompareFilter compares a client-computed serialized filter against the
+// node's basic filter for the block, byte for byte
+func (s *Service) CompareFilter(blockHash string, clientFilter []byte) (*FilterComparison, error) {
+	nodeFilterHex, nodeHeader, err := s.GetFilterForBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeFilter, err := hex.DecodeString(nodeFilterHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode node filter hex: %w", err)
+	}
+
+	comparison := &FilterComparison{
+		BlockHash:  blockHash,
+		Match:      bytes.Equal(clientFilter, nodeFilter),
+		NodeFilter: nodeFilterHex,
+		NodeHeader: nodeHeader,
+	}
+
+	if !comparison.Match {
+		offset := 0
+		for offset < len(clientFilter) && offset < len(nodeFilter) && clientFilter[offset] == nodeFilter[offset] {
+			offset++
+		}
+
+		comparison.Diff = &FilterDiff{
+			ClientLength:    len(clientFilter),
+			NodeLength:      len(nodeFilter),
+			ClientElements:  filterElementCount(clientFilter),
+			NodeElements:    filterElementCount(nodeFilter),
+			FirstDiffOffset: offset,
+		}
+	}
+
+	return comparison, nil
+}
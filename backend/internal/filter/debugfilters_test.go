@@ -0,0 +1,79 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestDebugFiltersIncludedPerMatchedBlock(t *testing.T) {
+	s, chain, node := newTestService(t)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2tr", 2)
+	paid := []*rpctest.Block{
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000))),
+	}
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(other, 2000)))
+	paid = append(paid, chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 3000))))
+	addresses := encodeAddresses(address)
+
+	plain, err := s.ScanUTXOsHybrid(addresses, 1, chain.Height(), "spv", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if plain.Statistics.MatchedFilters != nil || plain.Statistics.FiltersTruncated {
+		t.Errorf("filters included without debug_filters: %+v", plain.Statistics.MatchedFilters)
+	}
+
+	debug, err := s.ScanUTXOsHybrid(addresses, 1, chain.Height(), "spv", ScanOptions{DebugFilters: true})
+	if err != nil {
+		t.Fatalf("debug scan: %v", err)
+	}
+	matched := debug.Statistics.MatchedFilters
+	if len(matched) != len(paid) || debug.Statistics.FiltersTruncated {
+		t.Fatalf("got %d filters (truncated %v), want one per paying block", len(matched), debug.Statistics.FiltersTruncated)
+	}
+	for i, f := range matched {
+		if f.Hash != paid[i].Hash || f.Height != paid[i].Height {
+			t.Errorf("filter %d is for block %d %s, want %d %s", i, f.Height, f.Hash, paid[i].Height, paid[i].Hash)
+		}
+		raw, err := node.Client().GetBlockFilter(f.Hash, "basic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want struct {
+			Filter string `json:"filter"`
+			Header string `json:"header"`
+		}
+		if err := json.Unmarshal(raw, &want); err != nil {
+			t.Fatal(err)
+		}
+		if f.Filter != want.Filter || f.Header != want.Header {
+			t.Errorf("block %d filter %s header %s, want the node's %s %s", f.Height, f.Filter, f.Header, want.Filter, want.Header)
+		}
+		if ok, err := s.MatchAddressInFilter(address.EncodeAddress(), f.Filter, f.Hash); err != nil || !ok {
+			t.Errorf("block %d filter does not match the address: %v", f.Height, err)
+		}
+	}
+}
+
+func TestDebugFiltersBounded(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < MaxDebugFilters+5; i++ {
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	}
+
+	result, err := s.ScanUTXOsHybrid(encodeAddresses(address), 1, chain.Height(), "spv", ScanOptions{DebugFilters: true})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(result.Statistics.MatchedFilters) != MaxDebugFilters || !result.Statistics.FiltersTruncated {
+		t.Errorf("got %d filters (truncated %v), want the first %d and a truncation flag",
+			len(result.Statistics.MatchedFilters), result.Statistics.FiltersTruncated, MaxDebugFilters)
+	}
+	if result.TotalUTXOs != MaxDebugFilters+5 {
+		t.Errorf("truncating filters dropped UTXOs: found %d", result.TotalUTXOs)
+	}
+}
@@ -0,0 +1,177 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxDescriptorRange is the maximum number of addresses derived per descriptor
+const MaxDescriptorRange = 1000
+
+// ErrTooManyDerivedAddresses is returned when the descriptors of one scan
+// would derive more addresses than SetMaxDerivedAddresses allows
+var ErrTooManyDerivedAddresses = errors.New("too many derived addresses")
+
+// SetMaxDerivedAddresses caps the addresses derived across all descriptors
+// of one call to ExpandDescriptors; 0 leaves only the per-descriptor cap
+func (s *Service) SetMaxDerivedAddresses(max int) {
+	s.maxDerivedAddresses = max
+}
+
+// DescriptorRange identifies a ranged output descriptor and the child indexes to derive
+type DescriptorRange struct {
+	Descriptor string `json:"descriptor"`  // Ranged descriptor with checksum, e.g. "wpkh(tpub.../0/*)#checksum"
+	RangeStart int    `json:"range_start"` // First child index (inclusive)
+	RangeEnd   int    `json:"range_end"`   // Last child index (inclusive)
+}
+
+// DerivedAddress is an address derived from a ranged descriptor
+type DerivedAddress struct {
+	Address string
+	Index   int    // Child index the wildcard was replaced with
+	Path    string // Derivation path, empty if the descriptor has several ranged keys
+}
+
+// ExpandDescriptors derives the addresses for each ranged descriptor using the
+// node's deriveaddresses RPC, so no key derivation happens in the backend.
+// The total is checked against the derivation cap before anything is derived.
+func (s *Service) ExpandDescriptors(descriptors []DescriptorRange) ([]DerivedAddress, error) {
+	if s.maxDerivedAddresses > 0 {
+		total := 0
+		for _, d := range descriptors {
+			if d.RangeEnd >= d.RangeStart {
+				total += d.RangeEnd - d.RangeStart + 1
+			}
+		}
+		if total > s.maxDerivedAddresses {
+			return nil, fmt.Errorf("%w: descriptors request %d, max %d per scan", ErrTooManyDerivedAddresses, total, s.maxDerivedAddresses)
+		}
+	}
+
+	var addresses []DerivedAddress
+	for _, d := range descriptors {
+		if d.Descriptor == "" {
+			return nil, fmt.Errorf("descriptor is required")
+		}
+		if !strings.Contains(d.Descriptor, "*") {
+			return nil, fmt.Errorf("descriptor %s is not ranged, supply its address directly", d.Descriptor)
+		}
+		if d.RangeStart < 0 || d.RangeEnd < d.RangeStart {
+			return nil, fmt.Errorf("invalid range [%d, %d] for descriptor %s", d.RangeStart, d.RangeEnd, d.Descriptor)
+		}
+		if d.RangeEnd-d.RangeStart+1 > MaxDescriptorRange {
+			return nil, fmt.Errorf("descriptor range too large, max %d addresses", MaxDescriptorRange)
+		}
+
+		derived, err := s.rpcClient.DeriveAddresses(d.Descriptor, d.RangeStart, d.RangeEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive addresses for %s: %w", d.Descriptor, err)
+		}
+		for i, address := range derived {
+			index := d.RangeStart + i
+			addresses = append(addresses, DerivedAddress{
+				Address: address,
+				Index:   index,
+				Path:    derivationPath(d.Descriptor, index),
+			})
+		}
+	}
+
+	return addresses, nil
+}
+
+// derivationPath builds the derivation path of child index of the ranged key
+// in a descriptor. With a key origin, e.g. "wpkh([d34db33f/84'/0'/0']xpub.../0/*)",
+// the path is from the master key ("m/84'/0'/0'/0/5"); without one it is
+// relative to the extended key ("0/5"), with no "m/" as the key need not be
+// a master key. Descriptors with several ranged keys have no single path and
+// return "".
+func derivationPath(descriptor string, index int) string {
+	if i := strings.Index(descriptor, "#"); i >= 0 {
+		descriptor = descriptor[:i]
+	}
+	wildcard := strings.Index(descriptor, "*")
+	if wildcard < 0 || strings.Count(descriptor, "*") != 1 {
+		return ""
+	}
+
+	// The key expression runs between the enclosing delimiters of the wildcard
+	start := strings.LastIndexAny(descriptor[:wildcard], "(,") + 1
+	end := len(descriptor)
+	if i := strings.IndexAny(descriptor[wildcard:], "),"); i >= 0 {
+		end = wildcard + i
+	}
+	key := descriptor[start:end]
+
+	var steps []string
+	if strings.HasPrefix(key, "[") {
+		closing := strings.Index(key, "]")
+		if closing < 0 {
+			return ""
+		}
+		origin := strings.Split(key[1:closing], "/")
+		steps = append([]string{"m"}, origin[1:]...) // Skip the fingerprint
+		key = key[closing+1:]
+	}
+
+	// Skip the extended key itself, then resolve the wildcard
+	for _, step := range strings.Split(key, "/")[1:] {
+		steps = append(steps, strings.Replace(step, "*", strconv.Itoa(index), 1))
+	}
+
+	return strings.Join(steps, "/")
+}
+
+// AnnotateDerivations sets the derivation path and address index of UTXOs
+// paying derived addresses
+func AnnotateDerivations(utxos []UTXO, derived map[string]DerivedAddress) {
+	for i := range utxos {
+		d, ok := derived[utxos[i].Address]
+		if !ok {
+			continue
+		}
+		index := d.Index
+		utxos[i].AddressIndex = &index
+		utxos[i].DerivationPath = d.Path
+	}
+}
+
+// DescriptorInfo describes a descriptor as analysed by the node
+type DescriptorInfo struct {
+	Descriptor     string `json:"descriptor"` // Canonical form with checksum, private keys removed
+	Checksum       string `json:"checksum"`   // Checksum of the descriptor as supplied
+	IsRange        bool   `json:"is_range"`
+	IsSolvable     bool   `json:"is_solvable"`
+	HasPrivateKeys bool   `json:"has_private_keys"`
+}
+
+// DescriptorInfo runs getdescriptorinfo, which accepts descriptors with or
+// without a checksum and returns the canonical descriptor with its checksum
+func (s *Service) DescriptorInfo(descriptor string) (*DescriptorInfo, error) {
+	result, err := s.rpcClient.GetDescriptorInfo(descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Descriptor     string `json:"descriptor"`
+		Checksum       string `json:"checksum"`
+		IsRange        bool   `json:"isrange"`
+		IsSolvable     bool   `json:"issolvable"`
+		HasPrivateKeys bool   `json:"hasprivatekeys"`
+	}
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal descriptor info: %w", err)
+	}
+
+	return &DescriptorInfo{
+		Descriptor:     info.Descriptor,
+		Checksum:       info.Checksum,
+		IsRange:        info.IsRange,
+		IsSolvable:     info.IsSolvable,
+		HasPrivateKeys: info.HasPrivateKeys,
+	}, nil
+}
@@ -0,0 +1,242 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const testXpub = "tpubD6NzVbkrYhZ4XgiXtGrdW5XDAPFCL9h7we1vwNCpn8tGbBcgfVYjXyhWo4E1xkh56hjod1RhGjxbaTLV3X4FyWuejifB9jusQ46QzG87VKp"
+
+func TestDerivationPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor string
+		index      int
+		want       string
+	}{
+		{"key origin", "wpkh([d34db33f/84'/0'/0']" + testXpub + "/0/*)", 5, "m/84'/0'/0'/0/5"},
+		{"key origin with checksum", "wpkh([d34db33f/84'/0'/0']" + testXpub + "/1/*)#abcdefgh", 7, "m/84'/0'/0'/1/7"},
+		{"no key origin", "wpkh(" + testXpub + "/0/*)", 5, "0/5"},
+		{"no key origin, hardened wildcard", "wpkh(" + testXpub + "/*')", 3, "3'"},
+		{"nested", "sh(wpkh([d34db33f/49'/1'/0']" + testXpub + "/0/*))", 2, "m/49'/1'/0'/0/2"},
+		{"several ranged keys", "wsh(multi(1," + testXpub + "/0/*," + testXpub + "/1/*))", 0, ""},
+		{"not ranged", "wpkh(" + testXpub + "/0/1)", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := derivationPath(tt.descriptor, tt.index); got != tt.want {
+				t.Errorf("derivationPath(%q, %d) = %q, want %q", tt.descriptor, tt.index, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanAnnotatesDerivationPaths(t *testing.T) {
+	s, chain, node := newTestService(t)
+	// The node derives child i of the descriptor as test address 100+i
+	node.Handle("deriveaddresses", func(params []json.RawMessage) (interface{}, error) {
+		var bounds []int
+		if _, err := rpctest.Param(params, 1, &bounds); err != nil {
+			return nil, err
+		}
+		var addresses []string
+		for i := bounds[0]; i <= bounds[1]; i++ {
+			addresses = append(addresses, rpctest.Address(testParams, "p2wpkh", byte(100+i)).EncodeAddress())
+		}
+		return addresses, nil
+	})
+
+	chain.AddBlock(chain.NewTx(nil,
+		rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 102), 1000),
+		rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 105), 2000)))
+	chain.AddBlock()
+
+	descriptor := "wpkh([d34db33f/84'/1'/0']" + testXpub + "/0/*)"
+	derived, err := s.ExpandDescriptors([]DescriptorRange{{Descriptor: descriptor, RangeStart: 0, RangeEnd: 9}})
+	if err != nil {
+		t.Fatalf("expand descriptor: %v", err)
+	}
+	byAddress := make(map[string]DerivedAddress, len(derived))
+	addresses := make([]string, len(derived))
+	for i, d := range derived {
+		byAddress[d.Address] = d
+		addresses[i] = d.Address
+	}
+
+	result, err := s.ScanUTXOsHybrid(addresses, 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(result.UTXOs) != 2 {
+		t.Fatalf("scan found %d UTXOs, want 2", len(result.UTXOs))
+	}
+	AnnotateDerivations(result.UTXOs, byAddress)
+
+	want := map[int64]string{1000: "m/84'/1'/0'/0/2", 2000: "m/84'/1'/0'/0/5"}
+	wantIndex := map[int64]int{1000: 2, 2000: 5}
+	for _, utxo := range result.UTXOs {
+		if utxo.DerivationPath != want[utxo.Satoshis] {
+			t.Errorf("UTXO of %d sats has path %q, want %q", utxo.Satoshis, utxo.DerivationPath, want[utxo.Satoshis])
+		}
+		if utxo.AddressIndex == nil || *utxo.AddressIndex != wantIndex[utxo.Satoshis] {
+			t.Errorf("UTXO of %d sats has address index %v, want %d", utxo.Satoshis, utxo.AddressIndex, wantIndex[utxo.Satoshis])
+		}
+	}
+}
+
+// bip84Xpub is the account 0 key of the BIP84 test vectors (mnemonic
+// "abandon abandon ... about"), as an xpub
+const bip84Xpub = "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+
+func TestExpandDescriptorsMatchesBIP84Vectors(t *testing.T) {
+	chain := rpctest.NewChain(&chaincfg.MainNetParams)
+	node := rpctest.NewNode(t, chain)
+	s := NewService(node.Client(), &chaincfg.MainNetParams)
+
+	derived, err := s.ExpandDescriptors([]DescriptorRange{
+		{Descriptor: "wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/0/*)", RangeStart: 0, RangeEnd: 1},
+		{Descriptor: "wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/1/*)", RangeStart: 0, RangeEnd: 0},
+	})
+	if err != nil {
+		t.Fatalf("expand descriptors: %v", err)
+	}
+	want := []DerivedAddress{
+		{Address: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", Index: 0, Path: "m/84'/0'/0'/0/0"},
+		{Address: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g", Index: 1, Path: "m/84'/0'/0'/0/1"},
+		{Address: "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el", Index: 0, Path: "m/84'/0'/0'/1/0"},
+	}
+	if !reflect.DeepEqual(derived, want) {
+		t.Errorf("derived %+v, want %+v", derived, want)
+	}
+
+	// A range not starting at 0 keeps the child indexes
+	derived, err = s.ExpandDescriptors([]DescriptorRange{{Descriptor: "wpkh(" + bip84Xpub + "/0/*)", RangeStart: 1, RangeEnd: 1}})
+	if err != nil {
+		t.Fatalf("expand descriptor from index 1: %v", err)
+	}
+	if len(derived) != 1 || derived[0].Address != want[1].Address || derived[0].Index != 1 {
+		t.Errorf("derived %+v from index 1, want %s at index 1", derived, want[1].Address)
+	}
+}
+
+func TestExpandDescriptorsRejectsBadRanges(t *testing.T) {
+	s, _, node := newTestService(t)
+	ranged := "wpkh(" + testXpub + "/0/*)"
+	tests := []struct {
+		name  string
+		input DescriptorRange
+		want  string
+	}{
+		{"missing", DescriptorRange{RangeEnd: 1}, "descriptor is required"},
+		{"not ranged", DescriptorRange{Descriptor: "wpkh(" + testXpub + "/0/1)", RangeEnd: 1}, "not ranged"},
+		{"negative start", DescriptorRange{Descriptor: ranged, RangeStart: -1, RangeEnd: 1}, "invalid range"},
+		{"end before start", DescriptorRange{Descriptor: ranged, RangeStart: 5, RangeEnd: 4}, "invalid range"},
+		{"too large", DescriptorRange{Descriptor: ranged, RangeEnd: MaxDescriptorRange}, "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ExpandDescriptors([]DescriptorRange{tt.input})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+	if calls := node.Calls("deriveaddresses"); calls != 0 {
+		t.Errorf("bad ranges reached the node %d times", calls)
+	}
+}
+
+func TestDescriptorInfoAddsChecksum(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	// BIP380's test vector pins the checksum independently of the node
+	info, err := s.DescriptorInfo("raw(deadbeef)")
+	if err != nil {
+		t.Fatalf("descriptor info: %v", err)
+	}
+	if info.Checksum != "89f8spxm" || info.Descriptor != "raw(deadbeef)#89f8spxm" {
+		t.Errorf("got %s with checksum %s, want raw(deadbeef)#89f8spxm", info.Descriptor, info.Checksum)
+	}
+
+	ranged := "wpkh(" + testXpub + "/0/*)"
+	info, err = s.DescriptorInfo(ranged)
+	if err != nil {
+		t.Fatalf("descriptor info: %v", err)
+	}
+	if info.Descriptor != ranged+"#"+info.Checksum || len(info.Checksum) != 8 || !info.IsRange || !info.IsSolvable {
+		t.Errorf("got %+v, want %s with a checksum, ranged and solvable", info, ranged)
+	}
+
+	// The canonical form with its checksum is accepted back as is
+	again, err := s.DescriptorInfo(info.Descriptor)
+	if err != nil || again.Descriptor != info.Descriptor {
+		t.Errorf("checksummed descriptor: got %+v, %v", again, err)
+	}
+}
+
+func TestDescriptorInfoRejectsWrongChecksum(t *testing.T) {
+	s, _, _ := newTestService(t)
+	var rpcErr *rpc.RPCError
+	if _, err := s.DescriptorInfo("raw(deadbeef)#89f8spxn"); !errors.As(err, &rpcErr) {
+		t.Errorf("got %v, want the node's checksum error", err)
+	}
+}
+
+func TestExpandDescriptorsDerivationCap(t *testing.T) {
+	s, _, node := newTestService(t)
+	s.SetMaxDerivedAddresses(1500)
+	node.Handle("deriveaddresses", func(params []json.RawMessage) (interface{}, error) {
+		var bounds []int
+		if _, err := rpctest.Param(params, 1, &bounds); err != nil {
+			return nil, err
+		}
+		addresses := make([]string, 0, bounds[1]-bounds[0]+1)
+		for i := bounds[0]; i <= bounds[1]; i++ {
+			addresses = append(addresses, rpctest.Address(testParams, "p2wpkh", byte(i)).EncodeAddress())
+		}
+		return addresses, nil
+	})
+	receive := "wpkh(" + testXpub + "/0/*)"
+	change := "wpkh(" + testXpub + "/1/*)"
+
+	// Each descriptor is within its own cap, together they are over
+	_, err := s.ExpandDescriptors([]DescriptorRange{
+		{Descriptor: receive, RangeStart: 0, RangeEnd: 999},
+		{Descriptor: change, RangeStart: 0, RangeEnd: 999},
+	})
+	if !errors.Is(err, ErrTooManyDerivedAddresses) {
+		t.Fatalf("got %v, want ErrTooManyDerivedAddresses", err)
+	}
+	if !strings.Contains(err.Error(), "2000") || !strings.Contains(err.Error(), "1500") {
+		t.Errorf("error %q does not give the requested count and the cap", err)
+	}
+	if calls := node.Calls("deriveaddresses"); calls != 0 {
+		t.Errorf("deriveaddresses called %d times before the cap was checked", calls)
+	}
+
+	// Exactly at the cap is allowed
+	derived, err := s.ExpandDescriptors([]DescriptorRange{
+		{Descriptor: receive, RangeStart: 0, RangeEnd: 999},
+		{Descriptor: change, RangeStart: 500, RangeEnd: 999},
+	})
+	if err != nil || len(derived) != 1500 {
+		t.Errorf("got %d addresses, %v; want 1500", len(derived), err)
+	}
+
+	// 0 leaves only the per-descriptor cap
+	s.SetMaxDerivedAddresses(0)
+	if _, err := s.ExpandDescriptors([]DescriptorRange{
+		{Descriptor: receive, RangeStart: 0, RangeEnd: 999},
+		{Descriptor: change, RangeStart: 0, RangeEnd: 999},
+	}); err != nil {
+		t.Errorf("uncapped expansion: %v", err)
+	}
+}
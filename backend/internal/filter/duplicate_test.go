@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Before BIP30 a transaction could be mined again with the same txid; the
+// later copy overwrote the earlier one's outputs, which were lost. Mining
+// the same transaction twice reproduces that on the test chain.
+func TestScanDuplicateTxIDKeepsLaterInstance(t *testing.T) {
+	for _, mode := range []string{"direct", "spv"} {
+		t.Run(mode, func(t *testing.T) {
+			s, chain, _ := newTestService(t)
+			a := rpctest.Address(testParams, "p2wpkh", 1)
+			other := rpctest.Address(testParams, "p2pkh", 9)
+
+			dup := chain.NewTx(nil, rpctest.PayTo(a, 1000))
+			chain.AddBlock(dup)
+			chain.AddBlock()
+			later := chain.AddBlock(dup)
+
+			result, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), mode, ScanOptions{IncludeSpent: true})
+			if err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if result.TotalUTXOs != 1 || result.TotalSatoshis != 1000 {
+				t.Fatalf("found %d UTXOs, %d sats, want the one live instance", result.TotalUTXOs, result.TotalSatoshis)
+			}
+			if got := result.UTXOs[0]; got.BlockHash != later.Hash || got.Height != later.Height {
+				t.Errorf("UTXO from block %d %s, want the later instance at %d", got.Height, got.BlockHash, later.Height)
+			}
+			if len(result.SpentOutputs) != 0 {
+				t.Errorf("overwritten instance reported spent: %+v", result.SpentOutputs)
+			}
+
+			// A spend consumes the live instance; the overwritten one is gone
+			// and does not come back as unspent
+			spend := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(dup, 0)}, rpctest.PayTo(other, 900))
+			spentIn := chain.AddBlock(spend)
+
+			result, err = s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), mode, ScanOptions{IncludeSpent: true})
+			if err != nil {
+				t.Fatalf("scan after spend: %v", err)
+			}
+			if result.TotalUTXOs != 0 {
+				t.Errorf("found %d UTXOs after the spend, want 0: %+v", result.TotalUTXOs, result.UTXOs)
+			}
+			if len(result.SpentOutputs) != 1 {
+				t.Fatalf("%d spent outputs, want 1: %+v", len(result.SpentOutputs), result.SpentOutputs)
+			}
+			got := result.SpentOutputs[0]
+			if got.BlockHash != later.Hash || got.SpentByTxID != spend.TxHash().String() || got.SpentAtHeight != spentIn.Height {
+				t.Errorf("spent output %+v, want the later instance spent by %s at %d", got, spend.TxHash(), spentIn.Height)
+			}
+		})
+	}
+}
+
+func TestScanDuplicateTxIDAfterSpend(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2pkh", 9)
+
+	// Spent between the two instances: the second is a new, unspent output
+	dup := chain.NewTx(nil, rpctest.PayTo(a, 1000))
+	chain.AddBlock(dup)
+	chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(dup, 0)}, rpctest.PayTo(other, 900)))
+	later := chain.AddBlock(dup)
+
+	result, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), "direct", ScanOptions{IncludeSpent: true})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if result.TotalUTXOs != 1 || result.UTXOs[0].BlockHash != later.Hash {
+		t.Errorf("UTXOs %+v, want the instance in block %d", result.UTXOs, later.Height)
+	}
+	if len(result.SpentOutputs) != 1 || result.SpentOutputs[0].Height != 1 {
+		t.Errorf("spent outputs %+v, want the first instance", result.SpentOutputs)
+	}
+}
+
+func TestScanIncrementalDuplicateTxIDReplacesPrevious(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+
+	dup := chain.NewTx(nil, rpctest.PayTo(a, 1000))
+	chain.AddBlock(dup)
+	first, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	from := chain.Height() + 1
+	later := chain.AddBlock(dup)
+
+	result, err := s.ScanIncremental(encodeAddresses(a), first.UTXOs, from, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatalf("incremental scan: %v", err)
+	}
+	if result.TotalUTXOs != 1 || result.UTXOs[0].BlockHash != later.Hash {
+		t.Errorf("UTXOs %+v, want only the instance in block %d", result.UTXOs, later.Height)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].BlockHash != first.UTXOs[0].BlockHash {
+		t.Errorf("removed %+v, want the overwritten instance", result.Removed)
+	}
+}
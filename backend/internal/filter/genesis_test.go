@@ -0,0 +1,48 @@
+package filter
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+)
+
+// matchAll tracks every output script
+type matchAll struct{}
+
+func (matchAll) Match(scriptHex string) (string, bool) { return scriptHex, true }
+
+func TestScanFromGenesis(t *testing.T) {
+	// The genesis coinbase pays a P2PK script; its key as an address
+	// selects exactly that script
+	genesisScript := testParams.GenesisBlock.Transactions[0].TxOut[0].PkScript
+	genesisKey := hex.EncodeToString(genesisScript[1 : len(genesisScript)-1])
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+
+	for _, mode := range []string{"direct", "spv"} {
+		for _, skip := range []bool{false, true} {
+			s, chain, _ := newTestService(t)
+			s.SetSkipVerification(skip)
+			chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+
+			result, err := s.ScanUTXOsHybrid([]string{genesisKey, address.EncodeAddress()}, 0, chain.Height(), mode, ScanOptions{})
+			if err != nil {
+				t.Fatalf("%s scan from height 0 (skip verification %v): %v", mode, skip, err)
+			}
+			if result.TotalUTXOs != 1 || result.UTXOs[0].Height != 1 || result.TotalSatoshis != 1000 {
+				t.Errorf("%s scan from height 0 (skip verification %v) found %+v, want only the height 1 output", mode, skip, result.UTXOs)
+			}
+		}
+	}
+
+	// A matcher taking every script still leaves the genesis coinbase out
+	s, chain, _ := newTestService(t)
+	s.SetSkipVerification(true)
+	result, err := s.ScanBlocksMatching(matchAll{}, 0, chain.Height(), ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan of the genesis block: %v", err)
+	}
+	if result.TotalUTXOs != 0 {
+		t.Errorf("scan of the genesis block found %+v", result.UTXOs)
+	}
+}
@@ -0,0 +1,32 @@
+package filter
+
+// AddressGroup is the UTXOs of one address with its balance
+type AddressGroup struct {
+	UTXOs         []UTXO `json:"utxos"`
+	ConfirmedSats int64  `json:"confirmed_sats"`
+	TotalSats     int64  `json:"total_sats"` // Including unconfirmed outputs
+	UTXOCount     int    `json:"utxo_count"`
+}
+
+// GroupByAddress moves the result's UTXOs into per-address groups, keeping
+// the result's order within each group. Totals of the result are unchanged,
+// and its flat UTXO list is left empty.
+func (r *UTXOScanResult) GroupByAddress() {
+	groups := make(map[string]*AddressGroup)
+	for _, utxo := range r.UTXOs {
+		group, ok := groups[utxo.Address]
+		if !ok {
+			group = &AddressGroup{}
+			groups[utxo.Address] = group
+		}
+		group.UTXOs = append(group.UTXOs, utxo)
+		group.TotalSats += utxo.Satoshis
+		if utxo.Confirmations > 0 {
+			group.ConfirmedSats += utxo.Satoshis
+		}
+		group.UTXOCount++
+	}
+
+	r.ByAddress = groups
+	r.UTXOs = []UTXO{}
+}
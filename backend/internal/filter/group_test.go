@@ -0,0 +1,29 @@
+package filter
+
+import "testing"
+
+func TestGroupByAddressSeparatesUnconfirmed(t *testing.T) {
+	result := &UTXOScanResult{}
+	result.SetUTXOs([]UTXO{
+		{TxID: "a1", Address: "a", Satoshis: 1000, Confirmations: 6},
+		{TxID: "b1", Address: "b", Satoshis: 2000, Confirmations: 1},
+		{TxID: "a2", Address: "a", Satoshis: 500, Confirmations: 0},
+		{TxID: "a3", Address: "a", Satoshis: 250, Confirmations: 2},
+	})
+	result.GroupByAddress()
+
+	a := result.ByAddress["a"]
+	if a == nil || a.UTXOCount != 3 || a.ConfirmedSats != 1250 || a.TotalSats != 1750 {
+		t.Fatalf("group a: %+v, want 3 UTXOs, 1250 of 1750 sats confirmed", a)
+	}
+	// Order within a group follows the result
+	if a.UTXOs[0].TxID != "a1" || a.UTXOs[1].TxID != "a2" || a.UTXOs[2].TxID != "a3" {
+		t.Errorf("group a order %+v", a.UTXOs)
+	}
+	if b := result.ByAddress["b"]; b == nil || b.UTXOCount != 1 || b.ConfirmedSats != 2000 {
+		t.Errorf("group b: %+v", b)
+	}
+	if result.TotalUTXOs != 4 || result.TotalSatoshis != 3750 || len(result.UTXOs) != 0 {
+		t.Errorf("result totals %d UTXOs, %d sats, %d listed", result.TotalUTXOs, result.TotalSatoshis, len(result.UTXOs))
+	}
+}
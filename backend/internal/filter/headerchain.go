@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MaxHeaderChainLength caps the number of headers in one header chain
+const MaxHeaderChainLength = 2000
+
+// headerBatchSize is the number of getblockhash/getblockheader calls sent per batch request
+const headerBatchSize = 500
+
+// ErrHeaderChainChanged is returned when the active chain reorganized while
+// a header chain was being fetched, so its headers do not connect
+var ErrHeaderChainChanged = errors.New("chain changed while fetching headers, retry")
+
+// ChainHeader is a serialized block header and its position in the chain
+type ChainHeader struct {
+	Height int64  `json:"height"`
+	Hash   string `json:"hash"`
+	Header string `json:"header"` // 80-byte serialized header, hex encoded
+}
+
+// HeaderChain returns the headers of the active chain from startHeight to
+// endHeight inclusive, in ascending order. Each header's previous block hash
+// is checked against the header before it, so the result connects the two
+// heights without the client trusting the server for anything in between.
+func (s *Service) HeaderChain(startHeight, endHeight int64) ([]ChainHeader, error) {
+	if startHeight < 0 || startHeight > endHeight {
+		return nil, fmt.Errorf("invalid header range %d-%d", startHeight, endHeight)
+	}
+	if endHeight-startHeight+1 > MaxHeaderChainLength {
+		return nil, fmt.Errorf("header chain too long: %d headers (max %d)", endHeight-startHeight+1, MaxHeaderChainLength)
+	}
+
+	params := make([][]interface{}, 0, endHeight-startHeight+1)
+	for height := startHeight; height <= endHeight; height++ {
+		params = append(params, []interface{}{height})
+	}
+	hashes, err := s.batchStrings("getblockhash", params)
+	if err != nil {
+		return nil, err
+	}
+
+	params = params[:0]
+	for _, hash := range hashes {
+		params = append(params, []interface{}{hash, false})
+	}
+	rawHeaders, err := s.batchStrings("getblockheader", params)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]ChainHeader, len(rawHeaders))
+	var prev *wire.BlockHeader
+	for i, rawHeader := range rawHeaders {
+		headerBytes, err := hex.DecodeString(rawHeader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode header %s: %w", hashes[i], err)
+		}
+		var header wire.BlockHeader
+		if err := header.Deserialize(bytes.NewReader(headerBytes)); err != nil {
+			return nil, fmt.Errorf("failed to parse header %s: %w", hashes[i], err)
+		}
+
+		// A reorg between the two batches leaves hashes from different chains
+		hash := header.BlockHash()
+		if hash.String() != hashes[i] {
+			return nil, ErrHeaderChainChanged
+		}
+		if prev != nil && header.PrevBlock != prev.BlockHash() {
+			return nil, ErrHeaderChainChanged
+		}
+		prev = &header
+
+		chain[i] = ChainHeader{
+			Height: startHeight + int64(i),
+			Hash:   hashes[i],
+			Header: rawHeader,
+		}
+	}
+
+	return chain, nil
+}
+
+// batchStrings calls method once per params entry, in batches, and returns
+// the string results in the same order
+func (s *Service) batchStrings(method string, params [][]interface{}) ([]string, error) {
+	results := make([]string, len(params))
+	for start := 0; start < len(params); start += headerBatchSize {
+		end := start + headerBatchSize
+		if end > len(params) {
+			end = len(params)
+		}
+
+		requests := make([]rpc.RPCRequest, 0, end-start)
+		for i := start; i < end; i++ {
+			requests = append(requests, rpc.RPCRequest{
+				Jsonrpc: "1.0",
+				Method:  method,
+				Params:  params[i],
+				ID:      i,
+			})
+		}
+
+		responses, err := s.rpcClient.BatchCall(requests)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call %s: %w", method, err)
+		}
+		if len(responses) != len(requests) {
+			return nil, fmt.Errorf("%s: expected %d responses, got %d", method, len(requests), len(responses))
+		}
+
+		for _, resp := range responses {
+			if resp.ID < start || resp.ID >= end {
+				return nil, fmt.Errorf("%s: unexpected response id %d", method, resp.ID)
+			}
+			if resp.Error != nil {
+				return nil, fmt.Errorf("%s failed: %s", method, resp.Error.Message)
+			}
+			if err := json.Unmarshal(resp.Result, &results[resp.ID]); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+			}
+		}
+	}
+
+	return results, nil
+}
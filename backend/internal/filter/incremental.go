@@ -0,0 +1,178 @@
+package filter
+
+import (
+	"fmt"
+)
+
+// IncrementalScanResult is the updated UTXO set after scanning new blocks
+type IncrementalScanResult struct {
+	*UTXOScanResult
+	FromHeight int64  `json:"from_height"`
+	ToHeight   int64  `json:"to_height"` // Last block scanned
+	Added      int    `json:"added"`     // New UTXOs found in the range
+	Removed    []UTXO `json:"removed"`   // Previous UTXOs spent in the range or since
+
+	// Set by the API: the tip when the scan started, and whether ToHeight
+	// reached it. Otherwise the set is current up to ToHeight only; scan
+	// again from ToHeight+1 with the returned UTXOs to catch up.
+	TipHeight int64 `json:"tip_height"`
+	CaughtUp  bool  `json:"caught_up"`
+}
+
+// IncrementalAddresses returns the addresses an incremental scan tracks:
+// the given addresses followed by any other address of the previous UTXOs
+func IncrementalAddresses(addresses []string, previous []UTXO) []string {
+	tracked := append([]string(nil), addresses...)
+	seen := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		seen[addr] = true
+	}
+	for _, utxo := range previous {
+		if utxo.Address != "" && !seen[utxo.Address] {
+			seen[utxo.Address] = true
+			tracked = append(tracked, utxo.Address)
+		}
+	}
+	return tracked
+}
+
+// ScanIncremental updates a previously scanned UTXO set with the blocks in
+// [fromHeight, toHeight]: outputs created in the range are added and previous
+// UTXOs spent in the range are removed, so history is not rescanned.
+// The addresses of the previous UTXOs are tracked too, so their spends are
+// seen even if the caller omits them from addresses.
+func (s *Service) ScanIncremental(addresses []string, previous []UTXO, fromHeight, toHeight int64, mode string, opts ScanOptions) (*IncrementalScanResult, error) {
+	if fromHeight > toHeight {
+		return nil, fmt.Errorf("from height must be less than or equal to the tip")
+	}
+
+	if toHeight-fromHeight > MaxScanRange {
+		return nil, fmt.Errorf("scan range too large, max %d blocks", MaxScanRange)
+	}
+
+	startTime := getCurrentTimeMs()
+
+	tracked := IncrementalAddresses(addresses, previous)
+	addressScripts, err := s.buildAddressScripts(tracked)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pick the blocks to fetch. Basic filters also commit to the scripts of
+	// spent outputs, so a block spending a previous UTXO matches its address.
+	var refs []blockRef
+	blocksFiltered := 0
+	if mode == "spv" {
+		matchedBlocks, total, err := s.filterBlocks(tracked, fromHeight, toHeight)
+		if err != nil {
+			return nil, err
+		}
+		blocksFiltered = total
+		refs = matchedBlockRefs(matchedBlocks)
+	} else {
+		mode = "direct"
+		refs, err = s.blockRefsInRange(fromHeight, toHeight)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blocks, err := s.fetchBlockOutputs(refs, addressScripts)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make(map[string]bool)
+	created := make(map[string]string) // "txid:vout" -> hash of the block creating it
+	for _, block := range blocks {
+		for _, spend := range block.spends {
+			spent[spend] = true
+		}
+		for _, utxo := range block.utxos {
+			created[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)] = utxo.BlockHash
+		}
+	}
+
+	// Keep previous UTXOs not spent in the range, then append new ones.
+	// A previous UTXO whose txid is repeated in the range (pre-BIP30
+	// duplicate coinbase) was overwritten, so it is removed as well.
+	var candidates []UTXO
+	var removed []UTXO
+	known := make(map[string]string, len(previous)) // "txid:vout" -> block hash
+	for _, utxo := range previous {
+		outpoint := fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)
+		if _, exists := known[outpoint]; exists {
+			continue
+		}
+		known[outpoint] = utxo.BlockHash
+		blockHash, recreated := created[outpoint]
+		if spent[outpoint] || (recreated && !sameBlock(blockHash, utxo.BlockHash)) {
+			removed = append(removed, utxo)
+			continue
+		}
+		candidates = append(candidates, utxo)
+	}
+
+	added := 0
+	for _, utxo := range resolveSpentOutputs(blocks) {
+		blockHash, exists := known[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)]
+		if exists && sameBlock(blockHash, utxo.BlockHash) {
+			continue
+		}
+		candidates = append(candidates, utxo)
+		added++
+	}
+
+	// Verify the merged set so spends outside the range (e.g. in the mempool)
+	// are also reflected
+	scanResult, err := s.verifyUTXOs(candidates, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.BalanceOnly {
+		verified := make(map[string]bool, len(scanResult.UTXOs))
+		for _, utxo := range scanResult.UTXOs {
+			verified[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)] = true
+		}
+		for _, utxo := range candidates {
+			outpoint := fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)
+			if _, exists := known[outpoint]; !verified[outpoint] && exists {
+				removed = append(removed, utxo)
+			}
+		}
+	}
+
+	endTime := getCurrentTimeMs()
+	filterHitRate := 0.0
+	if blocksFiltered > 0 {
+		filterHitRate = float64(len(blocks)) / float64(blocksFiltered)
+	}
+
+	scanResult.BlocksScanned = len(blocks)
+	scanResult.AddressCount = len(tracked)
+	scanResult.Statistics = &ScanStatistics{
+		Mode:           mode,
+		BlocksFiltered: blocksFiltered,
+		BlocksScanned:  len(blocks),
+		FilterHitRate:  filterHitRate,
+		ScanTimeMs:     endTime - startTime,
+	}
+
+	if removed == nil {
+		removed = []UTXO{}
+	}
+
+	return &IncrementalScanResult{
+		UTXOScanResult: scanResult,
+		FromHeight:     fromHeight,
+		ToHeight:       toHeight,
+		Added:          added,
+		Removed:        removed,
+	}, nil
+}
+
+// sameBlock reports whether two UTXO block hashes refer to the same output
+// instance; a previous UTXO without a block hash is assumed to match
+func sameBlock(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Matcher selects the outputs a block scan collects by their scriptPubKey
+type Matcher interface {
+	// Match reports whether an output script (hex) is tracked, and the
+	// address its UTXOs are reported under ("" if it has none)
+	Match(scriptHex string) (address string, ok bool)
+}
+
+// ScriptSetMatcher matches an exact set of scripts, e.g. those of an address
+// list or an expanded descriptor range
+type ScriptSetMatcher map[string]string // scriptPubKey hex -> address
+
+// Match implements Matcher
+func (m ScriptSetMatcher) Match(scriptHex string) (string, bool) {
+	address, ok := m[scriptHex]
+	return address, ok
+}
+
+// ScriptTemplate matches the scripts made of Prefix, DataLen bytes of any
+// value, and Suffix, e.g. every P2WPKH script. Data, if set, narrows the
+// family by the variable part.
+type ScriptTemplate struct {
+	Prefix  []byte
+	DataLen int
+	Suffix  []byte
+	Data    func(data []byte) bool
+}
+
+// Standard output script templates
+var (
+	TemplateP2PKH  = ScriptTemplate{Prefix: []byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20}, DataLen: 20, Suffix: []byte{txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG}}
+	TemplateP2SH   = ScriptTemplate{Prefix: []byte{txscript.OP_HASH160, txscript.OP_DATA_20}, DataLen: 20, Suffix: []byte{txscript.OP_EQUAL}}
+	TemplateP2WPKH = ScriptTemplate{Prefix: []byte{txscript.OP_0, txscript.OP_DATA_20}, DataLen: 20}
+	TemplateP2WSH  = ScriptTemplate{Prefix: []byte{txscript.OP_0, txscript.OP_DATA_32}, DataLen: 32}
+	TemplateP2TR   = ScriptTemplate{Prefix: []byte{txscript.OP_1, txscript.OP_DATA_32}, DataLen: 32}
+)
+
+// match reports whether script fits the template
+func (t ScriptTemplate) match(script []byte) bool {
+	if len(script) != len(t.Prefix)+t.DataLen+len(t.Suffix) {
+		return false
+	}
+	if !bytes.HasPrefix(script, t.Prefix) || !bytes.HasSuffix(script, t.Suffix) {
+		return false
+	}
+	return t.Data == nil || t.Data(script[len(t.Prefix):len(t.Prefix)+t.DataLen])
+}
+
+// TemplateMatcher matches every script fitting one of its templates, without
+// enumerating the scripts up front. Matches are reported under the address
+// the script encodes on the matcher's network.
+type TemplateMatcher struct {
+	templates   []ScriptTemplate
+	chainParams *chaincfg.Params
+}
+
+// NewTemplateMatcher creates a matcher for the given script templates
+func NewTemplateMatcher(chainParams *chaincfg.Params, templates ...ScriptTemplate) *TemplateMatcher {
+	return &TemplateMatcher{templates: templates, chainParams: chainParams}
+}
+
+// Match implements Matcher
+func (m *TemplateMatcher) Match(scriptHex string) (string, bool) {
+	script, err := hex.DecodeString(scriptHex)
+	if err != nil {
+		return "", false
+	}
+
+	for _, template := range m.templates {
+		if !template.match(script) {
+			continue
+		}
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, m.chainParams)
+		if err != nil || len(addrs) != 1 {
+			return "", true
+		}
+		return addrs[0].EncodeAddress(), true
+	}
+	return "", false
+}
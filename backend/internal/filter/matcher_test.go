@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// scriptHex is an address's scriptPubKey in hex
+func scriptHex(t *testing.T, address btcutil.Address) string {
+	t.Helper()
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(script)
+}
+
+func TestStandardTemplates(t *testing.T) {
+	templates := map[string]ScriptTemplate{
+		"p2pkh":  TemplateP2PKH,
+		"p2sh":   TemplateP2SH,
+		"p2wpkh": TemplateP2WPKH,
+		"p2wsh":  TemplateP2WSH,
+		"p2tr":   TemplateP2TR,
+	}
+	for name, template := range templates {
+		matcher := NewTemplateMatcher(testParams, template)
+		for kind := range templates {
+			address := rpctest.Address(testParams, kind, 1)
+			got, ok := matcher.Match(scriptHex(t, address))
+			if want := kind == name; ok != want {
+				t.Errorf("%s template matched a %s script: %v, want %v", name, kind, ok, want)
+			} else if ok && got != address.EncodeAddress() {
+				t.Errorf("%s template reported %s, want %s", name, got, address)
+			}
+		}
+	}
+
+	// Scripts of the wrong length or not hex do not fit
+	matcher := NewTemplateMatcher(testParams, TemplateP2WPKH)
+	for _, script := range []string{"0014", "0014" + hex.EncodeToString(make([]byte, 21)), "zz"} {
+		if _, ok := matcher.Match(script); ok {
+			t.Errorf("P2WPKH template matched %q", script)
+		}
+	}
+}
+
+func TestScanWithTemplateMatcher(t *testing.T) {
+	s, chain, _ := newTestService(t)
+
+	// A family of P2WPKH scripts no address list enumerates, among other
+	// script types
+	var family []string
+	for seed := byte(1); seed <= 4; seed++ {
+		address := rpctest.Address(testParams, "p2wpkh", seed)
+		family = append(family, address.EncodeAddress())
+		chain.AddBlock(chain.NewTx(nil,
+			rpctest.PayTo(address, 1000*int64(seed)),
+			rpctest.PayTo(rpctest.Address(testParams, "p2pkh", seed), 500),
+			rpctest.PayTo(rpctest.Address(testParams, "p2tr", seed), 700)))
+	}
+
+	result, err := s.ScanBlocksMatching(NewTemplateMatcher(testParams, TemplateP2WPKH), 1, chain.Height(), ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var found []string
+	for _, utxo := range result.UTXOs {
+		found = append(found, utxo.Address)
+	}
+	sort.Strings(found)
+	sort.Strings(family)
+	if len(found) != len(family) || result.TotalSatoshis != 10000 {
+		t.Fatalf("found %v (%d sats), want %v", found, result.TotalSatoshis, family)
+	}
+	for i := range found {
+		if found[i] != family[i] {
+			t.Errorf("found %v, want %v", found, family)
+			break
+		}
+	}
+
+	// Data narrows the family to the programs it accepts
+	first := rpctest.Address(testParams, "p2wpkh", 1)
+	narrowed := TemplateP2WPKH
+	narrowed.Data = func(data []byte) bool { return bytes.Equal(data, first.ScriptAddress()) }
+	result, err = s.ScanBlocksMatching(NewTemplateMatcher(testParams, narrowed), 1, chain.Height(), ScanOptions{})
+	if err != nil {
+		t.Fatalf("narrowed scan: %v", err)
+	}
+	if result.TotalUTXOs != 1 || result.UTXOs[0].Address != first.EncodeAddress() {
+		t.Errorf("narrowed scan found %+v, want only %s", result.UTXOs, first)
+	}
+}
+
+func TestScriptSetMatcherMatchesAddressScan(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 2000), rpctest.PayTo(rpctest.Address(testParams, "p2pkh", 3), 3000)))
+
+	matcher := ScriptSetMatcher{scriptHex(t, a): a.EncodeAddress(), scriptHex(t, b): b.EncodeAddress()}
+	byMatcher, err := s.ScanBlocksMatching(matcher, 0, chain.Height(), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAddress, err := s.ScanUTXOsHybrid(encodeAddresses(a, b), 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byMatcher.TotalUTXOs != 2 || byMatcher.TotalSatoshis != byAddress.TotalSatoshis || byMatcher.TotalUTXOs != byAddress.TotalUTXOs {
+		t.Errorf("matcher scan found %d UTXOs, %d sats; address scan %d, %d", byMatcher.TotalUTXOs, byMatcher.TotalSatoshis, byAddress.TotalUTXOs, byAddress.TotalSatoshis)
+	}
+}
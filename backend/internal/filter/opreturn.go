@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// OPReturnMatch is an OP_RETURN output whose payload matched a search
+type OPReturnMatch struct {
+	TxID          string `json:"txid"`
+	Vout          int    `json:"vout"`
+	Height        int64  `json:"height"`
+	BlockHash     string `json:"block_hash"`
+	Confirmations int64  `json:"confirmations"`
+	Payload       string `json:"payload"`      // Hex encoded pushed data
+	PayloadText   string `json:"payload_text"` // Pushed data as text
+}
+
+// opReturnPayload returns the data pushed after OP_RETURN, concatenated
+func opReturnPayload(script []byte) ([]byte, bool) {
+	if len(script) == 0 || script[0] != txscript.OP_RETURN {
+		return nil, false
+	}
+
+	var payload []byte
+	tokenizer := txscript.MakeScriptTokenizer(0, script[1:])
+	for tokenizer.Next() {
+		payload = append(payload, tokenizer.Data()...)
+	}
+	if tokenizer.Err() != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// OPReturnData is the data carried by an OP_RETURN script
+type OPReturnData struct {
+	Payload     string   `json:"payload"`                // Hex of the pushed data, concatenated
+	PayloadText *string  `json:"payload_text,omitempty"` // Set if the payload is valid UTF-8
+	Pushes      []string `json:"pushes"`                 // Hex of each push
+	PushOnly    bool     `json:"push_only"`              // False if opcodes other than pushes follow OP_RETURN
+}
+
+// DecodeOPReturn extracts the data pushed by an OP_RETURN script
+func DecodeOPReturn(script []byte) (*OPReturnData, error) {
+	if len(script) == 0 || script[0] != txscript.OP_RETURN {
+		return nil, errors.New("not an OP_RETURN script")
+	}
+
+	data := &OPReturnData{Pushes: []string{}, PushOnly: true}
+	var payload []byte
+	tokenizer := txscript.MakeScriptTokenizer(0, script[1:])
+	for tokenizer.Next() {
+		if tokenizer.Opcode() > txscript.OP_16 {
+			data.PushOnly = false
+			continue
+		}
+		push := tokenizer.Data()
+		switch op := tokenizer.Opcode(); {
+		case op == txscript.OP_1NEGATE:
+			push = []byte{0x81}
+		case op >= txscript.OP_1 && op <= txscript.OP_16:
+			push = []byte{op - txscript.OP_1 + 1} // Small integers push their value
+		}
+		payload = append(payload, push...)
+		data.Pushes = append(data.Pushes, hex.EncodeToString(push))
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, fmt.Errorf("malformed script: %w", err)
+	}
+
+	data.Payload = hex.EncodeToString(payload)
+	if utf8.Valid(payload) {
+		text := string(payload)
+		data.PayloadText = &text
+	}
+	return data, nil
+}
+
+// FindOPReturn walks the blocks in [startHeight, endHeight] and returns the
+// OP_RETURN outputs whose payload satisfies match, in chain order
+func (s *Service) FindOPReturn(startHeight, endHeight int64, match func(payload []byte) bool) ([]OPReturnMatch, error) {
+	refs, err := s.blockRefsInRange(startHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+	tipHeight, err := s.scanTipHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	perBlock := make([][]OPReturnMatch, len(refs))
+	err = runParallel(len(refs), s.blockWorkers, func(i int) error {
+		block, err := s.fetchScanBlock(refs[i], tipHeight)
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range block.Tx {
+			for _, vout := range tx.Vout {
+				if vout.ScriptPubKey.Type != "nulldata" {
+					continue
+				}
+				script, err := hex.DecodeString(vout.ScriptPubKey.Hex)
+				if err != nil {
+					continue
+				}
+				payload, ok := opReturnPayload(script)
+				if !ok || !match(payload) {
+					continue
+				}
+				perBlock[i] = append(perBlock[i], OPReturnMatch{
+					TxID:          tx.Txid,
+					Vout:          vout.N,
+					Height:        block.Height,
+					BlockHash:     block.Hash,
+					Confirmations: block.Confirmations,
+					Payload:       hex.EncodeToString(payload),
+					PayloadText:   string(payload),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []OPReturnMatch{}
+	for _, blockMatches := range perBlock {
+		matches = append(matches, blockMatches...)
+	}
+	return matches, nil
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestDecodeOPReturn(t *testing.T) {
+	script := func(build func(*txscript.ScriptBuilder)) []byte {
+		builder := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN)
+		build(builder)
+		s, err := builder.Script()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		script   []byte
+		payload  string
+		text     *string
+		pushes   []string
+		pushOnly bool
+	}{
+		{
+			name:     "one push",
+			script:   script(func(b *txscript.ScriptBuilder) { b.AddData([]byte("OT_REQUEST|a|b")) }),
+			payload:  "4f545f524551554553547c617c62",
+			text:     strPtr("OT_REQUEST|a|b"),
+			pushes:   []string{"4f545f524551554553547c617c62"},
+			pushOnly: true,
+		},
+		{
+			name:     "pushes are concatenated",
+			script:   script(func(b *txscript.ScriptBuilder) { b.AddData([]byte("ab")).AddData([]byte("cd")) }),
+			payload:  "61626364",
+			text:     strPtr("abcd"),
+			pushes:   []string{"6162", "6364"},
+			pushOnly: true,
+		},
+		{
+			name: "small integers push their value",
+			script: script(func(b *txscript.ScriptBuilder) {
+				b.AddOp(txscript.OP_1).AddOp(txscript.OP_16).AddOp(txscript.OP_1NEGATE)
+			}),
+			payload:  "011081",
+			pushes:   []string{"01", "10", "81"},
+			pushOnly: true,
+		},
+		{
+			name:     "bare OP_RETURN",
+			script:   []byte{txscript.OP_RETURN},
+			payload:  "",
+			text:     strPtr(""),
+			pushes:   []string{},
+			pushOnly: true,
+		},
+		{
+			name:     "other opcodes",
+			script:   script(func(b *txscript.ScriptBuilder) { b.AddData([]byte{0xff, 0xfe}).AddOp(txscript.OP_DUP) }),
+			payload:  "fffe",
+			pushes:   []string{"fffe"},
+			pushOnly: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := DecodeOPReturn(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Payload != tt.payload || data.PushOnly != tt.pushOnly || len(data.Pushes) != len(tt.pushes) {
+				t.Fatalf("got %+v, want payload %s in pushes %v", data, tt.payload, tt.pushes)
+			}
+			for i := range tt.pushes {
+				if data.Pushes[i] != tt.pushes[i] {
+					t.Errorf("push %d: %s, want %s", i, data.Pushes[i], tt.pushes[i])
+				}
+			}
+			if (data.PayloadText == nil) != (tt.text == nil) || (tt.text != nil && *data.PayloadText != *tt.text) {
+				t.Errorf("payload text %v, want %v", data.PayloadText, tt.text)
+			}
+		})
+	}
+}
+
+func TestDecodeOPReturnRejects(t *testing.T) {
+	for name, script := range map[string][]byte{
+		"empty":     nil,
+		"p2wpkh":    append([]byte{txscript.OP_0, txscript.OP_DATA_20}, make([]byte, 20)...),
+		"truncated": {txscript.OP_RETURN, txscript.OP_DATA_20, 0x01},
+	} {
+		if data, err := DecodeOPReturn(script); err == nil {
+			t.Errorf("%s: decoded %+v", name, data)
+		}
+	}
+}
+
+// strPtr returns a pointer to s
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"spv-backend/internal/merkle"
+)
+
+// MaxProofBlocksPerScan caps the number of distinct blocks fetched for include_proofs
+const MaxProofBlocksPerScan = 100
+
+// UTXOProof proves a UTXO's creating transaction is included in a block.
+// Clients fold Branch up from the txid at Index and compare the result with
+// the merkle root in BlockHeader, which they check against their header chain.
+type UTXOProof struct {
+	BlockHash   string   `json:"block_hash"`
+	BlockHeader string   `json:"block_header"` // 80-byte serialized header, hex encoded
+	MerkleRoot  string   `json:"merkle_root"`
+	Index       int      `json:"index"`  // Position of the transaction in the block
+	Branch      []string `json:"branch"` // Sibling hashes from leaf to root
+}
+
+// AttachProofs sets Proof on every UTXO. Each distinct block is fetched once
+// and its merkle tree built once for all of the UTXOs it contains.
+func (s *Service) AttachProofs(utxos []UTXO) error {
+	var blockHashes []string
+	seen := make(map[string]bool)
+	for _, utxo := range utxos {
+		if !seen[utxo.BlockHash] {
+			seen[utxo.BlockHash] = true
+			blockHashes = append(blockHashes, utxo.BlockHash)
+		}
+	}
+
+	if len(blockHashes) > MaxProofBlocksPerScan {
+		return fmt.Errorf("too many blocks for include_proofs: %d (max %d)", len(blockHashes), MaxProofBlocksPerScan)
+	}
+
+	proofs := make(map[string]*UTXOProof) // txid -> proof
+	for _, blockHash := range blockHashes {
+		blockProofs, err := s.blockProofs(blockHash)
+		if err != nil {
+			return err
+		}
+		for txid, proof := range blockProofs {
+			proofs[txid] = proof
+		}
+	}
+
+	for i := range utxos {
+		utxos[i].Proof = proofs[utxos[i].TxID]
+	}
+
+	return nil
+}
+
+// blockProofs builds the inclusion proof of every transaction in a block
+func (s *Service) blockProofs(blockHash string) (map[string]*UTXOProof, error) {
+	blockData, err := s.rpcClient.GetBlock(blockHash, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", blockHash, err)
+	}
+
+	var block struct {
+		MerkleRoot string   `json:"merkleroot"`
+		Tx         []string `json:"tx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block %s: %w", blockHash, err)
+	}
+
+	headerData, err := s.rpcClient.GetBlockHeader(blockHash, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get header %s: %w", blockHash, err)
+	}
+
+	var headerHex string
+	if err := json.Unmarshal(headerData, &headerHex); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal header %s: %w", blockHash, err)
+	}
+
+	root, branches, err := merkle.BuildBranches(block.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build merkle branches for %s: %w", blockHash, err)
+	}
+
+	if root != block.MerkleRoot {
+		return nil, fmt.Errorf("computed merkle root %s does not match block %s", root, blockHash)
+	}
+
+	proofs := make(map[string]*UTXOProof, len(branches))
+	for _, branch := range branches {
+		proofs[branch.TxID] = &UTXOProof{
+			BlockHash:   blockHash,
+			BlockHeader: headerHex,
+			MerkleRoot:  root,
+			Index:       branch.Index,
+			Branch:      branch.Branch,
+		}
+	}
+
+	return proofs, nil
+}
@@ -0,0 +1,171 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// How scans fetch block contents (see SetBlockFetch)
+const (
+	// BlockFetchAuto fetches serialized blocks (verbosity 0) and decodes them
+	// locally, about half the bytes of verbosity 2 JSON, in one call per block
+	// as the scan already knows each block's height. If a block does not
+	// decode, e.g. on a node build with a different transaction format, the
+	// service falls back to BlockFetchVerbose for the rest of its lifetime.
+	BlockFetchAuto = "auto"
+	// BlockFetchVerbose always fetches verbosity 2 blocks
+	BlockFetchVerbose = "verbose"
+)
+
+// SetBlockFetch selects how scans fetch blocks, BlockFetchAuto (the default)
+// or BlockFetchVerbose
+func (s *Service) SetBlockFetch(mode string) {
+	s.blockFetch = mode
+}
+
+// fetchRawScanBlock fetches a serialized block and converts it to the
+// verbose form the scan reads, in one call: the height comes from ref and
+// the confirmations from tipHeight (0 if it is below the block). ok is false
+// if the block does not decode, in which case the caller should fetch the
+// verbose block instead.
+func (s *Service) fetchRawScanBlock(ref blockRef, tipHeight int64) (block *scanBlock, ok bool, err error) {
+	blockHash := ref.Hash
+	var blockData json.RawMessage
+	err = s.withRetry(func() error {
+		var err error
+		blockData, err = s.rpcClient.GetBlock(blockHash, 0)
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get block %s: %w", blockHash, err)
+	}
+
+	var blockHex string
+	if err := json.Unmarshal(blockData, &blockHex); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal block %s: %w", blockHash, err)
+	}
+	msgBlock, err := decodeRawBlock(blockHex)
+	if err != nil || msgBlock.BlockHash().String() != blockHash {
+		if err == nil {
+			err = fmt.Errorf("decoded block hash %s does not match", msgBlock.BlockHash())
+		}
+		if s.rawBlocksFailed.CompareAndSwap(false, true) {
+			log.Printf("Warning: block %s does not decode (%v), fetching verbose blocks from now on; set BLOCK_FETCH=verbose to skip the attempt", blockHash, err)
+		}
+		return nil, false, nil
+	}
+
+	var confirmations int64
+	if tipHeight >= ref.Height {
+		confirmations = tipHeight - ref.Height + 1
+	}
+	return rawScanBlock(msgBlock, blockHash, ref.Height, confirmations), true, nil
+}
+
+// decodeRawBlock deserializes a hex block, rejecting trailing bytes
+func decodeRawBlock(blockHex string) (*wire.MsgBlock, error) {
+	raw, err := hex.DecodeString(blockHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hex: %w", err)
+	}
+
+	reader := bytes.NewReader(raw)
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(reader); err != nil {
+		return nil, err
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after block", reader.Len())
+	}
+	return &msgBlock, nil
+}
+
+// rawScanBlock converts a decoded block to the fields a verbosity 2 block
+// carries. Values come from the serialization, so they are exact satoshis.
+func rawScanBlock(msgBlock *wire.MsgBlock, hash string, height, confirmations int64) *scanBlock {
+	block := &scanBlock{Hash: hash, Height: height, Confirmations: confirmations}
+	block.Tx = make([]scanTx, len(msgBlock.Transactions))
+
+	for i, msgTx := range msgBlock.Transactions {
+		tx := &block.Tx[i]
+		tx.Txid = msgTx.TxHash().String()
+
+		// The coinbase input has no previous output, like its verbose form
+		if !isCoinbase(msgTx) {
+			tx.Vin = make([]scanVin, len(msgTx.TxIn))
+			for j, txIn := range msgTx.TxIn {
+				tx.Vin[j].Txid = txIn.PreviousOutPoint.Hash.String()
+				tx.Vin[j].Vout = int(txIn.PreviousOutPoint.Index)
+			}
+		}
+
+		tx.Vout = make([]scanTxOut, len(msgTx.TxOut))
+		for n, txOut := range msgTx.TxOut {
+			valueSat := txOut.Value
+			vout := &tx.Vout[n]
+			vout.Value = json.Number(formatBTC(valueSat))
+			vout.ValueSat = &valueSat
+			vout.N = n
+			vout.ScriptPubKey.Hex = hex.EncodeToString(txOut.PkScript)
+			vout.ScriptPubKey.Type = scriptType(txOut.PkScript)
+		}
+	}
+
+	return block
+}
+
+// formatBTC renders satoshis as an exact decimal BTC amount
+func formatBTC(sats int64) string {
+	sign := ""
+	if sats < 0 {
+		sign = "-"
+		sats = -sats
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, sats/satoshisPerBTC, sats%satoshisPerBTC)
+}
+
+// isCoinbase reports whether a transaction is a coinbase: a single
+// input spending the null outpoint
+func isCoinbase(msgTx *wire.MsgTx) bool {
+	if len(msgTx.TxIn) != 1 {
+		return false
+	}
+	prev := msgTx.TxIn[0].PreviousOutPoint
+	return prev.Index == wire.MaxPrevOutIndex && prev.Hash == [32]byte{}
+}
+
+// scriptType returns the type Bitcoin Core reports for an output script
+func scriptType(script []byte) string {
+	// Core treats any push-only script after OP_RETURN as data, without
+	// btcd's size limit
+	if len(script) > 0 && script[0] == txscript.OP_RETURN && txscript.IsPushOnlyScript(script[1:]) {
+		return "nulldata"
+	}
+
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyTy:
+		return "pubkey"
+	case txscript.PubKeyHashTy:
+		return "pubkeyhash"
+	case txscript.ScriptHashTy:
+		return "scripthash"
+	case txscript.MultiSigTy:
+		return "multisig"
+	case txscript.WitnessV0PubKeyHashTy:
+		return "witness_v0_keyhash"
+	case txscript.WitnessV0ScriptHashTy:
+		return "witness_v0_scripthash"
+	case txscript.WitnessV1TaprootTy:
+		return "witness_v1_taproot"
+	}
+	if txscript.IsWitnessProgram(script) {
+		return "witness_unknown"
+	}
+	return "nonstandard"
+}
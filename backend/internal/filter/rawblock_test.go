@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fillChain mines blocks paying the addresses and unrelated scripts, with
+// spends between them, and returns the addresses
+func fillChain(chain *rpctest.Chain, blocks, txsPerBlock int) []string {
+	kinds := []string{"p2pkh", "p2sh", "p2wpkh", "p2wsh", "p2tr"}
+	var addresses []string
+	for i, kind := range kinds {
+		addresses = append(addresses, rpctest.Address(chain.Params, kind, byte(i+1)).EncodeAddress())
+	}
+	opReturn, _ := txscript.NullDataScript([]byte("memo"))
+
+	var previous []*wire.MsgTx
+	for b := 0; b < blocks; b++ {
+		var txs []*wire.MsgTx
+		for i := 0; i < txsPerBlock; i++ {
+			kind := kinds[(b+i)%len(kinds)]
+			outs := []*wire.TxOut{
+				rpctest.PayTo(rpctest.Address(chain.Params, kind, byte((b+i)%len(kinds)+1)), int64(1000*(b+1)+i)),
+				rpctest.PayTo(rpctest.Address(chain.Params, kind, 200), 500),
+				rpctest.Out(opReturn, 0),
+			}
+			var spends []wire.OutPoint
+			if i < len(previous) && i%2 == 0 {
+				spends = append(spends, rpctest.OutPoint(previous[i], 0))
+			}
+			txs = append(txs, chain.NewTx(spends, outs...))
+		}
+		chain.AddBlock(txs...)
+		previous = txs
+	}
+	return addresses
+}
+
+func TestRawAndVerboseBlockFetchAgree(t *testing.T) {
+	raw, chain, node := newTestService(t)
+	addresses := fillChain(chain, 12, 4)
+	verbose := NewService(node.Client(), testParams)
+	verbose.SetBlockFetch(BlockFetchVerbose)
+
+	opts := ScanOptions{IncludeSpent: true}
+	rawResult, err := raw.ScanUTXOsHybrid(addresses, 0, chain.Height(), "direct", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocksFetched := node.Calls("getblock")
+	if headers := node.Calls("getblockheader"); headers != 0 {
+		t.Errorf("raw fetch made %d getblockheader calls", headers)
+	}
+	if blocksFetched != int(chain.Height()+1) {
+		t.Errorf("raw fetch made %d getblock calls for %d blocks", blocksFetched, chain.Height()+1)
+	}
+
+	verboseResult, err := verbose.ScanUTXOsHybrid(addresses, 0, chain.Height(), "direct", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rawResult.UTXOs) == 0 || len(rawResult.SpentOutputs) == 0 {
+		t.Fatalf("scan found %d UTXOs, %d spent", len(rawResult.UTXOs), len(rawResult.SpentOutputs))
+	}
+	if !reflect.DeepEqual(rawResult.UTXOs, verboseResult.UTXOs) {
+		t.Errorf("raw UTXOs %+v\nverbose %+v", rawResult.UTXOs, verboseResult.UTXOs)
+	}
+	if !reflect.DeepEqual(rawResult.SpentOutputs, verboseResult.SpentOutputs) {
+		t.Errorf("raw spent %+v\nverbose %+v", rawResult.SpentOutputs, verboseResult.SpentOutputs)
+	}
+
+	rawMatches, err := raw.FindOPReturn(0, chain.Height(), func([]byte) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	verboseMatches, err := verbose.FindOPReturn(0, chain.Height(), func([]byte) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rawMatches) != 48 || !reflect.DeepEqual(rawMatches, verboseMatches) {
+		t.Errorf("raw found %d OP_RETURN outputs, verbose %d", len(rawMatches), len(verboseMatches))
+	}
+}
+
+// BenchmarkBlockFetch scans the same blocks fetched serialized and as
+// verbose JSON, reporting the bytes and calls the node served per scan
+func BenchmarkBlockFetch(b *testing.B) {
+	for _, mode := range []string{BlockFetchVerbose, BlockFetchAuto} {
+		b.Run(mode, func(b *testing.B) {
+			s, chain, node := newTestService(b)
+			addresses := fillChain(chain, 20, 50)
+			s.SetBlockFetch(mode)
+			matcher, err := s.buildAddressScripts(addresses)
+			if err != nil {
+				b.Fatal(err)
+			}
+			refs, err := s.blockRefsInRange(0, chain.Height())
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			bytes, requests := node.BytesSent(), node.Requests()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, _, err := s.scanBlockOutputs(refs, matcher); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(node.BytesSent()-bytes)/float64(b.N), "node-bytes/op")
+			b.ReportMetric(float64(node.Requests()-requests)/float64(b.N), "calls/op")
+		})
+	}
+}
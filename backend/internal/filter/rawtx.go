@@ -0,0 +1,76 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"spv-backend/internal/rpc"
+)
+
+// MaxRawTxPerScan caps the number of distinct transactions fetched for include_raw_tx
+const MaxRawTxPerScan = 500
+
+// rawTxBatchSize is the number of getrawtransaction calls sent per batch request
+const rawTxBatchSize = 100
+
+// AttachRawTransactions sets RawTx on every UTXO to the hex of its creating
+// transaction. Transactions are deduplicated by txid and fetched in batches,
+// passing the block hash so the lookup works without -txindex.
+func (s *Service) AttachRawTransactions(utxos []UTXO) error {
+	blockByTxID := make(map[string]string)
+	var txids []string
+	for _, utxo := range utxos {
+		if _, seen := blockByTxID[utxo.TxID]; !seen {
+			blockByTxID[utxo.TxID] = utxo.BlockHash
+			txids = append(txids, utxo.TxID)
+		}
+	}
+
+	if len(txids) > MaxRawTxPerScan {
+		return fmt.Errorf("too many transactions for include_raw_tx: %d (max %d)", len(txids), MaxRawTxPerScan)
+	}
+
+	rawByTxID := make(map[string]string, len(txids))
+	for start := 0; start < len(txids); start += rawTxBatchSize {
+		end := start + rawTxBatchSize
+		if end > len(txids) {
+			end = len(txids)
+		}
+
+		requests := make([]rpc.RPCRequest, 0, end-start)
+		for i, txid := range txids[start:end] {
+			requests = append(requests, rpc.RPCRequest{
+				Jsonrpc: "1.0",
+				Method:  "getrawtransaction",
+				Params:  []interface{}{txid, false, blockByTxID[txid]},
+				ID:      start + i,
+			})
+		}
+
+		responses, err := s.rpcClient.BatchCall(requests)
+		if err != nil {
+			return fmt.Errorf("failed to fetch raw transactions: %w", err)
+		}
+
+		for _, resp := range responses {
+			if resp.ID < 0 || resp.ID >= len(txids) {
+				continue
+			}
+			txid := txids[resp.ID]
+			if resp.Error != nil {
+				return fmt.Errorf("failed to fetch raw transaction %s: %s", txid, resp.Error.Message)
+			}
+			var rawHex string
+			if err := json.Unmarshal(resp.Result, &rawHex); err != nil {
+				return fmt.Errorf("failed to unmarshal raw transaction %s: %w", txid, err)
+			}
+			rawByTxID[txid] = rawHex
+		}
+	}
+
+	for i := range utxos {
+		utxos[i].RawTx = rawByTxID[utxos[i].TxID]
+	}
+
+	return nil
+}
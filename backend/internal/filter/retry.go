@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"spv-backend/internal/rpc"
+)
+
+// SetRetry makes per-block fetches retry transient failures up to retries
+// times, waiting backoff before the first retry and doubling it after each
+func (s *Service) SetRetry(retries int, backoff time.Duration) {
+	if retries < 0 {
+		retries = 0
+	}
+	s.retries = retries
+	s.retryBackoff = backoff
+}
+
+// isTransient reports whether a failed RPC call may succeed if repeated.
+// Errors answered by the node (e.g. an unknown block), refused by the call
+// budget or allowlist, and cancellations are final.
+func isTransient(err error) bool {
+	var rpcErr *rpc.RPCError
+	switch {
+	case errors.As(err, &rpcErr),
+		errors.Is(err, rpc.ErrCallBudgetExceeded),
+		errors.Is(err, rpc.ErrMethodNotAllowed),
+		errors.Is(err, rpc.ErrUnauthorized),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+
+	var statusErr *rpc.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return errors.Is(err, rpc.ErrNodeUnavailable)
+	}
+	// Transport errors: connection refused or reset, timeouts
+	return true
+}
+
+// withRetry runs call, repeating it with backoff while it fails transiently
+func (s *Service) withRetry(call func() error) error {
+	backoff := s.retryBackoff
+	err := call()
+	for attempt := 0; attempt < s.retries && err != nil && isTransient(err); attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-s.rpcClient.Context().Done():
+			return err
+		}
+		backoff *= 2
+		err = call()
+	}
+	return err
+}
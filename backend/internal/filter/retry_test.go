@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+)
+
+// flakyService returns a service whose node answers the first failures
+// getblock calls for blockHash with a 503 page, as bitcoind does when its
+// work queue is full, and the number of such calls seen
+func flakyService(t *testing.T, node *rpctest.Node, blockHash string, failures int) (*Service, func() int) {
+	t.Helper()
+	target, err := url.Parse(node.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		var hash string
+		if json.Unmarshal(body, &req) == nil && req.Method == "getblock" {
+			rpctest.Param(req.Params, 0, &hash)
+		}
+		if hash == blockHash {
+			mu.Lock()
+			attempts++
+			fail := attempts <= failures
+			mu.Unlock()
+			if fail {
+				http.Error(w, "Work queue depth exceeded", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(rpc.NewClient(u.Hostname(), u.Port(), "user", "pass"), testParams)
+	return s, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts
+	}
+}
+
+func TestScanRetriesTransientBlockFailure(t *testing.T) {
+	chain := rpctest.NewChain(testParams)
+	node := rpctest.NewNode(t, chain)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 5; i++ {
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, int64(1000+i))))
+	}
+	flaky := chain.BlockAt(3).Hash
+
+	for _, mode := range []string{"direct", "spv"} {
+		t.Run(mode, func(t *testing.T) {
+			s, attempts := flakyService(t, node, flaky, 2)
+			s.SetRetry(3, time.Millisecond)
+
+			result, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), mode, ScanOptions{})
+			if err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if result.TotalUTXOs != 5 {
+				t.Errorf("found %d UTXOs, want 5", result.TotalUTXOs)
+			}
+			if n := attempts(); n != 3 {
+				t.Errorf("block fetched %d times, want 2 failures and a success", n)
+			}
+		})
+	}
+}
+
+func TestScanAbortsAfterRetriesExhausted(t *testing.T) {
+	chain := rpctest.NewChain(testParams)
+	node := rpctest.NewNode(t, chain)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 5; i++ {
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, int64(1000+i))))
+	}
+	s, attempts := flakyService(t, node, chain.BlockAt(3).Hash, 10)
+	s.SetRetry(2, time.Millisecond)
+
+	_, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), "direct", ScanOptions{})
+	if !errors.Is(err, rpc.ErrNodeUnavailable) {
+		t.Fatalf("got %v, want the node's 503", err)
+	}
+	if n := attempts(); n != 3 {
+		t.Errorf("block fetched %d times, want 1 and 2 retries", n)
+	}
+}
+
+func TestScanDoesNotRetryNodeErrors(t *testing.T) {
+	s, chain, node := newTestService(t)
+	s.SetRetry(3, time.Millisecond)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 3; i++ {
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, 1000)))
+	}
+	// The node answers; repeating the call cannot help
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	node.Handle("getblock", func(params []json.RawMessage) (interface{}, error) {
+		var hash string
+		rpctest.Param(params, 0, &hash)
+		mu.Lock()
+		fetches[hash]++
+		mu.Unlock()
+		return nil, &rpc.RPCError{Code: -5, Message: "Block not found"}
+	})
+
+	if _, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, chain.Height(), "direct", ScanOptions{}); err == nil {
+		t.Fatal("scan succeeded without blocks")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for hash, n := range fetches {
+		if n != 1 {
+			t.Errorf("block %s fetched %d times, want 1", hash, n)
+		}
+	}
+}
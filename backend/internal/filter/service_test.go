@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testParams is the chain the filter tests run on
+var testParams = &chaincfg.RegressionNetParams
+
+// newTestService returns a service over a fresh regtest chain served by an
+// in-memory node
+func newTestService(t testing.TB, opts ...rpc.Option) (*Service, *rpctest.Chain, *rpctest.Node) {
+	t.Helper()
+	chain := rpctest.NewChain(testParams)
+	node := rpctest.NewNode(t, chain)
+	return NewService(node.Client(opts...), testParams), chain, node
+}
+
+// encodeAddresses returns the addresses' string forms
+func encodeAddresses(addresses ...btcutil.Address) []string {
+	encoded := make([]string, len(addresses))
+	for i, address := range addresses {
+		encoded[i] = address.EncodeAddress()
+	}
+	return encoded
+}
+
+func TestSPVMatchesDirectForMixedScriptTypes(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	p2pkh := rpctest.Address(testParams, "p2pkh", 1)
+	p2tr := rpctest.Address(testParams, "p2tr", 2)
+	p2wsh := rpctest.Address(testParams, "p2wsh", 3)
+	other := rpctest.Address(testParams, "p2wpkh", 4)
+
+	// Each type is paid, some outputs are spent within the range, and
+	// blocks paying only other scripts are left for the filters to skip
+	fund := chain.NewTx(nil, rpctest.PayTo(p2pkh, 1000), rpctest.PayTo(p2tr, 2000), rpctest.PayTo(other, 500))
+	chain.AddBlock(fund)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(other, 700)))
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(p2wsh, 3000)))
+	chain.AddBlock()
+	spend := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(p2tr, 900), rpctest.PayTo(other, 50))
+	chain.AddBlock(spend)
+	chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(p2wsh, 1900)))
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(other, 800)))
+
+	addresses := encodeAddresses(p2pkh, p2tr, p2wsh)
+	opts := ScanOptions{IncludeSpent: true}
+	direct, err := s.ScanUTXOsHybrid(addresses, 0, chain.Height(), "direct", opts)
+	if err != nil {
+		t.Fatalf("direct scan: %v", err)
+	}
+	spv, err := s.ScanUTXOsHybrid(addresses, 0, chain.Height(), "spv", opts)
+	if err != nil {
+		t.Fatalf("spv scan: %v", err)
+	}
+
+	// p2tr 900 and both p2wsh outputs are unspent; p2pkh 1000 and p2tr 2000
+	// were spent within the range
+	if direct.TotalUTXOs != 3 || direct.TotalSatoshis != 5800 || len(direct.SpentOutputs) != 2 {
+		t.Fatalf("direct scan found %d UTXOs, %d sats, %d spent", direct.TotalUTXOs, direct.TotalSatoshis, len(direct.SpentOutputs))
+	}
+	if !reflect.DeepEqual(spv.UTXOs, direct.UTXOs) {
+		t.Errorf("spv UTXOs %+v, direct %+v", spv.UTXOs, direct.UTXOs)
+	}
+	if !reflect.DeepEqual(spv.SpentOutputs, direct.SpentOutputs) {
+		t.Errorf("spv spent outputs %+v, direct %+v", spv.SpentOutputs, direct.SpentOutputs)
+	}
+	if spv.TotalSatoshis != direct.TotalSatoshis {
+		t.Errorf("spv total %d sats, direct %d", spv.TotalSatoshis, direct.TotalSatoshis)
+	}
+
+	// Blocks 2, 4 and 7 pay none of the addresses, and the genesis block
+	// neither, so the filters must have let spv skip them
+	if spv.Statistics.BlocksScanned != 4 {
+		t.Errorf("spv fetched %d blocks, want the 4 paying or spending the addresses", spv.Statistics.BlocksScanned)
+	}
+}
+
+func TestScanSingleBlock(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+	target := chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 2000)))
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 3000)))
+
+	for _, mode := range []string{"direct", "spv"} {
+		t.Run(mode, func(t *testing.T) {
+			result, err := s.ScanUTXOsHybrid(encodeAddresses(address), target.Height, target.Height, mode, ScanOptions{})
+			if err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if result.TotalUTXOs != 1 || result.TotalSatoshis != 2000 || result.UTXOs[0].BlockHash != target.Hash {
+				t.Errorf("found %d UTXOs, %d sats, want only the 2000 sat output of block %d", result.TotalUTXOs, result.TotalSatoshis, target.Height)
+			}
+			if result.Statistics.BlocksScanned != 1 {
+				t.Errorf("scanned %d blocks, want 1", result.Statistics.BlocksScanned)
+			}
+		})
+	}
+}
+
+func TestScanEmptyAddressSet(t *testing.T) {
+	s, chain, node := newTestService(t)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000)))
+
+	for _, mode := range []string{"direct", "spv"} {
+		result, err := s.ScanUTXOsHybrid([]string{"", "  ", ""}, 0, chain.Height(), mode, ScanOptions{})
+		if err != nil {
+			t.Fatalf("%s scan: %v", mode, err)
+		}
+		if result.TotalUTXOs != 0 || result.UTXOs == nil || result.Statistics == nil || result.Statistics.Mode != mode {
+			t.Errorf("%s scan: got %+v, want an empty result", mode, result)
+		}
+	}
+	if node.Requests() != 0 {
+		t.Errorf("node received %d requests for a scan with no addresses", node.Requests())
+	}
+}
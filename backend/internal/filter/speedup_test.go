@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpctest"
+)
+
+func TestSpeedupFactor(t *testing.T) {
+	// 10 of 1000 filtered blocks fetched in 50ms is 5ms a block, so a direct
+	// scan would take 5000ms plus the same 100ms verification pass
+	stats := &ScanStatistics{BlocksFiltered: 1000, ScanTimeMs: 600}
+	stats.setSpeedup(50*time.Millisecond, 10, 100*time.Millisecond)
+	if stats.EstimatedDirectTimeMs == nil || *stats.EstimatedDirectTimeMs != 5100 {
+		t.Fatalf("estimated direct time %v, want 5100ms", stats.EstimatedDirectTimeMs)
+	}
+	if stats.SpeedupFactor == nil || math.Abs(*stats.SpeedupFactor-8.5) > 1e-9 {
+		t.Errorf("speedup %v, want 5100/600 = 8.5", stats.SpeedupFactor)
+	}
+
+	// Without a measurable scan time there is nothing to divide by
+	stats = &ScanStatistics{BlocksFiltered: 100}
+	stats.setSpeedup(10*time.Millisecond, 10, 0)
+	if stats.EstimatedDirectTimeMs == nil || *stats.EstimatedDirectTimeMs != 100 || stats.SpeedupFactor != nil {
+		t.Errorf("got estimate %v, speedup %v; want 100ms and no speedup", stats.EstimatedDirectTimeMs, stats.SpeedupFactor)
+	}
+
+	// Without fetched blocks there is no per-block time
+	stats = &ScanStatistics{BlocksFiltered: 100, ScanTimeMs: 5}
+	stats.setSpeedup(0, 0, time.Millisecond)
+	if stats.EstimatedDirectTimeMs != nil || stats.SpeedupFactor != nil {
+		t.Errorf("got estimate %v, speedup %v without fetched blocks", stats.EstimatedDirectTimeMs, stats.SpeedupFactor)
+	}
+}
+
+func TestScanReportsSpeedup(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 20; i++ {
+		chain.AddBlock()
+	}
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1000)))
+
+	result, err := s.ScanUTXOsHybrid(encodeAddresses(address), 0, chain.Height(), "spv", ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := result.Statistics; stats == nil || stats.EstimatedDirectTimeMs == nil {
+		t.Errorf("spv scan statistics %+v, want a direct time estimate", stats)
+	}
+
+	// Nothing to compare without fetched blocks, or for direct scans
+	result, err = s.ScanUTXOsHybrid(encodeAddresses(rpctest.Address(testParams, "p2wpkh", 2)), 0, chain.Height(), "spv", ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := result.Statistics; stats != nil && (stats.EstimatedDirectTimeMs != nil || stats.SpeedupFactor != nil) {
+		t.Errorf("scan without matches estimated %+v", stats)
+	}
+	result, err = s.ScanUTXOsHybrid(encodeAddresses(address), 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := result.Statistics; stats != nil && (stats.EstimatedDirectTimeMs != nil || stats.SpeedupFactor != nil) {
+		t.Errorf("direct scan estimated %+v", stats)
+	}
+}
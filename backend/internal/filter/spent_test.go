@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Run with -race: blocks are fetched by many workers and answered in reverse
+// chain order, so spends are seen before the outputs they spend
+func TestScanSpentOutputsIgnoreFetchOrder(t *testing.T) {
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	other := rpctest.Address(testParams, "p2pkh", 9)
+
+	// Both chains are built the same way, so their UTXOs are identical
+	var results [][]UTXO
+	for _, workers := range []int{1, 8} {
+		s, chain, node := newTestService(t)
+		s.SetWorkers(1, workers)
+
+		fund := chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 2000), rpctest.PayTo(a, 3000))
+		chain.AddBlock(fund)
+		// Spent and respent along a chain of blocks
+		hop1 := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(b, 900))
+		chain.AddBlock(hop1)
+		chain.AddBlock()
+		hop2 := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(hop1, 0)}, rpctest.PayTo(a, 800))
+		chain.AddBlock(hop2)
+		// Created and spent within one block
+		inBlock := chain.NewTx(nil, rpctest.PayTo(b, 5000))
+		chain.AddBlock(inBlock, chain.NewTx([]wire.OutPoint{rpctest.OutPoint(inBlock, 0)}, rpctest.PayTo(other, 4900)))
+		// Spent to another script late in the range
+		for i := 0; i < 6; i++ {
+			chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(a, int64(100+i))))
+		}
+		chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(other, 1900)))
+
+		tip := chain.Height()
+		node.Wrap("getblock", func(next rpctest.Handler) rpctest.Handler {
+			return func(params []json.RawMessage) (interface{}, error) {
+				var hash string
+				if _, err := rpctest.Param(params, 0, &hash); err == nil {
+					if block := chain.Block(hash); block != nil {
+						time.Sleep(time.Duration(tip-block.Height) * time.Millisecond)
+					}
+				}
+				return next(params)
+			}
+		})
+
+		result, err := s.ScanUTXOsHybrid(encodeAddresses(a, b), 0, tip, "direct", ScanOptions{})
+		if err != nil {
+			t.Fatalf("scan with %d workers: %v", workers, err)
+		}
+		// fund:2 (3000), hop2 (800) and the six small payments
+		if result.TotalUTXOs != 8 || result.TotalSatoshis != 3000+800+100+101+102+103+104+105 {
+			t.Fatalf("scan with %d workers found %d UTXOs, %d sats", workers, result.TotalUTXOs, result.TotalSatoshis)
+		}
+		for i := 1; i < len(result.UTXOs); i++ {
+			if result.UTXOs[i].Height < result.UTXOs[i-1].Height {
+				t.Errorf("scan with %d workers returned UTXOs out of chain order", workers)
+			}
+		}
+		results = append(results, result.UTXOs)
+	}
+
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Errorf("parallel scan %+v, sequential %+v", results[1], results[0])
+	}
+}
+
+func TestResolveSpentOutputsDeduplicates(t *testing.T) {
+	utxo := func(txid string, vout int) UTXO { return UTXO{TxID: txid, Vout: vout} }
+	blocks := []*blockOutputs{
+		{height: 1, utxos: []UTXO{utxo("aa", 0), utxo("aa", 1)}},
+		// The same outpoint listed twice is spent once
+		{height: 2, utxos: []UTXO{utxo("bb", 0)}, spends: []string{"aa:0", "aa:0"}, spenders: []string{"dd", "dd"}},
+		{height: 3, utxos: []UTXO{utxo("cc", 0), utxo("cc", 1)}, spends: []string{"cc:0"}, spenders: []string{"ee"}},
+	}
+
+	utxos, spent := resolveOutputs(blocks)
+	want := []UTXO{utxo("aa", 1), utxo("bb", 0), utxo("cc", 1)}
+	if !reflect.DeepEqual(utxos, want) {
+		t.Errorf("got UTXOs %+v, want %+v", utxos, want)
+	}
+	wantSpent := []SpentOutput{
+		{UTXO: utxo("aa", 0), SpentByTxID: "dd", SpentAtHeight: 2},
+		{UTXO: utxo("cc", 0), SpentByTxID: "ee", SpentAtHeight: 3},
+	}
+	if !reflect.DeepEqual(spent, wantSpent) {
+		t.Errorf("got spent %+v, want %+v", spent, wantSpent)
+	}
+}
+
+func TestScanReportsSpender(t *testing.T) {
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	other := rpctest.Address(testParams, "p2tr", 9)
+
+	for _, mode := range []string{"direct", "spv"} {
+		t.Run(mode, func(t *testing.T) {
+			s, chain, _ := newTestService(t)
+			fund := chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(a, 2000), rpctest.PayTo(a, 3000))
+			chain.AddBlock(fund)
+			chain.AddBlock()
+			spend := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 1)}, rpctest.PayTo(other, 1900))
+			chain.AddBlock(spend)
+			end := chain.Height()
+			// Spent after the scanned range
+			chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 2)}, rpctest.PayTo(other, 2900)))
+
+			result, err := s.ScanUTXOsHybrid(encodeAddresses(a), 0, end, mode, ScanOptions{IncludeSpent: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.TotalUTXOs != 1 || result.UTXOs[0].Vout != 0 {
+				t.Errorf("UTXOs %+v, want only output 0", result.UTXOs)
+			}
+			if len(result.SpentOutputs) != 1 {
+				t.Fatalf("spent outputs %+v, want the output spent at height %d", result.SpentOutputs, end)
+			}
+			got := result.SpentOutputs[0]
+			if got.TxID != fund.TxHash().String() || got.Vout != 1 || got.Satoshis != 2000 || got.Height != 1 {
+				t.Errorf("spent output %+v, want %s:1", got.UTXO, fund.TxHash())
+			}
+			if got.SpentByTxID != spend.TxHash().String() || got.SpentAtHeight != end {
+				t.Errorf("spent by %s at %d, want %s at %d", got.SpentByTxID, got.SpentAtHeight, spend.TxHash(), end)
+			}
+
+			// Only reported when asked for
+			result, err = s.ScanUTXOsHybrid(encodeAddresses(a), 0, end, mode, ScanOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.SpentOutputs != nil {
+				t.Errorf("spent outputs %+v without IncludeSpent", result.SpentOutputs)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// ErrSupplyExceeded is returned when a scan's total is more than the network
+// can ever have issued, which points to corrupt node data or a broken scan
+var ErrSupplyExceeded = errors.New("scan total exceeds the network's maximum money supply; the node's data is likely corrupt")
+
+// SetSupplyCheck enables failing scans whose total exceeds MaxMoney
+func (s *Service) SetSupplyCheck(enabled bool) {
+	s.supplyCheck = enabled
+}
+
+// MaxMoney returns the most satoshis a network can issue: every block
+// subsidy until halvings reduce it to zero. That is 20999999.9769 BTC on
+// mainnet, and about 15000 BTC on regtest with its 150 block halvings.
+func MaxMoney(params *chaincfg.Params) int64 {
+	var total int64
+	subsidy := int64(50 * btcutil.SatoshiPerBitcoin)
+	for ; subsidy > 0; subsidy >>= 1 {
+		total += subsidy * int64(params.SubsidyReductionInterval)
+	}
+	return total
+}
+
+// checkSupply fails a scan total above the network's MaxMoney. A negative
+// total can only be an overflow of one.
+func (s *Service) checkSupply(totalSatoshis int64) error {
+	if !s.supplyCheck {
+		return nil
+	}
+	if maxMoney := MaxMoney(s.chainParams); totalSatoshis < 0 || totalSatoshis > maxMoney {
+		return fmt.Errorf("%w (%d satoshis, max %d)", ErrSupplyExceeded, totalSatoshis, maxMoney)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestMaxMoney(t *testing.T) {
+	if got := MaxMoney(&chaincfg.MainNetParams); got != 2099999997690000 {
+		t.Errorf("mainnet max money %d, want 2099999997690000", got)
+	}
+	// 150 block halvings from 50 BTC
+	if got := MaxMoney(testParams); got != 1499999998350 {
+		t.Errorf("regtest max money %d, want 1499999998350", got)
+	}
+}
+
+func TestScanOverSupplyFails(t *testing.T) {
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	maxMoney := MaxMoney(testParams)
+
+	for _, mode := range []string{"direct", "spv"} {
+		t.Run(mode, func(t *testing.T) {
+			s, chain, _ := newTestService(t)
+			s.SetSupplyCheck(true)
+			// Made-up inputs let a regtest chain pay out more than it issued
+			chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, maxMoney)))
+			chain.AddBlock()
+
+			// The whole supply is still a sane total
+			result, err := s.ScanUTXOsHybrid(encodeAddresses(address), 0, 2, mode, ScanOptions{})
+			if err != nil || result.TotalSatoshis != maxMoney {
+				t.Fatalf("got %v, %v; want the whole supply", result, err)
+			}
+
+			chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 1)))
+			_, err = s.ScanUTXOsHybrid(encodeAddresses(address), 0, 3, mode, ScanOptions{})
+			if !errors.Is(err, ErrSupplyExceeded) {
+				t.Errorf("got %v, want ErrSupplyExceeded", err)
+			}
+			if _, err := s.BalanceAt(address.EncodeAddress(), 0, 3, mode); !errors.Is(err, ErrSupplyExceeded) {
+				t.Errorf("balance at: got %v, want ErrSupplyExceeded", err)
+			}
+
+			// The check is optional
+			s.SetSupplyCheck(false)
+			result, err = s.ScanUTXOsHybrid(encodeAddresses(address), 0, 3, mode, ScanOptions{})
+			if err != nil || result.TotalSatoshis != maxMoney+1 {
+				t.Errorf("unchecked: got %v, %v; want %d sats", result, err, maxMoney+1)
+			}
+		})
+	}
+}
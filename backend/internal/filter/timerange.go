@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// timestampTolerance is how far, in seconds, a block's timestamp may deviate
+// from the median time past the search runs over: consensus only requires a
+// block's time to exceed the median of the previous 11 blocks and to be at most
+// two hours ahead of network time, so timestamps are not monotonic
+const timestampTolerance = 2 * 60 * 60
+
+// medianTimeAtHeight returns the median time past of the block at a height
+func (s *Service) medianTimeAtHeight(height int64) (int64, error) {
+	blockHash, err := s.rpcClient.GetBlockHash(height)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block hash at height %d: %w", height, err)
+	}
+
+	headerData, err := s.rpcClient.GetBlockHeader(blockHash, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get header at height %d: %w", height, err)
+	}
+
+	var header struct {
+		MedianTime int64 `json:"mediantime"`
+	}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal header at height %d: %w", height, err)
+	}
+
+	return header.MedianTime, nil
+}
+
+// HeightForTime returns the first height in [0, tip] whose median time past
+// is at or after timestamp, or tip+1 if there is none. Median time past never
+// decreases, so it can be binary searched where raw timestamps cannot.
+func (s *Service) HeightForTime(timestamp, tip int64) (int64, error) {
+	var searchErr error
+	height := sort.Search(int(tip+1), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		medianTime, err := s.medianTimeAtHeight(int64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return medianTime >= timestamp
+	})
+	if searchErr != nil {
+		return 0, searchErr
+	}
+
+	return int64(height), nil
+}
+
+// ResolveTimeRange converts a Unix time range into the height range of blocks
+// that may carry timestamps within it. The range is widened by the timestamp
+// tolerance so blocks with skewed timestamps at either edge are not missed;
+// scanning a few extra blocks is harmless. The start may exceed the end when
+// no blocks fall in the range.
+func (s *Service) ResolveTimeRange(startTime, endTime, tip int64) (int64, int64, error) {
+	if startTime > endTime {
+		return 0, 0, fmt.Errorf("start time must be less than or equal to end time")
+	}
+
+	startHeight, err := s.HeightForTime(startTime-timestampTolerance, tip)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Last height whose median time past is within the widened end
+	endHeight, err := s.HeightForTime(endTime+timestampTolerance+1, tip)
+	if err != nil {
+		return 0, 0, err
+	}
+	endHeight--
+
+	return startHeight, endHeight, nil
+}
@@ -0,0 +1,53 @@
+package filter
+
+import (
+	"sort"
+	"testing"
+)
+
+// medianTimePast computes a block's median time past from the chain's
+// header times: the median of it and up to ten blocks before it
+func medianTimePast(times []int64, height int) int64 {
+	start := height - 10
+	if start < 0 {
+		start = 0
+	}
+	window := append([]int64(nil), times[start:height+1]...)
+	sort.Slice(window, func(i, j int) bool { return window[i] < window[j] })
+	return window[len(window)/2]
+}
+
+func TestHeightForTime(t *testing.T) {
+	s, chain, _ := newTestService(t)
+	for i := 0; i < 30; i++ {
+		chain.AddBlock()
+	}
+	times := make([]int64, chain.Height()+1)
+	for height := range times {
+		times[height] = chain.BlockAt(int64(height)).Time()
+	}
+	tip := chain.Height()
+
+	// Probe each block's median time and the second after it, including the
+	// short windows near genesis
+	for height := 0; height <= int(tip); height++ {
+		mtp := medianTimePast(times, height)
+		for _, timestamp := range []int64{mtp, mtp + 1} {
+			want := int64(sort.Search(len(times), func(i int) bool { return medianTimePast(times, i) >= timestamp }))
+			got, err := s.HeightForTime(timestamp, tip)
+			if err != nil {
+				t.Fatalf("height for %d: %v", timestamp, err)
+			}
+			if got != want {
+				t.Errorf("height for time %d is %d, want %d", timestamp, got, want)
+			}
+		}
+	}
+
+	if got, err := s.HeightForTime(times[tip]+1, tip); err != nil || got != tip+1 {
+		t.Errorf("time after the tip resolved to %d (%v), want %d", got, err, tip+1)
+	}
+	if got, err := s.HeightForTime(0, tip); err != nil || got != 0 {
+		t.Errorf("time before genesis resolved to %d (%v), want 0", got, err)
+	}
+}
@@ -0,0 +1,151 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"spv-backend/internal/rpc"
+)
+
+// SetTxOutSetThreshold makes scans of more than threshold addresses over
+// the whole chain, from height 0 to the tip, read the node's UTXO set with
+// scantxoutset instead of matching every block. 0 disables the switch.
+func (s *Service) SetTxOutSetThreshold(threshold int) {
+	s.txOutSetThreshold = threshold
+}
+
+// useTxOutSet reports whether a scan should use scantxoutset. Its answer is
+// the UTXO set at the tip, so it only stands in for scans from genesis to
+// the tip, and it knows nothing of outputs spent along the way.
+func (s *Service) useTxOutSet(addresses int, startHeight, endHeight int64, opts ScanOptions) bool {
+	if s.txOutSetThreshold <= 0 || addresses <= s.txOutSetThreshold || startHeight != 0 || opts.IncludeSpent || opts.DebugFilters {
+		return false
+	}
+	tip, err := s.rpcClient.GetBlockCount()
+	return err == nil && endHeight == tip
+}
+
+// txOutSetScan is the part of a scantxoutset answer a scan needs
+type txOutSetScan struct {
+	Success   bool   `json:"success"`
+	Height    int64  `json:"height"`
+	BestBlock string `json:"bestblock"`
+	Unspents  []struct {
+		TxID         string      `json:"txid"`
+		Vout         int         `json:"vout"`
+		ScriptPubKey string      `json:"scriptPubKey"`
+		Amount       json.Number `json:"amount"`
+		Height       int64       `json:"height"`
+		BlockHash    string      `json:"blockhash"` // Reported by Core 25 and later
+	} `json:"unspents"`
+}
+
+// ScanTxOutSet finds the addresses' UTXOs created in [startHeight,
+// endHeight] in the node's UTXO set, scanning the addresses as addr()
+// descriptors, then verifies them like a block scan so mempool spends are
+// handled the same way. With endHeight at the tip this is what a block scan
+// of the range returns, without walking the blocks. The second return is
+// false if the node could not run the scan (e.g. another one is in
+// progress), and the caller should fall back to scanning blocks.
+func (s *Service) ScanTxOutSet(addresses []string, startHeight, endHeight int64, opts ScanOptions) (*UTXOScanResult, bool, error) {
+	addresses = uniqueAddresses(addresses)
+	if len(addresses) == 0 {
+		return emptyScanResult("txoutset"), true, nil
+	}
+
+	startTime := getCurrentTimeMs()
+
+	addressScripts, err := s.buildAddressScripts(addresses)
+	if err != nil {
+		return nil, true, err
+	}
+	descriptors := make([]string, len(addresses))
+	for i, address := range addresses {
+		descriptors[i] = fmt.Sprintf("addr(%s)", address)
+	}
+
+	data, err := s.rpcClient.ScanTxOutSet(descriptors)
+	var rpcErr *rpc.RPCError
+	if errors.As(err, &rpcErr) || errors.Is(err, rpc.ErrMethodNotAllowed) {
+		log.Printf("Warning: scantxoutset failed, scanning blocks instead: %v", err)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to scan UTXO set: %w", err)
+	}
+	var scan txOutSetScan
+	if err := json.Unmarshal(data, &scan); err != nil {
+		return nil, true, fmt.Errorf("failed to unmarshal UTXO set scan: %w", err)
+	}
+	if !scan.Success {
+		log.Printf("Warning: scantxoutset was aborted, scanning blocks instead")
+		return nil, false, nil
+	}
+
+	// Older nodes leave out the block hash; look those up by height
+	blockHashes := make(map[int64]string)
+	var utxos []UTXO
+	for _, unspent := range scan.Unspents {
+		// Created before the range, or after the requested tip as the chain
+		// moved on meanwhile
+		if unspent.Height < startHeight || unspent.Height > endHeight {
+			continue
+		}
+		address, ok := addressScripts.Match(unspent.ScriptPubKey)
+		if !ok {
+			continue
+		}
+		satoshis, err := ParseSatoshis(unspent.Amount)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to parse value of %s:%d: %w", unspent.TxID, unspent.Vout, err)
+		}
+
+		blockHash := unspent.BlockHash
+		if blockHash == "" {
+			if blockHash = blockHashes[unspent.Height]; blockHash == "" {
+				blockHash, err = s.rpcClient.GetBlockHash(unspent.Height)
+				if err != nil {
+					return nil, true, fmt.Errorf("failed to get block hash at height %d: %w", unspent.Height, err)
+				}
+				blockHashes[unspent.Height] = blockHash
+			}
+		}
+
+		utxos = append(utxos, UTXO{
+			TxID:          unspent.TxID,
+			Vout:          unspent.Vout,
+			Address:       address,
+			Amount:        float64(satoshis) / satoshisPerBTC,
+			Satoshis:      satoshis,
+			ScriptPubKey:  unspent.ScriptPubKey,
+			Height:        unspent.Height,
+			BlockHash:     blockHash,
+			Confirmations: scan.Height - unspent.Height + 1,
+		})
+	}
+	// Chain order, as a block scan returns them
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Height != utxos[j].Height {
+			return utxos[i].Height < utxos[j].Height
+		}
+		if utxos[i].TxID != utxos[j].TxID {
+			return utxos[i].TxID < utxos[j].TxID
+		}
+		return utxos[i].Vout < utxos[j].Vout
+	})
+
+	result, err := s.verifyUTXOs(utxos, opts)
+	if result == nil {
+		return nil, true, err
+	}
+	result.AddressCount = len(addresses)
+	endTime := getCurrentTimeMs()
+	result.Statistics = &ScanStatistics{
+		Mode:       "txoutset",
+		ScanTimeMs: endTime - startTime,
+	}
+	return result, true, err
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestTxOutSetThresholdSwitch(t *testing.T) {
+	s, chain, node := newTestService(t)
+	s.SetTxOutSetThreshold(2)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	b := rpctest.Address(testParams, "p2tr", 2)
+	c := rpctest.Address(testParams, "p2pkh", 3)
+
+	fund := chain.NewTx(nil, rpctest.PayTo(a, 1000), rpctest.PayTo(b, 2000))
+	chain.AddBlock(fund)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(c, 3000)))
+	chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(c, 900)))
+	chain.AddBlock()
+
+	// At the threshold the blocks are scanned
+	if _, err := s.ScanUTXOsHybrid(encodeAddresses(a, b), 0, chain.Height(), "direct", ScanOptions{}); err != nil {
+		t.Fatalf("scan at the threshold: %v", err)
+	}
+	if calls := node.Calls("scantxoutset"); calls != 0 {
+		t.Fatalf("scan at the threshold called scantxoutset %d times", calls)
+	}
+
+	// Above it the UTXO set is read, with the block scan's answer
+	addresses := encodeAddresses(a, b, c)
+	txOutSet, err := s.ScanUTXOsHybrid(addresses, 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan above the threshold: %v", err)
+	}
+	if calls := node.Calls("scantxoutset"); calls != 1 {
+		t.Fatalf("scan above the threshold called scantxoutset %d times, want 1", calls)
+	}
+	if txOutSet.Statistics == nil || txOutSet.Statistics.Mode != "txoutset" {
+		t.Fatalf("scan above the threshold has statistics %+v, want mode txoutset", txOutSet.Statistics)
+	}
+
+	s.SetTxOutSetThreshold(0)
+	blocks, err := s.ScanUTXOsHybrid(addresses, 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatalf("block scan: %v", err)
+	}
+	if blocks.TotalUTXOs != 3 || blocks.TotalSatoshis != 5900 {
+		t.Fatalf("block scan found %d UTXOs, %d sats", blocks.TotalUTXOs, blocks.TotalSatoshis)
+	}
+	if !reflect.DeepEqual(txOutSet.UTXOs, blocks.UTXOs) {
+		t.Errorf("scantxoutset UTXOs %+v, block scan %+v", txOutSet.UTXOs, blocks.UTXOs)
+	}
+	if txOutSet.TotalSatoshis != blocks.TotalSatoshis {
+		t.Errorf("scantxoutset total %d sats, block scan %d", txOutSet.TotalSatoshis, blocks.TotalSatoshis)
+	}
+
+	// Not from genesis, the UTXO set cannot answer
+	if _, err := s.ScanUTXOsHybrid(addresses, 1, chain.Height(), "direct", ScanOptions{}); err != nil {
+		t.Fatalf("scan from height 1: %v", err)
+	}
+	if calls := node.Calls("scantxoutset"); calls != 1 {
+		t.Errorf("scan from height 1 called scantxoutset, %d calls in all", calls)
+	}
+}
+
+func TestTxOutSetScanAbortedOnCancel(t *testing.T) {
+	s, chain, node := newTestService(t)
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 1000)))
+
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	node.Handle("scantxoutset", func(params []json.RawMessage) (interface{}, error) {
+		var action string
+		if _, err := rpctest.Param(params, 0, &action); err != nil {
+			return nil, err
+		}
+		if action == "abort" {
+			close(aborted)
+			return true, nil
+		}
+		// Like a long scan, running until aborted
+		close(started)
+		select {
+		case <-aborted:
+		case <-time.After(5 * time.Second):
+		}
+		return map[string]interface{}{"success": false}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	addresses := encodeAddresses(rpctest.Address(testParams, "p2wpkh", 1))
+	if _, _, err := s.WithContext(ctx).ScanTxOutSet(addresses, 0, chain.Height(), ScanOptions{}); err == nil {
+		t.Fatal("cancelled scan succeeded")
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled scan was not aborted on the node")
+	}
+}
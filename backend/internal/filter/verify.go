@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"spv-backend/internal/rpc"
+)
+
+// Verification modes of the gettxout pass (see SetVerificationMode)
+const (
+	// VerifyLive checks each UTXO against the node's state at the moment of
+	// its gettxout call. Outputs spent in the mempool or by a new block while
+	// the pass runs can make two scans seconds apart disagree.
+	VerifyLive = "live"
+	// VerifySnapshot checks confirmed spends against the tip captured at the
+	// start of the pass and mempool spends against one gettxspendingprevout
+	// call, so the result reflects a single point in time. A tip change during
+	// the pass is reported in Verification.TipChanged.
+	VerifySnapshot = "snapshot"
+	// VerifySkipped is reported when the pass is disabled (see
+	// SetSkipVerification) and UTXOs are only checked by the block walk
+	VerifySkipped = "skipped"
+)
+
+// Verification describes the chain state a scan's UTXOs were verified against
+type Verification struct {
+	Mode string `json:"mode"`
+	// Tip at the start of the pass, in snapshot mode or with
+	// ScanOptions.RecordTip
+	TipHash   string `json:"tip_hash,omitempty"`
+	TipHeight int64  `json:"tip_height,omitempty"`
+	// A block arrived during the pass, so outputs it spent may still be
+	// reported; rescan for a consistent result. Only checked with TipHash set.
+	TipChanged bool `json:"tip_changed,omitempty"`
+}
+
+// SetVerificationMode selects how UTXOs are checked for spends, VerifyLive
+// (the default) or VerifySnapshot
+func (s *Service) SetVerificationMode(mode string) {
+	s.verificationMode = mode
+}
+
+// SetSkipVerification disables the gettxout pass for every scan. The block
+// walk then decides alone whether an output is spent, so spends in the
+// mempool or in blocks after the scanned range are not seen.
+func (s *Service) SetSkipVerification(skip bool) {
+	s.skipVerification = skip
+}
+
+// chainTip is the node's best block
+type chainTip struct {
+	Hash   string `json:"bestblockhash"`
+	Height int64  `json:"blocks"`
+}
+
+// getChainTip reads the best block's hash and height in one call, so they
+// cannot straddle a new block
+func (s *Service) getChainTip() (chainTip, error) {
+	var tip chainTip
+	data, err := s.rpcClient.GetBlockchainInfo()
+	if err != nil {
+		return tip, err
+	}
+	if err := json.Unmarshal(data, &tip); err != nil {
+		return tip, fmt.Errorf("failed to parse blockchain info: %w", err)
+	}
+	return tip, nil
+}
+
+// verifySnapshot is the state captured at the start of a snapshot pass
+type verifySnapshot struct {
+	tip           chainTip
+	mempoolSpends map[string]bool // "txid:vout" of outputs spent in the mempool
+}
+
+// takeVerifySnapshot captures the tip and, unless mempool spends are
+// ignored, which of the UTXOs the mempool spends
+func (s *Service) takeVerifySnapshot(utxos []UTXO, opts ScanOptions) (*verifySnapshot, error) {
+	tip, err := s.getChainTip()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tip for verification snapshot: %w", err)
+	}
+
+	snapshot := &verifySnapshot{tip: tip, mempoolSpends: make(map[string]bool)}
+	if opts.IgnoreMempoolSpends || len(utxos) == 0 {
+		return snapshot, nil
+	}
+
+	outpoints := make([]rpc.OutPoint, len(utxos))
+	for i, utxo := range utxos {
+		outpoints[i] = rpc.OutPoint{TxID: utxo.TxID, Vout: utxo.Vout}
+	}
+
+	// One call, so every outpoint is checked against the same mempool
+	result, err := s.rpcClient.GetTxSpendingPrevOut(outpoints)
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeMethodNotFound {
+			return nil, fmt.Errorf("snapshot verification requires gettxspendingprevout (Bitcoin Core 24+): %w", err)
+		}
+		return nil, fmt.Errorf("failed to get mempool spends: %w", err)
+	}
+
+	var spends []struct {
+		TxID         string `json:"txid"`
+		Vout         int    `json:"vout"`
+		SpendingTxID string `json:"spendingtxid"`
+	}
+	if err := json.Unmarshal(result, &spends); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mempool spends: %w", err)
+	}
+	for _, spend := range spends {
+		if spend.SpendingTxID != "" {
+			snapshot.mempoolSpends[fmt.Sprintf("%s:%d", spend.TxID, spend.Vout)] = true
+		}
+	}
+
+	return snapshot, nil
+}
+
+// spentInMempool reports whether the snapshot's mempool spends the UTXO
+func (v *verifySnapshot) spentInMempool(utxo UTXO) bool {
+	return v.mempoolSpends[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)]
+}
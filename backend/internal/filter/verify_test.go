@@ -0,0 +1,157 @@
+package filter
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newVerifyChain returns a service over five blocks each paying a once, and
+// the funding transactions in chain order
+func newVerifyChain(t *testing.T, mode string) (*Service, *rpctest.Chain, *rpctest.Node, []*wire.MsgTx) {
+	t.Helper()
+	s, chain, node := newTestService(t)
+	s.SetVerificationMode(mode)
+	a := rpctest.Address(testParams, "p2wpkh", 1)
+	var funds []*wire.MsgTx
+	for i := 0; i < 5; i++ {
+		fund := chain.NewTx(nil, rpctest.PayTo(a, int64(1000+i)))
+		chain.AddBlock(fund)
+		funds = append(funds, fund)
+	}
+	return s, chain, node, funds
+}
+
+// duringVerification runs fn once, when the verification pass makes its
+// first gettxout call, after the blocks were walked
+func duringVerification(node *rpctest.Node, fn func()) {
+	var once sync.Once
+	node.Wrap("gettxout", func(next rpctest.Handler) rpctest.Handler {
+		return func(params []json.RawMessage) (interface{}, error) {
+			once.Do(fn)
+			return next(params)
+		}
+	})
+}
+
+// scanA scans every block for the address the verify chain pays
+func scanA(t *testing.T, s *Service, chain *rpctest.Chain) *UTXOScanResult {
+	t.Helper()
+	result, err := s.ScanUTXOsHybrid(encodeAddresses(rpctest.Address(testParams, "p2wpkh", 1)), 0, chain.Height(), "direct", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	return result
+}
+
+// A mempool spend arriving while the pass runs: live verification reports
+// the current state, so the output is dropped; snapshot verification reports
+// the state when the pass started, so it is kept
+func TestVerificationOfMempoolSpendDuringPass(t *testing.T) {
+	for mode, wantUTXOs := range map[string]int{VerifyLive: 4, VerifySnapshot: 5} {
+		t.Run(mode, func(t *testing.T) {
+			s, chain, node, funds := newVerifyChain(t, mode)
+			other := rpctest.Address(testParams, "p2tr", 9)
+			// The last output in chain order is checked after the spend
+			duringVerification(node, func() {
+				chain.AddToMempool(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funds[4], 0)}, rpctest.PayTo(other, 900)))
+			})
+
+			result := scanA(t, s, chain)
+			if result.TotalUTXOs != wantUTXOs {
+				t.Errorf("found %d UTXOs, want %d", result.TotalUTXOs, wantUTXOs)
+			}
+			if result.Verification == nil || result.Verification.Mode != mode {
+				t.Fatalf("verification %+v, want mode %s", result.Verification, mode)
+			}
+			if mode == VerifySnapshot && (result.Verification.TipHash != chain.Tip().Hash || result.Verification.TipChanged) {
+				t.Errorf("snapshot %+v, want the unchanged tip %s", result.Verification, chain.Tip().Hash)
+			}
+
+			// The next scan sees the spend either way
+			if again := scanA(t, s, chain); again.TotalUTXOs != 4 {
+				t.Errorf("rescan found %d UTXOs, want 4", again.TotalUTXOs)
+			}
+		})
+	}
+}
+
+func TestSnapshotVerificationSeesEarlierMempoolSpends(t *testing.T) {
+	s, chain, node, funds := newVerifyChain(t, VerifySnapshot)
+	other := rpctest.Address(testParams, "p2tr", 9)
+	chain.AddToMempool(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funds[0], 0)}, rpctest.PayTo(other, 900)))
+
+	result := scanA(t, s, chain)
+	if result.TotalUTXOs != 4 {
+		t.Errorf("found %d UTXOs, want the mempool spend excluded", result.TotalUTXOs)
+	}
+	// One gettxspendingprevout call covers every output
+	if calls := node.Calls("gettxspendingprevout"); calls != 1 {
+		t.Errorf("%d gettxspendingprevout calls, want 1", calls)
+	}
+}
+
+func TestSnapshotVerificationFlagsTipChange(t *testing.T) {
+	s, chain, node, _ := newVerifyChain(t, VerifySnapshot)
+	start := chain.Tip().Hash
+	duringVerification(node, func() { chain.AddBlock() })
+
+	result := scanA(t, s, chain)
+	if result.Verification.TipHash != start || !result.Verification.TipChanged {
+		t.Errorf("verification %+v, want the tip at the start %s flagged as changed", result.Verification, start)
+	}
+}
+
+func TestSnapshotVerificationNeedsMempoolSpendLookup(t *testing.T) {
+	s, chain, node, _ := newVerifyChain(t, VerifySnapshot)
+	// Bitcoin Core before 24
+	node.Handle("gettxspendingprevout", func([]json.RawMessage) (interface{}, error) {
+		return nil, &rpc.RPCError{Code: rpc.ErrCodeMethodNotFound, Message: "Method not found"}
+	})
+
+	_, err := s.ScanUTXOsHybrid(encodeAddresses(rpctest.Address(testParams, "p2wpkh", 1)), 0, chain.Height(), "direct", ScanOptions{})
+	if err == nil {
+		t.Fatal("snapshot verification without gettxspendingprevout succeeded")
+	}
+
+	// Ignoring mempool spends needs no lookup
+	result, err := s.ScanUTXOsHybrid(encodeAddresses(rpctest.Address(testParams, "p2wpkh", 1)), 0, chain.Height(), "direct", ScanOptions{IgnoreMempoolSpends: true})
+	if err != nil || result.TotalUTXOs != 5 {
+		t.Errorf("scan ignoring mempool spends: %v", err)
+	}
+}
+
+func TestSkipVerificationMakesNoVerificationCalls(t *testing.T) {
+	for _, mode := range []string{VerifyLive, VerifySnapshot} {
+		t.Run(mode, func(t *testing.T) {
+			s, chain, node, funds := newVerifyChain(t, mode)
+			s.SetSkipVerification(true)
+			other := rpctest.Address(testParams, "p2tr", 9)
+			chain.AddToMempool(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funds[0], 0)}, rpctest.PayTo(other, 900)))
+
+			for _, scanMode := range []string{"direct", "spv"} {
+				result, err := s.ScanUTXOsHybrid(encodeAddresses(rpctest.Address(testParams, "p2wpkh", 1)), 0, chain.Height(), scanMode, ScanOptions{BalanceOnly: true})
+				if err != nil {
+					t.Fatalf("%s scan: %v", scanMode, err)
+				}
+				// The tradeoff: the mempool spend goes unnoticed
+				if result.TotalUTXOs != 5 || result.Balance == nil || result.Balance.ConfirmedSatoshis != 5010 {
+					t.Errorf("%s scan found %d UTXOs, balance %+v; want all 5 confirmed", scanMode, result.TotalUTXOs, result.Balance)
+				}
+				if result.Verification == nil || result.Verification.Mode != VerifySkipped {
+					t.Errorf("%s scan verification %+v, want mode %s", scanMode, result.Verification, VerifySkipped)
+				}
+			}
+			for _, method := range []string{"gettxout", "gettxspendingprevout"} {
+				if calls := node.Calls(method); calls != 0 {
+					t.Errorf("%s called %d times with verification skipped", method, calls)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package filter
+
+import "sync"
+
+// runParallel calls fn for every index in [0, n) using at most workers
+// goroutines. It returns the error of the lowest failing index, if any.
+func runParallel(n, workers int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetWorkers sets the concurrency of the filter pass (fetching and matching
+// filters) and of block fetching, so they can be tuned independently
+func (s *Service) SetWorkers(filterWorkers, blockWorkers int) {
+	if filterWorkers > 0 {
+		s.filterWorkers = filterWorkers
+	}
+	if blockWorkers > 0 {
+		s.blockWorkers = blockWorkers
+	}
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpctest"
+)
+
+// concurrency records the most calls of a method in flight at once
+type concurrency struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+// track wraps a handler, holding each call briefly so concurrent ones overlap
+func (c *concurrency) track(next rpctest.Handler) rpctest.Handler {
+	return func(params []json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		c.inFlight++
+		if c.inFlight > c.max {
+			c.max = c.inFlight
+		}
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			c.inFlight--
+			c.mu.Unlock()
+		}()
+		time.Sleep(5 * time.Millisecond)
+		return next(params)
+	}
+}
+
+func (c *concurrency) peak() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.max
+}
+
+func TestScanPassesRespectTheirWorkerCaps(t *testing.T) {
+	s, chain, node := newTestService(t)
+	s.SetWorkers(4, 2)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	for i := 0; i < 24; i++ {
+		chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, int64(1000+i))))
+	}
+
+	var filters, blocks concurrency
+	node.Wrap("getblockfilter", filters.track)
+	node.Wrap("getblock", blocks.track)
+
+	result, err := s.ScanUTXOsHybrid(encodeAddresses(address), 1, chain.Height(), "spv", ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if result.TotalUTXOs != 24 {
+		t.Fatalf("scan found %d UTXOs, want 24", result.TotalUTXOs)
+	}
+
+	// Each pass runs up to its own cap, not the other's
+	if peak := filters.peak(); peak != 4 {
+		t.Errorf("filter pass peaked at %d concurrent calls, want 4", peak)
+	}
+	if peak := blocks.peak(); peak != 2 {
+		t.Errorf("block fetches peaked at %d concurrent calls, want 2", peak)
+	}
+}
@@ -0,0 +1,136 @@
+// Package merkle provides Bitcoin transaction merkle tree functionality
+package merkle
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Branch is the merkle branch proving a transaction's inclusion in a block
+type Branch struct {
+	TxID   string   `json:"txid"`
+	Index  int      `json:"index"`  // Position of the transaction in the block
+	Branch []string `json:"branch"` // Sibling hashes from leaf to root (display byte order)
+}
+
+// parseTxIDs converts display-order txids into hashes
+func parseTxIDs(txids []string) ([]chainhash.Hash, error) {
+	hashes := make([]chainhash.Hash, len(txids))
+	for i, txid := range txids {
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid txid %s: %w", txid, err)
+		}
+		hashes[i] = *hash
+	}
+	return hashes, nil
+}
+
+// hashPair returns the double SHA256 of the concatenated pair
+func hashPair(left, right chainhash.Hash) chainhash.Hash {
+	var buf [chainhash.HashSize * 2]byte
+	copy(buf[:chainhash.HashSize], left[:])
+	copy(buf[chainhash.HashSize:], right[:])
+	return chainhash.DoubleHashH(buf[:])
+}
+
+// BuildTree returns every level of the merkle tree, leaves first and root last.
+// Odd levels duplicate their last hash, as in Bitcoin's merkle construction.
+func BuildTree(leaves []chainhash.Hash) [][]chainhash.Hash {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	levels := [][]chainhash.Hash{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([]chainhash.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels
+}
+
+// branchFromTree collects the sibling hashes for the leaf at index
+func branchFromTree(levels [][]chainhash.Hash, index int) []string {
+	var branch []string
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index // Odd level, paired with itself
+		}
+		branch = append(branch, level[sibling].String())
+		index /= 2
+	}
+	return branch
+}
+
+// BuildBranches computes the merkle root and the branch for every transaction,
+// building the tree once instead of once per transaction
+func BuildBranches(txids []string) (string, []Branch, error) {
+	leaves, err := parseTxIDs(txids)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(leaves) == 0 {
+		return "", nil, fmt.Errorf("no transactions")
+	}
+
+	levels := BuildTree(leaves)
+	root := levels[len(levels)-1][0]
+
+	branches := make([]Branch, len(txids))
+	for i, txid := range txids {
+		branches[i] = Branch{
+			TxID:   txid,
+			Index:  i,
+			Branch: branchFromTree(levels, i),
+		}
+	}
+
+	return root.String(), branches, nil
+}
+
+// ComputeRoot computes the merkle root of the transactions
+func ComputeRoot(txids []string) (string, error) {
+	root, _, err := BuildBranches(txids)
+	return root, err
+}
+
+// RootFromBranch folds a branch back up to the merkle root it commits to
+func RootFromBranch(txid string, index int, branch []string) (string, error) {
+	current, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return "", fmt.Errorf("invalid txid %s: %w", txid, err)
+	}
+
+	hash := *current
+	for _, siblingHex := range branch {
+		sibling, err := chainhash.NewHashFromStr(siblingHex)
+		if err != nil {
+			return "", fmt.Errorf("invalid branch hash %s: %w", siblingHex, err)
+		}
+		if index&1 == 1 {
+			hash = hashPair(*sibling, hash)
+		} else {
+			hash = hashPair(hash, *sibling)
+		}
+		index /= 2
+	}
+
+	return hash.String(), nil
+}
+
+// VerifyBranch reports whether the branch connects the transaction to the root
+func VerifyBranch(txid string, index int, branch []string, root string) bool {
+	computed, err := RootFromBranch(txid, index, branch)
+	return err == nil && computed == root
+}
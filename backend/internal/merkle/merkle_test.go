@@ -0,0 +1,92 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Mainnet block 100000
+var (
+	block100000Root = "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766"
+	block100000Txs  = []string{
+		"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+		"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+		"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+		"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+	}
+)
+
+func TestBuildBranchesMainnetBlock(t *testing.T) {
+	root, branches, err := BuildBranches(block100000Txs)
+	if err != nil {
+		t.Fatalf("build branches: %v", err)
+	}
+	if root != block100000Root {
+		t.Fatalf("root %s, want %s", root, block100000Root)
+	}
+	for _, b := range branches {
+		if !VerifyBranch(b.TxID, b.Index, b.Branch, root) {
+			t.Errorf("branch of tx %d does not reconstruct the root", b.Index)
+		}
+	}
+}
+
+// testTxIDs returns n distinct txids
+func testTxIDs(n int) []string {
+	txids := make([]string, n)
+	for i := range txids {
+		txids[i] = chainhash.DoubleHashH([]byte(fmt.Sprintf("tx %d", i))).String()
+	}
+	return txids
+}
+
+func TestBuildBranchesReconstructRoot(t *testing.T) {
+	// Odd counts exercise the duplicated last hash on each level
+	for _, n := range []int{1, 2, 3, 5, 7, 8, 13} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			txids := testTxIDs(n)
+			root, branches, err := BuildBranches(txids)
+			if err != nil {
+				t.Fatalf("build branches: %v", err)
+			}
+			if len(branches) != n {
+				t.Fatalf("got %d branches, want %d", len(branches), n)
+			}
+			for i, b := range branches {
+				if b.TxID != txids[i] || b.Index != i {
+					t.Errorf("branch %d is for tx %s at %d", i, b.TxID, b.Index)
+				}
+				computed, err := RootFromBranch(b.TxID, b.Index, b.Branch)
+				if err != nil {
+					t.Fatalf("fold branch %d: %v", i, err)
+				}
+				if computed != root {
+					t.Errorf("branch %d folds to %s, want root %s", i, computed, root)
+				}
+			}
+			if n == 1 && (root != txids[0] || len(branches[0].Branch) != 0) {
+				t.Errorf("single transaction block has root %s and branch %v", root, branches[0].Branch)
+			}
+		})
+	}
+}
+
+func TestVerifyBranchRejectsWrongPosition(t *testing.T) {
+	txids := testTxIDs(5)
+	root, branches, err := BuildBranches(txids)
+	if err != nil {
+		t.Fatalf("build branches: %v", err)
+	}
+	b := branches[2]
+	if VerifyBranch(b.TxID, b.Index+1, b.Branch, root) {
+		t.Error("branch verified at the wrong index")
+	}
+	if VerifyBranch(txids[3], b.Index, b.Branch, root) {
+		t.Error("branch verified for another transaction")
+	}
+	if _, _, err := BuildBranches(nil); err == nil {
+		t.Error("branches built for a block without transactions")
+	}
+}
@@ -0,0 +1,53 @@
+package ot
+
+import (
+	"strconv"
+	"strings"
+)
+
+// requestDataPrefix starts the OP_RETURN payload of an OT request, as
+// returned in the data field of validateotrequest
+const requestDataPrefix = "OT_REQUEST|"
+
+// Payload is an OP_RETURN payload in the OT pipe format, e.g.
+// "OT_REQUEST|<field>|<field>|..."
+type Payload struct {
+	Type   string   `json:"type"` // Leading segment, e.g. "OT_REQUEST"
+	Fields []string `json:"fields"`
+}
+
+// ParsePayload splits an OP_RETURN payload in the OT pipe format. ok is false
+// for payloads that do not start with an "OT_" type segment.
+func ParsePayload(payload []byte) (*Payload, bool) {
+	segments := strings.Split(string(payload), "|")
+	if len(segments) < 2 || !strings.HasPrefix(segments[0], "OT_") {
+		return nil, false
+	}
+	return &Payload{Type: segments[0], Fields: segments[1:]}, true
+}
+
+// MatchData matches an OP_RETURN payload equal to an OT data string
+func MatchData(data string) func(payload []byte) bool {
+	return func(payload []byte) bool {
+		return string(payload) == data
+	}
+}
+
+// MatchRequest matches the OP_RETURN payload of an OT request from fromAID to
+// toAID for amount satoshis. The payload also carries the request timestamp,
+// so the fields are compared individually rather than rebuilding the string.
+func MatchRequest(fromAID, toAID string, amount int64) func(payload []byte) bool {
+	amountField := strconv.FormatInt(amount, 10)
+	return func(payload []byte) bool {
+		data := string(payload)
+		if !strings.HasPrefix(data, requestDataPrefix) {
+			return false
+		}
+
+		fields := make(map[string]bool)
+		for _, field := range strings.Split(strings.TrimPrefix(data, requestDataPrefix), "|") {
+			fields[field] = true
+		}
+		return fields[fromAID] && fields[toAID] && fields[amountField]
+	}
+}
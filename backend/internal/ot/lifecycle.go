@@ -0,0 +1,177 @@
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"spv-backend/internal/rpc"
+)
+
+// ErrRequestNotFound is returned when no pending OT request has the given ID
+var ErrRequestNotFound = errors.New("OT request not found")
+
+// Request states reported by Lifecycle
+const (
+	StateUnconfirmed = "unconfirmed" // Request transaction is still in the mempool
+	StatePending     = "pending"     // Confirmed, waiting for a cycle
+	StateCycleFound  = "cycle_found" // Part of at least one detected cycle
+)
+
+// Request is a pending OT request as reported by listotrequests
+type Request struct {
+	RequestID string `json:"request_id"`
+	TxID      string `json:"txid"`
+	FromAID   string `json:"from_aid"`
+	ToAID     string `json:"to_aid"`
+	Amount    int64  `json:"amount"` // Satoshis
+	AmountBTC string `json:"amount_btc"`
+	Time      int64  `json:"time"` // 0 while unconfirmed
+}
+
+// rpcRequest is a request as reported by listotrequests
+type rpcRequest struct {
+	RequestID string `json:"request_id"`
+	TxID      string `json:"txid"`
+	FromAID   string `json:"from_aid"`
+	ToAID     string `json:"to_aid"`
+	Amount    int64  `json:"amount"`
+	AmountBTC string `json:"amountBTC"`
+	Time      int64  `json:"time"`
+}
+
+// Validation is the node's validateotrequest verdict for a request's fields
+type Validation struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"` // The node's reason when not valid
+}
+
+// Lifecycle is everything known about one OT request: the request itself,
+// whether its fields validate, the cycles it closes and its transactions
+type Lifecycle struct {
+	Request        Request    `json:"request"`
+	State          string     `json:"state"`
+	Validation     Validation `json:"validation"`
+	Cycles         []Cycle    `json:"cycles"`
+	BroadcastTxIDs []string   `json:"broadcast_txids"`
+}
+
+// ListRequests returns the pending OT requests of aid, or of every AID if empty
+func (s *Service) ListRequests(aid string) ([]Request, error) {
+	result, err := s.rpcClient.ListOTRequests(aid)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []rpcRequest
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal OT requests: %w", err)
+	}
+
+	requests := make([]Request, 0, len(raw))
+	for _, r := range raw {
+		requests = append(requests, Request{
+			RequestID: r.RequestID,
+			TxID:      r.TxID,
+			FromAID:   r.FromAID,
+			ToAID:     r.ToAID,
+			Amount:    r.Amount,
+			AmountBTC: r.AmountBTC,
+			Time:      r.Time,
+		})
+	}
+	return requests, nil
+}
+
+// RequestCycles returns the cycles closed by requests from fromAID to toAID
+func (s *Service) RequestCycles(fromAID, toAID string) ([]Cycle, error) {
+	result, err := s.rpcClient.GetRequestCycles(fromAID, toAID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Cycles json.RawMessage `json:"cycles"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request cycles: %w", err)
+	}
+	if len(response.Cycles) == 0 || string(response.Cycles) == "null" {
+		return []Cycle{}, nil
+	}
+
+	return parseCycles(response.Cycles)
+}
+
+// Validate asks the node whether a request's fields are valid. A rejection
+// by the node is a verdict, not an error.
+func (s *Service) Validate(fromAID, toAID string, amount int64) (Validation, error) {
+	result, err := s.rpcClient.ValidateOTRequest(fromAID, toAID, amount)
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) {
+			return Validation{Valid: false, Error: rpcErr.Message}, nil
+		}
+		return Validation{}, err
+	}
+
+	var response struct {
+		Valid bool `json:"valid"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil {
+		return Validation{}, fmt.Errorf("failed to unmarshal validateotrequest result: %w", err)
+	}
+	return Validation{Valid: response.Valid}, nil
+}
+
+// Lifecycle combines listotrequests, getrequestcycles and validateotrequest
+// into the state of the request with the given request ID or txid. An aid
+// narrows the listotrequests lookup to one AID's requests.
+func (s *Service) Lifecycle(id, aid string) (*Lifecycle, error) {
+	requests, err := s.ListRequests(aid)
+	if err != nil {
+		return nil, err
+	}
+
+	var request *Request
+	for i := range requests {
+		if requests[i].RequestID == id || requests[i].TxID == id {
+			request = &requests[i]
+			break
+		}
+	}
+	if request == nil {
+		return nil, ErrRequestNotFound
+	}
+
+	cycles, err := s.RequestCycles(request.FromAID, request.ToAID)
+	if err != nil {
+		return nil, err
+	}
+
+	validation, err := s.Validate(request.FromAID, request.ToAID, request.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	lifecycle := &Lifecycle{
+		Request:        *request,
+		Validation:     validation,
+		Cycles:         cycles,
+		BroadcastTxIDs: []string{},
+	}
+	if request.TxID != "" {
+		lifecycle.BroadcastTxIDs = append(lifecycle.BroadcastTxIDs, request.TxID)
+	}
+
+	switch {
+	case request.Time == 0:
+		lifecycle.State = StateUnconfirmed
+	case len(cycles) > 0:
+		lifecycle.State = StateCycleFound
+	default:
+		lifecycle.State = StatePending
+	}
+
+	return lifecycle, nil
+}
@@ -0,0 +1,157 @@
+// Package ot provides typed access to the node's OT request RPCs
+package ot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"spv-backend/internal/rpc"
+)
+
+// Service handles OT request queries
+type Service struct {
+	rpcClient *rpc.Client
+}
+
+// NewService creates a new OT service
+func NewService(rpcClient *rpc.Client) *Service {
+	return &Service{rpcClient: rpcClient}
+}
+
+// WithContext returns a copy of the service whose RPC calls are bound to ctx
+func (s *Service) WithContext(ctx context.Context) *Service {
+	bound := *s
+	bound.rpcClient = s.rpcClient.WithContext(ctx)
+	return &bound
+}
+
+// CycleRequest is one OT request taking part in a cycle
+type CycleRequest struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	AmountBTC string `json:"amount_btc"`
+	Time      int64  `json:"time"`
+}
+
+// Cycle is a closed loop of OT requests between AIDs
+type Cycle struct {
+	ID               string         `json:"cycle_id"`
+	ParticipantCount int            `json:"participant_count"`
+	MinAmountBTC     string         `json:"min_amount_btc"`
+	TotalAmountBTC   string         `json:"total_amount_btc"`
+	Timestamp        int64          `json:"timestamp"`
+	BlockHash        string         `json:"block_hash,omitempty"`
+	Participants     []string       `json:"participants"`
+	Requests         []CycleRequest `json:"requests"`
+	Path             string         `json:"cycle_path,omitempty"` // Set when paths are requested
+}
+
+// rpcCycle is a cycle as reported by listotcycles and getrequestcycles
+type rpcCycle struct {
+	ID               string   `json:"cycle No."`
+	ParticipantCount int      `json:"participantCount"`
+	MinAmountBTC     string   `json:"minAmountBTC"`
+	TotalAmountBTC   string   `json:"totalAmountBTC"`
+	Timestamp        int64    `json:"timestamp"`
+	BlockHash        string   `json:"blockHash"`
+	Participants     []string `json:"participants"`
+	Requests         []struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		AmountBTC string `json:"amountBTC"`
+		Time      int64  `json:"time"`
+	} `json:"requests"`
+	Path string `json:"cycle_path"`
+}
+
+// toCycle converts the node's cycle representation
+func (c *rpcCycle) toCycle() Cycle {
+	cycle := Cycle{
+		ID:               c.ID,
+		ParticipantCount: c.ParticipantCount,
+		MinAmountBTC:     c.MinAmountBTC,
+		TotalAmountBTC:   c.TotalAmountBTC,
+		Timestamp:        c.Timestamp,
+		BlockHash:        c.BlockHash,
+		Participants:     c.Participants,
+		Requests:         make([]CycleRequest, 0, len(c.Requests)),
+		Path:             c.Path,
+	}
+	if cycle.Participants == nil {
+		cycle.Participants = []string{}
+	}
+	for _, req := range c.Requests {
+		cycle.Requests = append(cycle.Requests, CycleRequest{
+			From:      req.From,
+			To:        req.To,
+			AmountBTC: req.AmountBTC,
+			Time:      req.Time,
+		})
+	}
+	return cycle
+}
+
+// parseCycles converts a JSON array of node cycles
+func parseCycles(data json.RawMessage) ([]Cycle, error) {
+	var raw []rpcCycle
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cycles: %w", err)
+	}
+
+	cycles := make([]Cycle, 0, len(raw))
+	for i := range raw {
+		cycles = append(cycles, raw[i].toCycle())
+	}
+	return cycles, nil
+}
+
+// CycleFilter selects the cycles returned by ListCycles
+type CycleFilter struct {
+	AID       string // Empty lists cycles for every AID
+	MinHeight *int64
+	MaxHeight *int64
+	ShowPaths bool
+}
+
+// ListCycles returns the cycles detected by the node's OT scanner
+func (s *Service) ListCycles(filter CycleFilter) ([]Cycle, error) {
+	options := map[string]interface{}{
+		"show_paths":         filter.ShowPaths,
+		"group_by_structure": false,
+		"include_analysis":   false,
+	}
+
+	result, err := s.rpcClient.ListOTCycles(filter.AID, filter.MinHeight, filter.MaxHeight, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCycles(result)
+}
+
+// Page is a window of a result list
+type Page struct {
+	Total      int  `json:"total"`
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	NextOffset *int `json:"next_offset,omitempty"` // Set when more results remain
+}
+
+// PageCycles returns the cycles in [offset, offset+limit) with page details
+func PageCycles(cycles []Cycle, limit, offset int) ([]Cycle, Page) {
+	page := Page{Total: len(cycles), Limit: limit, Offset: offset}
+
+	if offset >= len(cycles) {
+		return []Cycle{}, page
+	}
+
+	end := offset + limit
+	if end < len(cycles) {
+		page.NextOffset = &end
+	} else {
+		end = len(cycles)
+	}
+
+	return cycles[offset:end], page
+}
@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrCallBudgetExceeded is returned once a request has used up its RPC call budget
+var ErrCallBudgetExceeded = errors.New("RPC call budget exceeded")
+
+// callBudget counts the RPC calls made on behalf of one request
+type callBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+type callBudgetKey struct{}
+
+// WithCallBudget returns a context that allows at most limit RPC calls by
+// clients bound to it (see Client.WithContext). A limit <= 0 is unlimited.
+func WithCallBudget(ctx context.Context, limit int) context.Context {
+	if limit <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, callBudgetKey{}, &callBudget{limit: int64(limit)})
+}
+
+// CallBudgetExceeded reports whether a call was refused by ctx's budget
+func CallBudgetExceeded(ctx context.Context) bool {
+	budget, ok := ctx.Value(callBudgetKey{}).(*callBudget)
+	return ok && budget.used.Load() > budget.limit
+}
+
+// spendCallBudget counts calls against the bound context's budget. Each
+// request in a batch counts as a call, since the node executes each one.
+func (c *Client) spendCallBudget(calls int) error {
+	budget, ok := c.requestContext().Value(callBudgetKey{}).(*callBudget)
+	if !ok {
+		return nil
+	}
+	if budget.used.Add(int64(calls)) > budget.limit {
+		return fmt.Errorf("%w: limit of %d calls per request", ErrCallBudgetExceeded, budget.limit)
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -17,6 +20,49 @@ type Client struct {
 	user     string
 	password string
 	client   *http.Client
+
+	allowedMethods map[string]bool // nil means every method is allowed
+	deniedMethods  map[string]bool // Refused even when allowed
+
+	ctx context.Context // Bound by WithContext, nil means no deadline
+
+	coalescer *coalescer // Shared by bound copies, nil unless WithCallCoalescing
+
+	limiter *concurrencyLimiter // Shared by bound copies, nil unless WithMaxConcurrent
+}
+
+// Option configures optional Client behavior
+type Option func(*Client)
+
+// ErrMethodNotAllowed is returned when a call targets a method outside the allowlist
+var ErrMethodNotAllowed = errors.New("RPC method not allowed")
+
+// WithMethodAllowlist restricts the client to the given RPC methods.
+// An empty list leaves the client unrestricted.
+func WithMethodAllowlist(methods []string) Option {
+	return func(c *Client) {
+		if len(methods) == 0 {
+			return
+		}
+		c.allowedMethods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			c.allowedMethods[method] = true
+		}
+	}
+}
+
+// WithMethodDenylist refuses the given RPC methods. It is checked after the
+// allowlist, so a method must be allowed and not denied to be called.
+func WithMethodDenylist(methods []string) Option {
+	return func(c *Client) {
+		if len(methods) == 0 {
+			return
+		}
+		c.deniedMethods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			c.deniedMethods[method] = true
+		}
+	}
 }
 
 // RPCRequest represents a JSON-RPC request
@@ -40,9 +86,28 @@ type RPCError struct {
 	Message string `json:"message"`
 }
 
+// Bitcoin Core RPC error codes the API maps to specific responses
+const (
+	ErrCodeInvalidAddressOrKey = -5     // Also returned for unknown transactions and blocks
+	ErrCodeInvalidParameter    = -8     // Also returned for heights beyond the tip
+	ErrCodeMethodNotFound      = -32601 // The node does not implement the method
+)
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
+// IsNoTxIndex reports whether err is getrawtransaction failing to find a
+// transaction outside the mempool because the node runs without -txindex
+// and no block hash was given
+func IsNoTxIndex(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == ErrCodeInvalidAddressOrKey && strings.Contains(rpcErr.Message, "-txindex")
+}
+
 // NewClient creates a new Bitcoin Core RPC client
-func NewClient(host, port, user, password string) *Client {
-	return &Client{
+func NewClient(host, port, user, password string, opts ...Option) *Client {
+	c := &Client{
 		host:     host,
 		port:     port,
 		user:     user,
@@ -51,17 +116,135 @@ func NewClient(host, port, user, password string) *Client {
 			Timeout: 30 * time.Second,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithContext returns a copy of the client whose requests are bound to ctx,
+// so they are abandoned once the context is cancelled or times out
+func (c *Client) WithContext(ctx context.Context) *Client {
+	bound := *c
+	bound.ctx = ctx
+	return &bound
+}
+
+// requestContext returns the bound context, or Background when unbound
+func (c *Client) requestContext() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// Context returns the context the client's requests are bound to
+func (c *Client) Context() context.Context {
+	return c.requestContext()
+}
+
+// checkMethod returns an error if the method is not on the configured
+// allowlist or is on the denylist
+func (c *Client) checkMethod(method string) error {
+	if c.allowedMethods != nil && !c.allowedMethods[method] {
+		return fmt.Errorf("%w: %s", ErrMethodNotAllowed, method)
+	}
+	if c.deniedMethods[method] {
+		return fmt.Errorf("%w: %s is denied", ErrMethodNotAllowed, method)
+	}
+	return nil
+}
+
+// maxErrorBodyLen caps how much of an unexpected HTTP response is kept in errors
+const maxErrorBodyLen = 256
+
+var (
+	// ErrUnauthorized is wrapped by HTTP 401/403 responses (bad RPC credentials)
+	ErrUnauthorized = errors.New("RPC authentication failed")
+	// ErrNodeUnavailable is wrapped by HTTP 5xx responses without a JSON-RPC error
+	ErrNodeUnavailable = errors.New("RPC server error")
+)
+
+// HTTPStatusError is returned when the node answers with a non-2xx HTTP status
+// and no JSON-RPC error body, e.g. an HTML error page
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string // Truncated to maxErrorBodyLen
+}
+
+func (e *HTTPStatusError) Error() string {
+	msg := fmt.Sprintf("unexpected HTTP status %d from RPC server", e.StatusCode)
+	if kind := e.Unwrap(); kind != nil {
+		msg = fmt.Sprintf("%v: HTTP %d", kind, e.StatusCode)
+	}
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// Unwrap lets callers distinguish auth failures from server errors with errors.Is
+func (e *HTTPStatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode >= 500:
+		return ErrNodeUnavailable
+	}
+	return nil
+}
+
+// checkHTTPStatus returns an HTTPStatusError for non-2xx responses. Bitcoin
+// Core reports RPC errors with 404/500 and a JSON-RPC body, so responses that
+// carry a JSON-RPC error are left for the caller to parse.
+func checkHTTPStatus(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var single wireResponse
+	if err := json.Unmarshal(body, &single); err == nil && single.Error != nil {
+		return nil
+	}
+	var batch []wireResponse
+	if err := json.Unmarshal(body, &batch); err == nil && len(batch) > 0 {
+		return nil
+	}
+
+	text := string(body)
+	if len(text) > maxErrorBodyLen {
+		text = text[:maxErrorBodyLen] + "..."
+	}
+	return &HTTPStatusError{StatusCode: resp.StatusCode, Body: text}
 }
 
 // Call makes a JSON-RPC call to Bitcoin Core
 func (c *Client) Call(method string, params ...interface{}) (json.RawMessage, error) {
-	// Prepare request
-	reqBody := RPCRequest{
+	if err := c.checkMethod(method); err != nil {
+		return nil, err
+	}
+	if err := c.spendCallBudget(1); err != nil {
+		return nil, err
+	}
+	if c.coalescer != nil && coalescedMethods[method] {
+		var tag string
+		if ids := c.boundRequestIDs(); ids != nil {
+			tag = ids.next()
+		}
+		return c.coalescer.call(c.requestContext(), method, params, tag)
+	}
+
+	// Prepare request, tagged with the originating request's ID if bound to one
+	request := RPCRequest{
 		Jsonrpc: "1.0",
 		Method:  method,
 		Params:  params,
 		ID:      1,
 	}
+	var reqBody interface{} = request
+	if ids := c.boundRequestIDs(); ids != nil {
+		reqBody = taggedRequest{RPCRequest: request, ID: ids.next()}
+	}
 
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
@@ -70,7 +253,7 @@ func (c *Client) Call(method string, params ...interface{}) (json.RawMessage, er
 
 	// Create HTTP request
 	url := fmt.Sprintf("http://%s:%s", c.host, c.port)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBytes))
+	req, err := http.NewRequestWithContext(c.requestContext(), "POST", url, bytes.NewReader(reqBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -78,7 +261,12 @@ func (c *Client) Call(method string, params ...interface{}) (json.RawMessage, er
 	req.SetBasicAuth(c.user, c.password)
 	req.Header.Set("Content-Type", "application/json")
 
-	// Execute request
+	// Execute request, holding a slot until the response is read
+	release, err := c.acquireSlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
@@ -91,15 +279,19 @@ func (c *Client) Call(method string, params ...interface{}) (json.RawMessage, er
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	if err := checkHTTPStatus(resp, respBytes); err != nil {
+		return nil, err
+	}
+
 	// Parse response
-	var rpcResp RPCResponse
+	var rpcResp wireResponse
 	if err := json.Unmarshal(respBytes, &rpcResp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	// Check for RPC error
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, rpcResp.Error
 	}
 
 	return rpcResp.Result, nil
@@ -110,6 +302,28 @@ func (c *Client) GetBlockchainInfo() (json.RawMessage, error) {
 	return c.Call("getblockchaininfo")
 }
 
+// GetNetworkInfo returns the node's network information
+func (c *Client) GetNetworkInfo() (json.RawMessage, error) {
+	return c.Call("getnetworkinfo")
+}
+
+// GetNodeVersion returns the node's numeric version (e.g. 290100)
+func (c *Client) GetNodeVersion() (int, error) {
+	result, err := c.GetNetworkInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	var info struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(result, &info); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal network info: %w", err)
+	}
+
+	return info.Version, nil
+}
+
 // GetBlockHash returns the block hash at the given height
 func (c *Client) GetBlockHash(height int64) (string, error) {
 	result, err := c.Call("getblockhash", height)
@@ -135,6 +349,32 @@ func (c *Client) GetBlock(hash string, verbosity int) (json.RawMessage, error) {
 	return c.Call("getblock", hash, verbosity)
 }
 
+// GetBlockStats returns per-block statistics for a block hash or height
+// Only the requested stats are computed when stats is non-empty
+func (c *Client) GetBlockStats(hashOrHeight interface{}, stats []string) (json.RawMessage, error) {
+	if len(stats) == 0 {
+		return c.Call("getblockstats", hashOrHeight)
+	}
+	return c.Call("getblockstats", hashOrHeight, stats)
+}
+
+// VerifyTxOutProof verifies a serialized merkle proof (as from gettxoutproof)
+// and returns the txids it commits to. The list is empty if the proof's
+// block is not in the node's active chain.
+func (c *Client) VerifyTxOutProof(proof string) ([]string, error) {
+	result, err := c.Call("verifytxoutproof", proof)
+	if err != nil {
+		return nil, err
+	}
+
+	var txids []string
+	if err := json.Unmarshal(result, &txids); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal verified txids: %w", err)
+	}
+
+	return txids, nil
+}
+
 // GetBlockFilter returns the BIP157 block filter for the given hash
 func (c *Client) GetBlockFilter(blockHash string, filterType string) (json.RawMessage, error) {
 	return c.Call("getblockfilter", blockHash, filterType)
@@ -155,16 +395,113 @@ func (c *Client) SendRawTransaction(hexTx string) (string, error) {
 	return txid, nil
 }
 
+// CombineRawTransaction merges the signatures of several partially signed
+// versions of the same transaction into one
+func (c *Client) CombineRawTransaction(txs []string) (string, error) {
+	result, err := c.Call("combinerawtransaction", txs)
+	if err != nil {
+		return "", err
+	}
+
+	var combined string
+	if err := json.Unmarshal(result, &combined); err != nil {
+		return "", fmt.Errorf("failed to unmarshal combined transaction: %w", err)
+	}
+
+	return combined, nil
+}
+
 // GetRawTransaction returns the raw transaction
 func (c *Client) GetRawTransaction(txid string, verbose bool) (json.RawMessage, error) {
 	return c.Call("getrawtransaction", txid, verbose)
 }
 
+// GetRawTransactionInBlock returns a transaction of the given block, which
+// works without -txindex
+func (c *Client) GetRawTransactionInBlock(txid string, verbose bool, blockHash string) (json.RawMessage, error) {
+	return c.Call("getrawtransaction", txid, verbose, blockHash)
+}
+
+// ScanTxOutSet scans the node's UTXO set for outputs matching the
+// descriptors. A scan can take minutes on mainnet, so it is not cut off by
+// the client's HTTP timeout, only by the bound context. The node keeps
+// scanning after the request is dropped and refuses other scans meanwhile,
+// so a scan cut off by the context is aborted on the node too.
+func (c *Client) ScanTxOutSet(descriptors []string) (json.RawMessage, error) {
+	unbounded := *c
+	httpClient := *c.client
+	httpClient.Timeout = 0
+	unbounded.client = &httpClient
+	result, err := unbounded.Call("scantxoutset", "start", descriptors)
+	if err != nil && c.requestContext().Err() != nil {
+		c.abortTxOutSetScan()
+	}
+	return result, err
+}
+
+// txOutSetAbortTimeout bounds the abort sent after a cancelled scan
+const txOutSetAbortTimeout = 5 * time.Second
+
+// abortTxOutSetScan stops the node's running scantxoutset. It runs on its
+// own context, as the scan's context is already done. A failed abort is
+// not reported: the caller already has the scan's error, and the node ends
+// the scan on its own eventually.
+func (c *Client) abortTxOutSetScan() {
+	ctx, cancel := context.WithTimeout(context.Background(), txOutSetAbortTimeout)
+	defer cancel()
+	c.WithContext(ctx).Call("scantxoutset", "abort")
+}
+
 // GetTxOut returns details about an unspent transaction output
 func (c *Client) GetTxOut(txid string, vout int, includeMempool bool) (json.RawMessage, error) {
 	return c.Call("gettxout", txid, vout, includeMempool)
 }
 
+// GetDescriptorInfo analyses a descriptor, returning its canonical form and checksum
+func (c *Client) GetDescriptorInfo(descriptor string) (json.RawMessage, error) {
+	return c.Call("getdescriptorinfo", descriptor)
+}
+
+// EstimateSmartFee estimates the fee rate (BTC/kvB) for confirmation within confTarget blocks
+func (c *Client) EstimateSmartFee(confTarget int) (json.RawMessage, error) {
+	return c.Call("estimatesmartfee", confTarget)
+}
+
+// GetRawMempool returns the mempool txids, or entry details when verbose is true
+func (c *Client) GetRawMempool(verbose bool) (json.RawMessage, error) {
+	return c.Call("getrawmempool", verbose)
+}
+
+// GetMempoolEntry returns the mempool entry for a transaction
+func (c *Client) GetMempoolEntry(txid string) (json.RawMessage, error) {
+	return c.Call("getmempoolentry", txid)
+}
+
+// GetMempoolAncestors returns the in-mempool ancestors of a transaction,
+// keyed by txid with entry details when verbose is true
+func (c *Client) GetMempoolAncestors(txid string, verbose bool) (json.RawMessage, error) {
+	return c.Call("getmempoolancestors", txid, verbose)
+}
+
+// GetMempoolDescendants returns the in-mempool descendants of a transaction,
+// keyed by txid with entry details when verbose is true
+func (c *Client) GetMempoolDescendants(txid string, verbose bool) (json.RawMessage, error) {
+	return c.Call("getmempooldescendants", txid, verbose)
+}
+
+// OutPoint identifies a transaction output
+type OutPoint struct {
+	TxID string `json:"txid"`
+	Vout int    `json:"vout"`
+}
+
+// GetTxSpendingPrevOut returns, for each outpoint, the mempool transaction
+// spending it if any (Bitcoin Core 24+). All outpoints are checked against
+// the same mempool state.
+func (c *Client) GetTxSpendingPrevOut(outpoints []OutPoint) (json.RawMessage, error) {
+	return c.Call("gettxspendingprevout", outpoints)
+}
+
 // GetBestBlockHash returns the hash of the best (tip) block
 func (c *Client) GetBestBlockHash() (string, error) {
 	result, err := c.Call("getbestblockhash")
@@ -195,18 +532,56 @@ func (c *Client) GetBlockCount() (int64, error) {
 	return count, nil
 }
 
+// GetNetworkHashPS estimates the network hashes per second from the last
+// blocks blocks before height. blocks -1 covers the blocks since the last
+// difficulty change, and height -1 is the tip.
+func (c *Client) GetNetworkHashPS(blocks, height int) (float64, error) {
+	result, err := c.Call("getnetworkhashps", blocks, height)
+	if err != nil {
+		return 0, err
+	}
+
+	var hashPS float64
+	if err := json.Unmarshal(result, &hashPS); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal network hashps: %w", err)
+	}
+
+	return hashPS, nil
+}
+
 // BatchCall makes multiple JSON-RPC calls in a single HTTP request
 // This significantly reduces network overhead when fetching multiple items
 func (c *Client) BatchCall(requests []RPCRequest) ([]RPCResponse, error) {
+	// Nothing to send; some nodes reject an empty batch outright
+	if len(requests) == 0 {
+		return []RPCResponse{}, nil
+	}
+
+	for _, r := range requests {
+		if err := c.checkMethod(r.Method); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.spendCallBudget(len(requests)); err != nil {
+		return nil, err
+	}
+
+	return c.sendBatch(requests, c.batchTags(len(requests)))
+}
+
+// sendBatch sends a batch as one HTTP request, with tags[i], when set, as
+// the id of requests[i]. Methods and the call budget are already checked.
+func (c *Client) sendBatch(requests []RPCRequest, tags []string) ([]RPCResponse, error) {
 	// Prepare batch request
-	reqBytes, err := json.Marshal(requests)
+	batch, callerIDs := tagBatch(requests, tags)
+	reqBytes, err := json.Marshal(batch)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal batch request: %w", err)
 	}
 
 	// Create HTTP request
 	url := fmt.Sprintf("http://%s:%s", c.host, c.port)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBytes))
+	req, err := http.NewRequestWithContext(c.requestContext(), "POST", url, bytes.NewReader(reqBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -214,7 +589,12 @@ func (c *Client) BatchCall(requests []RPCRequest) ([]RPCResponse, error) {
 	req.SetBasicAuth(c.user, c.password)
 	req.Header.Set("Content-Type", "application/json")
 
-	// Execute request
+	// Execute request, holding a slot until the response is read
+	release, err := c.acquireSlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
@@ -227,15 +607,67 @@ func (c *Client) BatchCall(requests []RPCRequest) ([]RPCResponse, error) {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	if err := checkHTTPStatus(resp, respBytes); err != nil {
+		return nil, err
+	}
+
 	// Parse batch response
-	var rpcResponses []RPCResponse
-	if err := json.Unmarshal(respBytes, &rpcResponses); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+	wireResponses, err := parseBatchResponse(respBytes, len(requests))
+	if err != nil {
+		return nil, err
+	}
+
+	rpcResponses := make([]RPCResponse, len(wireResponses))
+	for i, resp := range wireResponses {
+		rpcResponses[i] = untagResponse(resp, callerIDs)
 	}
 
 	return rpcResponses, nil
 }
 
+// parseBatchResponse parses the responses to a batch of size requests. Some
+// proxies unwrap the response to a single-element batch into a bare object,
+// so a lone response object is accepted as a batch of one. For larger
+// batches a bare object can only be an error about the batch as a whole.
+func parseBatchResponse(body []byte, size int) ([]wireResponse, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var single wireResponse
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+		}
+		if size > 1 {
+			if single.Error != nil {
+				return nil, single.Error
+			}
+			return nil, fmt.Errorf("expected %d batch responses, got a single object", size)
+		}
+		return []wireResponse{single}, nil
+	}
+
+	var responses []wireResponse
+	if err := json.Unmarshal(trimmed, &responses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+	}
+	return responses, nil
+}
+
+// DeriveAddresses derives the addresses for a ranged descriptor over [rangeStart, rangeEnd]
+// The descriptor must include its checksum
+func (c *Client) DeriveAddresses(descriptor string, rangeStart, rangeEnd int) ([]string, error) {
+	result, err := c.Call("deriveaddresses", descriptor, []int{rangeStart, rangeEnd})
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	if err := json.Unmarshal(result, &addresses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal derived addresses: %w", err)
+	}
+
+	return addresses, nil
+}
+
 // CallContract calls a smart contract method
 func (c *Client) CallContract(contractAddress, method string, params ...interface{}) (json.RawMessage, error) {
 	// Build parameters array: [contractAddress, method, ...params]
@@ -277,15 +709,107 @@ func (c *Client) ValidateOTRequest(fromAID string, toAID string, amount int64) (
 	return result, nil
 }
 
+// ListOTCycles calls the custom 'listotcycles' RPC.
+// An empty aid lists cycles for every AID; nil heights and options are passed
+// as null, which the node treats as unbounded and defaults respectively.
+func (c *Client) ListOTCycles(aid string, minHeight, maxHeight *int64, options map[string]interface{}) (json.RawMessage, error) {
+	result, err := c.Call("listotcycles", aid, minHeight, maxHeight, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call listotcycles: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListOTRequests calls the custom 'listotrequests' RPC.
+// An empty aid lists the pending requests of every AID.
+func (c *Client) ListOTRequests(aid string) (json.RawMessage, error) {
+	var params []interface{}
+	if aid != "" {
+		params = append(params, aid)
+	}
+
+	result, err := c.Call("listotrequests", params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call listotrequests: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetRequestCycles calls the custom 'getrequestcycles' RPC for the cycles
+// closed by requests from fromAID to toAID
+func (c *Client) GetRequestCycles(fromAID, toAID string) (json.RawMessage, error) {
+	result, err := c.Call("getrequestcycles", fromAID, toAID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call getrequestcycles: %w", err)
+	}
+
+	return result, nil
+}
+
+// IsBatch reports whether a JSON-RPC request body is a batch, an array of
+// requests
+func IsBatch(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+// ProxyRPC forwards a JSON-RPC request body to the node as is. A single
+// request returns its result or the node's RPC error; a batch returns the
+// node's response array as the result, each element carrying its own error.
+// The allowlist and denylist apply to every request of a batch, so a batch
+// with one disallowed method is not sent at all.
 func (c *Client) ProxyRPC(requestBody io.ReadCloser) (json.RawMessage, *RPCError, error) {
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read request: %w", err)
+	}
+
+	type proxiedRequest struct {
+		Method string `json:"method"`
+	}
+	batch := IsBatch(body)
+	calls := 1
+	if batch {
+		var requests []proxiedRequest
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse proxied batch: %w", err)
+		}
+		for _, request := range requests {
+			if err := c.checkMethod(request.Method); err != nil {
+				return nil, nil, err
+			}
+		}
+		calls = len(requests)
+	} else if c.allowedMethods != nil || c.deniedMethods != nil {
+		// Enforce the allowlist and denylist on the proxied method as well
+		var request proxiedRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse proxied request: %w", err)
+		}
+		if err := c.checkMethod(request.Method); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := c.spendCallBudget(calls); err != nil {
+		return nil, nil, err
+	}
+
 	url := fmt.Sprintf("http://%s:%s", c.host, c.port)
-	req, err := http.NewRequest("POST", url, requestBody)
+	req, err := http.NewRequestWithContext(c.requestContext(), "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.SetBasicAuth(c.user, c.password)
 	req.Header.Set("Content-Type", "application/json")
 
+	release, err := c.acquireSlot()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to execute request: %w", err)
@@ -297,7 +821,17 @@ func (c *Client) ProxyRPC(requestBody io.ReadCloser) (json.RawMessage, *RPCError
 		return nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	if err := checkHTTPStatus(resp, respBytes); err != nil {
+		return nil, nil, err
+	}
 
+	if batch {
+		var responses []json.RawMessage
+		if err := json.Unmarshal(respBytes, &responses); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+		}
+		return json.RawMessage(respBytes), nil, nil
+	}
 
 	var rpcResp RPCResponse
 	if err := json.Unmarshal(respBytes, &rpcResp); err != nil {
@@ -0,0 +1,336 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// newTestNode serves a fresh regtest chain
+func newTestNode(t *testing.T) *rpctest.Node {
+	t.Helper()
+	return rpctest.NewNode(t, rpctest.NewChain(&chaincfg.RegressionNetParams))
+}
+
+func TestMethodAllowlistRejectsBeforeRequest(t *testing.T) {
+	node := newTestNode(t)
+	client := node.Client(rpc.WithMethodAllowlist([]string{"getblockcount"}), rpc.WithMethodDenylist([]string{"getblockhash"}))
+
+	if _, err := client.GetBlockCount(); err != nil {
+		t.Fatalf("allowed method: %v", err)
+	}
+	if _, err := client.Call("stop"); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+		t.Errorf("method off the allowlist: got %v", err)
+	}
+	if _, err := client.Call("getblockhash", 0); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+		t.Errorf("denied method: got %v", err)
+	}
+	if n := node.Requests(); n != 1 {
+		t.Errorf("node received %d requests, want only the allowed one", n)
+	}
+}
+
+func TestUnrestrictedByDefault(t *testing.T) {
+	node := newTestNode(t)
+	if _, err := node.Client().Call("getbestblockhash"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDenylistOverridesAllowlist(t *testing.T) {
+	node := newTestNode(t)
+	client := node.Client(
+		rpc.WithMethodAllowlist([]string{"getblockcount", "getpeerinfo"}),
+		rpc.WithMethodDenylist([]string{"getpeerinfo"}),
+	)
+
+	if _, err := client.Call("getpeerinfo"); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+		t.Errorf("allowed but denied method: got %v", err)
+	}
+	if _, err := client.BatchCall([]rpc.RPCRequest{{Jsonrpc: "1.0", Method: "getpeerinfo", ID: 0}}); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+		t.Errorf("allowed but denied method in a batch: got %v", err)
+	}
+	if _, _, err := client.ProxyRPC(io.NopCloser(strings.NewReader(`{"id":1,"method":"getpeerinfo"}`))); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+		t.Errorf("allowed but denied method through the proxy: got %v", err)
+	}
+	if _, err := client.GetBlockCount(); err != nil {
+		t.Errorf("allowed method: %v", err)
+	}
+	if node.Calls("getpeerinfo") != 0 || node.Requests() != 1 {
+		t.Errorf("node received %d requests, want only the allowed one", node.Requests())
+	}
+
+	// Without an allowlist everything but the denied methods is callable
+	node = newTestNode(t)
+	client = node.Client(rpc.WithMethodDenylist([]string{"getpeerinfo"}))
+	if _, err := client.Call("getpeerinfo"); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+		t.Errorf("denied method: got %v", err)
+	}
+	if _, err := client.Call("getbestblockhash"); err != nil {
+		t.Errorf("method not denied: %v", err)
+	}
+}
+
+func TestProxyRPCChecksEveryBatchedMethod(t *testing.T) {
+	node := newTestNode(t)
+	client := node.Client(rpc.WithMethodAllowlist([]string{"getblockcount", "getbestblockhash"}))
+	proxy := func(body string) (json.RawMessage, *rpc.RPCError, error) {
+		return client.ProxyRPC(io.NopCloser(strings.NewReader(body)))
+	}
+
+	// A disallowed method anywhere in a batch keeps the whole batch local
+	for _, body := range []string{
+		`{"jsonrpc":"1.0","id":1,"method":"stop","params":[]}`,
+		`[{"id":1,"method":"getblockcount"},{"id":2,"method":"stop"}]`,
+		` [{"id":1,"method":"stop"}]`,
+	} {
+		if _, _, err := proxy(body); !errors.Is(err, rpc.ErrMethodNotAllowed) {
+			t.Errorf("%s: got %v", body, err)
+		}
+	}
+	if n := node.Requests(); n != 0 {
+		t.Fatalf("node received %d requests for disallowed methods", n)
+	}
+
+	result, rpcErr, err := proxy(`[{"id":1,"method":"getblockcount"},{"id":2,"method":"getbestblockhash"}]`)
+	if err != nil || rpcErr != nil {
+		t.Fatalf("allowed batch: %v %v", err, rpcErr)
+	}
+	var responses []struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(result, &responses); err != nil {
+		t.Fatalf("batch result %s: %v", result, err)
+	}
+	if len(responses) != 2 || responses[0].ID != 1 || string(responses[0].Result) != "0" {
+		t.Errorf("batch result %s", result)
+	}
+	if node.Batches() != 1 || node.Calls("getblockcount") != 1 || node.Calls("getbestblockhash") != 1 {
+		t.Errorf("node saw %d batches", node.Batches())
+	}
+}
+
+// statusClient is a client of a server answering every request with status
+// and body
+func statusClient(t *testing.T, status int, body string) *rpc.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rpc.NewClient(u.Hostname(), u.Port(), "user", "wrong")
+}
+
+func TestCallReportsUnauthorized(t *testing.T) {
+	_, err := statusClient(t, http.StatusUnauthorized, "").Call("getblockcount")
+	if !errors.Is(err, rpc.ErrUnauthorized) || errors.Is(err, rpc.ErrNodeUnavailable) {
+		t.Fatalf("got %v, want an authentication error", err)
+	}
+	var statusErr *rpc.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got %v, want an HTTPStatusError with status 401", err)
+	}
+	if strings.Contains(err.Error(), "unmarshal") {
+		t.Errorf("error %q still reports a decode failure", err)
+	}
+}
+
+func TestCallReportsServiceUnavailable(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("Work queue depth exceeded ", 50) + "</body></html>"
+	_, err := statusClient(t, http.StatusServiceUnavailable, page).Call("getblockcount")
+	if !errors.Is(err, rpc.ErrNodeUnavailable) || errors.Is(err, rpc.ErrUnauthorized) {
+		t.Fatalf("got %v, want a server error", err)
+	}
+	var statusErr *rpc.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want an HTTPStatusError with status 503", err)
+	}
+	if !strings.HasPrefix(statusErr.Body, "<html><body>Work queue") || len(statusErr.Body) >= len(page) {
+		t.Errorf("body %q is not the truncated error page", statusErr.Body)
+	}
+}
+
+func TestCallKeepsJSONRPCErrorsOnErrorStatus(t *testing.T) {
+	// Bitcoin Core answers RPC errors with HTTP 500 and a JSON-RPC body
+	body := `{"result":null,"error":{"code":-8,"message":"Block height out of range"},"id":1}`
+	_, err := statusClient(t, http.StatusInternalServerError, body).Call("getblockhash", 1000)
+	var rpcErr *rpc.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -8 {
+		t.Fatalf("got %v, want the node's RPC error", err)
+	}
+	var statusErr *rpc.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		t.Errorf("RPC error reported as HTTP status %d", statusErr.StatusCode)
+	}
+}
+
+func TestGetNetworkHashPS(t *testing.T) {
+	node := newTestNode(t)
+	var got [][2]int
+	node.Handle("getnetworkhashps", func(params []json.RawMessage) (interface{}, error) {
+		var blocks, height int
+		if _, err := rpctest.Param(params, 0, &blocks); err != nil {
+			return nil, err
+		}
+		if _, err := rpctest.Param(params, 1, &height); err != nil {
+			return nil, err
+		}
+		got = append(got, [2]int{blocks, height})
+		// As mainnet bitcoind reports it
+		return json.RawMessage(`6.257813693215926e+20`), nil
+	})
+	client := node.Client()
+
+	// The difficulty period at the tip, then 120 blocks before height 800000
+	for _, args := range [][2]int{{-1, -1}, {120, 800000}} {
+		hashPS, err := client.GetNetworkHashPS(args[0], args[1])
+		if err != nil {
+			t.Fatalf("getnetworkhashps %v: %v", args, err)
+		}
+		if hashPS != 6.257813693215926e+20 {
+			t.Errorf("parsed %g hashes per second", hashPS)
+		}
+	}
+	if want := [][2]int{{-1, -1}, {120, 800000}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("node called with %v, want %v", got, want)
+	}
+
+	node.Handle("getnetworkhashps", func([]json.RawMessage) (interface{}, error) {
+		return "fast", nil
+	})
+	if _, err := client.GetNetworkHashPS(-1, -1); err == nil {
+		t.Error("non-numeric hashrate parsed")
+	}
+}
+
+func TestRequestIDsTagCalls(t *testing.T) {
+	node := newTestNode(t)
+	client := node.Client().WithContext(rpc.WithRequestID(context.Background(), "req-abc"))
+
+	if _, err := client.GetBlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	// Batched calls are numbered on, and answered under the caller's ids
+	requests := []rpc.RPCRequest{
+		{Jsonrpc: "1.0", Method: "getblockhash", Params: []interface{}{0}, ID: 7},
+		{Jsonrpc: "1.0", Method: "getblockhash", Params: []interface{}{99}, ID: 8},
+	}
+	responses, err := client.BatchCall(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || responses[0].ID != 7 || responses[0].Error != nil || responses[1].ID != 8 || responses[1].Error == nil {
+		t.Errorf("batch responses %+v, want ids 7 and 8 with the second failing", responses)
+	}
+	if _, err := client.Call("getbestblockhash"); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, raw := range node.IDs() {
+		var id string
+		if err := json.Unmarshal(raw, &id); err != nil {
+			t.Fatalf("id %s is not a string", raw)
+		}
+		ids = append(ids, id)
+	}
+	if want := []string{"req-abc-1", "req-abc-2", "req-abc-3", "req-abc-4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("node saw ids %v, want %v", ids, want)
+	}
+
+	// Each request numbers its own calls
+	other := node.Client().WithContext(rpc.WithRequestID(context.Background(), "req-def"))
+	if _, err := other.GetBlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	if last := node.IDs()[len(node.IDs())-1]; string(last) != `"req-def-1"` {
+		t.Errorf("second request's first call has id %s", last)
+	}
+}
+
+func TestRequestIDsOffWithoutCorrelationID(t *testing.T) {
+	node := newTestNode(t)
+	if _, err := node.Client().GetBlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	if err := json.Unmarshal(node.IDs()[0], &id); err != nil {
+		t.Errorf("unbound client sent id %s, want a number", node.IDs()[0])
+	}
+}
+
+func TestBatchCallAcceptsBareObjectForOneRequest(t *testing.T) {
+	client := statusClient(t, http.StatusOK, `{"result":"00ff","error":null,"id":7}`)
+	responses, err := client.BatchCall([]rpc.RPCRequest{{Jsonrpc: "1.0", Method: "getblockhash", Params: []interface{}{1}, ID: 7}})
+	if err != nil {
+		t.Fatalf("batch of one answered with a bare object: %v", err)
+	}
+	if len(responses) != 1 || string(responses[0].Result) != `"00ff"` || responses[0].ID != 7 {
+		t.Errorf("got %+v, want the object as the batch's only response", responses)
+	}
+}
+
+func TestBatchCallReportsBareObjectForLargerBatch(t *testing.T) {
+	requests := []rpc.RPCRequest{{Method: "getblockcount", ID: 0}, {Method: "getbestblockhash", ID: 1}}
+
+	// An error about the batch as a whole is returned as such
+	_, err := statusClient(t, http.StatusOK, `{"result":null,"error":{"code":-32600,"message":"Batch too large"},"id":null}`).BatchCall(requests)
+	var rpcErr *rpc.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Message != "Batch too large" {
+		t.Errorf("got %v, want the node's error", err)
+	}
+
+	// A lone result cannot answer two requests
+	if _, err := statusClient(t, http.StatusOK, `{"result":5,"error":null,"id":0}`).BatchCall(requests); err == nil {
+		t.Error("one response accepted for a batch of two")
+	}
+}
+
+func TestBatchCallEmpty(t *testing.T) {
+	node := newTestNode(t)
+	responses, err := node.Client().BatchCall(nil)
+	if err != nil || len(responses) != 0 {
+		t.Fatalf("got %v, %v; want no responses", responses, err)
+	}
+	if node.Requests() != 0 {
+		t.Errorf("empty batch sent %d requests", node.Requests())
+	}
+}
+
+func TestIsNoTxIndex(t *testing.T) {
+	noTxIndex := &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "No such mempool transaction. Use -txindex or provide a block hash to enable blockchain transaction queries. Use gettransaction for wallet transactions."}
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{noTxIndex, true},
+		{fmt.Errorf("lookup: %w", noTxIndex), true},
+		{&rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "No such mempool or blockchain transaction. Use gettransaction for wallet transactions."}, false},
+		{&rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "Use -txindex"}, false},
+		{errors.New("Use -txindex"), false},
+		{nil, false},
+	} {
+		if got := rpc.IsNoTxIndex(tc.err); got != tc.want {
+			t.Errorf("rpc.IsNoTxIndex(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxCoalescedBatch caps the calls sent in one coalesced batch; a full
+// batch is sent at once instead of waiting for the window to close
+const maxCoalescedBatch = 100
+
+// coalescedMethods are the calls worth sharing a batch: small, read-only
+// lookups that concurrent scans make for overlapping heights
+var coalescedMethods = map[string]bool{
+	"getblockhash":   true,
+	"getblockfilter": true,
+	"getblockheader": true,
+}
+
+// coalescer gathers calls from concurrent requests over a short window and
+// sends them to the node as one batch
+type coalescer struct {
+	window time.Duration
+	send   func(requests []RPCRequest, tags []string) ([]RPCResponse, error)
+
+	mu      sync.Mutex
+	pending []*coalescedCall
+	timer   *time.Timer
+}
+
+// coalescedCall is one caller's call waiting for its batch
+type coalescedCall struct {
+	method string
+	params []interface{}
+	tag    string               // The caller's request-derived id, empty without one
+	done   chan coalescedResult // Buffered, so a caller that gave up never blocks the batch
+}
+
+type coalescedResult struct {
+	result json.RawMessage
+	err    error
+}
+
+// WithCallCoalescing sends getblockhash, getblockfilter and getblockheader
+// calls made within window of each other, by any request, as one batch call,
+// cutting HTTP round trips under concurrent load at the cost of up to window
+// of added latency. A window <= 0 leaves every call on its own request.
+func WithCallCoalescing(window time.Duration) Option {
+	return func(c *Client) {
+		if window <= 0 {
+			return
+		}
+		// Batches go out on the unbound client: each caller waits on its
+		// own context instead, and has already spent its call budget. Calls
+		// keep the ids their requests gave them.
+		c.coalescer = &coalescer{window: window, send: c.sendBatch}
+	}
+}
+
+// call queues a call for the next batch and waits for its result
+func (q *coalescer) call(ctx context.Context, method string, params []interface{}, tag string) (json.RawMessage, error) {
+	call := &coalescedCall{method: method, params: params, tag: tag, done: make(chan coalescedResult, 1)}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, call)
+	switch {
+	case len(q.pending) >= maxCoalescedBatch:
+		batch := q.take()
+		go q.flush(batch)
+	case len(q.pending) == 1:
+		q.timer = time.AfterFunc(q.window, func() {
+			q.mu.Lock()
+			batch := q.take()
+			q.mu.Unlock()
+			q.flush(batch)
+		})
+	}
+	q.mu.Unlock()
+
+	select {
+	case result := <-call.done:
+		return result.result, result.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to execute request: %w", ctx.Err())
+	}
+}
+
+// take removes the pending calls for sending. q.mu must be held.
+func (q *coalescer) take() []*coalescedCall {
+	batch := q.pending
+	q.pending = nil
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	return batch
+}
+
+// flush sends a batch and hands each caller its response
+func (q *coalescer) flush(batch []*coalescedCall) {
+	if len(batch) == 0 {
+		return
+	}
+
+	requests := make([]RPCRequest, len(batch))
+	tags := make([]string, len(batch))
+	for i, call := range batch {
+		requests[i] = RPCRequest{Jsonrpc: "1.0", Method: call.method, Params: call.params, ID: i}
+		tags[i] = call.tag
+	}
+
+	responses, err := q.send(requests, tags)
+	if err != nil {
+		for _, call := range batch {
+			call.done <- coalescedResult{err: err}
+		}
+		return
+	}
+
+	answered := make([]bool, len(batch))
+	for _, resp := range responses {
+		if resp.ID < 0 || resp.ID >= len(batch) || answered[resp.ID] {
+			continue
+		}
+		answered[resp.ID] = true
+		if resp.Error != nil {
+			batch[resp.ID].done <- coalescedResult{err: resp.Error}
+			continue
+		}
+		batch[resp.ID].done <- coalescedResult{result: resp.Result}
+	}
+	for i, call := range batch {
+		if !answered[i] {
+			call.done <- coalescedResult{err: fmt.Errorf("no response to %s in coalesced batch", call.method)}
+		}
+	}
+}
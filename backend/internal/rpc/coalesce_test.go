@@ -0,0 +1,218 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+)
+
+// hashesConcurrently looks up each height's block hash from its own
+// goroutine, all started together
+func hashesConcurrently(client *rpc.Client, heights []int64) ([]string, []error) {
+	hashes := make([]string, len(heights))
+	errs := make([]error, len(heights))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, height := range heights {
+		wg.Add(1)
+		go func(i int, height int64) {
+			defer wg.Done()
+			<-start
+			hashes[i], errs[i] = client.GetBlockHash(height)
+		}(i, height)
+	}
+	close(start)
+	wg.Wait()
+	return hashes, errs
+}
+
+func TestCoalescingSharesBatches(t *testing.T) {
+	node := newTestNode(t)
+	for i := 0; i < 20; i++ {
+		node.Chain.AddBlock()
+	}
+	client := node.Client(rpc.WithCallCoalescing(50 * time.Millisecond))
+
+	// Requests ask for overlapping heights
+	var heights []int64
+	for i := 0; i < 60; i++ {
+		heights = append(heights, int64(i%20))
+	}
+	hashes, errs := hashesConcurrently(client, heights)
+	for i, height := range heights {
+		if errs[i] != nil {
+			t.Fatalf("height %d: %v", height, errs[i])
+		}
+		if want := node.Chain.BlockAt(height).Hash; hashes[i] != want {
+			t.Errorf("height %d: got %s, want %s", height, hashes[i], want)
+		}
+	}
+	if node.Calls("getblockhash") != len(heights) {
+		t.Errorf("node answered %d getblockhash calls, want %d", node.Calls("getblockhash"), len(heights))
+	}
+	if requests := node.Requests(); requests > 3 || node.Batches() != requests {
+		t.Errorf("%d calls took %d requests (%d batches), want a few batches", len(heights), requests, node.Batches())
+	}
+
+	// Other methods are not held back
+	requests := node.Requests()
+	if _, err := client.GetBlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	if node.Requests() != requests+1 || node.Batches() != requests {
+		t.Error("getblockcount was batched")
+	}
+}
+
+func TestCoalescingSendsFullBatchesEarly(t *testing.T) {
+	node := newTestNode(t)
+	node.Chain.AddBlock()
+	client := node.Client(rpc.WithCallCoalescing(time.Hour))
+
+	// Nothing would go out for an hour unless full batches are sent at once
+	heights := make([]int64, 200)
+	done := make(chan struct{})
+	go func() {
+		hashesConcurrently(client, heights)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("full batches waited for the window")
+	}
+	if node.Batches() != 2 || node.Calls("getblockhash") != 200 {
+		t.Errorf("%d calls in %d batches, want 200 in 2", node.Calls("getblockhash"), node.Batches())
+	}
+}
+
+func TestCoalescingHandsBackEachError(t *testing.T) {
+	node := newTestNode(t)
+	node.Chain.AddBlock()
+	client := node.Client(rpc.WithCallCoalescing(50 * time.Millisecond))
+
+	hashes, errs := hashesConcurrently(client, []int64{0, 99, 1})
+	var rpcErr *rpc.RPCError
+	if !errors.As(errs[1], &rpcErr) {
+		t.Errorf("height 99: got %v, want the node's error", errs[1])
+	}
+	if errs[0] != nil || errs[2] != nil || hashes[0] != node.Chain.BlockAt(0).Hash || hashes[2] != node.Chain.BlockAt(1).Hash {
+		t.Errorf("other calls got %v, %v", hashes, errs)
+	}
+	if node.Batches() != 1 {
+		t.Errorf("sent %d batches, want 1", node.Batches())
+	}
+}
+
+func TestCoalescedCallerGivesUpOnItsContext(t *testing.T) {
+	node := newTestNode(t)
+	client := node.Client(rpc.WithCallCoalescing(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if _, err := client.WithContext(ctx).GetBlockHash(0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's deadline", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
+
+func TestCoalescingOff(t *testing.T) {
+	for _, client := range []func(*rpctest.Node) *rpc.Client{
+		func(node *rpctest.Node) *rpc.Client { return node.Client() },
+		func(node *rpctest.Node) *rpc.Client { return node.Client(rpc.WithCallCoalescing(0)) },
+	} {
+		node := newTestNode(t)
+		hashesConcurrently(client(node), make([]int64, 5))
+		if node.Requests() != 5 || node.Batches() != 0 {
+			t.Errorf("%d requests, %d batches; want each call on its own", node.Requests(), node.Batches())
+		}
+	}
+}
+
+func TestCoalescingKeepsRequestIDs(t *testing.T) {
+	node := newTestNode(t)
+	for i := 0; i < 5; i++ {
+		node.Chain.AddBlock()
+	}
+	client := node.Client(rpc.WithCallCoalescing(50 * time.Millisecond))
+	callers := map[string]*rpc.Client{
+		"req-a": client.WithContext(rpc.WithRequestID(context.Background(), "req-a")),
+		"req-b": client.WithContext(rpc.WithRequestID(context.Background(), "req-b")),
+		"":      client, // Not bound to a request
+	}
+
+	// Every caller asks for the same heights at once, plus one past the tip
+	heights := []int64{0, 1, 2, 3, 4, 99}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failures := make(map[string]error)
+	for name, caller := range callers {
+		for _, height := range heights {
+			wg.Add(1)
+			go func(name string, caller *rpc.Client, height int64) {
+				defer wg.Done()
+				hash, err := caller.GetBlockHash(height)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case height == 99:
+					if err == nil {
+						failures[name] = fmt.Errorf("height past the tip did not fail")
+					}
+				case err != nil:
+					failures[name] = err
+				case hash != node.Chain.BlockAt(height).Hash:
+					failures[name] = fmt.Errorf("height %d: got %s", height, hash)
+				}
+			}(name, caller, height)
+		}
+	}
+	wg.Wait()
+	for name, err := range failures {
+		t.Errorf("caller %q: %v", name, err)
+	}
+
+	if requests := node.Requests(); requests > 2 || node.Batches() != requests {
+		t.Errorf("%d calls took %d requests (%d batches), want them coalesced", len(callers)*len(heights), requests, node.Batches())
+	}
+
+	// Bound callers' calls keep their request-derived ids, numbered per
+	// request; the unbound caller's stay numeric
+	tagged := make(map[string][]string)
+	numeric := 0
+	for _, raw := range node.IDs() {
+		var id string
+		if err := json.Unmarshal(raw, &id); err != nil {
+			numeric++
+			continue
+		}
+		prefix := id[:len("req-a")]
+		tagged[prefix] = append(tagged[prefix], id)
+	}
+	for _, prefix := range []string{"req-a", "req-b"} {
+		var want []string
+		for n := 1; n <= len(heights); n++ {
+			want = append(want, fmt.Sprintf("%s-%d", prefix, n))
+		}
+		got := tagged[prefix]
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("node saw ids %v for %s, want %v", got, prefix, want)
+		}
+	}
+	if numeric != len(heights) {
+		t.Errorf("node saw %d numeric ids, want %d from the unbound caller", numeric, len(heights))
+	}
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrConcurrencyLimit is returned when a call waited too long for one of
+// the node's request slots
+var ErrConcurrencyLimit = errors.New("too many concurrent RPC requests")
+
+// concurrencyLimiter bounds the HTTP requests in flight to the node
+type concurrencyLimiter struct {
+	slots chan struct{}
+	wait  time.Duration // Longest a call queues for a slot, 0 waits for its context only
+}
+
+// WithMaxConcurrent allows at most n HTTP requests to the node in flight at
+// once across every user of the client (and its bound copies), so the
+// server stays within the node's -rpcthreads. A call, batch or proxied
+// request holds a slot until its response is read; calls beyond the limit
+// queue for up to wait, then fail with ErrConcurrencyLimit. n <= 0 leaves
+// requests unlimited.
+func WithMaxConcurrent(n int, wait time.Duration) Option {
+	return func(c *Client) {
+		if n <= 0 {
+			return
+		}
+		c.limiter = &concurrencyLimiter{slots: make(chan struct{}, n), wait: wait}
+	}
+}
+
+// acquireSlot waits for a request slot and returns the function releasing
+// it. Waiting also ends with the bound context.
+func (c *Client) acquireSlot() (func(), error) {
+	if c.limiter == nil {
+		return func() {}, nil
+	}
+	release := func() { <-c.limiter.slots }
+
+	select {
+	case c.limiter.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if c.limiter.wait > 0 {
+		timer := time.NewTimer(c.limiter.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case c.limiter.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, fmt.Errorf("%w: limit of %d, waited %v", ErrConcurrencyLimit, cap(c.limiter.slots), c.limiter.wait)
+	case <-c.requestContext().Done():
+		return nil, fmt.Errorf("failed to execute request: %w", c.requestContext().Err())
+	}
+}
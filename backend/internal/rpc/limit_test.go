@@ -0,0 +1,165 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpc"
+	"spv-backend/internal/rpctest"
+)
+
+// inFlight records the most handlers running at once on a node. Batched
+// calls run one after another within their request, so this is also the
+// most HTTP requests the node served at once.
+type inFlight struct {
+	mu       sync.Mutex
+	current  int
+	max      int
+	release  chan struct{} // Handlers block on it when set
+	entering chan struct{} // Signalled as each handler starts when set
+}
+
+func (f *inFlight) wrap(node *rpctest.Node, methods ...string) {
+	for _, method := range methods {
+		node.Wrap(method, func(next rpctest.Handler) rpctest.Handler {
+			return func(params []json.RawMessage) (interface{}, error) {
+				f.mu.Lock()
+				f.current++
+				if f.current > f.max {
+					f.max = f.current
+				}
+				f.mu.Unlock()
+				if f.entering != nil {
+					f.entering <- struct{}{}
+				}
+				if f.release != nil {
+					<-f.release
+				} else {
+					time.Sleep(time.Millisecond)
+				}
+				f.mu.Lock()
+				f.current--
+				f.mu.Unlock()
+				return next(params)
+			}
+		})
+	}
+}
+
+func (f *inFlight) peak() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.max
+}
+
+func TestMaxConcurrentBoundsMixedLoad(t *testing.T) {
+	node := newTestNode(t)
+	for i := 0; i < 10; i++ {
+		node.Chain.AddBlock()
+	}
+	var gauge inFlight
+	gauge.wrap(node, "getblockhash", "getblockcount", "getbestblockhash")
+
+	const limit = 3
+	client := node.Client(rpc.WithMaxConcurrent(limit, time.Minute), rpc.WithCallCoalescing(time.Millisecond))
+
+	// Single calls, coalesced calls, explicit batches, proxied requests and
+	// calls through bound copies all share the limit
+	load := []func(i int) error{
+		func(i int) error {
+			_, err := client.GetBlockCount()
+			return err
+		},
+		func(i int) error {
+			_, err := client.GetBlockHash(int64(i % 10))
+			return err
+		},
+		func(i int) error {
+			_, err := client.BatchCall([]rpc.RPCRequest{
+				{Jsonrpc: "1.0", Method: "getblockhash", Params: []interface{}{i % 10}, ID: 1},
+				{Jsonrpc: "1.0", Method: "getblockcount", Params: []interface{}{}, ID: 2},
+			})
+			return err
+		},
+		func(i int) error {
+			_, rpcErr, err := client.ProxyRPC(io.NopCloser(strings.NewReader(`{"jsonrpc":"1.0","id":1,"method":"getbestblockhash","params":[]}`)))
+			if rpcErr != nil {
+				return rpcErr
+			}
+			return err
+		},
+		func(i int) error {
+			_, err := client.WithContext(context.Background()).GetBlockCount()
+			return err
+		},
+	}
+
+	start := make(chan struct{})
+	errs := make(chan error, 200)
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs <- load[i%len(load)](i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if peak := gauge.peak(); peak > limit {
+		t.Errorf("node served %d requests at once, limit is %d", peak, limit)
+	} else if peak < 2 {
+		t.Errorf("node served at most %d request at once, want the load to overlap", peak)
+	}
+}
+
+func TestMaxConcurrentQueueTimeout(t *testing.T) {
+	node := newTestNode(t)
+	gauge := inFlight{release: make(chan struct{}), entering: make(chan struct{}, 1)}
+	gauge.wrap(node, "getblockcount")
+	client := node.Client(rpc.WithMaxConcurrent(1, 20*time.Millisecond))
+
+	held := make(chan error, 1)
+	go func() {
+		_, err := client.GetBlockCount()
+		held <- err
+	}()
+	<-gauge.entering
+
+	// The only slot is taken, so the next call gives up after the queue timeout
+	if _, err := client.GetBlockCount(); !errors.Is(err, rpc.ErrConcurrencyLimit) {
+		t.Errorf("got %v, want ErrConcurrencyLimit", err)
+	}
+	// and a bound context ending first wins over the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.WithContext(ctx).GetBlockCount(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	close(gauge.release)
+	if err := <-held; err != nil {
+		t.Fatal(err)
+	}
+	// The slot is free again once the held call returns
+	if _, err := client.GetBlockCount(); err != nil {
+		t.Errorf("after release: %v", err)
+	}
+	if calls := node.Calls("getblockcount"); calls != 2 {
+		t.Errorf("node answered %d getblockcount calls, want 2", calls)
+	}
+}
@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// requestIDs derives JSON-RPC ids from the correlation ID of the HTTP request
+// the calls are made for, so they can be matched up in the node's logs
+type requestIDs struct {
+	prefix string
+	seq    atomic.Int64
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose RPC calls, by clients bound to it
+// (see Client.WithContext), carry JSON-RPC ids of the form "<id>-<n>", where
+// n numbers the calls of the request from 1
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, &requestIDs{prefix: id})
+}
+
+// boundRequestIDs returns the request ID state of the bound context, or nil
+func (c *Client) boundRequestIDs() *requestIDs {
+	ids, _ := c.requestContext().Value(requestIDKey{}).(*requestIDs)
+	return ids
+}
+
+// next returns the id for the request's next call
+func (ids *requestIDs) next() string {
+	return fmt.Sprintf("%s-%d", ids.prefix, ids.seq.Add(1))
+}
+
+// taggedRequest is an RPCRequest sent with a string id in place of its own
+type taggedRequest struct {
+	RPCRequest
+	ID string `json:"id"`
+}
+
+// wireResponse is a JSON-RPC response whose id may be a number or a string
+type wireResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+	ID     json.RawMessage `json:"id"`
+}
+
+// batchTags returns the request-derived ids for a batch of n calls, or nil
+// without a request ID
+func (c *Client) batchTags(n int) []string {
+	ids := c.boundRequestIDs()
+	if ids == nil {
+		return nil
+	}
+
+	tags := make([]string, n)
+	for i := range tags {
+		tags[i] = ids.next()
+	}
+	return tags
+}
+
+// tagBatch replaces the id of each request that has a non-empty tag with the
+// tag, returning the batch to send and a map back to the callers' ids.
+// Without tags the batch is sent as is and the map is nil.
+func tagBatch(requests []RPCRequest, tags []string) (interface{}, map[string]int) {
+	var callerIDs map[string]int
+	batch := make([]interface{}, len(requests))
+	for i, r := range requests {
+		batch[i] = r
+		if i < len(tags) && tags[i] != "" {
+			if callerIDs == nil {
+				callerIDs = make(map[string]int, len(requests))
+			}
+			batch[i] = taggedRequest{RPCRequest: r, ID: tags[i]}
+			callerIDs[tags[i]] = r.ID
+		}
+	}
+	if callerIDs == nil {
+		return requests, nil
+	}
+	return batch, callerIDs
+}
+
+// untagResponse converts a response to the caller's id space. Numeric ids
+// are the callers' own; string ids not among the batch's tags get -1.
+func untagResponse(resp wireResponse, callerIDs map[string]int) RPCResponse {
+	out := RPCResponse{Result: resp.Result, Error: resp.Error}
+	if err := json.Unmarshal(resp.ID, &out.ID); err == nil || callerIDs == nil {
+		return out
+	}
+
+	out.ID = -1
+	var id string
+	if err := json.Unmarshal(resp.ID, &id); err == nil {
+		if callerID, ok := callerIDs[id]; ok {
+			out.ID = callerID
+		}
+	}
+	return out
+}
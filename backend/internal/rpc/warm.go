@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// WithIdleConnections keeps up to n idle keep-alive connections to the node
+// instead of the default transport's two, so connections opened by Warm or
+// by bursts of parallel calls are reused rather than closed
+func WithIdleConnections(n int) Option {
+	return func(c *Client) {
+		if n <= http.DefaultMaxIdleConnsPerHost {
+			return
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = n
+		if transport.MaxIdleConns < n {
+			transport.MaxIdleConns = n
+		}
+		c.client.Transport = transport
+	}
+}
+
+// Warm makes n concurrent getblockcount calls so the pool holds up to n
+// keep-alive connections before the first requests arrive. It returns how
+// many distinct connections the calls used, which can be fewer than n if a
+// call finishes before another starts dialing.
+func (c *Client) Warm(n int) (int, error) {
+	var mu sync.Mutex
+	conns := make(map[string]bool)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			conns[info.Conn.LocalAddr().String()] = true
+			mu.Unlock()
+		},
+	}
+	traced := c.WithContext(httptrace.WithClientTrace(c.requestContext(), trace))
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = traced.GetBlockCount()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, err := range errs {
+		if err != nil {
+			return len(conns), err
+		}
+	}
+	return len(conns), nil
+}
@@ -0,0 +1,145 @@
+package rpc_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpc"
+)
+
+// connServer answers getblockcount, holding each request until n are in
+// flight so concurrent calls need their own connections, and reports the
+// state of the connections clients opened
+type connServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	states  map[net.Conn]http.ConnState
+	opened  int
+	waiting sync.WaitGroup
+}
+
+func newConnServer(t *testing.T, n int) *connServer {
+	t.Helper()
+	s := &connServer{states: make(map[net.Conn]http.ConnState)}
+	s.waiting.Add(n)
+	var once sync.Once
+	arrived := make(chan struct{})
+	go func() {
+		s.waiting.Wait()
+		close(arrived)
+	}()
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-arrived:
+		default:
+			s.waiting.Done()
+			select {
+			case <-arrived:
+			case <-time.After(time.Second):
+				once.Do(func() { t.Error("concurrent warm-up calls did not all arrive") })
+			}
+		}
+		w.Write([]byte(`{"result":100,"error":null,"id":0}`))
+	}))
+	s.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if state == http.StateNew {
+			s.opened++
+		}
+		s.states[conn] = state
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// count returns the connections in state
+func (s *connServer) count(state http.ConnState) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, st := range s.states {
+		if st == state {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *connServer) client(opts ...rpc.Option) *rpc.Client {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		panic(err)
+	}
+	return rpc.NewClient(u.Hostname(), u.Port(), "user", "pass", opts...)
+}
+
+// eventually polls cond until it holds or a second has passed
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+func TestWarmLeavesIdleConnections(t *testing.T) {
+	const n = 8
+	server := newConnServer(t, n)
+	client := server.client(rpc.WithIdleConnections(n))
+
+	opened, err := client.Warm(n)
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if opened != n {
+		t.Errorf("warm reported %d connections, want %d", opened, n)
+	}
+	if !eventually(func() bool { return server.count(http.StateIdle) == n }) {
+		t.Fatalf("%d idle connections after warm-up, want %d", server.count(http.StateIdle), n)
+	}
+
+	// Later calls reuse the pool instead of dialing
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetBlockCount(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.opened != n {
+		t.Errorf("%d connections opened in all, want the %d warmed ones", server.opened, n)
+	}
+}
+
+func TestWarmWithoutIdleConnectionsClosesExtras(t *testing.T) {
+	const n = 6
+	server := newConnServer(t, n)
+
+	// The default transport keeps only two idle connections per host
+	if _, err := server.client().Warm(n); err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if !eventually(func() bool { return server.count(http.StateClosed) == n-http.DefaultMaxIdleConnsPerHost }) {
+		t.Errorf("%d connections closed, want %d", server.count(http.StateClosed), n-http.DefaultMaxIdleConnsPerHost)
+	}
+	if idle := server.count(http.StateIdle); idle != http.DefaultMaxIdleConnsPerHost {
+		t.Errorf("%d idle connections, want %d", idle, http.DefaultMaxIdleConnsPerHost)
+	}
+}
@@ -0,0 +1,434 @@
+// Package rpctest provides an in-memory bitcoind stand-in for tests: a Chain
+// of real serialized blocks with their BIP158 filters and UTXO set, and a
+// Node answering the JSON-RPC calls the server makes against it
+package rpctest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"spv-backend/internal/merkle"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BlockInterval is the time between the blocks AddBlock mines, in seconds
+const BlockInterval = 600
+
+// opTrue is the script coinbases pay unless told otherwise
+var opTrue = []byte{txscript.OP_TRUE}
+
+// Block is a connected block with what the node derives from it
+type Block struct {
+	Msg          *wire.MsgBlock
+	Hash         string
+	Height       int64
+	Filter       []byte // BIP158 basic filter, serialized with its N
+	FilterHeader string
+	Fees         int64 // Satoshis, over the transactions whose inputs are known
+
+	prevOuts map[wire.OutPoint]*coin // Coins the block spent, to undo it
+}
+
+// Time returns the block's header timestamp
+func (b *Block) Time() int64 {
+	return b.Msg.Header.Timestamp.Unix()
+}
+
+// TxIDs returns the block's txids in block order
+func (b *Block) TxIDs() []string {
+	txids := make([]string, len(b.Msg.Transactions))
+	for i, tx := range b.Msg.Transactions {
+		txids[i] = tx.TxHash().String()
+	}
+	return txids
+}
+
+// coin is an unspent output
+type coin struct {
+	out      *wire.TxOut
+	height   int64 // -1 while unconfirmed
+	coinbase bool
+	block    string // Hash of the block creating it
+}
+
+// Chain is a regtest-style chain without proof of work. It is safe for
+// concurrent use.
+type Chain struct {
+	Params *chaincfg.Params
+
+	// ValueSat adds an integer satoshi "valueSat" field next to "value" in
+	// verbose outputs, as some node builds do
+	ValueSat bool
+
+	mu      sync.Mutex
+	blocks  []*Block
+	byHash  map[string]*Block
+	txBlock map[string]*Block // txid -> confirming block
+	coins   map[wire.OutPoint]*coin
+
+	mempool       map[string]*wire.MsgTx
+	mempoolSpends map[wire.OutPoint]string // Outpoint -> spending mempool txid
+
+	nonce uint32 // Makes NewTx transactions unique
+}
+
+// NewChain returns a chain holding only the params' genesis block
+func NewChain(params *chaincfg.Params) *Chain {
+	c := &Chain{
+		Params:        params,
+		byHash:        make(map[string]*Block),
+		txBlock:       make(map[string]*Block),
+		coins:         make(map[wire.OutPoint]*coin),
+		mempool:       make(map[string]*wire.MsgTx),
+		mempoolSpends: make(map[wire.OutPoint]string),
+	}
+	c.connect(params.GenesisBlock)
+	return c
+}
+
+// Out returns an output paying sats to script
+func Out(script []byte, sats int64) *wire.TxOut {
+	return wire.NewTxOut(sats, script)
+}
+
+// PayTo returns an output paying sats to an address
+func PayTo(address btcutil.Address, sats int64) *wire.TxOut {
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		panic(err)
+	}
+	return Out(script, sats)
+}
+
+// NewTx returns a transaction spending the outpoints to the outputs. Without
+// outpoints it spends a made-up one, so it is not a coinbase; such inputs are
+// unknown to the chain and do not count towards fees.
+func (c *Chain) NewTx(spends []wire.OutPoint, outs ...*wire.TxOut) *wire.MsgTx {
+	c.mu.Lock()
+	c.nonce++
+	nonce := c.nonce
+	c.mu.Unlock()
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if len(spends) == 0 {
+		var hash chainhash.Hash
+		binary.LittleEndian.PutUint32(hash[:], nonce)
+		hash[31] = 0xfe
+		spends = []wire.OutPoint{{Hash: hash}}
+	}
+	for i := range spends {
+		tx.AddTxIn(wire.NewTxIn(&spends[i], nil, nil))
+	}
+	for _, out := range outs {
+		tx.AddTxOut(out)
+	}
+	tx.LockTime = nonce
+	return tx
+}
+
+// OutPoint returns the outpoint of a transaction's output
+func OutPoint(tx *wire.MsgTx, index uint32) wire.OutPoint {
+	return wire.OutPoint{Hash: tx.TxHash(), Index: index}
+}
+
+// AddBlock mines a block of txs whose coinbase pays the subsidy and fees to
+// OP_TRUE, and connects it
+func (c *Chain) AddBlock(txs ...*wire.MsgTx) *Block {
+	return c.AddBlockPaying(nil, txs...)
+}
+
+// AddBlockPaying mines a block whose coinbase has the given outputs, or pays
+// the whole reward to OP_TRUE if there are none
+func (c *Chain) AddBlockPaying(coinbaseOuts []*wire.TxOut, txs ...*wire.MsgTx) *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tip := c.blocks[len(c.blocks)-1]
+	height := tip.Height + 1
+
+	var fees int64
+	for _, tx := range txs {
+		fees += c.fee(tx)
+	}
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	heightScript, _ := txscript.NewScriptBuilder().AddInt64(height).AddOp(txscript.OP_0).Script()
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), heightScript, nil))
+	if len(coinbaseOuts) == 0 {
+		coinbaseOuts = []*wire.TxOut{Out(opTrue, c.subsidy(height)+fees)}
+	}
+	for _, out := range coinbaseOuts {
+		coinbase.AddTxOut(out)
+	}
+
+	msg := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: tip.Msg.BlockHash(),
+			Timestamp: tip.Msg.Header.Timestamp.Add(BlockInterval * 1e9),
+			Bits:      c.Params.PowLimitBits,
+			Nonce:     uint32(height),
+		},
+		Transactions: append([]*wire.MsgTx{coinbase}, txs...),
+	}
+	leaves := make([]chainhash.Hash, len(msg.Transactions))
+	for i, tx := range msg.Transactions {
+		leaves[i] = tx.TxHash()
+	}
+	levels := merkle.BuildTree(leaves)
+	msg.Header.MerkleRoot = levels[len(levels)-1][0]
+
+	return c.connect(msg)
+}
+
+// subsidy returns the block reward at height without fees
+func (c *Chain) subsidy(height int64) int64 {
+	interval := int64(c.Params.SubsidyReductionInterval)
+	if interval <= 0 {
+		return 50 * btcutil.SatoshiPerBitcoin
+	}
+	halvings := height / interval
+	if halvings >= 64 {
+		return 0
+	}
+	return (50 * btcutil.SatoshiPerBitcoin) >> uint(halvings)
+}
+
+// Subsidy returns the block reward at height without fees
+func (c *Chain) Subsidy(height int64) int64 {
+	return c.subsidy(height)
+}
+
+// fee returns what a transaction pays in fees over its known inputs; c.mu
+// must be held
+func (c *Chain) fee(tx *wire.MsgTx) int64 {
+	var in, out int64
+	for _, txIn := range tx.TxIn {
+		prev := c.coins[txIn.PreviousOutPoint]
+		if prev == nil {
+			return 0
+		}
+		in += prev.out.Value
+	}
+	for _, txOut := range tx.TxOut {
+		out += txOut.Value
+	}
+	return in - out
+}
+
+// connect applies a block to the UTXO set and indexes it; c.mu must be held
+// (or the chain not yet shared)
+func (c *Chain) connect(msg *wire.MsgBlock) *Block {
+	height := int64(len(c.blocks))
+	block := &Block{
+		Msg:      msg,
+		Hash:     msg.BlockHash().String(),
+		Height:   height,
+		prevOuts: make(map[wire.OutPoint]*coin),
+	}
+
+	var prevScripts [][]byte
+	for i, tx := range msg.Transactions {
+		if i > 0 {
+			block.Fees += c.fee(tx)
+			for _, txIn := range tx.TxIn {
+				prev, ok := c.coins[txIn.PreviousOutPoint]
+				if !ok {
+					continue
+				}
+				prevScripts = append(prevScripts, prev.out.PkScript)
+				block.prevOuts[txIn.PreviousOutPoint] = prev
+				delete(c.coins, txIn.PreviousOutPoint)
+			}
+		}
+
+		txid := tx.TxHash()
+		c.txBlock[txid.String()] = block
+		c.dropFromMempool(tx)
+		// The genesis coinbase never enters the UTXO set
+		if height == 0 {
+			continue
+		}
+		for n, txOut := range tx.TxOut {
+			if len(txOut.PkScript) > 0 && txOut.PkScript[0] == txscript.OP_RETURN {
+				continue
+			}
+			c.coins[wire.OutPoint{Hash: txid, Index: uint32(n)}] = &coin{out: txOut, height: height, coinbase: i == 0, block: block.Hash}
+		}
+	}
+
+	filter, err := builder.BuildBasicFilter(msg, prevScripts)
+	if err != nil {
+		panic(err)
+	}
+	block.Filter, err = filter.NBytes()
+	if err != nil {
+		panic(err)
+	}
+	var prevHeader chainhash.Hash
+	if height > 0 {
+		parsed, _ := chainhash.NewHashFromStr(c.blocks[height-1].FilterHeader)
+		prevHeader = *parsed
+	}
+	header, err := builder.MakeHeaderForFilter(filter, prevHeader)
+	if err != nil {
+		panic(err)
+	}
+	block.FilterHeader = header.String()
+
+	c.blocks = append(c.blocks, block)
+	c.byHash[block.Hash] = block
+	return block
+}
+
+// Disconnect removes the tip block, restoring the outputs it spent. Its
+// transactions are not returned to the mempool.
+func (c *Chain) Disconnect() *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	block := c.blocks[len(c.blocks)-1]
+	c.blocks = c.blocks[:len(c.blocks)-1]
+	delete(c.byHash, block.Hash)
+	for _, tx := range block.Msg.Transactions {
+		txid := tx.TxHash()
+		delete(c.txBlock, txid.String())
+		for n := range tx.TxOut {
+			delete(c.coins, wire.OutPoint{Hash: txid, Index: uint32(n)})
+		}
+	}
+	for outpoint, prev := range block.prevOuts {
+		c.coins[outpoint] = prev
+	}
+	return block
+}
+
+// AddToMempool accepts an unconfirmed transaction: its spends show in
+// gettxout with include_mempool and in gettxspendingprevout
+func (c *Chain) AddToMempool(tx *wire.MsgTx) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	txid := tx.TxHash().String()
+	c.mempool[txid] = tx
+	for _, txIn := range tx.TxIn {
+		c.mempoolSpends[txIn.PreviousOutPoint] = txid
+	}
+}
+
+// RemoveFromMempool evicts an unconfirmed transaction
+func (c *Chain) RemoveFromMempool(txid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tx, ok := c.mempool[txid]; ok {
+		c.dropFromMempool(tx)
+	}
+}
+
+// dropFromMempool forgets a transaction and its spends; c.mu must be held
+func (c *Chain) dropFromMempool(tx *wire.MsgTx) {
+	txid := tx.TxHash().String()
+	if _, ok := c.mempool[txid]; !ok {
+		return
+	}
+	delete(c.mempool, txid)
+	for _, txIn := range tx.TxIn {
+		if c.mempoolSpends[txIn.PreviousOutPoint] == txid {
+			delete(c.mempoolSpends, txIn.PreviousOutPoint)
+		}
+	}
+}
+
+// Tip returns the last connected block
+func (c *Chain) Tip() *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blocks[len(c.blocks)-1]
+}
+
+// Height returns the tip height
+func (c *Chain) Height() int64 {
+	return c.Tip().Height
+}
+
+// BlockAt returns the block at height, nil beyond the tip
+func (c *Chain) BlockAt(height int64) *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if height < 0 || height >= int64(len(c.blocks)) {
+		return nil
+	}
+	return c.blocks[height]
+}
+
+// Block returns a connected block by hash
+func (c *Chain) Block(hash string) *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byHash[hash]
+}
+
+// Hex serializes a block or transaction
+func Hex(v interface{ Serialize(io.Writer) error }) string {
+	var buf bytes.Buffer
+	if err := v.Serialize(&buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// formatBTC renders satoshis the way Core's verbose output does
+func formatBTC(sats int64) string {
+	sign := ""
+	if sats < 0 {
+		sign, sats = "-", -sats
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, sats/btcutil.SatoshiPerBitcoin, sats%btcutil.SatoshiPerBitcoin)
+}
+
+// medianTime returns the median time past of the block at height; c.mu
+// must be held
+func (c *Chain) medianTime(height int64) int64 {
+	var times []int64
+	for h := height; h >= 0 && h > height-11; h-- {
+		times = append(times, c.blocks[h].Time())
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
+// Address returns a made-up address of a script type, "p2pkh", "p2sh",
+// "p2wpkh", "p2wsh" or "p2tr", distinct for each seed
+func Address(params *chaincfg.Params, kind string, seed byte) btcutil.Address {
+	program := bytes.Repeat([]byte{seed}, 32)
+	var address btcutil.Address
+	var err error
+	switch kind {
+	case "p2pkh":
+		address, err = btcutil.NewAddressPubKeyHash(program[:20], params)
+	case "p2sh":
+		address, err = btcutil.NewAddressScriptHashFromHash(program[:20], params)
+	case "p2wpkh":
+		address, err = btcutil.NewAddressWitnessPubKeyHash(program[:20], params)
+	case "p2wsh":
+		address, err = btcutil.NewAddressWitnessScriptHash(program, params)
+	case "p2tr":
+		address, err = btcutil.NewAddressTaproot(program, params)
+	default:
+		err = fmt.Errorf("unknown address type %q", kind)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return address
+}
@@ -0,0 +1,110 @@
+package rpctest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// combineRawTransaction merges the signatures of partially signed copies of
+// a transaction, like bitcoind. Only P2SH multisig inputs are merged; other
+// inputs keep the first copy's scriptSig that is not empty.
+func (n *Node) combineRawTransaction(params []json.RawMessage) (interface{}, error) {
+	var txHexes []string
+	if _, err := Param(params, 0, &txHexes); err != nil {
+		return nil, err
+	}
+	txs := make([]*wire.MsgTx, len(txHexes))
+	for i, txHex := range txHexes {
+		raw, err := hex.DecodeString(txHex)
+		if err != nil {
+			return nil, &rpc.RPCError{Code: -22, Message: "TX decode failed for tx " + txHex}
+		}
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+			return nil, &rpc.RPCError{Code: -22, Message: "TX decode failed for tx " + txHex}
+		}
+		txs[i] = &tx
+	}
+	if len(txs) == 0 {
+		return nil, &rpc.RPCError{Code: -22, Message: "Missing transactions"}
+	}
+
+	merged := txs[0].Copy()
+	for i, txIn := range merged.TxIn {
+		var scripts [][]byte
+		for _, tx := range txs {
+			if len(tx.TxIn) != len(merged.TxIn) || tx.TxIn[i].PreviousOutPoint != txIn.PreviousOutPoint {
+				return nil, &rpc.RPCError{Code: -25, Message: "Input not found or already spent"}
+			}
+			if len(tx.TxIn[i].SignatureScript) > 0 {
+				scripts = append(scripts, tx.TxIn[i].SignatureScript)
+			}
+		}
+		if len(scripts) == 0 {
+			continue
+		}
+		txIn.SignatureScript = scripts[0]
+		if combined, ok := n.combineMultiSig(merged, i, scripts); ok {
+			txIn.SignatureScript = combined
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := merged.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// combineMultiSig builds a P2SH multisig scriptSig from the signatures in
+// scripts, ordered by the redeem script's keys
+func (n *Node) combineMultiSig(tx *wire.MsgTx, idx int, scripts [][]byte) ([]byte, bool) {
+	var redeem []byte
+	var sigs [][]byte
+	for _, script := range scripts {
+		pushes, err := txscript.PushedData(script)
+		if err != nil || len(pushes) == 0 {
+			return nil, false
+		}
+		redeem = pushes[len(pushes)-1]
+		sigs = append(sigs, pushes[:len(pushes)-1]...)
+	}
+	class, addresses, required, err := txscript.ExtractPkScriptAddrs(redeem, n.Chain.Params)
+	if err != nil || class != txscript.MultiSigTy {
+		return nil, false
+	}
+
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_FALSE)
+	signed := 0
+	for _, address := range addresses {
+		pubKey, ok := address.(*btcutil.AddressPubKey)
+		if !ok || signed == required {
+			break
+		}
+		for _, sig := range sigs {
+			if len(sig) == 0 {
+				continue
+			}
+			hash, err := txscript.CalcSignatureHash(redeem, txscript.SigHashType(sig[len(sig)-1]), tx, idx)
+			if err != nil {
+				continue
+			}
+			parsed, err := ecdsa.ParseDERSignature(sig[:len(sig)-1])
+			if err == nil && parsed.Verify(hash, pubKey.PubKey()) {
+				builder.AddData(sig)
+				signed++
+				break
+			}
+		}
+	}
+	script, err := builder.AddData(redeem).Script()
+	return script, err == nil
+}
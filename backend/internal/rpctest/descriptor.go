@@ -0,0 +1,184 @@
+package rpctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// deriveAddresses derives the addresses of a ranged pkh(), wpkh() or
+// sh(wpkh()) descriptor over an extended public key, like bitcoind. The
+// checksum is not checked, and hardened steps fail as they need the
+// private key.
+func (n *Node) deriveAddresses(params []json.RawMessage) (interface{}, error) {
+	var descriptor string
+	if _, err := Param(params, 0, &descriptor); err != nil {
+		return nil, err
+	}
+	start, end, err := deriveRange(params)
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.Index(descriptor, "#"); i >= 0 {
+		descriptor = descriptor[:i]
+	}
+
+	var wrap string
+	for _, prefix := range []string{"sh(wpkh(", "wpkh(", "pkh("} {
+		if strings.HasPrefix(descriptor, prefix) && strings.HasSuffix(descriptor, strings.Repeat(")", strings.Count(prefix, "("))) {
+			wrap = prefix
+			break
+		}
+	}
+	if wrap == "" {
+		return nil, descriptorError("deriveaddresses stand-in only reads pkh(), wpkh() and sh(wpkh()) descriptors")
+	}
+	key := descriptor[len(wrap) : len(descriptor)-strings.Count(wrap, "(")]
+	if strings.HasPrefix(key, "[") {
+		closing := strings.Index(key, "]")
+		if closing < 0 {
+			return nil, descriptorError("Key origin start '[ character expected but not found")
+		}
+		key = key[closing+1:]
+	}
+
+	steps := strings.Split(key, "/")
+	extended, err := hdkeychain.NewKeyFromString(steps[0])
+	if err != nil {
+		return nil, descriptorError("key '" + steps[0] + "' is not valid")
+	}
+	if strings.Count(key, "*") != 1 || steps[len(steps)-1] != "*" {
+		return nil, descriptorError("Range must be specified for a ranged descriptor")
+	}
+	for _, step := range steps[1 : len(steps)-1] {
+		index, err := strconv.ParseUint(step, 10, 31)
+		if err != nil {
+			return nil, descriptorError("Key path value '" + step + "' is not a valid uint32")
+		}
+		if extended, err = extended.Derive(uint32(index)); err != nil {
+			return nil, err
+		}
+	}
+
+	addresses := make([]string, 0, end-start+1)
+	for index := start; index <= end; index++ {
+		child, err := extended.Derive(uint32(index))
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		hash := btcutil.Hash160(pubKey.SerializeCompressed())
+		var address btcutil.Address
+		switch wrap {
+		case "pkh(":
+			address, err = btcutil.NewAddressPubKeyHash(hash, n.Chain.Params)
+		case "wpkh(":
+			address, err = btcutil.NewAddressWitnessPubKeyHash(hash, n.Chain.Params)
+		case "sh(wpkh(":
+			address, err = btcutil.NewAddressScriptHash(append([]byte{0x00, 0x14}, hash...), n.Chain.Params)
+		}
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address.EncodeAddress())
+	}
+	return addresses, nil
+}
+
+// deriveRange reads deriveaddresses' range, an end index or [begin,end]
+func deriveRange(params []json.RawMessage) (int, int, error) {
+	var bounds []int
+	if ok, err := Param(params, 1, &bounds); err == nil && ok && len(bounds) == 2 && bounds[0] <= bounds[1] {
+		return bounds[0], bounds[1], nil
+	}
+	var end int
+	if ok, err := Param(params, 1, &end); err != nil || !ok || end < 0 {
+		return 0, 0, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "Range should be greater or equal than 0"}
+	}
+	return 0, end, nil
+}
+
+func descriptorError(message string) error {
+	return &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: message}
+}
+
+// getDescriptorInfo analyses a descriptor like bitcoind, appending the
+// checksum to the canonical form. Keys are not parsed, so the descriptor is
+// returned as supplied and only raw() and addr() count as unsolvable.
+func (n *Node) getDescriptorInfo(params []json.RawMessage) (interface{}, error) {
+	var descriptor string
+	if _, err := Param(params, 0, &descriptor); err != nil {
+		return nil, err
+	}
+	body, supplied, hasChecksum := strings.Cut(descriptor, "#")
+	checksum, ok := descriptorChecksum(body)
+	if !ok {
+		return nil, descriptorError("Invalid characters in payload")
+	}
+	if hasChecksum && supplied != checksum {
+		return nil, descriptorError(fmt.Sprintf("Provided checksum '%s' does not match computed checksum '%s'", supplied, checksum))
+	}
+	return map[string]interface{}{
+		"descriptor":     body + "#" + checksum,
+		"checksum":       checksum,
+		"isrange":        strings.Contains(body, "*"),
+		"issolvable":     !strings.HasPrefix(body, "raw(") && !strings.HasPrefix(body, "addr("),
+		"hasprivatekeys": strings.Contains(body, "prv"),
+	}, nil
+}
+
+// descriptorChecksum computes the BIP380 checksum of a descriptor without
+// one; ok is false for characters outside the descriptor character set
+func descriptorChecksum(descriptor string) (string, bool) {
+	const (
+		inputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+		checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	)
+	polymod := func(c, val uint64) uint64 {
+		c0 := c >> 35
+		c = (c&0x7ffffffff)<<5 ^ val
+		for i, g := range []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd} {
+			if c0>>i&1 == 1 {
+				c ^= g
+			}
+		}
+		return c
+	}
+
+	c := uint64(1)
+	var cls, clsCount uint64
+	for _, ch := range descriptor {
+		pos := strings.IndexRune(inputCharset, ch)
+		if pos < 0 {
+			return "", false
+		}
+		c = polymod(c, uint64(pos&31))
+		cls = cls*3 + uint64(pos>>5)
+		if clsCount++; clsCount == 3 {
+			c = polymod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polymod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = polymod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, 8)
+	for i := range checksum {
+		checksum[i] = checksumCharset[c>>(5*(7-i))&31]
+	}
+	return string(checksum), true
+}
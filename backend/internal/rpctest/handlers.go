@@ -0,0 +1,723 @@
+package rpctest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// NodeVersion is the version getnetworkinfo reports
+const NodeVersion = 270000
+
+// chainHandler returns the handler answering a method from the chain
+func (n *Node) chainHandler(method string) (Handler, bool) {
+	handlers := map[string]Handler{
+		"getblockcount":         n.getBlockCount,
+		"getblockhash":          n.getBlockHash,
+		"getbestblockhash":      n.getBestBlockHash,
+		"getblockheader":        n.getBlockHeader,
+		"getblock":              n.getBlock,
+		"getblockfilter":        n.getBlockFilter,
+		"getblockstats":         n.getBlockStats,
+		"gettxout":              n.getTxOut,
+		"getrawtransaction":     n.getRawTransaction,
+		"gettxspendingprevout":  n.getTxSpendingPrevOut,
+		"getrawmempool":         n.getRawMempool,
+		"getmempoolentry":       n.getMempoolEntry,
+		"sendrawtransaction":    n.sendRawTransaction,
+		"combinerawtransaction": n.combineRawTransaction,
+		"scantxoutset":          n.scanTxOutSet,
+		"deriveaddresses":       n.deriveAddresses,
+		"getdescriptorinfo":     n.getDescriptorInfo,
+		"getblockchaininfo":     n.getBlockchainInfo,
+		"getnetworkinfo":        n.getNetworkInfo,
+		"gettxoutproof":         n.getTxOutProof,
+		"verifytxoutproof":      n.verifyTxOutProof,
+	}
+	handler, ok := handlers[method]
+	return handler, ok
+}
+
+// Errors as bitcoind words them
+var (
+	errBlockNotFound  = &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "Block not found"}
+	errHeightRange    = &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "Block height out of range"}
+	errNotInMempool   = &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "Transaction not in mempool"}
+	errNoTxIndex      = &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "No such mempool transaction. Use -txindex or provide a block hash to enable blockchain transaction queries. Use gettransaction for wallet transactions."}
+	errNotInBlock     = &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "No such transaction found in the provided block. Use gettransaction for wallet transactions."}
+	errNoSuchTx       = &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "No such mempool or blockchain transaction. Use gettransaction for wallet transactions."}
+	errInvalidRequest = &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "Invalid parameters"}
+)
+
+func (n *Node) getBlockCount(params []json.RawMessage) (interface{}, error) {
+	return n.Chain.Height(), nil
+}
+
+func (n *Node) getBlockHash(params []json.RawMessage) (interface{}, error) {
+	var height int64
+	if _, err := Param(params, 0, &height); err != nil {
+		return nil, err
+	}
+	block := n.Chain.BlockAt(height)
+	if block == nil {
+		return nil, errHeightRange
+	}
+	return block.Hash, nil
+}
+
+func (n *Node) getBestBlockHash(params []json.RawMessage) (interface{}, error) {
+	return n.Chain.Tip().Hash, nil
+}
+
+// blockParam reads a block hash parameter
+func (n *Node) blockParam(params []json.RawMessage, i int) (*Block, error) {
+	var hash string
+	if _, err := Param(params, i, &hash); err != nil {
+		return nil, err
+	}
+	block := n.Chain.Block(hash)
+	if block == nil {
+		return nil, errBlockNotFound
+	}
+	return block, nil
+}
+
+func (n *Node) getBlockHeader(params []json.RawMessage) (interface{}, error) {
+	block, err := n.blockParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	verbose := true
+	if _, err := Param(params, 1, &verbose); err != nil {
+		return nil, err
+	}
+	if !verbose {
+		return Hex(&block.Msg.Header), nil
+	}
+	return n.Chain.headerFields(block), nil
+}
+
+func (n *Node) getBlock(params []json.RawMessage) (interface{}, error) {
+	block, err := n.blockParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	verbosity := 1
+	if _, err := Param(params, 1, &verbosity); err != nil {
+		return nil, err
+	}
+	if verbosity == 0 {
+		return Hex(block.Msg), nil
+	}
+
+	c := n.Chain
+	fields := c.headerFields(block)
+	var buf bytes.Buffer
+	block.Msg.Serialize(&buf)
+	fields["size"] = buf.Len()
+	fields["strippedsize"] = block.Msg.SerializeSizeStripped()
+	fields["weight"] = block.Msg.SerializeSizeStripped()*3 + buf.Len()
+	if verbosity == 1 {
+		fields["tx"] = block.TxIDs()
+		return fields, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	txs := make([]map[string]interface{}, len(block.Msg.Transactions))
+	for i, tx := range block.Msg.Transactions {
+		txs[i] = c.txFields(tx, verbosity == 3, block.prevOuts)
+		if i > 0 {
+			if fee := c.blockTxFee(tx, block.prevOuts); fee >= 0 {
+				txs[i]["fee"] = json.Number(formatBTC(fee))
+			}
+		}
+	}
+	fields["tx"] = txs
+	return fields, nil
+}
+
+func (n *Node) getBlockFilter(params []json.RawMessage) (interface{}, error) {
+	block, err := n.blockParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"filter": hex.EncodeToString(block.Filter),
+		"header": block.FilterHeader,
+	}, nil
+}
+
+func (n *Node) getBlockStats(params []json.RawMessage) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, errInvalidRequest
+	}
+	var block *Block
+	var height int64
+	if err := json.Unmarshal(params[0], &height); err == nil {
+		if block = n.Chain.BlockAt(height); block == nil {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: fmt.Sprintf("Target block height %d after current tip %d", height, n.Chain.Height())}
+		}
+	} else if block, err = n.blockParam(params, 0); err != nil {
+		return nil, err
+	}
+	var wanted []string
+	if _, err := Param(params, 1, &wanted); err != nil {
+		return nil, err
+	}
+
+	c := n.Chain
+	c.mu.Lock()
+	var totalOut int64
+	for _, tx := range block.Msg.Transactions[1:] {
+		for _, out := range tx.TxOut {
+			totalOut += out.Value
+		}
+	}
+	stats := map[string]interface{}{
+		"height":     block.Height,
+		"blockhash":  block.Hash,
+		"subsidy":    c.subsidy(block.Height),
+		"totalfee":   block.Fees,
+		"txs":        len(block.Msg.Transactions),
+		"total_out":  totalOut,
+		"time":       block.Time(),
+		"mediantime": c.medianTime(block.Height),
+		"ins":        countInputs(block.Msg),
+		"outs":       countOutputs(block.Msg),
+	}
+	c.mu.Unlock()
+
+	if len(wanted) == 0 {
+		return stats, nil
+	}
+	selected := make(map[string]interface{}, len(wanted))
+	for _, name := range wanted {
+		value, ok := stats[name]
+		if !ok {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: fmt.Sprintf("Invalid selected statistic '%s'", name)}
+		}
+		selected[name] = value
+	}
+	return selected, nil
+}
+
+func (n *Node) getTxOut(params []json.RawMessage) (interface{}, error) {
+	var txid string
+	var vout uint32
+	includeMempool := true
+	if _, err := Param(params, 0, &txid); err != nil {
+		return nil, err
+	}
+	if _, err := Param(params, 1, &vout); err != nil {
+		return nil, err
+	}
+	if _, err := Param(params, 2, &includeMempool); err != nil {
+		return nil, err
+	}
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "txid must be hexadecimal string"}
+	}
+	outpoint := wire.OutPoint{Hash: *hash, Index: vout}
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tip := c.blocks[len(c.blocks)-1]
+
+	if includeMempool {
+		if _, spent := c.mempoolSpends[outpoint]; spent {
+			return nil, nil
+		}
+		if tx, ok := c.mempool[txid]; ok && int(vout) < len(tx.TxOut) {
+			return c.txOutFields(tip, tx.TxOut[vout], 0, false), nil
+		}
+	}
+	coin, ok := c.coins[outpoint]
+	if !ok {
+		return nil, nil
+	}
+	return c.txOutFields(tip, coin.out, tip.Height-coin.height+1, coin.coinbase), nil
+}
+
+func (n *Node) getRawTransaction(params []json.RawMessage) (interface{}, error) {
+	var txid, blockHash string
+	var verbose interface{}
+	if _, err := Param(params, 0, &txid); err != nil {
+		return nil, err
+	}
+	if _, err := Param(params, 1, &verbose); err != nil {
+		return nil, err
+	}
+	if _, err := Param(params, 2, &blockHash); err != nil {
+		return nil, err
+	}
+	// Older nodes take a bool, newer ones a verbosity number
+	isVerbose := verbose == true || (verbose != nil && verbose != false && verbose != float64(0))
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var tx *wire.MsgTx
+	var block *Block
+	switch {
+	case blockHash != "":
+		block = c.byHash[blockHash]
+		if block == nil {
+			return nil, errBlockNotFound
+		}
+		tx = findTx(block.Msg, txid)
+		if tx == nil {
+			return nil, errNotInBlock
+		}
+	case c.mempool[txid] != nil:
+		tx = c.mempool[txid]
+	case c.txBlock[txid] != nil:
+		if !n.TxIndex {
+			return nil, errNoTxIndex
+		}
+		block = c.txBlock[txid]
+		tx = findTx(block.Msg, txid)
+	default:
+		if !n.TxIndex {
+			return nil, errNoTxIndex
+		}
+		return nil, errNoSuchTx
+	}
+
+	if !isVerbose {
+		return Hex(tx), nil
+	}
+	fields := c.txFields(tx, false, nil)
+	if block != nil {
+		tip := c.blocks[len(c.blocks)-1]
+		fields["blockhash"] = block.Hash
+		fields["confirmations"] = tip.Height - block.Height + 1
+		fields["time"] = block.Time()
+		fields["blocktime"] = block.Time()
+	}
+	return fields, nil
+}
+
+func (n *Node) getTxSpendingPrevOut(params []json.RawMessage) (interface{}, error) {
+	var outpoints []rpc.OutPoint
+	if _, err := Param(params, 0, &outpoints); err != nil {
+		return nil, err
+	}
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]map[string]interface{}, len(outpoints))
+	for i, op := range outpoints {
+		entry := map[string]interface{}{"txid": op.TxID, "vout": op.Vout}
+		if hash, err := chainhash.NewHashFromStr(op.TxID); err == nil {
+			if spender, ok := c.mempoolSpends[wire.OutPoint{Hash: *hash, Index: uint32(op.Vout)}]; ok {
+				entry["spendingtxid"] = spender
+			}
+		}
+		result[i] = entry
+	}
+	return result, nil
+}
+
+func (n *Node) getRawMempool(params []json.RawMessage) (interface{}, error) {
+	var verbose bool
+	if _, err := Param(params, 0, &verbose); err != nil {
+		return nil, err
+	}
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if verbose {
+		entries := make(map[string]interface{}, len(c.mempool))
+		for txid, tx := range c.mempool {
+			entries[txid] = c.mempoolEntryFields(tx)
+		}
+		return entries, nil
+	}
+	txids := make([]string, 0, len(c.mempool))
+	for txid := range c.mempool {
+		txids = append(txids, txid)
+	}
+	sort.Strings(txids)
+	return txids, nil
+}
+
+func (n *Node) getMempoolEntry(params []json.RawMessage) (interface{}, error) {
+	var txid string
+	if _, err := Param(params, 0, &txid); err != nil {
+		return nil, err
+	}
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.mempool[txid]
+	if !ok {
+		return nil, errNotInMempool
+	}
+	return c.mempoolEntryFields(tx), nil
+}
+
+// mempoolEntryFields is a mempool transaction's entry, as getmempoolentry
+// and verbose getrawmempool report it. c.mu must be held.
+func (c *Chain) mempoolEntryFields(tx *wire.MsgTx) map[string]interface{} {
+	vsize := (tx.SerializeSizeStripped()*3 + tx.SerializeSize() + 3) / 4
+	fee := json.Number(formatBTC(c.fee(tx)))
+	return map[string]interface{}{
+		"vsize":           vsize,
+		"weight":          tx.SerializeSizeStripped()*3 + tx.SerializeSize(),
+		"time":            c.blocks[len(c.blocks)-1].Time(),
+		"height":          c.blocks[len(c.blocks)-1].Height,
+		"descendantcount": 1,
+		"descendantsize":  vsize,
+		"ancestorcount":   1,
+		"ancestorsize":    vsize,
+		"fees": map[string]interface{}{
+			"base":       fee,
+			"modified":   fee,
+			"ancestor":   fee,
+			"descendant": fee,
+		},
+		"depends": []string{},
+	}
+}
+
+func (n *Node) sendRawTransaction(params []json.RawMessage) (interface{}, error) {
+	var txHex string
+	if _, err := Param(params, 0, &txHex); err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, &rpc.RPCError{Code: -22, Message: "TX decode failed"}
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, &rpc.RPCError{Code: -22, Message: "TX decode failed"}
+	}
+	n.Chain.AddToMempool(&tx)
+	return tx.TxHash().String(), nil
+}
+
+func (n *Node) scanTxOutSet(params []json.RawMessage) (interface{}, error) {
+	var action string
+	if _, err := Param(params, 0, &action); err != nil {
+		return nil, err
+	}
+	switch action {
+	case "abort":
+		return false, nil
+	case "status":
+		return nil, nil
+	case "start":
+	default:
+		return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "Invalid action '" + action + "'"}
+	}
+
+	var descriptors []string
+	if _, err := Param(params, 1, &descriptors); err != nil {
+		return nil, err
+	}
+	c := n.Chain
+	scripts := make(map[string]string) // Script hex -> descriptor
+	for _, descriptor := range descriptors {
+		if !strings.HasPrefix(descriptor, "addr(") || !strings.HasSuffix(descriptor, ")") {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "scantxoutset stand-in only reads addr() descriptors"}
+		}
+		address, err := btcutil.DecodeAddress(descriptor[len("addr("):len(descriptor)-1], c.Params)
+		if err != nil {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "Invalid address"}
+		}
+		script, err := txscript.PayToAddrScript(address)
+		if err != nil {
+			return nil, err
+		}
+		scripts[hex.EncodeToString(script)] = descriptor
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tip := c.blocks[len(c.blocks)-1]
+	unspents := []map[string]interface{}{}
+	var total int64
+	for outpoint, coin := range c.coins {
+		scriptHex := hex.EncodeToString(coin.out.PkScript)
+		descriptor, ok := scripts[scriptHex]
+		if !ok {
+			continue
+		}
+		total += coin.out.Value
+		unspents = append(unspents, map[string]interface{}{
+			"txid":         outpoint.Hash.String(),
+			"vout":         outpoint.Index,
+			"scriptPubKey": scriptHex,
+			"desc":         descriptor,
+			"amount":       json.Number(formatBTC(coin.out.Value)),
+			"coinbase":     coin.coinbase,
+			"height":       coin.height,
+			"blockhash":    coin.block,
+		})
+	}
+	sort.Slice(unspents, func(i, j int) bool {
+		a, b := unspents[i], unspents[j]
+		if a["txid"] != b["txid"] {
+			return a["txid"].(string) < b["txid"].(string)
+		}
+		return a["vout"].(uint32) < b["vout"].(uint32)
+	})
+	return map[string]interface{}{
+		"success":      true,
+		"txouts":       len(c.coins),
+		"height":       tip.Height,
+		"bestblock":    tip.Hash,
+		"unspents":     unspents,
+		"total_amount": json.Number(formatBTC(total)),
+	}, nil
+}
+
+func (n *Node) getBlockchainInfo(params []json.RawMessage) (interface{}, error) {
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tip := c.blocks[len(c.blocks)-1]
+	return map[string]interface{}{
+		"chain":                c.Params.Name,
+		"blocks":               tip.Height,
+		"headers":              tip.Height,
+		"bestblockhash":        tip.Hash,
+		"time":                 tip.Time(),
+		"mediantime":           c.medianTime(tip.Height),
+		"verificationprogress": 1,
+		"initialblockdownload": false,
+		"pruned":               false,
+	}, nil
+}
+
+func (n *Node) getNetworkInfo(params []json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"version":         NodeVersion,
+		"subversion":      "/Satoshi:27.0.0/",
+		"protocolversion": 70016,
+		"connections":     8,
+		"networkactive":   true,
+	}, nil
+}
+
+// headerFields returns a block's verbose header
+func (c *Chain) headerFields(block *Block) map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tip := c.blocks[len(c.blocks)-1]
+	header := block.Msg.Header
+	fields := map[string]interface{}{
+		"hash":          block.Hash,
+		"confirmations": tip.Height - block.Height + 1,
+		"height":        block.Height,
+		"version":       header.Version,
+		"versionHex":    fmt.Sprintf("%08x", header.Version),
+		"merkleroot":    header.MerkleRoot.String(),
+		"time":          block.Time(),
+		"mediantime":    c.medianTime(block.Height),
+		"nonce":         header.Nonce,
+		"bits":          fmt.Sprintf("%08x", header.Bits),
+		"difficulty":    1,
+		"nTx":           len(block.Msg.Transactions),
+	}
+	// Every block is mined at the same target, so each adds the same work
+	work := new(big.Int).Mul(blockchain.CalcWork(header.Bits), big.NewInt(block.Height+1))
+	fields["chainwork"] = fmt.Sprintf("%064x", work)
+	if block.Height > 0 {
+		fields["previousblockhash"] = header.PrevBlock.String()
+	}
+	if block.Height < tip.Height {
+		fields["nextblockhash"] = c.blocks[block.Height+1].Hash
+	}
+	return fields
+}
+
+// txFields returns a transaction's verbose form; with prevOut, inputs carry
+// the output they spend from prevOuts or the UTXO set. c.mu must be held.
+func (c *Chain) txFields(tx *wire.MsgTx, prevOut bool, prevOuts map[wire.OutPoint]*coin) map[string]interface{} {
+	coinbase := len(tx.TxIn) == 1 && tx.TxIn[0].PreviousOutPoint.Index == wire.MaxPrevOutIndex && tx.TxIn[0].PreviousOutPoint.Hash == chainhash.Hash{}
+
+	vin := make([]map[string]interface{}, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		if coinbase {
+			vin[i] = map[string]interface{}{
+				"coinbase": hex.EncodeToString(txIn.SignatureScript),
+				"sequence": txIn.Sequence,
+			}
+			continue
+		}
+		in := map[string]interface{}{
+			"txid":      txIn.PreviousOutPoint.Hash.String(),
+			"vout":      txIn.PreviousOutPoint.Index,
+			"scriptSig": map[string]string{"asm": "", "hex": hex.EncodeToString(txIn.SignatureScript)},
+			"sequence":  txIn.Sequence,
+		}
+		if len(txIn.Witness) > 0 {
+			witness := make([]string, len(txIn.Witness))
+			for j, item := range txIn.Witness {
+				witness[j] = hex.EncodeToString(item)
+			}
+			in["txinwitness"] = witness
+		}
+		if prevOut {
+			prev := prevOuts[txIn.PreviousOutPoint]
+			if prev == nil {
+				prev = c.coins[txIn.PreviousOutPoint]
+			}
+			if prev != nil {
+				in["prevout"] = map[string]interface{}{
+					"generated":    prev.coinbase,
+					"height":       prev.height,
+					"value":        json.Number(formatBTC(prev.out.Value)),
+					"scriptPubKey": c.scriptFields(prev.out.PkScript),
+				}
+			}
+		}
+		vin[i] = in
+	}
+
+	vout := make([]map[string]interface{}, len(tx.TxOut))
+	for i, txOut := range tx.TxOut {
+		out := map[string]interface{}{
+			"value":        json.Number(formatBTC(txOut.Value)),
+			"n":            i,
+			"scriptPubKey": c.scriptFields(txOut.PkScript),
+		}
+		if c.ValueSat {
+			out["valueSat"] = txOut.Value
+		}
+		vout[i] = out
+	}
+
+	size := tx.SerializeSize()
+	weight := tx.SerializeSizeStripped()*3 + size
+	return map[string]interface{}{
+		"txid":     tx.TxHash().String(),
+		"hash":     tx.WitnessHash().String(),
+		"version":  tx.Version,
+		"size":     size,
+		"vsize":    (weight + 3) / 4,
+		"weight":   weight,
+		"locktime": tx.LockTime,
+		"vin":      vin,
+		"vout":     vout,
+		"hex":      Hex(tx),
+	}
+}
+
+// txOutFields is gettxout's answer for an output
+func (c *Chain) txOutFields(tip *Block, out *wire.TxOut, confirmations int64, coinbase bool) map[string]interface{} {
+	fields := map[string]interface{}{
+		"bestblock":     tip.Hash,
+		"confirmations": confirmations,
+		"value":         json.Number(formatBTC(out.Value)),
+		"scriptPubKey":  c.scriptFields(out.PkScript),
+		"coinbase":      coinbase,
+	}
+	if c.ValueSat {
+		fields["valueSat"] = out.Value
+	}
+	return fields
+}
+
+// scriptFields is the verbose form of an output script
+func (c *Chain) scriptFields(script []byte) map[string]interface{} {
+	fields := map[string]interface{}{
+		"asm":  "",
+		"hex":  hex.EncodeToString(script),
+		"type": coreScriptType(script),
+	}
+	if _, addresses, _, err := txscript.ExtractPkScriptAddrs(script, c.Params); err == nil && len(addresses) == 1 {
+		if class := txscript.GetScriptClass(script); class != txscript.PubKeyTy && class != txscript.MultiSigTy {
+			fields["address"] = addresses[0].EncodeAddress()
+		}
+	}
+	return fields
+}
+
+// blockTxFee returns a connected transaction's fee from the coins its block
+// spent, -1 if an input is unknown
+func (c *Chain) blockTxFee(tx *wire.MsgTx, prevOuts map[wire.OutPoint]*coin) int64 {
+	var in, out int64
+	for _, txIn := range tx.TxIn {
+		prev := prevOuts[txIn.PreviousOutPoint]
+		if prev == nil {
+			return -1
+		}
+		in += prev.out.Value
+	}
+	for _, txOut := range tx.TxOut {
+		out += txOut.Value
+	}
+	return in - out
+}
+
+// coreScriptType returns the type bitcoind reports for an output script
+func coreScriptType(script []byte) string {
+	if len(script) > 0 && script[0] == txscript.OP_RETURN {
+		return "nulldata"
+	}
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyTy:
+		return "pubkey"
+	case txscript.PubKeyHashTy:
+		return "pubkeyhash"
+	case txscript.ScriptHashTy:
+		return "scripthash"
+	case txscript.MultiSigTy:
+		return "multisig"
+	case txscript.WitnessV0PubKeyHashTy:
+		return "witness_v0_keyhash"
+	case txscript.WitnessV0ScriptHashTy:
+		return "witness_v0_scripthash"
+	case txscript.WitnessV1TaprootTy:
+		return "witness_v1_taproot"
+	}
+	return "nonstandard"
+}
+
+// findTx returns the block's transaction with txid, nil if it has none
+func findTx(block *wire.MsgBlock, txid string) *wire.MsgTx {
+	for _, tx := range block.Transactions {
+		if tx.TxHash().String() == txid {
+			return tx
+		}
+	}
+	return nil
+}
+
+func countInputs(block *wire.MsgBlock) int {
+	count := 0
+	for _, tx := range block.Transactions[1:] {
+		count += len(tx.TxIn)
+	}
+	return count
+}
+
+func countOutputs(block *wire.MsgBlock) int {
+	count := 0
+	for _, tx := range block.Transactions {
+		count += len(tx.TxOut)
+	}
+	return count
+}
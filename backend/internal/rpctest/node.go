@@ -0,0 +1,236 @@
+package rpctest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"spv-backend/internal/rpc"
+)
+
+// Handler answers one JSON-RPC method. Returning an *rpc.RPCError sends it
+// as the call's error; any other error is sent with code -1.
+type Handler func(params []json.RawMessage) (interface{}, error)
+
+// Node serves JSON-RPC over HTTP like bitcoind, single calls and batches,
+// answering from its Chain unless a method is overridden with Handle
+type Node struct {
+	Chain *Chain
+
+	// TxIndex lets getrawtransaction find confirmed transactions without a
+	// block hash, like a node running with -txindex
+	TxIndex bool
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    map[string]int
+	ids      []json.RawMessage
+	requests int
+	batches  int
+	bytes    int64
+}
+
+// NewNode starts a node serving chain, closed when the test ends
+func NewNode(t testing.TB, chain *Chain) *Node {
+	n := &Node{
+		Chain:    chain,
+		handlers: make(map[string]Handler),
+		calls:    make(map[string]int),
+	}
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	t.Cleanup(n.server.Close)
+	return n
+}
+
+// Handle overrides a method, or adds one the chain does not answer
+func (n *Node) Handle(method string, handler Handler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers[method] = handler
+}
+
+// Wrap replaces a method's handler, the override or the chain's own, with
+// wrap(handler), e.g. to delay or fail some calls before answering
+func (n *Node) Wrap(method string, wrap func(next Handler) Handler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	next, ok := n.handlers[method]
+	if !ok {
+		next, _ = n.chainHandler(method)
+	}
+	n.handlers[method] = wrap(next)
+}
+
+// Calls returns how many times a method was called, batched or not
+func (n *Node) Calls(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+// IDs returns the JSON-RPC ids of the calls received, batched or not, in
+// the order they were answered
+func (n *Node) IDs() []json.RawMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]json.RawMessage(nil), n.ids...)
+}
+
+// Requests returns how many HTTP requests the node received
+func (n *Node) Requests() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests
+}
+
+// Batches returns how many of the HTTP requests were batches
+func (n *Node) Batches() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.batches
+}
+
+// BytesSent returns how many response body bytes the node has written
+func (n *Node) BytesSent() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.bytes
+}
+
+// URL returns the node's base URL
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// Close stops the node, so later calls fail to connect
+func (n *Node) Close() {
+	n.server.Close()
+}
+
+// Client returns an RPC client for the node
+func (n *Node) Client(opts ...rpc.Option) *rpc.Client {
+	u, err := url.Parse(n.server.URL)
+	if err != nil {
+		panic(err)
+	}
+	return rpc.NewClient(u.Hostname(), u.Port(), "user", "pass", opts...)
+}
+
+// request is a JSON-RPC request as the node reads it; the ID is echoed as is
+type request struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     json.RawMessage   `json:"id"`
+}
+
+// response is a JSON-RPC response
+type response struct {
+	Result interface{}     `json:"result"`
+	Error  *rpc.RPCError   `json:"error"`
+	ID     json.RawMessage `json:"id"`
+}
+
+// countingWriter counts the response body bytes written through it
+type countingWriter struct {
+	http.ResponseWriter
+	n *Node
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	written, err := w.ResponseWriter.Write(p)
+	w.n.mu.Lock()
+	w.n.bytes += int64(written)
+	w.n.mu.Unlock()
+	return written, err
+}
+
+func (n *Node) serveHTTP(rw http.ResponseWriter, r *http.Request) {
+	w := countingWriter{ResponseWriter: rw, n: n}
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.requests++
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(body) > 0 && body[0] == '[' {
+		var requests []request
+		if err := json.Unmarshal(body, &requests); err != nil {
+			http.Error(w, "invalid batch", http.StatusBadRequest)
+			return
+		}
+		n.mu.Lock()
+		n.batches++
+		n.mu.Unlock()
+
+		responses := make([]response, len(requests))
+		for i, req := range requests {
+			responses[i] = n.call(req)
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	resp := n.call(req)
+	// Like bitcoind, errors come with a non-2xx status and a JSON-RPC body
+	if resp.Error != nil {
+		status := http.StatusInternalServerError
+		if resp.Error.Code == rpc.ErrCodeMethodNotFound {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// call runs one request through its handler
+func (n *Node) call(req request) response {
+	n.mu.Lock()
+	n.calls[req.Method]++
+	n.ids = append(n.ids, req.ID)
+	handler, ok := n.handlers[req.Method]
+	n.mu.Unlock()
+	if !ok {
+		handler, ok = n.chainHandler(req.Method)
+	}
+	if !ok {
+		return response{ID: req.ID, Error: &rpc.RPCError{Code: rpc.ErrCodeMethodNotFound, Message: "Method not found"}}
+	}
+
+	result, err := handler(req.Params)
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpc.RPCError{Code: -1, Message: err.Error()}
+		}
+		return response{ID: req.ID, Error: rpcErr}
+	}
+	return response{ID: req.ID, Result: result}
+}
+
+// Param decodes params[i] into v, reporting whether it was present
+func Param(params []json.RawMessage, i int, v interface{}) (bool, error) {
+	if i >= len(params) || string(params[i]) == "null" {
+		return false, nil
+	}
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return true, &rpc.RPCError{Code: -1, Message: fmt.Sprintf("invalid parameter %d: %v", i, err)}
+	}
+	return true, nil
+}
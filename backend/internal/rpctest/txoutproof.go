@@ -0,0 +1,242 @@
+package rpctest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+
+	"spv-backend/internal/rpc"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// getTxOutProof serializes a merkle block proving txids are in a block, in
+// bitcoind's CMerkleBlock format. Without a block hash, the transactions are
+// looked up in the chain as if -txindex were set.
+func (n *Node) getTxOutProof(params []json.RawMessage) (interface{}, error) {
+	var txids []string
+	var blockHash string
+	if _, err := Param(params, 0, &txids); err != nil {
+		return nil, err
+	}
+	if _, err := Param(params, 1, &blockHash); err != nil {
+		return nil, err
+	}
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wanted := make(map[chainhash.Hash]bool)
+	for _, txid := range txids {
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "txid must be hexadecimal string"}
+		}
+		if wanted[*hash] {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "Invalid parameter, duplicated txid: " + txid}
+		}
+		wanted[*hash] = true
+	}
+
+	var block *Block
+	switch {
+	case blockHash != "":
+		block = c.byHash[blockHash]
+		if block == nil {
+			return nil, errBlockNotFound
+		}
+	case len(txids) > 0:
+		block = c.txBlock[txids[0]]
+		if block == nil {
+			return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "Transaction not yet in block"}
+		}
+	default:
+		return nil, errInvalidRequest
+	}
+
+	leaves := make([]chainhash.Hash, len(block.Msg.Transactions))
+	matches := make([]bool, len(leaves))
+	found := 0
+	for i, tx := range block.Msg.Transactions {
+		leaves[i] = tx.TxHash()
+		if wanted[leaves[i]] {
+			matches[i] = true
+			found++
+		}
+	}
+	if found != len(wanted) {
+		return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "Not all transactions found in specified or retrieved block"}
+	}
+
+	tree := &partialMerkleTree{leaves: leaves, matches: matches}
+	tree.build(tree.height(), 0)
+	msg := wire.MsgMerkleBlock{
+		Header:       block.Msg.Header,
+		Transactions: uint32(len(leaves)),
+		Flags:        make([]byte, (len(tree.bits)+7)/8),
+	}
+	for i := range tree.hashes {
+		msg.Hashes = append(msg.Hashes, &tree.hashes[i])
+	}
+	for i, bit := range tree.bits {
+		if bit {
+			msg.Flags[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// verifyTxOutProof returns the txids a merkle block proves, like bitcoind:
+// an empty list if the proof does not reproduce the header's merkle root,
+// and an error if its block is not in the active chain
+func (n *Node) verifyTxOutProof(params []json.RawMessage) (interface{}, error) {
+	var proof string
+	if _, err := Param(params, 0, &proof); err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(proof)
+	if err != nil {
+		return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidParameter, Message: "proof must be hexadecimal string (not '" + proof + "')"}
+	}
+	var msg wire.MsgMerkleBlock
+	reader := bytes.NewReader(raw)
+	if err := msg.BtcDecode(reader, wire.ProtocolVersion, wire.BaseEncoding); err != nil || reader.Len() != 0 {
+		return nil, &rpc.RPCError{Code: -22, Message: "Proof decode failed"}
+	}
+
+	tree := &partialMerkleTree{leaves: make([]chainhash.Hash, msg.Transactions)}
+	for i := 0; i < len(msg.Flags)*8; i++ {
+		tree.bits = append(tree.bits, msg.Flags[i/8]&(1<<(i%8)) != 0)
+	}
+	for _, hash := range msg.Hashes {
+		tree.hashes = append(tree.hashes, *hash)
+	}
+	root, matched, ok := tree.extract()
+	if !ok || root != msg.Header.MerkleRoot {
+		return []string{}, nil
+	}
+
+	c := n.Chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	block := c.byHash[msg.Header.BlockHash().String()]
+	if block == nil {
+		return nil, &rpc.RPCError{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "Block not found in chain"}
+	}
+
+	txids := []string{}
+	if len(block.Msg.Transactions) == len(tree.leaves) {
+		for _, hash := range matched {
+			txids = append(txids, hash.String())
+		}
+	}
+	return txids, nil
+}
+
+// partialMerkleTree is bitcoind's CPartialMerkleTree: a depth-first walk of
+// the merkle tree with one bit per visited node, recording whether it is an
+// ancestor of a match, and the hashes of the subtrees not descended into
+type partialMerkleTree struct {
+	leaves  []chainhash.Hash // Only the count is used when extracting
+	matches []bool
+	bits    []bool
+	hashes  []chainhash.Hash
+}
+
+// width returns the number of nodes at a height, leaves being height 0
+func (t *partialMerkleTree) width(height uint) int {
+	return (len(t.leaves) + (1 << height) - 1) >> height
+}
+
+// height returns the height of the root
+func (t *partialMerkleTree) height() uint {
+	var height uint
+	for t.width(height) > 1 {
+		height++
+	}
+	return height
+}
+
+func (t *partialMerkleTree) hash(height uint, pos int) chainhash.Hash {
+	if height == 0 {
+		return t.leaves[pos]
+	}
+	left := t.hash(height-1, pos*2)
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		right = t.hash(height-1, pos*2+1)
+	}
+	return chainhash.DoubleHashH(append(left[:], right[:]...))
+}
+
+func (t *partialMerkleTree) build(height uint, pos int) {
+	parentOfMatch := false
+	for p := pos << height; p < (pos+1)<<height && p < len(t.leaves); p++ {
+		parentOfMatch = parentOfMatch || t.matches[p]
+	}
+	t.bits = append(t.bits, parentOfMatch)
+	if height == 0 || !parentOfMatch {
+		t.hashes = append(t.hashes, t.hash(height, pos))
+		return
+	}
+	t.build(height-1, pos*2)
+	if pos*2+1 < t.width(height-1) {
+		t.build(height-1, pos*2+1)
+	}
+}
+
+// extract rebuilds the root from bits and hashes, returning the matched
+// leaves; ok is false for a malformed tree
+func (t *partialMerkleTree) extract() (chainhash.Hash, []chainhash.Hash, bool) {
+	if len(t.leaves) == 0 || len(t.hashes) > len(t.leaves) || len(t.bits) < len(t.hashes) {
+		return chainhash.Hash{}, nil, false
+	}
+	var bitsUsed, hashesUsed int
+	var matched []chainhash.Hash
+	bad := false
+
+	var walk func(height uint, pos int) chainhash.Hash
+	walk = func(height uint, pos int) chainhash.Hash {
+		if bitsUsed >= len(t.bits) {
+			bad = true
+			return chainhash.Hash{}
+		}
+		parentOfMatch := t.bits[bitsUsed]
+		bitsUsed++
+		if height == 0 || !parentOfMatch {
+			if hashesUsed >= len(t.hashes) {
+				bad = true
+				return chainhash.Hash{}
+			}
+			hash := t.hashes[hashesUsed]
+			hashesUsed++
+			if height == 0 && parentOfMatch {
+				matched = append(matched, hash)
+			}
+			return hash
+		}
+		left := walk(height-1, pos*2)
+		right := left
+		if pos*2+1 < t.width(height-1) {
+			right = walk(height-1, pos*2+1)
+			// Identical siblings allow CVE-2012-2459 style forgeries
+			if right == left {
+				bad = true
+			}
+		}
+		return chainhash.DoubleHashH(append(left[:], right[:]...))
+	}
+
+	root := walk(t.height(), 0)
+	if bad || (bitsUsed+7)/8 != (len(t.bits)+7)/8 || hashesUsed != len(t.hashes) {
+		return chainhash.Hash{}, nil, false
+	}
+	return root, matched, true
+}
@@ -0,0 +1,271 @@
+// Package txstatus follows broadcast transactions until they confirm,
+// detecting ones evicted from the mempool before confirming
+package txstatus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"spv-backend/internal/rpc"
+)
+
+// Transaction statuses
+const (
+	StatusInMempool = "in_mempool"
+	StatusConfirmed = "confirmed"
+	// StatusDropped is a transaction that left the mempool without appearing
+	// in a block, e.g. evicted for its fee rate or replaced. It is still
+	// checked and moves back to in_mempool if it is rebroadcast.
+	StatusDropped = "dropped"
+	// StatusUnknown is a transaction that is not tracked
+	StatusUnknown = "unknown"
+)
+
+// Retention is how long a confirmed or dropped transaction stays tracked
+// after its last status change
+const Retention = 24 * time.Hour
+
+// ErrTooManyTracked is returned when the tracking limit is reached
+var ErrTooManyTracked = errors.New("too many tracked transactions")
+
+// Status is the last known state of a tracked transaction
+type Status struct {
+	TxID        string     `json:"txid"`
+	Status      string     `json:"status"`
+	TrackedAt   time.Time  `json:"tracked_at"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"` // Last background check, unset before the first
+	ChangedAt   time.Time  `json:"changed_at"`
+	BlockHash   string     `json:"block_hash,omitempty"`
+	BlockHeight int64      `json:"block_height,omitempty"`
+}
+
+// Tracker checks tracked transactions against the mempool and new blocks.
+// Blocks are walked from the tip at which tracking started, so confirmations
+// are found without -txindex. A confirmed transaction is not followed
+// through reorgs.
+type Tracker struct {
+	rpcClient  *rpc.Client
+	maxTracked int
+
+	mu      sync.Mutex
+	txs     map[string]*Status
+	scanned int64 // Height of the last block searched for tracked transactions
+}
+
+// NewTracker creates a tracker following at most maxTracked transactions
+// (0 for no limit)
+func NewTracker(rpcClient *rpc.Client, maxTracked int) *Tracker {
+	return &Tracker{
+		rpcClient:  rpcClient,
+		maxTracked: maxTracked,
+		txs:        make(map[string]*Status),
+	}
+}
+
+// Start anchors the tracker at the current tip and checks the tracked
+// transactions every interval until ctx is done
+func (t *Tracker) Start(ctx context.Context, interval time.Duration) error {
+	tip, err := t.rpcClient.GetBlockCount()
+	if err != nil {
+		return fmt.Errorf("failed to get block count: %w", err)
+	}
+	t.mu.Lock()
+	t.scanned = tip
+	t.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.poll(); err != nil {
+					log.Printf("[TxStatus] Failed to check tracked transactions: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Track starts following a transaction that was just accepted to the mempool.
+// Tracking an already tracked transaction keeps its status.
+func (t *Tracker) Track(txid string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.txs[txid]; ok {
+		return nil
+	}
+	t.expire(time.Now())
+	if t.maxTracked > 0 && len(t.txs) >= t.maxTracked {
+		return fmt.Errorf("%w, max %d", ErrTooManyTracked, t.maxTracked)
+	}
+
+	now := time.Now()
+	t.txs[txid] = &Status{TxID: txid, Status: StatusInMempool, TrackedAt: now, ChangedAt: now}
+	return nil
+}
+
+// Status returns a copy of a transaction's status, StatusUnknown if it is
+// not tracked
+func (t *Tracker) Status(txid string) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.txs[txid]
+	if !ok {
+		return Status{TxID: txid, Status: StatusUnknown}
+	}
+	return *status
+}
+
+// Count returns the number of tracked transactions
+func (t *Tracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.txs)
+}
+
+// expire forgets confirmed and dropped transactions past Retention; callers
+// hold mu
+func (t *Tracker) expire(now time.Time) {
+	for txid, status := range t.txs {
+		if status.Status != StatusInMempool && now.Sub(status.ChangedAt) > Retention {
+			delete(t.txs, txid)
+		}
+	}
+}
+
+// poll checks every unconfirmed transaction. The mempool is read before the
+// new blocks are searched, so a transaction mined between the two is found
+// in its block instead of being reported dropped.
+func (t *Tracker) poll() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.expire(now)
+
+	// Phase 1: which unconfirmed transactions are still in the mempool
+	inMempool := make(map[string]bool)
+	for txid, status := range t.txs {
+		if status.Status == StatusConfirmed {
+			continue
+		}
+		found, err := t.inMempool(txid)
+		if err != nil {
+			return err
+		}
+		inMempool[txid] = found
+	}
+
+	// Phase 2: search the blocks connected since the last poll. With nothing
+	// unconfirmed there is nothing to find, so the blocks are only skipped.
+	tip, err := t.rpcClient.GetBlockCount()
+	if err != nil {
+		return fmt.Errorf("failed to get block count: %w", err)
+	}
+	if len(inMempool) == 0 && tip > t.scanned {
+		t.scanned = tip
+	}
+	for height := t.scanned + 1; height <= tip; height++ {
+		if err := t.searchBlock(height, now); err != nil {
+			return err
+		}
+		t.scanned = height
+	}
+
+	// Phase 3: what is neither confirmed nor in the mempool was dropped
+	for txid, found := range inMempool {
+		status := t.txs[txid]
+		status.CheckedAt = &now
+		switch {
+		case status.Status == StatusConfirmed:
+		case found:
+			t.setStatus(status, StatusInMempool, now)
+		default:
+			t.setStatus(status, t.confirmedOrDropped(status), now)
+		}
+	}
+
+	return nil
+}
+
+// inMempool reports whether the node's mempool holds a transaction
+func (t *Tracker) inMempool(txid string) (bool, error) {
+	_, err := t.rpcClient.GetMempoolEntry(txid)
+	var rpcErr *rpc.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get mempool entry for %s: %w", txid, err)
+	}
+	return true, nil
+}
+
+// searchBlock marks the tracked transactions in the block at height confirmed
+func (t *Tracker) searchBlock(height int64, now time.Time) error {
+	hash, err := t.rpcClient.GetBlockHash(height)
+	if err != nil {
+		return fmt.Errorf("failed to get block hash at height %d: %w", height, err)
+	}
+	blockData, err := t.rpcClient.GetBlock(hash, 1)
+	if err != nil {
+		return fmt.Errorf("failed to get block %s: %w", hash, err)
+	}
+	var block struct {
+		Tx []string `json:"tx"`
+	}
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		return fmt.Errorf("failed to parse block %s: %w", hash, err)
+	}
+
+	for _, txid := range block.Tx {
+		if status, ok := t.txs[txid]; ok && status.Status != StatusConfirmed {
+			status.BlockHash = hash
+			status.BlockHeight = height
+			t.setStatus(status, StatusConfirmed, now)
+		}
+	}
+	return nil
+}
+
+// confirmedOrDropped asks getrawtransaction about a transaction missing from
+// the mempool and the searched blocks. With -txindex it finds transactions
+// confirmed in blocks the tracker did not search; without it, the lookup
+// fails and the transaction is dropped.
+func (t *Tracker) confirmedOrDropped(status *Status) string {
+	txData, err := t.rpcClient.GetRawTransaction(status.TxID, true)
+	if err != nil {
+		return StatusDropped
+	}
+	var tx struct {
+		BlockHash string `json:"blockhash"`
+	}
+	if err := json.Unmarshal(txData, &tx); err != nil || tx.BlockHash == "" {
+		return StatusDropped
+	}
+	status.BlockHash = tx.BlockHash
+	return StatusConfirmed
+}
+
+// setStatus records a status, updating ChangedAt if it differs
+func (t *Tracker) setStatus(status *Status, newStatus string, now time.Time) {
+	if status.Status == newStatus {
+		return
+	}
+	if newStatus == StatusDropped {
+		log.Printf("[TxStatus] Transaction %s left the mempool without confirming", status.TxID)
+	}
+	status.Status = newStatus
+	status.ChangedAt = now
+}
@@ -0,0 +1,212 @@
+package txstatus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var testParams = &chaincfg.RegressionNetParams
+
+// newTestTracker returns a tracker anchored at the tip of a chain holding
+// one spendable output, which polls only when the test calls poll
+func newTestTracker(t *testing.T, maxTracked int) (*Tracker, *rpctest.Chain, *rpctest.Node, *wire.MsgTx) {
+	t.Helper()
+	chain := rpctest.NewChain(testParams)
+	fund := chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 1), 100000))
+	chain.AddBlock(fund)
+	node := rpctest.NewNode(t, chain)
+
+	tracker := NewTracker(node.Client(), maxTracked)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := tracker.Start(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	return tracker, chain, node, fund
+}
+
+// broadcast adds a spend of fund's output to the mempool and tracks it
+func broadcast(t *testing.T, tracker *Tracker, chain *rpctest.Chain, fund *wire.MsgTx) *wire.MsgTx {
+	t.Helper()
+	tx := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)}, rpctest.PayTo(rpctest.Address(testParams, "p2tr", 2), 99000))
+	chain.AddToMempool(tx)
+	if err := tracker.Track(tx.TxHash().String()); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// pollStatus polls and returns a transaction's status
+func pollStatus(t *testing.T, tracker *Tracker, txid string) Status {
+	t.Helper()
+	if err := tracker.poll(); err != nil {
+		t.Fatal(err)
+	}
+	return tracker.Status(txid)
+}
+
+func TestDroppedTransaction(t *testing.T) {
+	tracker, chain, _, fund := newTestTracker(t, 0)
+	tx := broadcast(t, tracker, chain, fund)
+	txid := tx.TxHash().String()
+
+	status := pollStatus(t, tracker, txid)
+	if status.Status != StatusInMempool || status.CheckedAt == nil {
+		t.Fatalf("got %+v, want in_mempool after a check", status)
+	}
+
+	// Evicted, and no block carries it
+	chain.RemoveFromMempool(txid)
+	chain.AddBlock()
+	status = pollStatus(t, tracker, txid)
+	if status.Status != StatusDropped || status.BlockHash != "" {
+		t.Fatalf("got %+v, want dropped", status)
+	}
+	if !status.ChangedAt.After(status.TrackedAt) {
+		t.Errorf("changed at %v, not after tracked at %v", status.ChangedAt, status.TrackedAt)
+	}
+
+	// Still checked: a rebroadcast puts it back
+	chain.AddToMempool(tx)
+	if status := pollStatus(t, tracker, txid); status.Status != StatusInMempool {
+		t.Errorf("got %+v after the rebroadcast, want in_mempool", status)
+	}
+}
+
+func TestConfirmedTransaction(t *testing.T) {
+	tracker, chain, node, fund := newTestTracker(t, 0)
+	tx := broadcast(t, tracker, chain, fund)
+	txid := tx.TxHash().String()
+	pollStatus(t, tracker, txid)
+
+	chain.AddBlock()
+	block := chain.AddBlock(tx)
+	status := pollStatus(t, tracker, txid)
+	if status.Status != StatusConfirmed || status.BlockHash != block.Hash || status.BlockHeight != block.Height {
+		t.Fatalf("got %+v, want confirmed in %s at %d", status, block.Hash, block.Height)
+	}
+
+	// Confirmed transactions are no longer checked
+	calls := node.Calls("getmempoolentry")
+	pollStatus(t, tracker, txid)
+	if node.Calls("getmempoolentry") != calls {
+		t.Error("a confirmed transaction was checked against the mempool")
+	}
+}
+
+func TestIdlePollSkipsBlocks(t *testing.T) {
+	tracker, chain, node, fund := newTestTracker(t, 0)
+
+	// Nothing tracked, so new blocks are not fetched
+	chain.AddBlock()
+	chain.AddBlock()
+	if err := tracker.poll(); err != nil {
+		t.Fatal(err)
+	}
+	// and once everything tracked is confirmed, neither
+	tx := broadcast(t, tracker, chain, fund)
+	txid := tx.TxHash().String()
+	block := chain.AddBlock(tx)
+	if status := pollStatus(t, tracker, txid); status.Status != StatusConfirmed || status.BlockHeight != block.Height {
+		t.Fatalf("got %+v, want confirmed at %d", status, block.Height)
+	}
+	chain.AddBlock()
+	pollStatus(t, tracker, txid)
+
+	if calls := node.Calls("getblock"); calls != 1 {
+		t.Errorf("fetched %d blocks, want only the one searched for the tracked transaction", calls)
+	}
+	if calls := node.Calls("getblockhash"); calls != 1 {
+		t.Errorf("looked up %d block hashes, want 1", calls)
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.scanned != chain.Height() {
+		t.Errorf("scanned up to %d, want the tip %d", tracker.scanned, chain.Height())
+	}
+}
+
+func TestTransactionMinedDuringPollIsNotDropped(t *testing.T) {
+	tracker, chain, node, fund := newTestTracker(t, 0)
+	tx := broadcast(t, tracker, chain, fund)
+	txid := tx.TxHash().String()
+
+	// The block lands after the mempool was read, before blocks are searched
+	var once sync.Once
+	node.Wrap("getblockcount", func(next rpctest.Handler) rpctest.Handler {
+		return func(params []json.RawMessage) (interface{}, error) {
+			once.Do(func() { chain.AddBlock(tx) })
+			return next(params)
+		}
+	})
+
+	if status := pollStatus(t, tracker, txid); status.Status != StatusConfirmed {
+		t.Errorf("got %+v, want confirmed", status)
+	}
+}
+
+func TestConfirmedBeforeTrackingNeedsTxIndex(t *testing.T) {
+	for _, txIndex := range []bool{false, true} {
+		tracker, chain, node, fund := newTestTracker(t, 0)
+		node.TxIndex = txIndex
+		// Mined before the tracker's anchor, so no searched block holds it
+		tx := chain.NewTx([]wire.OutPoint{rpctest.OutPoint(fund, 0)})
+		block := chain.AddBlock(tx)
+		tracker.mu.Lock()
+		tracker.scanned = chain.Height()
+		tracker.mu.Unlock()
+		if err := tracker.Track(tx.TxHash().String()); err != nil {
+			t.Fatal(err)
+		}
+
+		status := pollStatus(t, tracker, tx.TxHash().String())
+		want := StatusDropped
+		if txIndex {
+			want = StatusConfirmed
+		}
+		if status.Status != want || (txIndex && status.BlockHash != block.Hash) {
+			t.Errorf("txindex %v: got %+v, want %s", txIndex, status, want)
+		}
+	}
+}
+
+func TestUntrackedTransactionIsUnknown(t *testing.T) {
+	tracker, _, _, _ := newTestTracker(t, 0)
+	txid := "00000000000000000000000000000000000000000000000000000000000000aa"
+	if status := tracker.Status(txid); status.Status != StatusUnknown || status.TxID != txid {
+		t.Errorf("got %+v, want unknown", status)
+	}
+}
+
+func TestTrackLimit(t *testing.T) {
+	tracker, _, _, _ := newTestTracker(t, 2)
+	for _, txid := range []string{"a1", "a2", "a1"} {
+		if err := tracker.Track(txid); err != nil {
+			t.Fatalf("track %s: %v", txid, err)
+		}
+	}
+	if err := tracker.Track("a3"); !errors.Is(err, ErrTooManyTracked) {
+		t.Fatalf("got %v, want ErrTooManyTracked", err)
+	}
+
+	// Finished transactions past retention make room
+	tracker.mu.Lock()
+	tracker.txs["a1"].Status = StatusDropped
+	tracker.txs["a1"].ChangedAt = time.Now().Add(-Retention - time.Minute)
+	tracker.mu.Unlock()
+	if err := tracker.Track("a3"); err != nil {
+		t.Fatalf("track after expiry: %v", err)
+	}
+	if tracker.Count() != 2 || tracker.Status("a1").Status != StatusUnknown {
+		t.Errorf("got %d tracked, a1 %s; want the expired a1 forgotten", tracker.Count(), tracker.Status("a1").Status)
+	}
+}
@@ -0,0 +1,472 @@
+// Package watch keeps the UTXO sets of watched addresses up to date as
+// blocks arrive, following the node's tip
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"spv-backend/internal/events"
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpc"
+)
+
+// MaxReorgDepth is how many recent blocks are kept to roll back on a reorg.
+// A deeper reorg re-anchors at the new tip without rolling back.
+const MaxReorgDepth = 100
+
+var (
+	// ErrNotFound is returned for an unknown watch ID
+	ErrNotFound = errors.New("watch not found")
+	// ErrTooManyWatches is returned when the watch limit is reached
+	ErrTooManyWatches = errors.New("too many watches")
+	// ErrAnchorLost is returned when the block a watch's initial UTXOs were
+	// scanned up to left the active chain or is no longer among the kept
+	// blocks; the caller should scan again
+	ErrAnchorLost = errors.New("scanned-to block is no longer on the watched chain")
+)
+
+// chainBlock is a block the manager has connected
+type chainBlock struct {
+	hash   string
+	height int64
+}
+
+// Anchor is the block a watch's initial UTXOs were scanned up to
+type Anchor struct {
+	Height int64
+	Hash   string
+}
+
+// appliedBlock records how a block changed a watch, so it can be undone
+type appliedBlock struct {
+	hash    string
+	added   []filter.UTXO
+	removed []filter.UTXO
+}
+
+// watch is one set of watched addresses and their UTXOs
+type watch struct {
+	addresses []string
+	tracked   map[string]bool        // scriptPubKey hex of each address
+	utxos     map[string]filter.UTXO // "txid:vout" -> UTXO
+	applied   []appliedBlock         // Recent blocks that changed the set, oldest first
+	webhook   string                 // URL each match is posted to, if set
+}
+
+// Snapshot is the current UTXO set of a watch
+type Snapshot struct {
+	ID            string        `json:"id"`
+	Addresses     []string      `json:"addresses"`
+	TipHeight     int64         `json:"tip_height"`
+	TipHash       string        `json:"tip_hash"`
+	UTXOs         []filter.UTXO `json:"utxos"`
+	TotalUTXOs    int           `json:"total_utxos"`
+	TotalSatoshis int64         `json:"total_satoshis"`
+}
+
+// BlockEvent is the payload of an events.TypeBlock event
+type BlockEvent struct {
+	Height int64  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// MatchEvent is the payload of an events.TypeMatch event: what a block
+// changed in one watch's UTXO set
+type MatchEvent struct {
+	WatchID   string        `json:"watch_id"`
+	Height    int64         `json:"height"`
+	BlockHash string        `json:"block_hash"`
+	Received  []filter.UTXO `json:"received"`
+	Spent     []filter.UTXO `json:"spent"`
+}
+
+// Manager polls the node for new blocks and applies each one to every watch
+type Manager struct {
+	rpcClient  *rpc.Client
+	filters    *filter.Service
+	useFilters bool // Skip blocks whose BIP158 filter does not match
+	maxWatches int
+	events     *events.Log // Connected blocks and matches, if set
+	notifier   *Notifier   // Delivers matches to webhooks, if set
+
+	mu      sync.Mutex
+	watches map[string]*watch
+	chain   []chainBlock // Recently connected blocks, oldest first
+}
+
+// NewManager creates a watch manager. With useFilters, blocks are only
+// fetched when their filter matches a watched address.
+func NewManager(rpcClient *rpc.Client, filters *filter.Service, useFilters bool, maxWatches int) *Manager {
+	return &Manager{
+		rpcClient:  rpcClient,
+		filters:    filters,
+		useFilters: useFilters,
+		maxWatches: maxWatches,
+		watches:    make(map[string]*watch),
+	}
+}
+
+// SetEventLog records connected blocks and the matches they make in log
+func (m *Manager) SetEventLog(log *events.Log) {
+	m.events = log
+}
+
+// SetNotifier delivers the matches of watches with a webhook through n
+func (m *Manager) SetNotifier(n *Notifier) {
+	m.notifier = n
+}
+
+// Notifier returns the webhook notifier, nil if webhooks are disabled
+func (m *Manager) Notifier() *Notifier {
+	return m.notifier
+}
+
+// Start anchors the manager at the current tip and follows the chain every
+// interval until ctx is done
+func (m *Manager) Start(ctx context.Context, interval time.Duration) error {
+	if err := m.anchor(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.poll(); err != nil {
+					log.Printf("[Watch] Failed to follow the tip: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// anchor resets the connected chain to the current tip
+func (m *Manager) anchor() error {
+	tip, err := m.rpcClient.GetBlockCount()
+	if err != nil {
+		return fmt.Errorf("failed to get block count: %w", err)
+	}
+	hash, err := m.rpcClient.GetBlockHash(tip)
+	if err != nil {
+		return fmt.Errorf("failed to get block hash at height %d: %w", tip, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chain = []chainBlock{{hash: hash, height: tip}}
+	return nil
+}
+
+// Tip returns the last block applied to the watches
+func (m *Manager) Tip() Anchor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tip := m.tip()
+	return Anchor{Height: tip.height, Hash: tip.hash}
+}
+
+// Count returns the number of watches
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.watches)
+}
+
+// tip returns the last connected block; callers hold mu
+func (m *Manager) tip() chainBlock {
+	if len(m.chain) == 0 {
+		return chainBlock{height: -1}
+	}
+	return m.chain[len(m.chain)-1]
+}
+
+// Add starts watching addresses from the initial UTXOs, those of a scan up
+// to the anchor block (e.g. Tip before the scan), and returns the new
+// watch's ID. Blocks the manager connected after the anchor while the scan
+// ran are applied to the watch before it is added, so none is missed; if
+// the anchor was reorged out or is older than the kept blocks, Add fails
+// with ErrAnchorLost. With a webhook URL, every match is also posted there.
+func (m *Manager) Add(addresses []string, initial []filter.UTXO, anchor Anchor, webhook string) (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate watch ID: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	w := &watch{
+		addresses: addresses,
+		tracked:   make(map[string]bool, len(addresses)),
+		utxos:     make(map[string]filter.UTXO, len(initial)),
+		webhook:   webhook,
+	}
+	// Outputs are matched by script: another watch may list the same
+	// address encoded differently, and a block's UTXOs only carry one
+	for _, address := range addresses {
+		script, err := m.filters.AddressToScriptPubKey(address)
+		if err != nil {
+			return "", fmt.Errorf("invalid address %s: %w", address, err)
+		}
+		w.tracked[hex.EncodeToString(script)] = true
+	}
+	for _, utxo := range initial {
+		w.utxos[outpoint(utxo)] = utxo
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maxWatches > 0 && len(m.watches) >= m.maxWatches {
+		return "", fmt.Errorf("%w, max %d", ErrTooManyWatches, m.maxWatches)
+	}
+
+	// Catch up on the blocks connected since the anchor
+	replay := -1
+	for i, block := range m.chain {
+		if block.height == anchor.Height && block.hash == anchor.Hash {
+			replay = i + 1
+			break
+		}
+	}
+	if replay < 0 {
+		return "", fmt.Errorf("%w: %s at height %d", ErrAnchorLost, anchor.Hash, anchor.Height)
+	}
+	for _, block := range m.chain[replay:] {
+		activity, err := m.filters.BlockActivityFor(block.hash, block.height, addresses, m.useFilters)
+		if err != nil {
+			return "", fmt.Errorf("failed to process block %s: %w", block.hash, err)
+		}
+		if applied := w.apply(activity); applied != nil {
+			m.notify(id, w, applied, block)
+		}
+	}
+
+	m.watches[id] = w
+	return id, nil
+}
+
+// Remove stops a watch
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.watches[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.watches, id)
+	return nil
+}
+
+// UTXOs returns a watch's UTXOs in chain order, with confirmations as of the
+// last applied block
+func (m *Manager) UTXOs(id string) (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.watches[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	tip := m.tip()
+	snapshot := &Snapshot{
+		ID:        id,
+		Addresses: w.addresses,
+		TipHeight: tip.height,
+		TipHash:   tip.hash,
+		UTXOs:     make([]filter.UTXO, 0, len(w.utxos)),
+	}
+	for _, utxo := range w.utxos {
+		utxo.Confirmations = tip.height - utxo.Height + 1
+		snapshot.UTXOs = append(snapshot.UTXOs, utxo)
+		snapshot.TotalSatoshis += utxo.Satoshis
+	}
+	snapshot.TotalUTXOs = len(snapshot.UTXOs)
+
+	sort.Slice(snapshot.UTXOs, func(i, j int) bool {
+		a, b := snapshot.UTXOs[i], snapshot.UTXOs[j]
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		if a.TxID != b.TxID {
+			return a.TxID < b.TxID
+		}
+		return a.Vout < b.Vout
+	})
+
+	return snapshot, nil
+}
+
+// poll rolls back blocks that left the active chain, then connects every
+// block up to the node's tip. The lock is held throughout, so reads see
+// watches at a consistent block.
+func (m *Manager) poll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.chain) > 0 {
+		top := m.tip()
+		hash, err := m.rpcClient.GetBlockHash(top.height)
+		var rpcErr *rpc.RPCError
+		if err != nil && !(errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidParameter) {
+			return fmt.Errorf("failed to get block hash at height %d: %w", top.height, err)
+		}
+		if err == nil && hash == top.hash {
+			break
+		}
+		m.disconnect(top)
+	}
+
+	tipHeight, err := m.rpcClient.GetBlockCount()
+	if err != nil {
+		return fmt.Errorf("failed to get block count: %w", err)
+	}
+
+	// Reorg deeper than the kept blocks: re-anchor at the new tip
+	if len(m.chain) == 0 {
+		hash, err := m.rpcClient.GetBlockHash(tipHeight)
+		if err != nil {
+			return fmt.Errorf("failed to get block hash at height %d: %w", tipHeight, err)
+		}
+		log.Printf("[Watch] Reorg deeper than %d blocks, re-anchoring at height %d", MaxReorgDepth, tipHeight)
+		m.chain = []chainBlock{{hash: hash, height: tipHeight}}
+		return nil
+	}
+
+	for height := m.tip().height + 1; height <= tipHeight; height++ {
+		hash, err := m.rpcClient.GetBlockHash(height)
+		if err != nil {
+			return fmt.Errorf("failed to get block hash at height %d: %w", height, err)
+		}
+		if err := m.connect(hash, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connect applies a block to every watch; callers hold mu
+func (m *Manager) connect(hash string, height int64) error {
+	var addresses []string
+	for _, w := range m.watches {
+		addresses = append(addresses, w.addresses...)
+	}
+
+	activity, err := m.filters.BlockActivityFor(hash, height, addresses, m.useFilters)
+	if err != nil {
+		return fmt.Errorf("failed to process block %s: %w", hash, err)
+	}
+
+	block := chainBlock{hash: hash, height: height}
+	m.events.Record(events.TypeBlock, BlockEvent{Height: height, Hash: hash})
+	for id, w := range m.watches {
+		if applied := w.apply(activity); applied != nil {
+			m.notify(id, w, applied, block)
+		}
+	}
+
+	m.chain = append(m.chain, block)
+	if len(m.chain) > MaxReorgDepth {
+		m.chain = m.chain[len(m.chain)-MaxReorgDepth:]
+	}
+	return nil
+}
+
+// notify records what a block changed in a watch and posts it to the
+// watch's webhook; callers hold mu
+func (m *Manager) notify(id string, w *watch, applied *appliedBlock, block chainBlock) {
+	match := MatchEvent{
+		WatchID:   id,
+		Height:    block.height,
+		BlockHash: block.hash,
+		Received:  nonNil(applied.added),
+		Spent:     nonNil(applied.removed),
+	}
+	m.events.Record(events.TypeMatch, match)
+	if w.webhook != "" && m.notifier != nil {
+		m.notifier.Enqueue(Delivery{URL: w.webhook, Event: match})
+	}
+}
+
+// disconnect undoes the top block on every watch; callers hold mu
+func (m *Manager) disconnect(block chainBlock) {
+	log.Printf("[Watch] Rolling back block %s at height %d", block.hash, block.height)
+	for _, w := range m.watches {
+		w.rollback(block.hash)
+	}
+	m.chain = m.chain[:len(m.chain)-1]
+}
+
+// apply adds the block's outputs paying the watch's addresses, then removes
+// the watch's UTXOs the block spends. It returns the changes, nil if none.
+func (w *watch) apply(activity *filter.BlockActivity) *appliedBlock {
+	applied := appliedBlock{hash: activity.Hash}
+	for _, utxo := range activity.UTXOs {
+		if !w.tracked[utxo.ScriptPubKey] {
+			continue
+		}
+		key := outpoint(utxo)
+		if _, exists := w.utxos[key]; exists {
+			continue
+		}
+		w.utxos[key] = utxo
+		applied.added = append(applied.added, utxo)
+	}
+	for _, spend := range activity.Spends {
+		if utxo, ok := w.utxos[spend]; ok {
+			delete(w.utxos, spend)
+			applied.removed = append(applied.removed, utxo)
+		}
+	}
+
+	if len(applied.added) == 0 && len(applied.removed) == 0 {
+		return nil
+	}
+	w.applied = append(w.applied, applied)
+	if len(w.applied) > MaxReorgDepth {
+		w.applied = w.applied[len(w.applied)-MaxReorgDepth:]
+	}
+	return &applied
+}
+
+// rollback undoes a block if it changed the watch: spent UTXOs are restored
+// first, then its outputs removed, so outputs created and spent in the same
+// block stay gone
+func (w *watch) rollback(hash string) {
+	if len(w.applied) == 0 || w.applied[len(w.applied)-1].hash != hash {
+		return
+	}
+	applied := w.applied[len(w.applied)-1]
+	w.applied = w.applied[:len(w.applied)-1]
+
+	for _, utxo := range applied.removed {
+		w.utxos[outpoint(utxo)] = utxo
+	}
+	for _, utxo := range applied.added {
+		delete(w.utxos, outpoint(utxo))
+	}
+}
+
+// outpoint returns the "txid:vout" key of a UTXO
+func outpoint(utxo filter.UTXO) string {
+	return fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)
+}
+
+// nonNil returns utxos, or an empty list in place of nil
+func nonNil(utxos []filter.UTXO) []filter.UTXO {
+	if utxos == nil {
+		return []filter.UTXO{}
+	}
+	return utxos
+}
@@ -0,0 +1,143 @@
+package watch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"spv-backend/internal/filter"
+	"spv-backend/internal/rpctest"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var testParams = &chaincfg.RegressionNetParams
+
+// newTestManager returns a manager anchored at the tip of a chain of a few
+// empty blocks
+func newTestManager(t *testing.T, useFilters bool) (*Manager, *rpctest.Chain) {
+	t.Helper()
+	chain := rpctest.NewChain(testParams)
+	for i := 0; i < 3; i++ {
+		chain.AddBlock()
+	}
+	client := rpctest.NewNode(t, chain).Client()
+	m := NewManager(client, filter.NewService(client, testParams), useFilters, 0)
+	if err := m.anchor(); err != nil {
+		t.Fatal(err)
+	}
+	return m, chain
+}
+
+// snapshotOf returns a watch's UTXOs, failing the test on error
+func snapshotOf(t *testing.T, m *Manager, id string) *Snapshot {
+	t.Helper()
+	snapshot, err := m.UTXOs(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestNewBlockUpdatesWatch(t *testing.T) {
+	for _, useFilters := range []bool{false, true} {
+		m, chain := newTestManager(t, useFilters)
+		address := rpctest.Address(testParams, "p2wpkh", 1)
+		id, err := m.Add([]string{address.EncodeAddress()}, nil, m.Tip(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		funding := chain.NewTx(nil, rpctest.PayTo(address, 7000), rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 2), 1000))
+		block := chain.AddBlock(funding)
+		if err := m.poll(); err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot := snapshotOf(t, m, id)
+		if snapshot.TipHash != block.Hash || snapshot.TotalUTXOs != 1 || snapshot.TotalSatoshis != 7000 {
+			t.Fatalf("filters %v: got %+v after the funding block", useFilters, snapshot)
+		}
+		if utxo := snapshot.UTXOs[0]; utxo.TxID != funding.TxHash().String() || utxo.Vout != 0 || utxo.Height != block.Height {
+			t.Fatalf("filters %v: got UTXO %+v", useFilters, utxo)
+		}
+
+		chain.AddBlock(chain.NewTx([]wire.OutPoint{rpctest.OutPoint(funding, 0)}, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 3), 6000)))
+		if err := m.poll(); err != nil {
+			t.Fatal(err)
+		}
+		if snapshot := snapshotOf(t, m, id); snapshot.TotalUTXOs != 0 {
+			t.Fatalf("filters %v: spent UTXO still watched: %+v", useFilters, snapshot.UTXOs)
+		}
+	}
+}
+
+func TestAddReplaysBlocksConnectedDuringScan(t *testing.T) {
+	m, chain := newTestManager(t, true)
+	address := rpctest.Address(testParams, "p2tr", 1)
+
+	// The initial scan reaches the anchor, then a block paying the address
+	// is connected before the watch is added
+	anchor := m.Tip()
+	funding := chain.NewTx(nil, rpctest.PayTo(address, 9000))
+	chain.AddBlock(funding)
+	if err := m.poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.Add([]string{address.EncodeAddress()}, []filter.UTXO{}, anchor, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := snapshotOf(t, m, id)
+	if snapshot.TotalUTXOs != 1 || snapshot.UTXOs[0].TxID != funding.TxHash().String() {
+		t.Fatalf("block connected during the scan was lost: %+v", snapshot)
+	}
+}
+
+func TestAddRejectsReorgedAnchor(t *testing.T) {
+	m, chain := newTestManager(t, false)
+	chain.AddBlock()
+	if err := m.poll(); err != nil {
+		t.Fatal(err)
+	}
+	anchor := m.Tip()
+
+	chain.Disconnect()
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(rpctest.Address(testParams, "p2wpkh", 4), 1)))
+	if err := m.poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := m.Add([]string{rpctest.Address(testParams, "p2wpkh", 1).EncodeAddress()}, nil, anchor, "")
+	if !errors.Is(err, ErrAnchorLost) {
+		t.Fatalf("got %v, want ErrAnchorLost", err)
+	}
+	if m.Count() != 0 {
+		t.Fatalf("rejected watch was added")
+	}
+}
+
+func TestWatchesMatchAddressesByScript(t *testing.T) {
+	m, chain := newTestManager(t, true)
+	address := rpctest.Address(testParams, "p2wpkh", 1)
+	lower, err := m.Add([]string{address.EncodeAddress()}, nil, m.Tip(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper, err := m.Add([]string{strings.ToUpper(address.EncodeAddress())}, nil, m.Tip(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain.AddBlock(chain.NewTx(nil, rpctest.PayTo(address, 4000)))
+	if err := m.poll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{lower, upper} {
+		if snapshot := snapshotOf(t, m, id); snapshot.TotalUTXOs != 1 || snapshot.TotalSatoshis != 4000 {
+			t.Errorf("watch %s: got %+v, want the payment", id, snapshot)
+		}
+	}
+}
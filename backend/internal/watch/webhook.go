@@ -0,0 +1,261 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// webhookTimeout bounds one delivery attempt
+const webhookTimeout = 10 * time.Second
+
+// ErrPrivateDestination is returned for webhooks resolving to loopback,
+// link-local, private or otherwise internal addresses
+var ErrPrivateDestination = errors.New("webhook destination is not a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal
+// like the private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is an address a webhook may be sent to
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// CheckWebhookURL validates a webhook URL: http or https, with a host whose
+// addresses are all public unless allowPrivate is set
+func CheckWebhookURL(ctx context.Context, rawURL string, allowPrivate bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("webhook_url must be an http or https URL")
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host %s: %w", u.Hostname(), err)
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateDestination, u.Hostname(), ip)
+		}
+	}
+	return nil
+}
+
+// publicOnlyControl refuses connections to internal addresses. It runs on
+// the address being dialed, after DNS resolution, so a host re-pointed at
+// an internal address after CheckWebhookURL is still refused.
+func publicOnlyControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateDestination, host)
+	}
+	return nil
+}
+
+// Delivery is a match queued for a watch's webhook
+type Delivery struct {
+	URL   string     `json:"url"`
+	Event MatchEvent `json:"event"`
+}
+
+// DeadLetter is a delivery that failed on every attempt
+type DeadLetter struct {
+	Delivery
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// Notifier posts matches to webhooks from a fixed pool of workers, so block
+// processing never waits on an endpoint. A failed delivery is queued again
+// after an exponential backoff kept on a timer, not in a worker, so an
+// endpoint that keeps failing does not hold up others' deliveries; a slow
+// one holds a worker for at most webhookTimeout per attempt. Deliveries
+// that run out of retries are kept in a bounded dead-letter log. Only
+// public destinations are posted to unless SetAllowPrivate is used. It is
+// safe for concurrent use.
+type Notifier struct {
+	client         *http.Client
+	workers        int
+	maxRetries     int           // Retries after the first attempt
+	backoff        time.Duration // Before the first retry, doubled for each one after
+	maxDeadLetters int
+	allowPrivate   bool
+
+	queue chan attempt
+
+	mu          sync.Mutex
+	deadLetters []DeadLetter // Oldest first
+}
+
+// attempt is a queued delivery and how many times it was tried
+type attempt struct {
+	Delivery
+	tries int
+}
+
+// NewNotifier creates a notifier with workers delivering from a queue of
+// queueSize. Deliveries that find the queue full are dead-lettered at once.
+func NewNotifier(workers, queueSize, maxRetries int, backoff time.Duration, maxDeadLetters int) *Notifier {
+	n := &Notifier{
+		workers:        workers,
+		maxRetries:     maxRetries,
+		backoff:        backoff,
+		maxDeadLetters: maxDeadLetters,
+		queue:          make(chan attempt, queueSize),
+	}
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: n.control}
+	n.client = &http.Client{
+		Timeout: webhookTimeout,
+		// No proxy: the dialed address must be the destination's
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// Redirects are not followed, so a public endpoint cannot bounce
+		// deliveries to an internal one
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return n
+}
+
+// SetAllowPrivate lets webhooks reach loopback, link-local and private
+// addresses, e.g. a receiver on the same host. Off by default so watch
+// creators cannot make the server post to internal services.
+func (n *Notifier) SetAllowPrivate(allow bool) {
+	n.allowPrivate = allow
+}
+
+// AllowPrivate reports whether webhooks may reach internal addresses
+func (n *Notifier) AllowPrivate() bool {
+	return n.allowPrivate
+}
+
+// control vets each address the client dials
+func (n *Notifier) control(network, address string, conn syscall.RawConn) error {
+	if n.allowPrivate {
+		return nil
+	}
+	return publicOnlyControl(network, address, conn)
+}
+
+// Start runs the workers until ctx is done
+func (n *Notifier) Start(ctx context.Context) {
+	for i := 0; i < n.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case next := <-n.queue:
+					n.deliver(ctx, next)
+				}
+			}
+		}()
+	}
+}
+
+// Enqueue queues a delivery without blocking
+func (n *Notifier) Enqueue(delivery Delivery) {
+	n.enqueue(attempt{Delivery: delivery})
+}
+
+// enqueue queues an attempt, dead-lettering it if the queue is full
+func (n *Notifier) enqueue(next attempt) {
+	select {
+	case n.queue <- next:
+	default:
+		log.Printf("[Webhook] Queue full, dropping delivery to %s", next.URL)
+		n.deadLetter(next.Delivery, next.tries, fmt.Errorf("delivery queue full"))
+	}
+}
+
+// DeadLetters returns the deliveries that failed permanently, oldest first
+func (n *Notifier) DeadLetters() []DeadLetter {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	deadLetters := make([]DeadLetter, len(n.deadLetters))
+	copy(deadLetters, n.deadLetters)
+	return deadLetters
+}
+
+// deliver makes one attempt at a delivery. A failure is queued again once
+// its backoff has passed, or dead-lettered when out of retries; an internal
+// destination is dead-lettered at once, as retrying cannot help.
+func (n *Notifier) deliver(ctx context.Context, next attempt) {
+	err := n.post(ctx, next.Delivery)
+	if err == nil {
+		return
+	}
+	next.tries++
+	if next.tries > n.maxRetries || errors.Is(err, ErrPrivateDestination) {
+		log.Printf("[Webhook] Giving up on delivery to %s after %d attempts: %v", next.URL, next.tries, err)
+		n.deadLetter(next.Delivery, next.tries, err)
+		return
+	}
+
+	backoff := n.backoff << (next.tries - 1)
+	time.AfterFunc(backoff, func() {
+		if ctx.Err() == nil {
+			n.enqueue(next)
+		}
+	})
+}
+
+// post makes one delivery attempt; any 2xx answer counts as delivered
+func (n *Notifier) post(ctx context.Context, delivery Delivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // Drain so the connection is reused
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deadLetter records a failed delivery, dropping the oldest beyond
+// maxDeadLetters
+func (n *Notifier) deadLetter(delivery Delivery, attempts int, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.deadLetters = append(n.deadLetters, DeadLetter{
+		Delivery:  delivery,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now(),
+	})
+	if len(n.deadLetters) > n.maxDeadLetters {
+		n.deadLetters = n.deadLetters[len(n.deadLetters)-n.maxDeadLetters:]
+	}
+}
@@ -0,0 +1,105 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// startNotifier runs a notifier until the test ends
+func startNotifier(t *testing.T, n *Notifier) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	n.Start(ctx)
+}
+
+func TestFailingWebhookIsDeadLettered(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	n := NewNotifier(1, 10, 2, time.Millisecond, 10)
+	n.SetAllowPrivate(true)
+	startNotifier(t, n)
+
+	n.Enqueue(Delivery{URL: server.URL, Event: MatchEvent{WatchID: "w1", Height: 7}})
+	waitFor(t, "the dead letter", func() bool { return len(n.DeadLetters()) == 1 })
+
+	deadLetter := n.DeadLetters()[0]
+	if deadLetter.Attempts != 3 || hits.Load() != 3 {
+		t.Fatalf("got %d attempts recorded and %d requests, want 3", deadLetter.Attempts, hits.Load())
+	}
+	if deadLetter.URL != server.URL || deadLetter.Event.WatchID != "w1" || deadLetter.LastError != "webhook returned status 502" {
+		t.Fatalf("got dead letter %+v", deadLetter)
+	}
+}
+
+func TestRetryBackoffDoesNotHoldWorkers(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	var delivered atomic.Bool
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered.Store(true)
+	}))
+	defer healthy.Close()
+
+	// One worker and a long backoff: the healthy endpoint is only reached
+	// in time if the failing delivery waits off the worker
+	n := NewNotifier(1, 10, 3, time.Hour, 10)
+	n.SetAllowPrivate(true)
+	startNotifier(t, n)
+
+	n.Enqueue(Delivery{URL: failing.URL})
+	n.Enqueue(Delivery{URL: healthy.URL})
+	waitFor(t, "the healthy delivery", delivered.Load)
+}
+
+func TestPrivateWebhookIsRefused(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	for _, url := range []string{server.URL, "http://localhost/hook", "http://169.254.169.254/latest", "http://10.1.2.3/", "http://[::1]:8080/"} {
+		if err := CheckWebhookURL(context.Background(), url, false); !errors.Is(err, ErrPrivateDestination) {
+			t.Errorf("%s: got %v, want ErrPrivateDestination", url, err)
+		}
+	}
+	if err := CheckWebhookURL(context.Background(), "ftp://example.com/", true); err == nil {
+		t.Errorf("non-http scheme accepted")
+	}
+	if err := CheckWebhookURL(context.Background(), "http://93.184.215.14/hook", false); err != nil {
+		t.Errorf("public address refused: %v", err)
+	}
+
+	// Checked again when connecting, e.g. after a DNS change
+	n := NewNotifier(1, 10, 5, time.Millisecond, 10)
+	startNotifier(t, n)
+	n.Enqueue(Delivery{URL: server.URL})
+	waitFor(t, "the dead letter", func() bool { return len(n.DeadLetters()) == 1 })
+	if hits.Load() != 0 || n.DeadLetters()[0].Attempts != 1 {
+		t.Fatalf("private destination reached: %d requests, %+v", hits.Load(), n.DeadLetters()[0])
+	}
+}